@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+
+	"github.com/cdriehuys/secret-santa/internal/application"
+	"github.com/cdriehuys/secret-santa/internal/templating"
+	"github.com/cdriehuys/secret-santa/ui"
+)
+
+// runCheckTemplates renders every page and email template with representative data. It checks the
+// live templates if their paths were given, and the embedded templates otherwise.
+func runCheckTemplates(ctx context.Context, logger *slog.Logger, args []string) error {
+	flags := newFlagSet("check-templates", "[flags]", "Renders every page and email template with representative data to catch errors.")
+
+	var liveTemplatePath, liveEmailTemplatePath string
+	flags.StringVar(&liveTemplatePath, "live-templates", "", "check the UI templates in this path instead of the embedded templates")
+	flags.StringVar(&liveEmailTemplatePath, "live-email-templates", "", "check the email templates in this path instead of the embedded templates")
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	pageFS, err := fs.Sub(ui.FS, "templates")
+	if err != nil {
+		return err
+	}
+
+	if liveTemplatePath != "" {
+		pageFS = os.DirFS(liveTemplatePath)
+	}
+
+	emailFS, err := fs.Sub(ui.EmailFS, "emails")
+	if err != nil {
+		return err
+	}
+
+	if liveEmailTemplatePath != "" {
+		emailFS = os.DirFS(liveEmailTemplatePath)
+	}
+
+	pages, err := templating.NewTemplateCache(logger, pageFS, templating.DefaultFuncs())
+	if err != nil {
+		return err
+	}
+
+	emails, err := templating.NewEmailTemplateCache(logger, emailFS)
+	if err != nil {
+		return err
+	}
+
+	if err := application.CheckTemplates(pages, emails); err != nil {
+		return fmt.Errorf("templates failed to render:\n%v", err)
+	}
+
+	logger.Info("All templates rendered successfully.")
+
+	return nil
+}
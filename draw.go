@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/cdriehuys/secret-santa/internal/application"
+	"github.com/cdriehuys/secret-santa/internal/config"
+	"github.com/cdriehuys/secret-santa/internal/email"
+	"github.com/cdriehuys/secret-santa/internal/models"
+	"github.com/cdriehuys/secret-santa/internal/pairings"
+	"github.com/cdriehuys/secret-santa/internal/roster"
+	"gopkg.in/yaml.v3"
+)
+
+// drawFile is the layout of JSON and YAML participant files. It matches the body of the pairings
+// API.
+type drawFile struct {
+	Participants []application.APIParticipant `json:"participants" yaml:"participants"`
+}
+
+// runDraw draws pairings for the participants in a file and prints them, or emails each giver
+// their recipient.
+func runDraw(ctx context.Context, logger *slog.Logger, args []string) error {
+	flags := newFlagSet("draw", "[flags] FILE", "Draws pairings for the participants in FILE, which may be CSV, JSON, or YAML. Use - to read from stdin.\n\nCSV files have a header row naming the name, email, household, and exclusions columns. JSON and\nYAML files contain a list of participants with the same fields:\n\n  participants:\n    - name: Ross\n      email: ross@example.com\n      exclusions: [Monica]")
+
+	var (
+		format                string
+		output                string
+		seed                  int64
+		sendEmail             bool
+		locale                string
+		liveEmailTemplatePath string
+
+		maxParticipants int
+		maxExclusions   int
+
+		mail = config.Default().Email
+	)
+
+	flags.StringVar(&format, "format", "", "format of FILE: csv, json, or yaml; detected from the file extension by default, and csv for stdin")
+	flags.StringVar(&output, "output", "text", "format of the printed pairings: text, csv, or json")
+	flags.Int64Var(&seed, "seed", 0, "seed that makes the draw repeatable; a random seed is used and logged by default")
+	flags.BoolVar(&sendEmail, "email", false, "email each giver their recipient instead of printing the pairings")
+	flags.StringVar(&locale, "locale", application.DefaultLocale, "language of the emails: "+strings.Join(application.SupportedLocales, ", "))
+	flags.StringVar(&liveEmailTemplatePath, "live-email-templates", "", "load email templates from this path instead of using the embedded templates")
+	flags.IntVar(&maxParticipants, "max-participants", roster.DefaultLimits.MaxParticipants, "maximum number of participants in a draw")
+	flags.IntVar(&maxExclusions, "max-exclusions", roster.DefaultLimits.MaxExclusions, "maximum number of people each participant can exclude")
+	mail.RegisterFlags(flags)
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		fmt.Fprintln(flags.Output(), "Expected exactly one participants file.")
+		flags.Usage()
+		return usageError{errors.New("expected exactly one participants file")}
+	}
+
+	path := flags.Arg(0)
+	if format == "" {
+		format = formatFromPath(path)
+	}
+
+	if !slices.Contains([]string{"csv", "json", "yaml"}, format) {
+		return fmt.Errorf("unknown input format %q: expected csv, json, or yaml", format)
+	}
+
+	if !slices.Contains([]string{"text", "csv", "json"}, output) {
+		return fmt.Errorf("unknown output format %q: expected text, csv, or json", output)
+	}
+
+	if !slices.Contains(application.SupportedLocales, locale) {
+		return fmt.Errorf("unsupported locale %q: expected one of %s", locale, strings.Join(application.SupportedLocales, ", "))
+	}
+
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+
+		defer file.Close()
+		in = file
+	}
+
+	limits := roster.Limits{MaxParticipants: maxParticipants, MaxExclusions: maxExclusions}
+	participants, restrictions, err := readRoster(in, format, limits)
+	if err != nil {
+		return err
+	}
+
+	if !isFlagSet(flags, "seed") {
+		seed = rand.Int64()
+	}
+
+	pairs, err := generatePairings(ctx, restrictions, application.PairingOptions{Seed: &seed})
+	switch {
+	case errors.Is(err, pairings.ErrTooFewNodes):
+		return errors.New("at least two participants are required")
+	case errors.Is(err, pairings.ErrNoPath):
+		return errors.New("no pairings satisfy the exclusions; try removing some exclusions")
+	case errors.Is(err, pairings.ErrSearchExhausted):
+		return errors.New("pairings couldn't be found in time; try another seed or remove some exclusions")
+	case err != nil:
+		return fmt.Errorf("generating pairings: %v", err)
+	}
+
+	// The seed is logged rather than printed with the pairings so the output can be piped
+	// elsewhere.
+	logger.Info("Drew pairings.", "participants", len(pairs), "seed", seed)
+
+	if !sendEmail {
+		return writePairings(os.Stdout, output, pairs, seed)
+	}
+
+	templates, err := emailTemplates(logger, liveEmailTemplatePath)
+	if err != nil {
+		return err
+	}
+
+	mail.SMTP.Password = os.Getenv(config.SMTPPasswordEnv)
+	if err := mail.Validate(); err != nil {
+		return fmt.Errorf("invalid email settings:\n%v", err)
+	}
+
+	emailer, _, err := newMailer(logger, mail)
+	if err != nil {
+		return err
+	}
+
+	notifier := application.NewAssignmentNotifier(templates, mail.Sender)
+
+	return emailAssignments(application.WithLocale(ctx, locale), logger, notifier, emailer, participants, pairs)
+}
+
+// formatFromPath guesses the format of a participants file from its extension.
+func formatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return "json"
+	case ".yaml", ".yml":
+		return "yaml"
+	default:
+		return "csv"
+	}
+}
+
+// readRoster reads and validates the participants in a file, returning them along with the
+// restrictions used to draw pairings. Every problem with the file is reported in the error.
+func readRoster(r io.Reader, format string, limits roster.Limits) ([]roster.Participant, application.GiftRestrictions, error) {
+	if format == "csv" {
+		return readCSVRoster(r, limits)
+	}
+
+	var file drawFile
+	switch format {
+	case "json":
+		decoder := json.NewDecoder(r)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&file); err != nil {
+			return nil, nil, fmt.Errorf("reading participants: %v", err)
+		}
+	case "yaml":
+		decoder := yaml.NewDecoder(r)
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+			return nil, nil, fmt.Errorf("reading participants: %v", err)
+		}
+	default:
+		return nil, nil, fmt.Errorf("unknown input format %q", format)
+	}
+
+	participants := make([]roster.Participant, len(file.Participants))
+	for i, p := range file.Participants {
+		participants[i] = roster.Participant(p)
+	}
+
+	restrictions, errs := roster.Validate(participants, limits)
+	if len(errs) > 0 {
+		messages := make([]string, len(errs))
+		for i, err := range errs {
+			messages[i] = formatRosterError(err)
+		}
+
+		return nil, nil, fmt.Errorf("invalid participants:\n%s", strings.Join(messages, "\n"))
+	}
+
+	return participants, restrictions, nil
+}
+
+func readCSVRoster(r io.Reader, limits roster.Limits) ([]roster.Participant, application.GiftRestrictions, error) {
+	imported, errs := roster.ParseCSV(r)
+	if len(errs) > 0 {
+		return nil, nil, importErrors(errs)
+	}
+
+	restrictions, errs := roster.ValidateImported(imported, limits)
+	if len(errs) > 0 {
+		return nil, nil, importErrors(errs)
+	}
+
+	participants := make([]roster.Participant, len(imported))
+	for i, p := range imported {
+		participants[i] = p.Participant
+	}
+
+	return participants, restrictions, nil
+}
+
+func importErrors(errs []roster.ImportError) error {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.String()
+	}
+
+	return fmt.Errorf("invalid participants:\n%s", strings.Join(messages, "\n"))
+}
+
+// formatRosterError describes a validation error in the style of a CSV import error, numbering
+// participants from 1.
+func formatRosterError(err roster.Error) string {
+	if err.Participant < 0 {
+		return err.Message.String()
+	}
+
+	return fmt.Sprintf("Participant %d, %s: %s", err.Participant+1, err.Field, err.Message)
+}
+
+// writePairings prints the pairings in the given output format. The JSON output matches the
+// response of the pairings API.
+func writePairings(w io.Writer, output string, pairs []pairings.Pairing, seed int64) error {
+	switch output {
+	case "text":
+		var buf bytes.Buffer
+		for _, pair := range pairs {
+			fmt.Fprintf(&buf, "%s -> %s\n", pair.From, pair.To)
+		}
+
+		_, err := buf.WriteTo(w)
+		return err
+	case "csv":
+		return pairings.WriteCSV(w, pairs)
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(application.APIPairingsResponse{Pairings: application.NewAPIPairings(pairs), Seed: seed})
+	default:
+		return fmt.Errorf("unknown output format %q", output)
+	}
+}
+
+// assignmentNotifier composes the email telling a giver who their recipient is.
+type assignmentNotifier interface {
+	Assignment(ctx context.Context, address string, pairing pairings.Pairing) (email.Message, error)
+}
+
+// emailAssignments emails each giver their recipient. Every giver must have an email address, and
+// all the emails are composed before any are sent so a mistake doesn't leave the draw half
+// announced.
+func emailAssignments(ctx context.Context, logger *slog.Logger, notifier assignmentNotifier, emailer models.Emailer, participants []roster.Participant, pairs []pairings.Pairing) error {
+	addresses := make(map[string]string, len(participants))
+	for _, p := range participants {
+		addresses[strings.TrimSpace(p.Name)] = strings.TrimSpace(p.Email)
+	}
+
+	var missing []string
+	for _, pair := range pairs {
+		if addresses[pair.From] == "" {
+			missing = append(missing, pair.From)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("cannot email the pairings because these participants have no email address: %s", strings.Join(missing, ", "))
+	}
+
+	messages := make([]email.Message, len(pairs))
+	for i, pair := range pairs {
+		msg, err := notifier.Assignment(ctx, addresses[pair.From], pair)
+		if err != nil {
+			return err
+		}
+
+		messages[i] = msg
+	}
+
+	for i, msg := range messages {
+		if err := emailer.Send(ctx, msg); err != nil {
+			return fmt.Errorf("emailing %s, after %d of %d emails were sent: %v", msg.To, i, len(messages), err)
+		}
+	}
+
+	logger.Info("Emailed pairings.", "emails", len(messages))
+
+	return nil
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cdriehuys/secret-santa/internal/application"
+	"github.com/cdriehuys/secret-santa/internal/email"
+	"github.com/cdriehuys/secret-santa/internal/pairings"
+	"github.com/cdriehuys/secret-santa/internal/roster"
+)
+
+var testLimits = roster.Limits{MaxParticipants: 10, MaxExclusions: 2}
+
+func TestReadRoster(t *testing.T) {
+	testCases := []struct {
+		name             string
+		format           string
+		input            string
+		wantRestrictions application.GiftRestrictions
+		wantErr          string
+	}{
+		{
+			name:   "csv",
+			format: "csv",
+			input:  "name,email,exclusions\nRoss,ross@example.com,Monica\nMonica,,\nJoey,,\n",
+			wantRestrictions: application.GiftRestrictions{
+				"Ross":   {"Monica"},
+				"Monica": nil,
+				"Joey":   nil,
+			},
+		},
+		{
+			name:    "csv errors",
+			format:  "csv",
+			input:   "name,exclusions\nRoss,Rachel\n",
+			wantErr: `Row 2, exclusions: "Rachel" is not a participant.`,
+		},
+		{
+			name:   "json",
+			format: "json",
+			input:  `{"participants": [{"name": "Ross", "household": "Geller"}, {"name": "Monica", "household": "Geller"}, {"name": "Joey"}, {"name": "Chandler"}]}`,
+			wantRestrictions: application.GiftRestrictions{
+				"Ross":     {"Monica"},
+				"Monica":   {"Ross"},
+				"Joey":     nil,
+				"Chandler": nil,
+			},
+		},
+		{
+			name:    "json unknown field",
+			format:  "json",
+			input:   `{"participants": [{"name": "Ross", "excludes": ["Monica"]}]}`,
+			wantErr: `unknown field "excludes"`,
+		},
+		{
+			name:   "yaml",
+			format: "yaml",
+			input:  "participants:\n  - name: Ross\n    exclusions: [Monica]\n  - name: Monica\n  - name: Joey\n",
+			wantRestrictions: application.GiftRestrictions{
+				"Ross":   {"Monica"},
+				"Monica": nil,
+				"Joey":   nil,
+			},
+		},
+		{
+			name:    "yaml errors",
+			format:  "yaml",
+			input:   "participants:\n  - name: Ross\n  - email: monica@example.com\n",
+			wantErr: "Participant 2, name: Name is required.",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, restrictions, err := readRoster(strings.NewReader(tt.input), tt.format, testLimits)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if !maps.EqualFunc(restrictions, tt.wantRestrictions, slices.Equal) {
+				t.Errorf("Expected restrictions %v, got %v", tt.wantRestrictions, restrictions)
+			}
+		})
+	}
+}
+
+func TestFormatFromPath(t *testing.T) {
+	testCases := map[string]string{
+		"people.csv":  "csv",
+		"people.JSON": "json",
+		"people.yaml": "yaml",
+		"people.yml":  "yaml",
+		"-":           "csv",
+	}
+
+	for path, want := range testCases {
+		if got := formatFromPath(path); got != want {
+			t.Errorf("Expected %q to be read as %s, got %s", path, want, got)
+		}
+	}
+}
+
+func TestWritePairings(t *testing.T) {
+	pairs := []pairings.Pairing{{From: "Ross", To: "Monica"}, {From: "Monica", To: "Ross"}}
+
+	testCases := []struct {
+		output string
+		pairs  []pairings.Pairing
+		want   string
+	}{
+		{
+			output: "text",
+			want:   "Ross -> Monica\nMonica -> Ross\n",
+		},
+		{
+			output: "csv",
+			want:   "giver,recipient\nRoss,Monica\nMonica,Ross\n",
+		},
+		{
+			// Names that spreadsheet apps would run as formulas are escaped.
+			output: "csv",
+			pairs:  []pairings.Pairing{{From: "=SUM(1+1)", To: "Monica"}, {From: "Monica", To: "=SUM(1+1)"}},
+			want:   "giver,recipient\n'=SUM(1+1),Monica\nMonica,'=SUM(1+1)\n",
+		},
+		{
+			output: "json",
+			want:   "{\n  \"pairings\": [\n    {\n      \"from\": \"Ross\",\n      \"to\": \"Monica\"\n    },\n    {\n      \"from\": \"Monica\",\n      \"to\": \"Ross\"\n    }\n  ],\n  \"seed\": 42\n}\n",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.output, func(t *testing.T) {
+			if tt.pairs == nil {
+				tt.pairs = pairs
+			}
+
+			var buf bytes.Buffer
+			if err := writePairings(&buf, tt.output, tt.pairs, 42); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if buf.String() != tt.want {
+				t.Errorf("Expected output:\n%s\nGot:\n%s", tt.want, buf.String())
+			}
+		})
+	}
+}
+
+type fakeNotifier struct{}
+
+func (fakeNotifier) Assignment(_ context.Context, address string, pairing pairings.Pairing) (email.Message, error) {
+	return email.Message{To: address, Subject: pairing.To}, nil
+}
+
+type fakeEmailer struct {
+	sent []email.Message
+}
+
+func (e *fakeEmailer) Send(_ context.Context, msg email.Message) error {
+	e.sent = append(e.sent, msg)
+	return nil
+}
+
+func TestEmailAssignments(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	pairs := []pairings.Pairing{{From: "Ross", To: "Monica"}, {From: "Monica", To: "Ross"}}
+
+	t.Run("sends each giver their recipient", func(t *testing.T) {
+		participants := []roster.Participant{
+			{Name: "Ross", Email: "ross@example.com"},
+			{Name: " Monica ", Email: " monica@example.com "},
+		}
+
+		var emailer fakeEmailer
+		if err := emailAssignments(context.Background(), logger, fakeNotifier{}, &emailer, participants, pairs); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		want := []email.Message{
+			{To: "ross@example.com", Subject: "Monica"},
+			{To: "monica@example.com", Subject: "Ross"},
+		}
+		if !slices.EqualFunc(emailer.sent, want, func(a, b email.Message) bool { return a.To == b.To && a.Subject == b.Subject }) {
+			t.Errorf("Expected emails %v, got %v", want, emailer.sent)
+		}
+	})
+
+	t.Run("sends nothing if an address is missing", func(t *testing.T) {
+		participants := []roster.Participant{
+			{Name: "Ross", Email: "ross@example.com"},
+			{Name: "Monica"},
+		}
+
+		var emailer fakeEmailer
+		err := emailAssignments(context.Background(), logger, fakeNotifier{}, &emailer, participants, pairs)
+		if err == nil || !strings.Contains(err.Error(), "Monica") {
+			t.Errorf("Expected error naming Monica, got %v", err)
+		}
+
+		if len(emailer.sent) != 0 {
+			t.Errorf("Expected no emails to be sent, got %d", len(emailer.sent))
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+
+	"github.com/cdriehuys/secret-santa/internal/application"
+	"github.com/cdriehuys/secret-santa/internal/config"
+	"github.com/cdriehuys/secret-santa/internal/email"
+	"github.com/cdriehuys/secret-santa/internal/models"
+	"github.com/cdriehuys/secret-santa/internal/templating"
+	"github.com/cdriehuys/secret-santa/ui"
+)
+
+// newMailer creates the mailer chosen by the settings, which must have been validated. The Maildir
+// is returned as well, if one was chosen, so its messages can be browsed.
+func newMailer(logger *slog.Logger, settings config.Email) (models.Emailer, *email.MaildirMailer, error) {
+	switch settings.Backend {
+	case config.BackendMaildir:
+		maildir, err := email.NewMaildirMailer(logger, settings.Maildir)
+		if err != nil {
+			return nil, nil, fmt.Errorf("opening Maildir: %v", err)
+		}
+
+		return maildir, maildir, nil
+	case config.BackendSMTP:
+		smtpMailer, err := email.NewSMTPMailer(email.SMTPConfig{
+			Host:     settings.SMTP.Host,
+			Port:     settings.SMTP.Port,
+			Username: settings.SMTP.Username,
+			Password: settings.SMTP.Password,
+			Auth:     email.AuthMechanism(settings.SMTP.Auth),
+			TLS:      email.TLSMode(settings.SMTP.TLS),
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("configuring SMTP: %v", err)
+		}
+
+		return smtpMailer, nil, nil
+	default:
+		return email.NewConsoleMailer(os.Stdout), nil, nil
+	}
+}
+
+// emailTemplates loads the email templates from livePath if it's given, and uses the embedded
+// templates otherwise.
+func emailTemplates(logger *slog.Logger, livePath string) (application.EmailTemplateEngine, error) {
+	if livePath != "" {
+		return &templating.LiveEmailLoader{Logger: logger, BaseDir: livePath}, nil
+	}
+
+	templateFS, err := fs.Sub(ui.EmailFS, "emails")
+	if err != nil {
+		return nil, err
+	}
+
+	templates, err := templating.NewEmailTemplateCache(logger, templateFS)
+	if err != nil {
+		return nil, fmt.Errorf("loading email templates: %v", err)
+	}
+
+	return templates, nil
+}
@@ -1,62 +1,261 @@
 package application
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
 
+	"github.com/cdriehuys/secret-santa/internal/email"
+	"github.com/cdriehuys/secret-santa/internal/i18n"
 	"github.com/cdriehuys/secret-santa/internal/models"
 	"github.com/cdriehuys/secret-santa/internal/pairings"
+	"github.com/cdriehuys/secret-santa/internal/ratelimit"
+	"github.com/cdriehuys/secret-santa/internal/roster"
+	"github.com/google/uuid"
 	"github.com/justinas/nosurf"
 )
 
-const MaxNames = 100
-const MaxExclusions = 3
-
 type GiftRestrictions map[string][]string
 
-type pairingGenerator func(GiftRestrictions) ([]pairings.Pairing, error)
+// PairingOptions customize how pairings are generated.
+type PairingOptions struct {
+	// Seed makes the draw reproducible. If nil, a random seed is used.
+	Seed *int64
+}
+
+// pairingGenerator draws pairings that respect the restrictions. It gives up with
+// pairings.ErrSearchExhausted if finding them takes too long, or with the context's error if the
+// request is canceled.
+type pairingGenerator func(context.Context, GiftRestrictions, PairingOptions) ([]pairings.Pairing, error)
+
 type TemplateEngine interface {
 	Render(io.Writer, string, any) error
 }
 
 type UserModel interface {
+	Authenticate(ctx context.Context, email string, password string) (uuid.UUID, error)
+	ChangeEmail(ctx context.Context, userID uuid.UUID, email string) error
+	ChangePassword(ctx context.Context, userID uuid.UUID, currentSession string, currentPassword string, newPassword string) error
+	Delete(ctx context.Context, userID uuid.UUID, password string) error
+	ExportData(ctx context.Context, userID uuid.UUID) (models.UserData, error)
 	Register(context.Context, models.NewUser) error
+	VerifyEmail(ctx context.Context, token string) error
+}
+
+type SessionModel interface {
+	Create(ctx context.Context, userID uuid.UUID) (string, error)
+	CreatePending(ctx context.Context, userID uuid.UUID) (string, error)
+	Delete(ctx context.Context, token string) error
+	PendingUser(ctx context.Context, token string) (models.User, error)
+	User(ctx context.Context, token string) (models.User, error)
+}
+
+type TwoFactorModel interface {
+	BeginEnrollment(ctx context.Context, userID uuid.UUID, account string) (models.TwoFactorEnrollment, error)
+	ConfirmEnrollment(ctx context.Context, userID uuid.UUID, code string) ([]string, error)
+	Disable(ctx context.Context, userID uuid.UUID, code string) error
+	Enabled(ctx context.Context, userID uuid.UUID) (bool, error)
+	Verify(ctx context.Context, userID uuid.UUID, code string) error
+}
+
+// Mailbox exposes emails captured during development so they can be viewed in the browser.
+type Mailbox interface {
+	Get(name string) (email.StoredMessage, error)
+	List() ([]email.StoredMessage, error)
+}
+
+// TemplateReloads notifies subscribers when the UI templates change so pages open in a browser can
+// be refreshed during development.
+type TemplateReloads interface {
+	Subscribe() (<-chan struct{}, func())
 }
 
 type TemplateData struct {
+	// Locale is the locale the page is rendered in, such as "en" or "de".
+	Locale string
+
 	IsAuthenticated bool
 	CSRFToken       string
+	User            models.User
+
+	// Form holds previously submitted values so a form can be re-rendered after a failed
+	// submission.
+	Form any
+
+	// Errors maps form field names to a description of what is wrong with the submitted value. The
+	// descriptions are translated by the templates.
+	Errors map[string]i18n.Message
+
+	TwoFactorEnabled    bool
+	TwoFactorEnrollment models.TwoFactorEnrollment
+	RecoveryCodes       []string
+
+	MailMessages []email.StoredMessage
+	MailMessage  email.StoredMessage
+
+	// Pairings are the results of a draw.
+	Pairings []pairings.Pairing
+
+	// LiveReload includes a script that refreshes the page when the templates change.
+	LiveReload bool
+}
+
+// RateLimits controls how often the forms that are attractive to brute force or spam can be
+// submitted. A zero limit is not enforced.
+type RateLimits struct {
+	LoginPerIP    ratelimit.Limit
+	LoginPerEmail ratelimit.Limit
+
+	RegisterPerIP    ratelimit.Limit
+	RegisterPerEmail ratelimit.Limit
+
+	TwoFactorPerIP      ratelimit.Limit
+	TwoFactorPerSession ratelimit.Limit
+}
+
+// DefaultRateLimits are generous enough for people who mistype a password a few times while
+// making guessing passwords or one time codes impractical.
+var DefaultRateLimits = RateLimits{
+	LoginPerIP:    ratelimit.Limit{Burst: 20, Every: time.Minute},
+	LoginPerEmail: ratelimit.Limit{Burst: 5, Every: 5 * time.Minute},
+
+	RegisterPerIP:    ratelimit.Limit{Burst: 5, Every: 10 * time.Minute},
+	RegisterPerEmail: ratelimit.Limit{Burst: 3, Every: time.Hour},
+
+	TwoFactorPerIP:      ratelimit.Limit{Burst: 20, Every: time.Minute},
+	TwoFactorPerSession: ratelimit.Limit{Burst: 5, Every: time.Minute},
 }
 
 type Application struct {
 	Logger *slog.Logger
 
 	PairingGenerator pairingGenerator
+	PairingLimits    roster.Limits
 	Templates        TemplateEngine
 
-	Users UserModel
+	Sessions  SessionModel
+	TwoFactor TwoFactorModel
+	Users     UserModel
+
+	// RateLimiter stores the state for RateLimits. If nil, requests are not rate limited.
+	RateLimiter ratelimit.Store
+	RateLimits  RateLimits
+
+	// Mailbox enables the development routes for viewing sent emails if it is not nil. They're only
+	// served to clients on the same machine, and Mailbox must still not be set in production since
+	// it exposes every email sent by the application.
+	Mailbox Mailbox
+
+	// TemplateReloads enables refreshing pages in the browser when templates change if it is not
+	// nil. Like Mailbox, it is only meant for development.
+	TemplateReloads TemplateReloads
+
+	// ReadinessChecks are run by the readiness probe in addition to checking the templates.
+	ReadinessChecks []ReadinessCheck
+	readiness       readinessCache
+
+	// ShuttingDown is closed when the server starts shutting down. Long-lived responses, like the
+	// live reload stream, end when it's closed so they don't hold up the shutdown.
+	ShuttingDown <-chan struct{}
+
+	// TrustedProxies are the addresses of proxies whose X-Forwarded-For header identifies the
+	// client. Without them, clients are identified by the address they connect from.
+	TrustedProxies []netip.Prefix
+
+	// SecureCookies limits cookies to HTTPS. It should be set whenever the site is served over
+	// HTTPS.
+	SecureCookies bool
 }
 
 func (a *Application) templateData(r *http.Request) TemplateData {
+	user, isAuthenticated := authenticatedUser(r)
+
 	return TemplateData{
-		CSRFToken: nosurf.Token(r),
+		Locale:          requestLocale(r.Context()),
+		IsAuthenticated: isAuthenticated,
+		CSRFToken:       nosurf.Token(r),
+		User:            user,
+		LiveReload:      a.TemplateReloads != nil,
 	}
 }
 
+// bufferPool holds the buffers pages are rendered into before being sent, so a template error can
+// still be reported with a proper error page.
+var bufferPool = sync.Pool{
+	New: func() any {
+		return new(bytes.Buffer)
+	},
+}
+
 func (a *Application) serverError(w http.ResponseWriter, r *http.Request, message string, err error, attrs ...any) {
 	attrs = append(attrs, "error", err)
 	a.Logger.ErrorContext(r.Context(), message, attrs...)
 
-	w.WriteHeader(http.StatusInternalServerError)
+	a.renderError(w, r, http.StatusInternalServerError, "server-error.html")
+}
+
+func (a *Application) badRequest(w http.ResponseWriter, r *http.Request) {
+	a.renderError(w, r, http.StatusBadRequest, "bad-request.html")
+}
+
+func (a *Application) notFound(w http.ResponseWriter, r *http.Request) {
+	a.renderError(w, r, http.StatusNotFound, "not-found.html")
+}
+
+// renderError sends an error page. If the error page can't be rendered either, a plain text
+// description of the status is sent instead so the client still gets a response.
+func (a *Application) renderError(w http.ResponseWriter, r *http.Request, status int, page string) {
+	buf, err := a.renderBuffer(page, a.templateData(r))
+	if err != nil {
+		a.Logger.ErrorContext(r.Context(), "Failed to render error page.", "page", page, "error", err)
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	defer bufferPool.Put(buf)
+
+	w.WriteHeader(status)
+	buf.WriteTo(w)
 }
 
 func (a *Application) render(w http.ResponseWriter, r *http.Request, page string, data TemplateData) {
-	if err := a.Templates.Render(w, page, data); err != nil {
+	a.renderStatus(w, r, http.StatusOK, page, data)
+}
+
+func (a *Application) renderStatus(w http.ResponseWriter, r *http.Request, status int, page string, data TemplateData) {
+	buf, err := a.renderBuffer(page, data)
+	if err != nil {
 		a.serverError(w, r, "Failed to render page.", err, "page", page)
+		return
+	}
+
+	defer bufferPool.Put(buf)
+
+	w.WriteHeader(status)
+	buf.WriteTo(w)
+}
+
+// renderBuffer renders a page into a pooled buffer. The page is translated into data.Locale. The
+// caller must return the buffer to the pool once it has been written.
+func (a *Application) renderBuffer(page string, data TemplateData) (*bytes.Buffer, error) {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+
+	if err := a.Templates.Render(buf, page, data); err != nil {
+		bufferPool.Put(buf)
+		return nil, err
 	}
+
+	return buf, nil
 }
 
 func (a *Application) homeGet(w http.ResponseWriter, r *http.Request) {
@@ -64,50 +263,181 @@ func (a *Application) homeGet(w http.ResponseWriter, r *http.Request) {
 	a.render(w, r, "home.html", data)
 }
 
+// minParticipantRows is the number of rows shown on the pairings form before any participants are
+// entered.
+const minParticipantRows = 5
+
+// participantFormFields are the names of the pairings form fields. Problems with the participants as
+// a whole are reported for the form.
+var participantFormFields = participantFields{
+	List:       "form",
+	Name:       "name[%d]",
+	Email:      "name[%d].email",
+	Exclusions: "name[%d].exclusions",
+	Exclusion:  "name[%d].exclusion[%d]",
+}
+
+type pairingsForm struct {
+	Participants    []roster.Participant
+	MaxParticipants int
+}
+
+// newPairingsForm prepares participants for display with at least the given number of rows, padding
+// rows with blank participants and participants with blank exclusions. The padding never exceeds
+// the limits, but participants beyond the limits are kept so they can be corrected.
+func newPairingsForm(participants []roster.Participant, limits roster.Limits, rows int) pairingsForm {
+	rows = min(max(rows, minParticipantRows), limits.MaxParticipants)
+
+	form := pairingsForm{
+		Participants:    make([]roster.Participant, max(rows, len(participants))),
+		MaxParticipants: limits.MaxParticipants,
+	}
+
+	copy(form.Participants, participants)
+	for i, p := range form.Participants {
+		if blank := limits.MaxExclusions - len(p.Exclusions); blank > 0 {
+			form.Participants[i].Exclusions = append(slices.Clone(p.Exclusions), make([]string, blank)...)
+		}
+	}
+
+	return form
+}
+
 func (a *Application) pairingsGet(w http.ResponseWriter, r *http.Request) {
-	var data TemplateData = a.templateData(r)
+	data := a.templateData(r)
+	data.Form = newPairingsForm(nil, a.pairingLimits(), 0)
+
 	a.render(w, r, "pairings.html", data)
 }
 
 func (a *Application) pairingsPost(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		a.badRequest(w, r)
 		return
 	}
 
-	restrictions := GiftRestrictions{}
+	limits := a.pairingLimits()
+	participants := parseParticipantsForm(r.PostForm)
 
-	for i := range MaxNames {
-		nameKey := fmt.Sprintf("name[%d]", i)
-		name := r.FormValue(nameKey)
-
-		if name == "" {
-			break
+	// Without JavaScript, participants are added by submitting the form to get another row.
+	if r.PostFormValue("action") == "add-participant" {
+		rows := 0
+		for key := range r.PostForm {
+			if match := participantFormKey.FindStringSubmatch(key); match != nil && match[2] == "" {
+				rows++
+			}
 		}
 
-		var exclusions []string
-		for j := range MaxExclusions {
-			exclusionKey := fmt.Sprintf("%s.exclusion[%d]", nameKey, j)
-			exclusion := r.FormValue(exclusionKey)
+		data := a.templateData(r)
+		data.Form = newPairingsForm(participants, limits, rows+1)
 
-			if exclusion == "" {
-				break
-			}
+		a.render(w, r, "pairings.html", data)
+		return
+	}
 
-			exclusions = append(exclusions, exclusion)
-		}
+	renderErrors := func(errors map[string]i18n.Message) {
+		data := a.templateData(r)
+		data.Form = newPairingsForm(participants, limits, len(participants)+1)
+		data.Errors = errors
 
-		restrictions[name] = exclusions
+		a.renderStatus(w, r, http.StatusUnprocessableEntity, "pairings.html", data)
+	}
+
+	restrictions, errs := roster.Validate(participants, limits)
+	if len(errs) > 0 {
+		renderErrors(participantFormFields.errorMap(errs))
+		return
 	}
 
-	pairs, err := a.PairingGenerator(restrictions)
+	pairs, err := a.PairingGenerator(r.Context(), restrictions, PairingOptions{})
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		switch {
+		case errors.Is(err, pairings.ErrTooFewNodes):
+			renderErrors(map[string]i18n.Message{"form": i18n.NewMessage("At least two participants are required.")})
+		case errors.Is(err, pairings.ErrNoPath):
+			renderErrors(map[string]i18n.Message{"form": i18n.NewMessage("No pairings satisfy the exclusions. Try removing some exclusions.")})
+		case errors.Is(err, pairings.ErrSearchExhausted):
+			renderErrors(map[string]i18n.Message{"form": i18n.NewMessage("Pairings couldn't be found in time. Try drawing again, or remove some exclusions.")})
+		default:
+			a.serverError(w, r, "Failed to generate pairings.", err)
+		}
+
+		return
+	}
+
+	data := a.templateData(r)
+	data.Pairings = pairs
+
+	a.render(w, r, "pairings-results.html", data)
+}
+
+// pairingsExportPost sends the results of a draw in the requested format. Results aren't stored, so
+// the results page posts the pairings back to be exported.
+func (a *Application) pairingsExportPost(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		a.badRequest(w, r)
 		return
 	}
 
-	fmt.Fprintln(w, "Pairings:")
-	for _, pair := range pairs {
-		fmt.Fprintf(w, "%s -> %s\n", pair.From, pair.To)
+	pairs, ok := a.parsePairingsForm(r)
+	if !ok {
+		a.badRequest(w, r)
+		return
+	}
+
+	switch r.PostFormValue("format") {
+	case "csv":
+		var buf bytes.Buffer
+		if err := pairings.WriteCSV(&buf, pairs); err != nil {
+			a.serverError(w, r, "Failed to encode pairings as CSV.", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="secret-santa-pairings.csv"`)
+		buf.WriteTo(w)
+	case "json":
+		// The export uses the same format as the API, minus the seed which isn't known here.
+		body, err := json.MarshalIndent(struct {
+			Pairings []APIPairing `json:"pairings"`
+		}{NewAPIPairings(pairs)}, "", "  ")
+		if err != nil {
+			a.serverError(w, r, "Failed to encode pairings as JSON.", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="secret-santa-pairings.json"`)
+		w.Write(body)
+	case "slips":
+		data := a.templateData(r)
+		data.Pairings = pairs
+
+		a.render(w, r, "pairings-slips.html", data)
+	default:
+		a.badRequest(w, r)
+	}
+}
+
+// parsePairingsForm reads the pairings posted by the results page as "pair[i].from" and
+// "pair[i].to" values. It reports false if there are no pairings or a pairing is incomplete.
+func (a *Application) parsePairingsForm(r *http.Request) ([]pairings.Pairing, bool) {
+	var pairs []pairings.Pairing
+
+	for i := range a.pairingLimits().MaxParticipants {
+		from := r.PostFormValue(fmt.Sprintf("pair[%d].from", i))
+		to := r.PostFormValue(fmt.Sprintf("pair[%d].to", i))
+
+		if from == "" && to == "" {
+			break
+		}
+
+		if from == "" || to == "" {
+			return nil, false
+		}
+
+		pairs = append(pairs, pairings.Pairing{From: from, To: to})
 	}
+
+	return pairs, len(pairs) > 0
 }
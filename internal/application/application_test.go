@@ -1,15 +1,22 @@
 package application_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"maps"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
 	"testing"
 
 	"github.com/cdriehuys/secret-santa/internal/application"
 	"github.com/cdriehuys/secret-santa/internal/application/testutils"
+	"github.com/cdriehuys/secret-santa/internal/i18n"
 	"github.com/cdriehuys/secret-santa/internal/pairings"
+	"github.com/cdriehuys/secret-santa/internal/roster"
 )
 
 func TestApplication_homeGet(t *testing.T) {
@@ -29,22 +36,25 @@ func TestApplication_homeGet(t *testing.T) {
 
 func TestApplication_pairingsPost(t *testing.T) {
 	names := []string{"Bob", "Jane"}
-	fakeGenerator := func(restrictions application.GiftRestrictions) ([]pairings.Pairing, error) {
-		pairs := []pairings.Pairing{
-			{From: "Bob", To: "Jane"},
-			{From: "Jane", To: "Bob"},
-		}
+	pairs := []pairings.Pairing{
+		{From: "Bob", To: "Jane"},
+		{From: "Jane", To: "Bob"},
+	}
 
+	fakeGenerator := func(context.Context, application.GiftRestrictions, application.PairingOptions) ([]pairings.Pairing, error) {
 		return pairs, nil
 	}
 
+	templates := CapturingTemplateEngine[application.TemplateData]{}
+
 	app := testutils.NewTestApplication(t)
 	app.PairingGenerator = fakeGenerator
+	app.Templates = &templates
 
 	ts := testutils.NewTestServer(t, app.Routes())
 	defer ts.Close()
 
-	form := url.Values{}
+	form := csrfFormValues(t, app, ts, "/pairings")
 	for i, name := range names {
 		form.Add(fmt.Sprintf("name[%d]", i), name)
 	}
@@ -55,8 +65,13 @@ func TestApplication_pairingsPost(t *testing.T) {
 		t.Errorf("Expected status %d, got %d", http.StatusOK, got)
 	}
 
-	assertContains(t, res.Body, "Bob -> Jane")
-	assertContains(t, res.Body, "Jane -> Bob")
+	if templates.RenderedName != "pairings-results.html" {
+		t.Errorf("Expected page %q, got %q", "pairings-results.html", templates.RenderedName)
+	}
+
+	if !slices.Equal(templates.RenderedData.Pairings, pairs) {
+		t.Errorf("Expected pairings %v, got %v", pairs, templates.RenderedData.Pairings)
+	}
 }
 
 func TestApplication_pairingsPostWithExclusions(t *testing.T) {
@@ -66,12 +81,15 @@ func TestApplication_pairingsPostWithExclusions(t *testing.T) {
 		"Chandler": {"Ross"},
 	}
 
-	fakeGenerator := func(application.GiftRestrictions) ([]pairings.Pairing, error) {
-		pairs := []pairings.Pairing{
-			{From: "Ross", To: "Chandler"},
-			{From: "Joey", To: "Ross"},
-			{From: "Chandler", To: "Joey"},
-		}
+	pairs := []pairings.Pairing{
+		{From: "Ross", To: "Chandler"},
+		{From: "Joey", To: "Ross"},
+		{From: "Chandler", To: "Joey"},
+	}
+
+	var gotRestrictions application.GiftRestrictions
+	fakeGenerator := func(_ context.Context, restrictions application.GiftRestrictions, _ application.PairingOptions) ([]pairings.Pairing, error) {
+		gotRestrictions = restrictions
 
 		return pairs, nil
 	}
@@ -82,7 +100,7 @@ func TestApplication_pairingsPostWithExclusions(t *testing.T) {
 	ts := testutils.NewTestServer(t, app.Routes())
 	defer ts.Close()
 
-	form := url.Values{}
+	form := csrfFormValues(t, app, ts, "/pairings")
 	i := 0
 	for person, restrictions := range people {
 		form.Add(fmt.Sprintf("name[%d]", i), person)
@@ -100,9 +118,463 @@ func TestApplication_pairingsPostWithExclusions(t *testing.T) {
 		t.Errorf("Expected status %d, got %d", http.StatusOK, got)
 	}
 
-	assertContains(t, res.Body, "Ross -> Chandler")
-	assertContains(t, res.Body, "Joey -> Ross")
-	assertContains(t, res.Body, "Chandler -> Joey")
+	for person, restrictions := range people {
+		if got := gotRestrictions[person]; !slices.Equal(got, restrictions) {
+			t.Errorf("Expected %s to exclude %v, got %v", person, restrictions, got)
+		}
+	}
+
+	// The results page posts the pairings back to export them.
+	for i, pair := range pairs {
+		assertContains(t, res.Body, fmt.Sprintf(`name="pair[%d].from" value="%s"`, i, pair.From))
+		assertContains(t, res.Body, fmt.Sprintf(`name="pair[%d].to" value="%s"`, i, pair.To))
+	}
+}
+
+func TestApplication_pairingsPost_generatorError(t *testing.T) {
+	app := testutils.NewTestApplication(t)
+	app.PairingGenerator = func(context.Context, application.GiftRestrictions, application.PairingOptions) ([]pairings.Pairing, error) {
+		return nil, errors.New("no valid pairings")
+	}
+
+	ts := testutils.NewTestServer(t, app.Routes())
+	defer ts.Close()
+
+	form := csrfFormValues(t, app, ts, "/pairings")
+	form.Add("name[0]", "Bob")
+
+	res := ts.PostForm(t, "/pairings", form)
+
+	if got := res.Status; got != http.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, got)
+	}
+
+	assertContains(t, res.Body, "Something Went Wrong")
+}
+
+func TestApplication_pairingsPost_form(t *testing.T) {
+	pairs := []pairings.Pairing{{From: "Ross", To: "Joey"}, {From: "Joey", To: "Ross"}}
+
+	testCases := []struct {
+		name         string
+		form         url.Values
+		limits       roster.Limits
+		generatorErr error
+
+		wantStatus       int
+		wantPage         string
+		wantRestrictions application.GiftRestrictions
+		wantErrors       map[string]string
+		wantRows         int
+	}{
+		{
+			name: "gaps between rows",
+			form: url.Values{
+				"name[0]":              {" Ross "},
+				"name[1]":              {""},
+				"name[1].exclusion[0]": {""},
+				"name[7]":              {"Joey"},
+				"name[7].exclusion[2]": {"Chandler"},
+				"name[12]":             {"Chandler"},
+			},
+			wantStatus: http.StatusOK,
+			wantPage:   "pairings-results.html",
+			wantRestrictions: application.GiftRestrictions{
+				"Ross":     nil,
+				"Joey":     {"Chandler"},
+				"Chandler": nil,
+			},
+		},
+		{
+			name: "more participants than the old hard-coded limit",
+			form: func() url.Values {
+				form := url.Values{}
+				for i := range 150 {
+					form.Set(fmt.Sprintf("name[%d]", i), fmt.Sprintf("Person %d", i))
+				}
+
+				return form
+			}(),
+			limits:     roster.Limits{MaxParticipants: 200},
+			wantStatus: http.StatusOK,
+			wantPage:   "pairings-results.html",
+		},
+		{
+			name: "invalid participants",
+			form: url.Values{
+				"name[0]":              {"Ross"},
+				"name[0].exclusion[0]": {"Monica"},
+				"name[0].exclusion[1]": {"Ross"},
+				"name[1]":              {"Ross"},
+				"name[2].exclusion[0]": {"Joey"},
+				"name[3]":              {"Joey"},
+				"name[3].exclusion[0]": {"Ross"},
+				"name[3].exclusion[1]": {"Ross"},
+			},
+			limits:     roster.Limits{MaxExclusions: 1},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantPage:   "pairings.html",
+			wantErrors: map[string]string{
+				"name[0].exclusions": "There can be at most 1 exclusions.",
+				"name[1]":            `"Ross" is listed more than once.`,
+				"name[2]":            "Name is required.",
+				"name[3].exclusions": "There can be at most 1 exclusions.",
+			},
+			wantRows: 5,
+		},
+		{
+			name: "unknown exclusion",
+			form: url.Values{
+				"name[0]":              {"Ross"},
+				"name[0].exclusion[0]": {"Monica"},
+				"name[1]":              {"Joey"},
+			},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantPage:   "pairings.html",
+			wantErrors: map[string]string{
+				"name[0].exclusion[0]": `"Monica" is not a participant.`,
+			},
+		},
+		{
+			name: "too many participants",
+			form: url.Values{
+				"name[0]": {"Ross"},
+				"name[1]": {"Joey"},
+				"name[2]": {"Chandler"},
+			},
+			limits:     roster.Limits{MaxParticipants: 2},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantPage:   "pairings.html",
+			wantErrors: map[string]string{"form": "There can be at most 2 participants."},
+			wantRows:   3,
+		},
+		{
+			name:         "too few participants",
+			form:         url.Values{"name[0]": {"Ross"}},
+			generatorErr: pairings.ErrTooFewNodes,
+			wantStatus:   http.StatusUnprocessableEntity,
+			wantPage:     "pairings.html",
+			wantErrors:   map[string]string{"form": "At least two participants are required."},
+		},
+		{
+			name: "no valid pairings",
+			form: url.Values{
+				"name[0]":              {"Ross"},
+				"name[0].exclusion[0]": {"Joey"},
+				"name[1]":              {"Joey"},
+				"name[2]":              {"Chandler"},
+			},
+			generatorErr: pairings.ErrNoPath,
+			wantStatus:   http.StatusUnprocessableEntity,
+			wantPage:     "pairings.html",
+			wantErrors:   map[string]string{"form": "No pairings satisfy the exclusions. Try removing some exclusions."},
+		},
+		{
+			name: "search exhausted",
+			form: url.Values{
+				"name[0]": {"Ross"},
+				"name[1]": {"Joey"},
+			},
+			generatorErr: pairings.ErrSearchExhausted,
+			wantStatus:   http.StatusUnprocessableEntity,
+			wantPage:     "pairings.html",
+			wantErrors:   map[string]string{"form": "Pairings couldn't be found in time. Try drawing again, or remove some exclusions."},
+		},
+		{
+			name: "add participant",
+			form: url.Values{
+				"action":  {"add-participant"},
+				"name[0]": {"Ross"},
+				"name[1]": {""},
+				"name[2]": {""},
+				"name[3]": {""},
+				"name[4]": {""},
+				"name[5]": {""},
+			},
+			wantStatus: http.StatusOK,
+			wantPage:   "pairings.html",
+			wantRows:   7,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotRestrictions application.GiftRestrictions
+
+			templates := CapturingTemplateEngine[application.TemplateData]{}
+
+			app := testutils.NewTestApplication(t)
+			app.PairingLimits = tt.limits
+			app.Templates = &templates
+			app.PairingGenerator = func(_ context.Context, restrictions application.GiftRestrictions, _ application.PairingOptions) ([]pairings.Pairing, error) {
+				gotRestrictions = restrictions
+
+				return pairs, tt.generatorErr
+			}
+
+			ts := testutils.NewTestServer(t, app.Routes())
+			defer ts.Close()
+
+			form := csrfFormValues(t, app, ts, "/pairings")
+			maps.Copy(form, tt.form)
+
+			res := ts.PostForm(t, "/pairings", form)
+
+			if res.Status != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, res.Status)
+			}
+
+			if templates.RenderedName != tt.wantPage {
+				t.Errorf("Expected page %q, got %q", tt.wantPage, templates.RenderedName)
+			}
+
+			if tt.wantRestrictions != nil && !maps.EqualFunc(gotRestrictions, tt.wantRestrictions, slices.Equal) {
+				t.Errorf("Expected restrictions %v, got %v", tt.wantRestrictions, gotRestrictions)
+			}
+
+			if got := englishErrors(templates.RenderedData.Errors); tt.wantErrors != nil && !maps.Equal(got, tt.wantErrors) {
+				t.Errorf("Expected errors %v, got %v", tt.wantErrors, got)
+			}
+
+			if tt.wantRows != 0 {
+				// The form data is unexported, so count the rows in its printed representation.
+				if got := strings.Count(res.Body, "Exclusions:[]string"); got != tt.wantRows {
+					t.Errorf("Expected %d rows, got %d", tt.wantRows, got)
+				}
+			}
+		})
+	}
+}
+
+func TestApplication_pairingsExportPost(t *testing.T) {
+	pairForm := url.Values{
+		"pair[0].from": {"Ross"},
+		"pair[0].to":   {"Monica, Geller"},
+		"pair[1].from": {"Monica, Geller"},
+		"pair[1].to":   {"Ross"},
+	}
+
+	withFormat := func(form url.Values, format string) url.Values {
+		form = maps.Clone(form)
+		form.Set("format", format)
+
+		return form
+	}
+
+	testCases := []struct {
+		name            string
+		form            url.Values
+		wantStatus      int
+		wantContentType string
+		wantFilename    string
+		wantBody        []string
+	}{
+		{
+			name:            "csv",
+			form:            withFormat(pairForm, "csv"),
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/csv; charset=utf-8",
+			wantFilename:    "secret-santa-pairings.csv",
+			wantBody:        []string{"giver,recipient\nRoss,\"Monica, Geller\"\n\"Monica, Geller\",Ross"},
+		},
+		{
+			name: "csv formulas",
+			form: url.Values{
+				"format":       {"csv"},
+				"pair[0].from": {"=HYPERLINK(\"https://example.com\")"},
+				"pair[0].to":   {"+1"},
+				"pair[1].from": {"-1"},
+				"pair[1].to":   {"@SUM(A1)"},
+				"pair[2].from": {"\tTab"},
+				"pair[2].to":   {"\rReturn"},
+				"pair[3].from": {"Ross = Monica"},
+				"pair[3].to":   {"Joey"},
+			},
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/csv; charset=utf-8",
+			wantBody: []string{
+				"\"'=HYPERLINK(\"\"https://example.com\"\")\",'+1\n",
+				"'-1,'@SUM(A1)\n",
+				"'\tTab,\"'\rReturn\"\n",
+				"Ross = Monica,Joey",
+			},
+		},
+		{
+			name:            "json",
+			form:            withFormat(pairForm, "json"),
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/json",
+			wantFilename:    "secret-santa-pairings.json",
+			wantBody:        []string{`"from": "Ross"`, `"to": "Monica, Geller"`},
+		},
+		{
+			name:            "slips",
+			form:            withFormat(pairForm, "slips"),
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/html; charset=utf-8",
+			wantBody:        []string{"Ross, you are the Secret Santa for", "<strong>Monica, Geller</strong>"},
+		},
+		{
+			name:       "unknown format",
+			form:       withFormat(pairForm, "xml"),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "no pairings",
+			form:       url.Values{"format": {"csv"}},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "incomplete pairing",
+			form: url.Values{
+				"format":       {"csv"},
+				"pair[0].from": {"Ross"},
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			app := testutils.NewTestApplication(t)
+			ts := testutils.NewTestServer(t, app.Routes())
+			defer ts.Close()
+
+			form := csrfFormValues(t, app, ts, "/pairings")
+			maps.Copy(form, tt.form)
+
+			res := ts.PostForm(t, "/pairings/export", form)
+
+			if res.Status != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, res.Status)
+			}
+
+			if tt.wantContentType != "" {
+				if got := res.Headers.Get("Content-Type"); got != tt.wantContentType {
+					t.Errorf("Expected content type %q, got %q", tt.wantContentType, got)
+				}
+			}
+
+			if tt.wantFilename != "" {
+				assertContains(t, res.Headers.Get("Content-Disposition"), tt.wantFilename)
+			}
+
+			for _, want := range tt.wantBody {
+				assertContains(t, res.Body, want)
+			}
+		})
+	}
+}
+
+// failingTemplateEngine writes part of every page before failing, except for the pages listed in
+// working.
+type failingTemplateEngine struct {
+	working []string
+}
+
+func (e *failingTemplateEngine) Render(w io.Writer, name string, data any) error {
+	fmt.Fprintf(w, "start of %s", name)
+
+	if slices.Contains(e.working, name) {
+		return nil
+	}
+
+	return errors.New("template failed")
+}
+
+func TestApplication_render_errors(t *testing.T) {
+	testCases := []struct {
+		name       string
+		path       string
+		templates  failingTemplateEngine
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "page fails",
+			path:       "/login",
+			templates:  failingTemplateEngine{working: []string{"server-error.html"}},
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   "start of server-error.html",
+		},
+		{
+			name:       "error page fails",
+			path:       "/login",
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   "Internal Server Error",
+		},
+		{
+			name:       "not found",
+			path:       "/does-not-exist",
+			templates:  failingTemplateEngine{working: []string{"not-found.html"}},
+			wantStatus: http.StatusNotFound,
+			wantBody:   "start of not-found.html",
+		},
+		{
+			name:       "not found page fails",
+			path:       "/does-not-exist",
+			wantStatus: http.StatusNotFound,
+			wantBody:   "Not Found",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			app := testutils.NewTestApplication(t)
+			app.Templates = &tt.templates
+
+			ts := testutils.NewTestServer(t, app.Routes())
+			defer ts.Close()
+
+			res := ts.Get(t, tt.path)
+
+			if res.Status != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, res.Status)
+			}
+
+			// Nothing from a page that failed to render should make it into the response.
+			if res.Body != tt.wantBody {
+				t.Errorf("Expected body %q, got %q", tt.wantBody, res.Body)
+			}
+		})
+	}
+}
+
+func TestApplication_errorPages(t *testing.T) {
+	app := testutils.NewTestApplication(t)
+
+	ts := testutils.NewTestServer(t, app.Routes())
+	defer ts.Close()
+
+	notFound := ts.Get(t, "/does-not-exist")
+	if notFound.Status != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, notFound.Status)
+	}
+
+	assertContains(t, notFound.Body, "Page Not Found")
+
+	// Forms posted to a missing page are a 404 rather than failing the CSRF check.
+	postNotFound := ts.PostForm(t, "/does-not-exist", url.Values{})
+	if postNotFound.Status != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, postNotFound.Status)
+	}
+
+	assertContains(t, postNotFound.Body, "Page Not Found")
+
+	// A page requested with the wrong method lists the methods it allows.
+	wrongMethod := ts.PostForm(t, "/account/export", url.Values{})
+	if wrongMethod.Status != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, wrongMethod.Status)
+	}
+
+	if allow := wrongMethod.Headers.Get("Allow"); allow != "GET, HEAD" {
+		t.Errorf("Expected Allow header %q, got %q", "GET, HEAD", allow)
+	}
+
+	// Submitting a form without a CSRF token is rejected.
+	badRequest := ts.PostForm(t, "/login", url.Values{"email": {"test@example.com"}})
+	if badRequest.Status != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, badRequest.Status)
+	}
+
+	assertContains(t, badRequest.Body, "Bad Request")
 }
 
 func assertContains(t *testing.T, haystack string, needle string) {
@@ -110,3 +582,13 @@ func assertContains(t *testing.T, haystack string, needle string) {
 		t.Errorf("Expected to find %q in %q", needle, haystack)
 	}
 }
+
+// englishErrors returns the form errors in English so they can be compared.
+func englishErrors(errs map[string]i18n.Message) map[string]string {
+	english := make(map[string]string, len(errs))
+	for field, msg := range errs {
+		english[field] = msg.String()
+	}
+
+	return english
+}
@@ -3,65 +3,179 @@ package application
 import (
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/url"
 	"strings"
+
+	"github.com/cdriehuys/secret-santa/internal/email"
+	"github.com/cdriehuys/secret-santa/internal/pairings"
 )
 
-type Emailer interface {
-	Send(ctx context.Context, to string, from string, subject string, body string) error
+// EmailTemplateEngine renders email templates. Besides the body, each plain text template defines
+// a "subject" block and may define a "preheader" block with the summary shown by mail clients next
+// to the subject.
+type EmailTemplateEngine interface {
+	TemplateEngine
+
+	RenderBlock(w io.Writer, name string, block string, data any) error
 }
 
 type EmailTemplateData struct {
+	// Locale is the locale the email is written in, such as "en" or "de".
+	Locale string
+
+	// Preheader is filled in from the plain text template's "preheader" block so it can be included
+	// in the HTML version.
+	Preheader string
+
 	VerificationLink string
+
+	// Giver and Recipient are the participants of a pairing.
+	Giver     string
+	Recipient string
+}
+
+// emailComposer renders emails from templates.
+type emailComposer struct {
+	templates EmailTemplateEngine
+	sender    string
 }
 
+// EmailVerifier composes the emails used to verify email addresses.
 type EmailVerifier struct {
-	logger *slog.Logger
+	emailComposer
 
-	emailer   Emailer
-	templates TemplateEngine
+	logger *slog.Logger
 
 	baseDomain *url.URL
-	sender     string
 }
 
-func NewEmailVerifier(logger *slog.Logger, emailer Emailer, templates TemplateEngine, baseDomain *url.URL, sender string) *EmailVerifier {
+func NewEmailVerifier(logger *slog.Logger, templates EmailTemplateEngine, baseDomain *url.URL, sender string) *EmailVerifier {
 	return &EmailVerifier{
-		logger:     logger,
-		emailer:    emailer,
-		templates:  templates,
-		baseDomain: baseDomain,
-		sender:     sender,
+		emailComposer: emailComposer{templates: templates, sender: sender},
+		logger:        logger,
+		baseDomain:    baseDomain,
 	}
 }
 
-func (v *EmailVerifier) DuplicateRegistration(ctx context.Context, email string) error {
-	body, err := v.render("duplicate-email.txt", EmailTemplateData{})
+// ChangeEmail composes the email sent to verify a user's new address. Like the other emails, it is
+// written in the locale of the request that caused it to be sent.
+func (v *EmailVerifier) ChangeEmail(ctx context.Context, address string, token string) (email.Message, error) {
+	verificationLink := v.baseDomain.JoinPath("verify-email", token).String()
+	data := EmailTemplateData{VerificationLink: verificationLink}
+
+	msg, err := v.message(ctx, "change-email", data)
 	if err != nil {
-		return fmt.Errorf("rendering duplicate email template: %v", err)
+		return email.Message{}, fmt.Errorf("rendering change email template: %v", err)
 	}
 
-	return v.emailer.Send(ctx, email, v.sender, "Duplicate Registration", body)
+	msg.To = address
+
+	return msg, nil
 }
 
-func (v *EmailVerifier) NewEmail(ctx context.Context, email string, token string) error {
+func (v *EmailVerifier) DuplicateRegistration(ctx context.Context, address string) (email.Message, error) {
+	msg, err := v.message(ctx, "duplicate-email", EmailTemplateData{})
+	if err != nil {
+		return email.Message{}, fmt.Errorf("rendering duplicate email template: %v", err)
+	}
+
+	msg.To = address
+
+	return msg, nil
+}
+
+func (v *EmailVerifier) NewEmail(ctx context.Context, address string, token string) (email.Message, error) {
 	verificationLink := v.baseDomain.JoinPath("verify-email", token).String()
 	data := EmailTemplateData{VerificationLink: verificationLink}
 
-	body, err := v.render("new-registration.txt", data)
+	msg, err := v.message(ctx, "new-registration", data)
 	if err != nil {
-		return fmt.Errorf("rendering new registration email template: %v", err)
+		return email.Message{}, fmt.Errorf("rendering new registration email template: %v", err)
 	}
 
-	return v.emailer.Send(ctx, email, v.sender, "Verify Your Email", body)
+	msg.To = address
+
+	return msg, nil
 }
 
-func (v *EmailVerifier) render(subject string, data EmailTemplateData) (string, error) {
+// AssignmentNotifier composes the emails that tell participants who they are giving a gift to.
+type AssignmentNotifier struct {
+	emailComposer
+}
+
+func NewAssignmentNotifier(templates EmailTemplateEngine, sender string) *AssignmentNotifier {
+	return &AssignmentNotifier{emailComposer{templates: templates, sender: sender}}
+}
+
+// Assignment composes the email telling the giver of a pairing who their recipient is.
+func (n *AssignmentNotifier) Assignment(ctx context.Context, address string, pairing pairings.Pairing) (email.Message, error) {
+	data := EmailTemplateData{Giver: pairing.From, Recipient: pairing.To}
+
+	msg, err := n.message(ctx, "pairing-assignment", data)
+	if err != nil {
+		return email.Message{}, fmt.Errorf("rendering pairing assignment email template: %v", err)
+	}
+
+	msg.To = address
+
+	return msg, nil
+}
+
+// message renders the subject and the plain text and HTML versions of the named email into a
+// message from the configured sender.
+func (c *emailComposer) message(ctx context.Context, name string, data EmailTemplateData) (email.Message, error) {
+	data.Locale = requestLocale(ctx)
+	name = localizedTemplate(data.Locale, name)
+	textName := name + ".txt"
+
+	subject, err := c.renderLine(textName, "subject", data)
+	if err != nil {
+		return email.Message{}, err
+	}
+
+	data.Preheader, err = c.renderLine(textName, "preheader", data)
+	if err != nil {
+		return email.Message{}, err
+	}
+
+	text, err := c.render(textName, data)
+	if err != nil {
+		return email.Message{}, err
+	}
+
+	html, err := c.render(name+".html", data)
+	if err != nil {
+		return email.Message{}, err
+	}
+
+	msg := email.Message{
+		From:    c.sender,
+		Subject: subject,
+		Text:    text,
+		HTML:    html,
+	}
+
+	return msg, nil
+}
+
+func (c *emailComposer) render(subject string, data EmailTemplateData) (string, error) {
 	var output strings.Builder
-	if err := v.templates.Render(&output, subject, data); err != nil {
+	if err := c.templates.Render(&output, subject, data); err != nil {
 		return "", fmt.Errorf("rendering email template %q: %v", subject, err)
 	}
 
 	return output.String(), nil
 }
+
+// renderLine renders a block of a template as a single line of text, since blocks like the subject
+// are easier to read in templates when they are allowed to wrap.
+func (c *emailComposer) renderLine(name string, block string, data EmailTemplateData) (string, error) {
+	var output strings.Builder
+	if err := c.templates.RenderBlock(&output, name, block, data); err != nil {
+		return "", fmt.Errorf("rendering %s of email template %q: %v", block, name, err)
+	}
+
+	return strings.Join(strings.Fields(output.String()), " "), nil
+}
@@ -1,15 +1,26 @@
 package application_test
 
 import (
-	"context"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log/slog"
+	"net/http"
 	"net/url"
+	"slices"
 	"testing"
 
+	"github.com/alexedwards/argon2id"
 	"github.com/cdriehuys/secret-santa/internal/application"
+	"github.com/cdriehuys/secret-santa/internal/application/testutils"
+	"github.com/cdriehuys/secret-santa/internal/email"
+	"github.com/cdriehuys/secret-santa/internal/models"
+	"github.com/cdriehuys/secret-santa/internal/models/mocks"
+	"github.com/cdriehuys/secret-santa/internal/pairings"
+	"github.com/cdriehuys/secret-santa/internal/security"
+	"github.com/cdriehuys/secret-santa/internal/templating"
+	"github.com/cdriehuys/secret-santa/ui"
 )
 
 const (
@@ -17,9 +28,10 @@ const (
 )
 
 type mockEmailTemplateEngine struct {
-	renderedSubject string
-	renderedData    application.EmailTemplateData
-	renderedRawData any
+	renderedSubjects []string
+	renderedBlocks   []string
+	renderedData     application.EmailTemplateData
+	renderedRawData  any
 
 	renderError error
 }
@@ -29,7 +41,7 @@ func (e *mockEmailTemplateEngine) Render(w io.Writer, subject string, data any)
 		return e.renderError
 	}
 
-	e.renderedSubject = subject
+	e.renderedSubjects = append(e.renderedSubjects, subject)
 	e.renderedRawData = data
 
 	if emailData, ok := data.(application.EmailTemplateData); ok {
@@ -41,21 +53,38 @@ func (e *mockEmailTemplateEngine) Render(w io.Writer, subject string, data any)
 	return nil
 }
 
-type capturingMailer struct {
-	sendTo      string
-	sendFrom    string
-	sendSubject string
-	sendBody    string
-	sendError   error
+// RenderBlock writes the template and block names so tests can tell which template a subject came
+// from.
+func (e *mockEmailTemplateEngine) RenderBlock(w io.Writer, subject string, block string, data any) error {
+	if e.renderError != nil {
+		return e.renderError
+	}
+
+	e.renderedBlocks = append(e.renderedBlocks, subject+" "+block)
+
+	fmt.Fprintf(w, "%s\n%s", subject, block)
+
+	return nil
 }
 
-func (m *capturingMailer) Send(ctx context.Context, to string, from string, subject string, body string) error {
-	m.sendTo = to
-	m.sendFrom = from
-	m.sendSubject = subject
-	m.sendBody = body
+func assertMessage(t *testing.T, want email.Message, got email.Message) {
+	t.Helper()
+
+	if got.To != want.To {
+		t.Errorf("Expected email to be sent to %q, got %q", want.To, got.To)
+	}
+
+	if got.From != want.From {
+		t.Errorf("Expected email to be sent from %q, got %q", want.From, got.From)
+	}
+
+	if got.Subject != want.Subject {
+		t.Errorf("Expected email subject %q, got %q", want.Subject, got.Subject)
+	}
 
-	return m.sendError
+	if want.To != "" && (got.Text == "" || got.HTML == "") {
+		t.Errorf("Expected both text and HTML bodies, got %q and %q", got.Text, got.HTML)
+	}
 }
 
 func TestEmailVerifier_DuplicateRegistration(t *testing.T) {
@@ -65,23 +94,22 @@ func TestEmailVerifier_DuplicateRegistration(t *testing.T) {
 	}
 
 	testCases := []struct {
-		name             string
-		mailer           capturingMailer
-		templates        mockEmailTemplateEngine
-		sender           string
-		email            string
-		wantEmailTo      string
-		wantEmailFrom    string
-		wantEmailSubject string
-		wantErr          bool
+		name      string
+		templates mockEmailTemplateEngine
+		sender    string
+		email     string
+		want      email.Message
+		wantErr   bool
 	}{
 		{
-			name:             "successful send",
-			sender:           "admin@localhost",
-			email:            "new-user@example.com",
-			wantEmailTo:      "new-user@example.com",
-			wantEmailFrom:    "admin@localhost",
-			wantEmailSubject: "Duplicate Registration",
+			name:   "successful send",
+			sender: "admin@localhost",
+			email:  "new-user@example.com",
+			want: email.Message{
+				To:      "new-user@example.com",
+				From:    "admin@localhost",
+				Subject: "duplicate-email.txt subject",
+			},
 		},
 		{
 			name: "rendering error",
@@ -96,54 +124,58 @@ func TestEmailVerifier_DuplicateRegistration(t *testing.T) {
 
 	for _, tt := range testCases {
 		t.Run(tt.name, func(t *testing.T) {
-			verifier := application.NewEmailVerifier(slog.New(slog.DiscardHandler), &tt.mailer, &tt.templates, baseDomain, tt.sender)
+			verifier := application.NewEmailVerifier(slog.New(slog.DiscardHandler), &tt.templates, baseDomain, tt.sender)
 
-			err = verifier.DuplicateRegistration(t.Context(), tt.email)
+			got, err := verifier.DuplicateRegistration(t.Context(), tt.email)
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Expected error presence %v, got error %#v", tt.wantErr, err)
 			}
 
-			if got := tt.mailer.sendTo; got != tt.wantEmailTo {
-				t.Errorf("Expected email to be sent to %q, got %q", tt.wantEmailTo, got)
-			}
-
-			if got := tt.mailer.sendFrom; got != tt.wantEmailFrom {
-				t.Errorf("Expected email to be sent from %q, got %q", tt.wantEmailFrom, got)
-			}
-
-			if got := tt.mailer.sendSubject; got != tt.wantEmailSubject {
-				t.Errorf("Expected email subject %q, got %q", tt.wantEmailSubject, got)
-			}
+			assertMessage(t, tt.want, got)
 		})
 	}
 }
 
 func TestEmailVerifier_NewEmail(t *testing.T) {
 	testCases := []struct {
-		name             string
-		mailer           capturingMailer
-		templates        mockEmailTemplateEngine
-		baseDomain       string
-		sender           string
-		email            string
-		token            string
-		wantEmailTo      string
-		wantEmailFrom    string
-		wantEmailSubject string
-		wantEmailToken   string
-		wantErr          bool
+		name           string
+		templates      mockEmailTemplateEngine
+		baseDomain     string
+		sender         string
+		locale         string
+		email          string
+		token          string
+		want           email.Message
+		wantEmailToken string
+		wantErr        bool
 	}{
 		{
-			name:             "successful send",
-			baseDomain:       "http://localhost",
-			sender:           "admin@localhost",
-			email:            "new-user@example.com",
-			token:            "secret-token",
-			wantEmailTo:      "new-user@example.com",
-			wantEmailFrom:    "admin@localhost",
-			wantEmailSubject: "Verify Your Email",
-			wantEmailToken:   "secret-token",
+			name:       "successful send",
+			baseDomain: "http://localhost",
+			sender:     "admin@localhost",
+			email:      "new-user@example.com",
+			token:      "secret-token",
+			want: email.Message{
+				To:      "new-user@example.com",
+				From:    "admin@localhost",
+				Subject: "new-registration.txt subject",
+			},
+			wantEmailToken: "secret-token",
+		},
+		{
+			name:       "translated",
+			baseDomain: "http://localhost",
+			sender:     "admin@localhost",
+			locale:     "de",
+			email:      "new-user@example.com",
+			token:      "secret-token",
+			want: email.Message{
+				To:      "new-user@example.com",
+				From:    "admin@localhost",
+				Subject: "de/new-registration.txt subject",
+			},
+			wantEmailToken: "secret-token",
 		},
 		{
 			name: "rendering error",
@@ -165,28 +197,91 @@ func TestEmailVerifier_NewEmail(t *testing.T) {
 				t.Fatalf("Base domain %q is invalid: %v", tt.baseDomain, err)
 			}
 
-			verifier := application.NewEmailVerifier(slog.New(slog.DiscardHandler), &tt.mailer, &tt.templates, baseDomain, tt.sender)
+			verifier := application.NewEmailVerifier(slog.New(slog.DiscardHandler), &tt.templates, baseDomain, tt.sender)
 
-			err = verifier.NewEmail(t.Context(), tt.email, tt.token)
+			ctx := t.Context()
+			if tt.locale != "" {
+				ctx = application.WithLocale(ctx, tt.locale)
+			}
+
+			got, err := verifier.NewEmail(ctx, tt.email, tt.token)
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Expected error presence %v, got error %#v", tt.wantErr, err)
 			}
 
-			if got := tt.mailer.sendTo; got != tt.wantEmailTo {
-				t.Errorf("Expected email to be sent to %q, got %q", tt.wantEmailTo, got)
+			assertMessage(t, tt.want, got)
+
+			if tt.wantEmailToken != "" {
+				wantLink := baseDomain.JoinPath(expectedVerificationPathSegment, tt.wantEmailToken).String()
+				if got := tt.templates.renderedData.VerificationLink; got != wantLink {
+					t.Errorf("Expected verification link %q, got %q", wantLink, got)
+				}
+			}
+		})
+	}
+}
+
+func TestEmailVerifier_ChangeEmail(t *testing.T) {
+	baseDomain, err := url.Parse("https://example.com")
+	if err != nil {
+		t.Fatalf("Invalid base domain: %v", err)
+	}
+
+	testCases := []struct {
+		name          string
+		templates     mockEmailTemplateEngine
+		want          email.Message
+		wantTemplates []string
+		wantErr       bool
+	}{
+		{
+			name: "successful send",
+			want: email.Message{
+				To:      "new@example.com",
+				From:    "admin@localhost",
+				Subject: "change-email.txt subject",
+			},
+			wantTemplates: []string{"change-email.txt", "change-email.html"},
+		},
+		{
+			name: "rendering error",
+			templates: mockEmailTemplateEngine{
+				renderError: errors.New("rendering failed"),
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier := application.NewEmailVerifier(slog.New(slog.DiscardHandler), &tt.templates, baseDomain, "admin@localhost")
+
+			got, err := verifier.ChangeEmail(t.Context(), "new@example.com", "secret-token")
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error presence %v, got error %#v", tt.wantErr, err)
 			}
 
-			if got := tt.mailer.sendFrom; got != tt.wantEmailFrom {
-				t.Errorf("Expected email to be sent from %q, got %q", tt.wantEmailFrom, got)
+			assertMessage(t, tt.want, got)
+
+			if got := tt.templates.renderedSubjects; !slices.Equal(got, tt.wantTemplates) {
+				t.Errorf("Expected templates %v, got %v", tt.wantTemplates, got)
 			}
 
-			if got := tt.mailer.sendSubject; got != tt.wantEmailSubject {
-				t.Errorf("Expected email subject %q, got %q", tt.wantEmailSubject, got)
+			if !tt.wantErr {
+				wantBlocks := []string{"change-email.txt subject", "change-email.txt preheader"}
+				if got := tt.templates.renderedBlocks; !slices.Equal(got, wantBlocks) {
+					t.Errorf("Expected blocks %v, got %v", wantBlocks, got)
+				}
+
+				if got, want := tt.templates.renderedData.Preheader, "change-email.txt preheader"; got != want {
+					t.Errorf("Expected preheader %q, got %q", want, got)
+				}
 			}
 
-			if tt.wantEmailToken != "" {
-				wantLink := baseDomain.JoinPath(expectedVerificationPathSegment, tt.wantEmailToken).String()
+			if !tt.wantErr {
+				wantLink := baseDomain.JoinPath(expectedVerificationPathSegment, "secret-token").String()
 				if got := tt.templates.renderedData.VerificationLink; got != wantLink {
 					t.Errorf("Expected verification link %q, got %q", wantLink, got)
 				}
@@ -194,3 +289,178 @@ func TestEmailVerifier_NewEmail(t *testing.T) {
 		})
 	}
 }
+
+func TestEmailVerifier_NewEmail_followLink(t *testing.T) {
+	const (
+		address  = "new-user@example.com"
+		password = "correct-horse"
+	)
+
+	logger := slog.New(slog.DiscardHandler)
+
+	app := testutils.NewTestApplication(t)
+
+	ts := testutils.NewTestServer(t, app.Routes())
+	defer ts.Close()
+
+	templateFS, err := fs.Sub(ui.EmailFS, "emails")
+	if err != nil {
+		t.Fatalf("failed to load email templates: %v", err)
+	}
+
+	templates, err := templating.NewEmailTemplateCache(logger, templateFS)
+	if err != nil {
+		t.Fatalf("failed to construct email template cache: %v", err)
+	}
+
+	baseDomain, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatalf("Invalid test server URL: %v", err)
+	}
+
+	// The real models run against an in-memory database so the email comes from the same path as
+	// in production: registration adds it to the outbox, and the outbox worker sends it.
+	store := &mocks.Store{}
+	verifier := application.NewEmailVerifier(logger, templates, baseDomain, "no-reply@example.com")
+	hasher := security.NewArgon2IDHasher(argon2id.Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+	users := models.NewUserModel(logger, verifier, hasher, security.TokenGenerator{}, store, store)
+	app.Users = users
+
+	form := csrfFormValues(t, app, ts, "/register")
+	form.Add("email", address)
+	form.Add("password", password)
+
+	if res := ts.PostForm(t, "/register", form); res.Status != http.StatusSeeOther {
+		t.Fatalf("Expected registration to redirect, got status %d", res.Status)
+	}
+
+	var mailer email.RecordingMailer
+	worker := models.NewOutboxWorker(logger, &mailer, store)
+	if _, err := worker.DeliverPending(t.Context()); err != nil {
+		t.Fatalf("failed to deliver pending emails: %v", err)
+	}
+
+	sent := mailer.To(address)
+	if len(sent) != 1 {
+		t.Fatalf("Expected one email to %s, got %d", address, len(sent))
+	}
+
+	if got, want := sent[0].Subject, "Verify Your Email"; got != want {
+		t.Errorf("Expected subject %q, got %q", want, got)
+	}
+
+	assertContains(t, sent[0].HTML, "Confirm your email address to finish setting up")
+
+	links := sent[0].Links()
+	if len(links) != 1 {
+		t.Fatalf("Expected one link in the email, got %q", links)
+	}
+
+	if htmlLinks := email.ExtractLinks(sent[0].HTML); !slices.Contains(htmlLinks, links[0]) {
+		t.Errorf("Expected HTML body to link to %q, got %q", links[0], htmlLinks)
+	}
+
+	link, err := url.Parse(links[0])
+	if err != nil {
+		t.Fatalf("Invalid link %q: %v", links[0], err)
+	}
+
+	if _, err := users.Authenticate(t.Context(), address, password); !errors.Is(err, models.ErrInvalidCredentials) {
+		t.Fatalf("Expected login to fail before the email is verified, got %v", err)
+	}
+
+	res := ts.Get(t, link.Path)
+
+	if res.Status != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, res.Status)
+	}
+
+	if _, err := users.Authenticate(t.Context(), address, password); err != nil {
+		t.Errorf("Expected login to succeed after following the link, got %v", err)
+	}
+
+	if res := ts.Get(t, link.Path); res.Status != http.StatusBadRequest {
+		t.Errorf("Expected the link to be used up, got status %d", res.Status)
+	}
+}
+
+func TestEmailVerifier_NewEmail_translated(t *testing.T) {
+	templateFS, err := fs.Sub(ui.EmailFS, "emails")
+	if err != nil {
+		t.Fatalf("failed to load email templates: %v", err)
+	}
+
+	templates, err := templating.NewEmailTemplateCache(slog.New(slog.DiscardHandler), templateFS)
+	if err != nil {
+		t.Fatalf("failed to construct email template cache: %v", err)
+	}
+
+	baseDomain, err := url.Parse("http://localhost")
+	if err != nil {
+		t.Fatalf("Invalid base domain: %v", err)
+	}
+
+	verifier := application.NewEmailVerifier(slog.New(slog.DiscardHandler), templates, baseDomain, "no-reply@example.com")
+
+	msg, err := verifier.NewEmail(application.WithLocale(t.Context(), "es"), "new-user@example.com", "secret-token")
+	if err != nil {
+		t.Fatalf("failed to compose email: %v", err)
+	}
+
+	if got, want := msg.Subject, "Verifica tu correo electrónico"; got != want {
+		t.Errorf("Expected subject %q, got %q", want, got)
+	}
+
+	assertContains(t, msg.Text, "Gracias por registrarte")
+	assertContains(t, msg.HTML, `<html lang="es">`)
+	assertContains(t, msg.HTML, "Los Elfos")
+}
+
+func TestAssignmentNotifier_Assignment(t *testing.T) {
+	templateFS, err := fs.Sub(ui.EmailFS, "emails")
+	if err != nil {
+		t.Fatalf("failed to load email templates: %v", err)
+	}
+
+	templates, err := templating.NewEmailTemplateCache(slog.New(slog.DiscardHandler), templateFS)
+	if err != nil {
+		t.Fatalf("failed to construct email template cache: %v", err)
+	}
+
+	notifier := application.NewAssignmentNotifier(templates, "no-reply@example.com")
+
+	testCases := []struct {
+		name        string
+		locale      string
+		wantSubject string
+		wantText    string
+	}{
+		{
+			name:        "default locale",
+			locale:      application.DefaultLocale,
+			wantSubject: "Your Secret Santa Assignment",
+			wantText:    "Hello Ross,",
+		},
+		{
+			name:        "translated",
+			locale:      "de",
+			wantSubject: "Dein Wichtelpartner",
+			wantText:    "Hallo Ross,",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := application.WithLocale(t.Context(), tt.locale)
+
+			msg, err := notifier.Assignment(ctx, "ross@example.com", pairings.Pairing{From: "Ross", To: "Joey"})
+			if err != nil {
+				t.Fatalf("failed to compose email: %v", err)
+			}
+
+			assertMessage(t, email.Message{To: "ross@example.com", From: "no-reply@example.com", Subject: tt.wantSubject}, msg)
+			assertContains(t, msg.Text, tt.wantText)
+			assertContains(t, msg.Text, "Joey")
+			assertContains(t, msg.HTML, "<strong>Joey</strong>")
+		})
+	}
+}
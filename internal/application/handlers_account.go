@@ -0,0 +1,151 @@
+package application
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/cdriehuys/secret-santa/internal/i18n"
+	"github.com/cdriehuys/secret-santa/internal/models"
+)
+
+// MinPasswordLength is the shortest password a user may choose.
+const MinPasswordLength = 8
+
+type accountEmailForm struct {
+	Email string
+}
+
+func (a *Application) accountGet(w http.ResponseWriter, r *http.Request) {
+	data := a.templateData(r)
+	data.Form = accountEmailForm{}
+
+	a.render(w, r, "account.html", data)
+}
+
+func (a *Application) accountEmailPost(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		a.badRequest(w, r)
+		return
+	}
+
+	user, _ := authenticatedUser(r)
+	form := accountEmailForm{Email: r.PostFormValue("email")}
+
+	if form.Email == "" {
+		data := a.templateData(r)
+		data.Form = form
+		data.Errors = map[string]i18n.Message{"email": i18n.NewMessage("Please enter an email address.")}
+
+		a.renderStatus(w, r, http.StatusUnprocessableEntity, "account.html", data)
+		return
+	}
+
+	if err := a.Users.ChangeEmail(r.Context(), user.ID, form.Email); err != nil {
+		a.serverError(w, r, "Failed to change email.", err, "userID", user.ID)
+		return
+	}
+
+	http.Redirect(w, r, "/account/email/success", http.StatusSeeOther)
+}
+
+func (a *Application) accountEmailSuccess(w http.ResponseWriter, r *http.Request) {
+	a.render(w, r, "account-email-success.html", a.templateData(r))
+}
+
+func (a *Application) accountPasswordPost(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		a.badRequest(w, r)
+		return
+	}
+
+	user, _ := authenticatedUser(r)
+	currentPassword := r.PostFormValue("current_password")
+	newPassword := r.PostFormValue("new_password")
+
+	errs := make(map[string]i18n.Message)
+	if len(newPassword) < MinPasswordLength {
+		errs["new_password"] = i18n.NewMessage("Password must be at least %d characters.", MinPasswordLength)
+	}
+
+	if len(errs) == 0 {
+		// The session cookie is present since the route requires authentication. It's kept so the
+		// user stays logged in while every other session ends.
+		session, _ := r.Cookie(sessionCookieName)
+
+		err := a.Users.ChangePassword(r.Context(), user.ID, session.Value, currentPassword, newPassword)
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			errs["current_password"] = i18n.NewMessage("Password is incorrect.")
+		} else if err != nil {
+			a.serverError(w, r, "Failed to change password.", err, "userID", user.ID)
+			return
+		}
+	}
+
+	if len(errs) > 0 {
+		data := a.templateData(r)
+		data.Form = accountEmailForm{}
+		data.Errors = errs
+
+		a.renderStatus(w, r, http.StatusUnprocessableEntity, "account.html", data)
+		return
+	}
+
+	http.Redirect(w, r, "/account/password/success", http.StatusSeeOther)
+}
+
+func (a *Application) accountPasswordSuccess(w http.ResponseWriter, r *http.Request) {
+	a.render(w, r, "account-password-success.html", a.templateData(r))
+}
+
+func (a *Application) accountExportGet(w http.ResponseWriter, r *http.Request) {
+	user, _ := authenticatedUser(r)
+
+	data, err := a.Users.ExportData(r.Context(), user.ID)
+	if err != nil {
+		a.serverError(w, r, "Failed to export user data.", err, "userID", user.ID)
+		return
+	}
+
+	body, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		a.serverError(w, r, "Failed to encode user data.", err, "userID", user.ID)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="secret-santa-data.json"`)
+	w.Write(body)
+}
+
+func (a *Application) accountDeletePost(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		a.badRequest(w, r)
+		return
+	}
+
+	user, _ := authenticatedUser(r)
+
+	err := a.Users.Delete(r.Context(), user.ID, r.PostFormValue("delete_password"))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			data := a.templateData(r)
+			data.Form = accountEmailForm{}
+			data.Errors = map[string]i18n.Message{"delete_password": i18n.NewMessage("Password is incorrect.")}
+
+			a.renderStatus(w, r, http.StatusUnprocessableEntity, "account.html", data)
+			return
+		}
+
+		a.serverError(w, r, "Failed to delete user.", err, "userID", user.ID)
+		return
+	}
+
+	// The session was deleted along with the user, so the cookie is no longer useful.
+	http.SetCookie(w, a.sessionCookie("", -1))
+	http.Redirect(w, r, "/account/deleted", http.StatusSeeOther)
+}
+
+func (a *Application) accountDeleted(w http.ResponseWriter, r *http.Request) {
+	a.render(w, r, "account-deleted.html", a.templateData(r))
+}
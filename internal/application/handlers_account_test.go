@@ -0,0 +1,322 @@
+package application_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/cdriehuys/secret-santa/internal/application/testutils"
+	"github.com/cdriehuys/secret-santa/internal/models"
+	"github.com/cdriehuys/secret-santa/internal/models/mocks"
+	"github.com/google/uuid"
+)
+
+var testUser = models.User{ID: uuid.MustParse("5f2b1d9e-3c4a-4e8b-9a0d-6f7e8c9b0a1d"), Email: "test@example.com"}
+
+func TestApplication_accountGet(t *testing.T) {
+	testCases := []struct {
+		name         string
+		loggedIn     bool
+		wantStatus   int
+		wantRedirect string
+	}{
+		{
+			name:       "logged in",
+			loggedIn:   true,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:         "anonymous",
+			wantStatus:   http.StatusSeeOther,
+			wantRedirect: "/login",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			app := testutils.NewTestApplication(t)
+			ts := testutils.NewTestServer(t, app.Routes())
+			defer ts.Close()
+
+			if tt.loggedIn {
+				logIn(t, app, ts, testUser)
+			}
+
+			res := ts.Get(t, "/account")
+
+			if res.Status != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, res.Status)
+			}
+
+			if got := res.Headers.Get("Location"); got != tt.wantRedirect {
+				t.Errorf("Expected redirect to %q, got %q", tt.wantRedirect, got)
+			}
+
+			if tt.loggedIn {
+				assertContains(t, res.Body, testUser.Email)
+			}
+		})
+	}
+}
+
+func TestApplication_accountEmailPost(t *testing.T) {
+	testCases := []struct {
+		name        string
+		users       mocks.UserModel
+		email       string
+		wantStatus  int
+		wantChanged string
+	}{
+		{
+			name:        "valid email",
+			email:       "new@example.com",
+			wantStatus:  http.StatusSeeOther,
+			wantChanged: "new@example.com",
+		},
+		{
+			name:       "missing email",
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "change error",
+			users: mocks.UserModel{
+				ChangeEmailError: errors.New("change failed"),
+			},
+			email:       "new@example.com",
+			wantStatus:  http.StatusInternalServerError,
+			wantChanged: "new@example.com",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			app := testutils.NewTestApplication(t)
+			app.Users = &tt.users
+
+			ts := testutils.NewTestServer(t, app.Routes())
+			defer ts.Close()
+
+			logIn(t, app, ts, testUser)
+
+			form := csrfFormValues(t, app, ts, "/account")
+			form.Add("email", tt.email)
+
+			res := ts.PostForm(t, "/account/email", form)
+
+			if res.Status != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, res.Status)
+			}
+
+			if got := tt.users.ChangedEmail; got != tt.wantChanged {
+				t.Errorf("Expected email change to %q, got %q", tt.wantChanged, got)
+			}
+
+			if tt.wantChanged != "" && tt.users.ChangedEmailUserID != testUser.ID {
+				t.Errorf("Expected email change for user %v, got %v", testUser.ID, tt.users.ChangedEmailUserID)
+			}
+		})
+	}
+}
+
+func TestApplication_accountPasswordPost(t *testing.T) {
+	testCases := []struct {
+		name            string
+		users           mocks.UserModel
+		currentPassword string
+		newPassword     string
+		wantStatus      int
+		wantChanged     bool
+	}{
+		{
+			name:            "valid change",
+			currentPassword: "old-password",
+			newPassword:     "new-password",
+			wantStatus:      http.StatusSeeOther,
+			wantChanged:     true,
+		},
+		{
+			name:            "new password too short",
+			currentPassword: "old-password",
+			newPassword:     "short",
+			wantStatus:      http.StatusUnprocessableEntity,
+		},
+		{
+			name: "incorrect current password",
+			users: mocks.UserModel{
+				ChangePasswordError: models.ErrInvalidCredentials,
+			},
+			currentPassword: "wrong-password",
+			newPassword:     "new-password",
+			wantStatus:      http.StatusUnprocessableEntity,
+			wantChanged:     true,
+		},
+		{
+			name: "change error",
+			users: mocks.UserModel{
+				ChangePasswordError: errors.New("change failed"),
+			},
+			currentPassword: "old-password",
+			newPassword:     "new-password",
+			wantStatus:      http.StatusInternalServerError,
+			wantChanged:     true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			app := testutils.NewTestApplication(t)
+			app.Users = &tt.users
+
+			ts := testutils.NewTestServer(t, app.Routes())
+			defer ts.Close()
+
+			logIn(t, app, ts, testUser)
+
+			form := csrfFormValues(t, app, ts, "/account")
+			form.Add("current_password", tt.currentPassword)
+			form.Add("new_password", tt.newPassword)
+
+			res := ts.PostForm(t, "/account/password", form)
+
+			if res.Status != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, res.Status)
+			}
+
+			changed := tt.users.ChangedPasswordUserID == testUser.ID
+			if changed != tt.wantChanged {
+				t.Errorf("Expected password change attempted=%v, got %v", tt.wantChanged, changed)
+			}
+
+			if tt.wantChanged {
+				if tt.users.ChangedPasswordFrom != tt.currentPassword || tt.users.ChangedPasswordTo != tt.newPassword {
+					t.Errorf("Expected password change from %q to %q, got %q to %q", tt.currentPassword, tt.newPassword, tt.users.ChangedPasswordFrom, tt.users.ChangedPasswordTo)
+				}
+
+				if want := "test-session-" + testUser.ID.String(); tt.users.ChangedPasswordSession != want {
+					t.Errorf("Expected session %q to be kept, got %q", want, tt.users.ChangedPasswordSession)
+				}
+			}
+		})
+	}
+}
+
+func TestApplication_accountExportGet(t *testing.T) {
+	testCases := []struct {
+		name       string
+		users      mocks.UserModel
+		wantStatus int
+	}{
+		{
+			name: "successful export",
+			users: mocks.UserModel{
+				ExportedData: models.UserData{User: models.UserDataAccount{ID: testUser.ID, Email: testUser.Email}},
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "export error",
+			users: mocks.UserModel{
+				ExportError: errors.New("query failed"),
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			app := testutils.NewTestApplication(t)
+			app.Users = &tt.users
+
+			ts := testutils.NewTestServer(t, app.Routes())
+			defer ts.Close()
+
+			logIn(t, app, ts, testUser)
+
+			res := ts.Get(t, "/account/export")
+
+			if res.Status != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, res.Status)
+			}
+
+			if got := tt.users.ExportedUserID; got != testUser.ID {
+				t.Errorf("Expected export for user %v, got %v", testUser.ID, got)
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			if got := res.Headers.Get("Content-Type"); got != "application/json" {
+				t.Errorf("Expected JSON content type, got %q", got)
+			}
+
+			var got models.UserData
+			if err := json.Unmarshal([]byte(res.Body), &got); err != nil {
+				t.Fatalf("Failed to decode export: %v", err)
+			}
+
+			if got.User.Email != testUser.Email {
+				t.Errorf("Expected exported email %q, got %q", testUser.Email, got.User.Email)
+			}
+		})
+	}
+}
+
+func TestApplication_accountDeletePost(t *testing.T) {
+	testCases := []struct {
+		name         string
+		users        mocks.UserModel
+		wantStatus   int
+		wantRedirect string
+	}{
+		{
+			name:         "correct password",
+			wantStatus:   http.StatusSeeOther,
+			wantRedirect: "/account/deleted",
+		},
+		{
+			name: "incorrect password",
+			users: mocks.UserModel{
+				DeleteError: models.ErrInvalidCredentials,
+			},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "delete error",
+			users: mocks.UserModel{
+				DeleteError: errors.New("delete failed"),
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			app := testutils.NewTestApplication(t)
+			app.Users = &tt.users
+
+			ts := testutils.NewTestServer(t, app.Routes())
+			defer ts.Close()
+
+			logIn(t, app, ts, testUser)
+
+			form := csrfFormValues(t, app, ts, "/account")
+			form.Add("delete_password", "tops3cret")
+
+			res := ts.PostForm(t, "/account/delete", form)
+
+			if res.Status != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, res.Status)
+			}
+
+			if got := res.Headers.Get("Location"); got != tt.wantRedirect {
+				t.Errorf("Expected redirect to %q, got %q", tt.wantRedirect, got)
+			}
+
+			if tt.users.DeletedUserID != testUser.ID || tt.users.DeletePassword != "tops3cret" {
+				t.Errorf("Expected deletion of user %v with submitted password, got %v/%q", testUser.ID, tt.users.DeletedUserID, tt.users.DeletePassword)
+			}
+		})
+	}
+}
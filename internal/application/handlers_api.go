@@ -0,0 +1,313 @@
+package application
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"mime"
+	"net/http"
+
+	"github.com/cdriehuys/secret-santa/internal/i18n"
+	"github.com/cdriehuys/secret-santa/internal/pairings"
+	"github.com/cdriehuys/secret-santa/internal/roster"
+	"github.com/cdriehuys/secret-santa/ui"
+)
+
+// maxAPIRequestBytes limits the size of API request bodies. It comfortably fits the default limit of
+// participants with long names and several exclusions each.
+const maxAPIRequestBytes = 64 * 1024
+
+// Error codes returned by the API. They are part of the API contract described in
+// ui/api/openapi.yaml, so they must not change.
+const (
+	apiErrorInternal             = "internal_error"
+	apiErrorInvalidCSV           = "invalid_csv"
+	apiErrorInvalidJSON          = "invalid_json"
+	apiErrorInvalidRequest       = "invalid_request"
+	apiErrorNoValidPairings      = "no_valid_pairings"
+	apiErrorRequestTooLarge      = "request_too_large"
+	apiErrorSearchExhausted      = "search_exhausted"
+	apiErrorTooFewParticipants   = "too_few_participants"
+	apiErrorUnsupportedMediaType = "unsupported_media_type"
+)
+
+// APIParticipant is a participant in the pairings API. It is exported, along with the pairings
+// response, so the draw command can read and write files in the same format. The YAML tags are for
+// the draw command's YAML files.
+type APIParticipant struct {
+	Name       string   `json:"name" yaml:"name"`
+	Email      string   `json:"email,omitempty" yaml:"email"`
+	Household  string   `json:"household,omitempty" yaml:"household"`
+	Exclusions []string `json:"exclusions,omitempty" yaml:"exclusions"`
+}
+
+var apiParticipantFields = participantFields{
+	List:       "participants",
+	Name:       "participants[%d].name",
+	Email:      "participants[%d].email",
+	Exclusions: "participants[%d].exclusions",
+	Exclusion:  "participants[%d].exclusions[%d]",
+}
+
+type apiPairingOptions struct {
+	Seed *int64 `json:"seed"`
+}
+
+type apiPairingsRequest struct {
+	Participants []APIParticipant  `json:"participants"`
+	Options      apiPairingOptions `json:"options"`
+}
+
+type APIPairing struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type APIPairingsResponse struct {
+	Pairings []APIPairing `json:"pairings"`
+
+	// Seed reproduces the draw when sent back in the request options.
+	Seed int64 `json:"seed"`
+}
+
+// apiError describes an error returned by the API. Its messages are translated into the request's
+// locale when the response is written.
+type apiError struct {
+	Code    string
+	Message i18n.Message
+
+	// Fields maps request fields, such as "participants[1].name", to what is wrong with them.
+	Fields map[string]i18n.Message
+
+	// Rows lists the problems with the rows of an imported file.
+	Rows []roster.ImportError
+}
+
+// apiErrorBody is an apiError as it is sent to the client.
+type apiErrorBody struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+	Rows    []apiRowError     `json:"rows,omitempty"`
+}
+
+type apiRowError struct {
+	Row     int    `json:"row,omitempty"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+type apiImportResponse struct {
+	Participants []APIParticipant `json:"participants"`
+}
+
+type apiErrorResponse struct {
+	Error apiErrorBody `json:"error"`
+}
+
+func (a *Application) apiPairingsPost(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		a.apiError(w, r, http.StatusUnsupportedMediaType, apiError{
+			Code:    apiErrorUnsupportedMediaType,
+			Message: i18n.NewMessage("Requests must be sent as application/json."),
+		})
+		return
+	}
+
+	var req apiPairingsRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			a.apiError(w, r, http.StatusRequestEntityTooLarge, apiError{
+				Code:    apiErrorRequestTooLarge,
+				Message: i18n.NewMessage("Request body must not be larger than %d bytes.", tooLarge.Limit),
+			})
+			return
+		}
+
+		a.apiError(w, r, http.StatusBadRequest, apiError{
+			Code:    apiErrorInvalidJSON,
+			Message: i18n.NewMessage("The request body is not valid JSON: %v", err),
+		})
+		return
+	}
+
+	participants := make([]roster.Participant, len(req.Participants))
+	for i, p := range req.Participants {
+		participants[i] = roster.Participant(p)
+	}
+
+	restrictions, errs := roster.Validate(participants, a.pairingLimits())
+	if len(errs) > 0 {
+		a.apiError(w, r, http.StatusUnprocessableEntity, apiError{
+			Code:    apiErrorInvalidRequest,
+			Message: i18n.NewMessage("The request contains invalid participants."),
+			Fields:  apiParticipantFields.errorMap(errs),
+		})
+		return
+	}
+
+	seed := rand.Int64()
+	if req.Options.Seed != nil {
+		seed = *req.Options.Seed
+	}
+
+	pairs, err := a.PairingGenerator(r.Context(), restrictions, PairingOptions{Seed: &seed})
+	if err != nil {
+		switch {
+		case errors.Is(err, pairings.ErrTooFewNodes):
+			a.apiError(w, r, http.StatusUnprocessableEntity, apiError{
+				Code:    apiErrorTooFewParticipants,
+				Message: i18n.NewMessage("At least two participants are required."),
+			})
+		case errors.Is(err, pairings.ErrNoPath):
+			a.apiError(w, r, http.StatusUnprocessableEntity, apiError{
+				Code:    apiErrorNoValidPairings,
+				Message: i18n.NewMessage("No pairings satisfy the exclusions. Try removing some exclusions."),
+			})
+		case errors.Is(err, pairings.ErrSearchExhausted):
+			a.apiError(w, r, http.StatusServiceUnavailable, apiError{
+				Code:    apiErrorSearchExhausted,
+				Message: i18n.NewMessage("Pairings couldn't be found in time. Try again with another seed, or remove some exclusions."),
+			})
+		default:
+			a.Logger.ErrorContext(r.Context(), "Failed to generate pairings.", "error", err)
+			a.apiError(w, r, http.StatusInternalServerError, apiError{
+				Code:    apiErrorInternal,
+				Message: i18n.NewMessage("Failed to generate pairings."),
+			})
+		}
+
+		return
+	}
+
+	a.writeJSON(w, r, http.StatusOK, APIPairingsResponse{Pairings: NewAPIPairings(pairs), Seed: seed})
+}
+
+// apiParticipantsImportPost parses participants from a CSV file. The participants are returned
+// without drawing pairings so they can be reviewed, and then sent to apiPairingsPost as they are.
+func (a *Application) apiParticipantsImportPost(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "text/csv" {
+		a.apiError(w, r, http.StatusUnsupportedMediaType, apiError{
+			Code:    apiErrorUnsupportedMediaType,
+			Message: i18n.NewMessage("Files must be sent as text/csv."),
+		})
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			a.apiError(w, r, http.StatusRequestEntityTooLarge, apiError{
+				Code:    apiErrorRequestTooLarge,
+				Message: i18n.NewMessage("Request body must not be larger than %d bytes.", tooLarge.Limit),
+			})
+			return
+		}
+
+		a.apiError(w, r, http.StatusBadRequest, apiError{
+			Code:    apiErrorInvalidCSV,
+			Message: i18n.NewMessage("The request body could not be read."),
+		})
+		return
+	}
+
+	participants, errs := roster.ParseCSV(bytes.NewReader(body))
+	if len(errs) == 0 {
+		_, errs = roster.ValidateImported(participants, a.pairingLimits())
+	}
+
+	if len(errs) > 0 {
+		a.apiError(w, r, http.StatusUnprocessableEntity, apiError{
+			Code:    apiErrorInvalidCSV,
+			Message: i18n.NewMessage("The file contains invalid participants."),
+			Rows:    errs,
+		})
+		return
+	}
+
+	res := apiImportResponse{Participants: make([]APIParticipant, len(participants))}
+	for i, p := range participants {
+		res.Participants[i] = APIParticipant(p.Participant)
+	}
+
+	a.writeJSON(w, r, http.StatusOK, res)
+}
+
+// NewAPIPairings converts pairings into the format returned by the API.
+func NewAPIPairings(pairs []pairings.Pairing) []APIPairing {
+	converted := make([]APIPairing, len(pairs))
+	for i, pair := range pairs {
+		converted[i] = APIPairing{From: pair.From, To: pair.To}
+	}
+
+	return converted
+}
+
+// apiOpenAPIGet serves the OpenAPI description of the API.
+func (a *Application) apiOpenAPIGet(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(ui.OpenAPI)
+}
+
+// decodeJSON decodes a single JSON value from a request body of limited size. Unknown fields are
+// rejected so that typos in optional fields aren't silently ignored.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIRequestBytes))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(dst); err != nil {
+		return err
+	}
+
+	if err := decoder.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return err
+		}
+
+		return errors.New("request body must only contain a single JSON object")
+	}
+
+	return nil
+}
+
+func (a *Application) writeJSON(w http.ResponseWriter, r *http.Request, status int, data any) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		a.Logger.ErrorContext(r.Context(), "Failed to encode JSON response.", "error", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+func (a *Application) apiError(w http.ResponseWriter, r *http.Request, status int, err apiError) {
+	locale := requestLocale(r.Context())
+
+	body := apiErrorBody{
+		Code:    err.Code,
+		Message: err.Message.Translate(locale),
+	}
+
+	if len(err.Fields) > 0 {
+		body.Fields = make(map[string]string, len(err.Fields))
+		for field, msg := range err.Fields {
+			body.Fields[field] = msg.Translate(locale)
+		}
+	}
+
+	for _, row := range err.Rows {
+		body.Rows = append(body.Rows, apiRowError{Row: row.Row, Column: row.Column, Message: row.Message.Translate(locale)})
+	}
+
+	a.writeJSON(w, r, status, apiErrorResponse{Error: body})
+}
@@ -0,0 +1,436 @@
+package application_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cdriehuys/secret-santa/internal/application"
+	"github.com/cdriehuys/secret-santa/internal/application/testutils"
+	"github.com/cdriehuys/secret-santa/internal/pairings"
+)
+
+type apiErrorBody struct {
+	Error struct {
+		Code    string            `json:"code"`
+		Message string            `json:"message"`
+		Fields  map[string]string `json:"fields"`
+	} `json:"error"`
+}
+
+// capturingPairingGenerator records what it was called with and returns a fixed result.
+type capturingPairingGenerator struct {
+	pairs []pairings.Pairing
+	err   error
+
+	called       bool
+	restrictions application.GiftRestrictions
+	options      application.PairingOptions
+}
+
+func (g *capturingPairingGenerator) Generate(_ context.Context, restrictions application.GiftRestrictions, options application.PairingOptions) ([]pairings.Pairing, error) {
+	g.called = true
+	g.restrictions = restrictions
+	g.options = options
+
+	return g.pairs, g.err
+}
+
+func postBody(t *testing.T, ts *testutils.TestServer, path string, contentType string, body string) testutils.TestResponse {
+	res, err := ts.Client().Post(ts.URL+path, contentType, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to send request to %q: %v", path, err)
+	}
+
+	defer res.Body.Close()
+
+	return testutils.MakeTestResponse(t, res)
+}
+
+func TestApplication_apiPairingsPost(t *testing.T) {
+	testCases := []struct {
+		name        string
+		contentType string
+		body        string
+		generator   capturingPairingGenerator
+
+		wantStatus       int
+		wantCode         string
+		wantFields       map[string]string
+		wantRestrictions application.GiftRestrictions
+		wantSeed         int64
+	}{
+		{
+			name:        "success",
+			contentType: "application/json",
+			body:        `{"participants": [{"name": " Ross ", "exclusions": ["Joey"]}, {"name": "Joey"}, {"name": "Chandler"}], "options": {"seed": 42}}`,
+			generator: capturingPairingGenerator{
+				pairs: []pairings.Pairing{{From: "Ross", To: "Chandler"}, {From: "Chandler", To: "Joey"}, {From: "Joey", To: "Ross"}},
+			},
+			wantStatus: http.StatusOK,
+			wantRestrictions: application.GiftRestrictions{
+				"Ross":     {"Joey"},
+				"Joey":     nil,
+				"Chandler": nil,
+			},
+			wantSeed: 42,
+		},
+		{
+			name:        "content type with charset",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"participants": [{"name": "Ross"}, {"name": "Joey"}]}`,
+			generator: capturingPairingGenerator{
+				pairs: []pairings.Pairing{{From: "Ross", To: "Joey"}, {From: "Joey", To: "Ross"}},
+			},
+			wantStatus:       http.StatusOK,
+			wantRestrictions: application.GiftRestrictions{"Ross": nil, "Joey": nil},
+		},
+		{
+			name:        "households",
+			contentType: "application/json",
+			body:        `{"participants": [{"name": "Ross", "household": "Geller"}, {"name": "Monica", "email": "monica@example.com", "household": "Geller"}, {"name": "Joey"}, {"name": "Chandler"}]}`,
+			generator: capturingPairingGenerator{
+				pairs: []pairings.Pairing{{From: "Ross", To: "Joey"}, {From: "Joey", To: "Monica"}, {From: "Monica", To: "Chandler"}, {From: "Chandler", To: "Ross"}},
+			},
+			wantStatus: http.StatusOK,
+			wantRestrictions: application.GiftRestrictions{
+				"Ross":     {"Monica"},
+				"Monica":   {"Ross"},
+				"Joey":     nil,
+				"Chandler": nil,
+			},
+		},
+		{
+			name:        "form content type",
+			contentType: "application/x-www-form-urlencoded",
+			body:        `name[0]=Ross`,
+			wantStatus:  http.StatusUnsupportedMediaType,
+			wantCode:    "unsupported_media_type",
+		},
+		{
+			name:        "invalid JSON",
+			contentType: "application/json",
+			body:        `{"participants": [`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    "invalid_json",
+		},
+		{
+			name:        "unknown field",
+			contentType: "application/json",
+			body:        `{"participants": [], "optoins": {}}`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    "invalid_json",
+		},
+		{
+			name:        "multiple values",
+			contentType: "application/json",
+			body:        `{"participants": []} {"participants": []}`,
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    "invalid_json",
+		},
+		{
+			name:        "too large",
+			contentType: "application/json",
+			body:        `{"participants": [{"name": "` + strings.Repeat("a", 70*1024) + `"}]}`,
+			wantStatus:  http.StatusRequestEntityTooLarge,
+			wantCode:    "request_too_large",
+		},
+		{
+			name:        "invalid participants",
+			contentType: "application/json",
+			body: `{"participants": [
+				{"name": "Ross", "exclusions": ["Monica", "Ross"]},
+				{"name": " "},
+				{"name": "Ross"},
+				{"name": "Joey", "exclusions": ["Ross", "Ross", "Ross", "Ross"]}
+			]}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   "invalid_request",
+			wantFields: map[string]string{
+				"participants[0].exclusions[0]": `"Monica" is not a participant.`,
+				"participants[0].exclusions[1]": "Participants are already excluded from drawing themselves.",
+				"participants[1].name":          "Name is required.",
+				"participants[2].name":          `"Ross" is listed more than once.`,
+				"participants[3].exclusions":    "There can be at most 3 exclusions.",
+			},
+		},
+		{
+			name:        "too few participants",
+			contentType: "application/json",
+			body:        `{"participants": [{"name": "Ross"}]}`,
+			generator:   capturingPairingGenerator{err: pairings.ErrTooFewNodes},
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantCode:    "too_few_participants",
+		},
+		{
+			name:        "no valid pairings",
+			contentType: "application/json",
+			body:        `{"participants": [{"name": "Ross", "exclusions": ["Joey"]}, {"name": "Joey"}, {"name": "Chandler"}]}`,
+			generator:   capturingPairingGenerator{err: pairings.ErrNoPath},
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantCode:    "no_valid_pairings",
+		},
+		{
+			name:        "search exhausted",
+			contentType: "application/json",
+			body:        `{"participants": [{"name": "Ross"}, {"name": "Joey"}]}`,
+			generator:   capturingPairingGenerator{err: pairings.ErrSearchExhausted},
+			wantStatus:  http.StatusServiceUnavailable,
+			wantCode:    "search_exhausted",
+		},
+		{
+			name:        "generator error",
+			contentType: "application/json",
+			body:        `{"participants": [{"name": "Ross"}, {"name": "Joey"}]}`,
+			generator:   capturingPairingGenerator{err: errors.New("out of coal")},
+			wantStatus:  http.StatusInternalServerError,
+			wantCode:    "internal_error",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			app := testutils.NewTestApplication(t)
+			app.PairingGenerator = tt.generator.Generate
+
+			ts := testutils.NewTestServer(t, app.Routes())
+			defer ts.Close()
+
+			res := postBody(t, ts, "/api/pairings", tt.contentType, tt.body)
+
+			if res.Status != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, res.Status, res.Body)
+			}
+
+			if got := res.Headers.Get("Content-Type"); got != "application/json" {
+				t.Errorf("Expected JSON response, got %q", got)
+			}
+
+			if tt.wantCode != "" {
+				var body apiErrorBody
+				if err := json.Unmarshal([]byte(res.Body), &body); err != nil {
+					t.Fatalf("failed to decode error response %q: %v", res.Body, err)
+				}
+
+				if body.Error.Code != tt.wantCode {
+					t.Errorf("Expected error code %q, got %q", tt.wantCode, body.Error.Code)
+				}
+
+				if body.Error.Message == "" {
+					t.Error("Expected an error message")
+				}
+
+				if tt.wantFields != nil && !reflect.DeepEqual(body.Error.Fields, tt.wantFields) {
+					t.Errorf("Expected field errors %v, got %v", tt.wantFields, body.Error.Fields)
+				}
+
+				return
+			}
+
+			var body struct {
+				Pairings []struct {
+					From string `json:"from"`
+					To   string `json:"to"`
+				} `json:"pairings"`
+				Seed int64 `json:"seed"`
+			}
+			if err := json.Unmarshal([]byte(res.Body), &body); err != nil {
+				t.Fatalf("failed to decode response %q: %v", res.Body, err)
+			}
+
+			if len(body.Pairings) != len(tt.generator.pairs) {
+				t.Fatalf("Expected %d pairings, got %d", len(tt.generator.pairs), len(body.Pairings))
+			}
+
+			for i, want := range tt.generator.pairs {
+				if got := body.Pairings[i]; got.From != want.From || got.To != want.To {
+					t.Errorf("Expected pairing %d to be %v, got %v", i, want, got)
+				}
+			}
+
+			if !reflect.DeepEqual(tt.generator.restrictions, tt.wantRestrictions) {
+				t.Errorf("Expected restrictions %v, got %v", tt.wantRestrictions, tt.generator.restrictions)
+			}
+
+			if tt.generator.options.Seed == nil || *tt.generator.options.Seed != body.Seed {
+				t.Errorf("Expected response seed %d to be passed to the generator, got %v", body.Seed, tt.generator.options.Seed)
+			}
+
+			if tt.wantSeed != 0 && body.Seed != tt.wantSeed {
+				t.Errorf("Expected seed %d, got %d", tt.wantSeed, body.Seed)
+			}
+		})
+	}
+}
+
+func TestApplication_apiPairingsPost_reproducible(t *testing.T) {
+	app := testutils.NewTestApplication(t)
+	app.PairingGenerator = func(ctx context.Context, restrictions application.GiftRestrictions, options application.PairingOptions) ([]pairings.Pairing, error) {
+		return pairings.NewGraphFromExclusions(restrictions).Pairings(ctx, rand.New(rand.NewSource(*options.Seed)), 0)
+	}
+
+	ts := testutils.NewTestServer(t, app.Routes())
+	defer ts.Close()
+
+	body := `{"participants": [{"name": "A"}, {"name": "B"}, {"name": "C"}, {"name": "D"}, {"name": "E"}], "options": {"seed": 1225}}`
+
+	first := postBody(t, ts, "/api/pairings", "application/json", body)
+	if first.Status != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, first.Status, first.Body)
+	}
+
+	for range 5 {
+		if again := postBody(t, ts, "/api/pairings", "application/json", body); again.Body != first.Body {
+			t.Fatalf("Expected the same seed to produce %s, got %s", first.Body, again.Body)
+		}
+	}
+}
+
+// TestApplication_apiPairingsPost_impossible checks that a draw that passes validation but has no
+// pairings is reported by the real search. The participants split into two pairs that can only be
+// connected through E, and a cycle through everyone would have to pass through E twice.
+func TestApplication_apiPairingsPost_impossible(t *testing.T) {
+	app := testutils.NewTestApplication(t)
+	app.PairingGenerator = func(ctx context.Context, restrictions application.GiftRestrictions, options application.PairingOptions) ([]pairings.Pairing, error) {
+		return pairings.NewGraphFromExclusions(restrictions).Pairings(ctx, rand.New(rand.NewSource(*options.Seed)), 0)
+	}
+
+	ts := testutils.NewTestServer(t, app.Routes())
+	defer ts.Close()
+
+	body := `{"participants": [
+		{"name": "A", "exclusions": ["C", "D"]},
+		{"name": "B", "exclusions": ["C", "D"]},
+		{"name": "C", "exclusions": ["A", "B"]},
+		{"name": "D", "exclusions": ["A", "B"]},
+		{"name": "E"}
+	]}`
+
+	res := postBody(t, ts, "/api/pairings", "application/json", body)
+	if res.Status != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusUnprocessableEntity, res.Status, res.Body)
+	}
+
+	assertContains(t, res.Body, `"no_valid_pairings"`)
+}
+
+func TestApplication_apiPairingsPost_localized(t *testing.T) {
+	app := testutils.NewTestApplication(t)
+	ts := testutils.NewTestServer(t, app.Routes())
+	defer ts.Close()
+
+	body := `{"participants": [{"name": "Ross"}, {"name": "Ross"}]}`
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/api/pairings", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Language", "de")
+
+	rawRes, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+
+	defer rawRes.Body.Close()
+
+	res := testutils.MakeTestResponse(t, rawRes)
+	if res.Status != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusUnprocessableEntity, res.Status, res.Body)
+	}
+
+	var errBody apiErrorBody
+	if err := json.Unmarshal([]byte(res.Body), &errBody); err != nil {
+		t.Fatalf("failed to decode error body %q: %v", res.Body, err)
+	}
+
+	if want := "Die Anfrage enthält ungültige Teilnehmende."; errBody.Error.Message != want {
+		t.Errorf("Expected message %q, got %q", want, errBody.Error.Message)
+	}
+
+	wantFields := map[string]string{"participants[1].name": `"Ross" ist mehrfach aufgeführt.`}
+	if !reflect.DeepEqual(errBody.Error.Fields, wantFields) {
+		t.Errorf("Expected fields %v, got %v", wantFields, errBody.Error.Fields)
+	}
+}
+
+func TestApplication_apiParticipantsImportPost(t *testing.T) {
+	testCases := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int
+		wantBody    string
+	}{
+		{
+			name:        "success",
+			contentType: "text/csv; charset=utf-8",
+			body:        "name,email,household,exclusions\nRoss,ross@example.com,Geller,Joey\nMonica,,Geller,\nJoey,,,\nChandler,,,",
+			wantStatus:  http.StatusOK,
+			wantBody:    `{"participants":[{"name":"Ross","email":"ross@example.com","household":"Geller","exclusions":["Joey"]},{"name":"Monica","household":"Geller"},{"name":"Joey"},{"name":"Chandler"}]}`,
+		},
+		{
+			name:        "invalid rows",
+			contentType: "text/csv",
+			body:        "name,exclusions\nRoss,Monica\nRoss,",
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantBody:    `{"error":{"code":"invalid_csv","message":"The file contains invalid participants.","rows":[{"row":2,"column":"exclusions","message":"\"Monica\" is not a participant."},{"row":3,"column":"name","message":"\"Ross\" is listed more than once."}]}}`,
+		},
+		{
+			name:        "missing name column",
+			contentType: "text/csv",
+			body:        "person\nRoss",
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantBody:    `{"error":{"code":"invalid_csv","message":"The file contains invalid participants.","rows":[{"message":"The first row must name the columns and include a \"name\" column."}]}}`,
+		},
+		{
+			name:        "JSON content type",
+			contentType: "application/json",
+			body:        `{"participants": []}`,
+			wantStatus:  http.StatusUnsupportedMediaType,
+			wantBody:    `"code":"unsupported_media_type"`,
+		},
+		{
+			name:        "too large",
+			contentType: "text/csv",
+			body:        "name\n" + strings.Repeat("Ross\n", 300_000),
+			wantStatus:  http.StatusRequestEntityTooLarge,
+			wantBody:    `"code":"request_too_large"`,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			app := testutils.NewTestApplication(t)
+			ts := testutils.NewTestServer(t, app.Routes())
+			defer ts.Close()
+
+			res := postBody(t, ts, "/api/participants/import", tt.contentType, tt.body)
+
+			if res.Status != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, res.Status)
+			}
+
+			assertContains(t, res.Body, tt.wantBody)
+		})
+	}
+}
+
+func TestApplication_apiOpenAPIGet(t *testing.T) {
+	app := testutils.NewTestApplication(t)
+
+	ts := testutils.NewTestServer(t, app.Routes())
+	defer ts.Close()
+
+	res := ts.Get(t, "/api/openapi.yaml")
+
+	if res.Status != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, res.Status)
+	}
+
+	assertContains(t, res.Body, "/api/pairings:")
+}
@@ -1,9 +1,12 @@
 package application
 
 import (
+	"errors"
 	"net/http"
 
+	"github.com/cdriehuys/secret-santa/internal/i18n"
 	"github.com/cdriehuys/secret-santa/internal/models"
+	"github.com/google/uuid"
 )
 
 func (a *Application) registerGet(w http.ResponseWriter, r *http.Request) {
@@ -30,3 +33,163 @@ func (a *Application) registerPost(w http.ResponseWriter, r *http.Request) {
 func (a *Application) registerSuccess(w http.ResponseWriter, r *http.Request) {
 	a.render(w, r, "register-success.html", a.templateData(r))
 }
+
+type loginForm struct {
+	Email string
+}
+
+func (a *Application) loginGet(w http.ResponseWriter, r *http.Request) {
+	data := a.templateData(r)
+	data.Form = loginForm{}
+
+	a.render(w, r, "login.html", data)
+}
+
+func (a *Application) loginPost(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		a.badRequest(w, r)
+		return
+	}
+
+	form := loginForm{Email: r.PostFormValue("email")}
+
+	userID, err := a.Users.Authenticate(r.Context(), form.Email, r.PostFormValue("password"))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			data := a.templateData(r)
+			data.Form = form
+			data.Errors = map[string]i18n.Message{"form": i18n.NewMessage("Email or password is incorrect.")}
+
+			a.renderStatus(w, r, http.StatusUnprocessableEntity, "login.html", data)
+			return
+		}
+
+		a.serverError(w, r, "Failed to authenticate user.", err)
+		return
+	}
+
+	twoFactorEnabled, err := a.TwoFactor.Enabled(r.Context(), userID)
+	if err != nil {
+		a.serverError(w, r, "Failed to check for two-factor authentication.", err, "userID", userID)
+		return
+	}
+
+	if twoFactorEnabled {
+		token, err := a.Sessions.CreatePending(r.Context(), userID)
+		if err != nil {
+			a.serverError(w, r, "Failed to create pending session.", err, "userID", userID)
+			return
+		}
+
+		http.SetCookie(w, a.pendingSessionCookie(token, int(models.PendingSessionLifetime.Seconds())))
+		http.Redirect(w, r, "/login/two-factor", http.StatusSeeOther)
+		return
+	}
+
+	a.startSession(w, r, userID)
+}
+
+// startSession logs the user in and sends them to their account.
+func (a *Application) startSession(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	token, err := a.Sessions.Create(r.Context(), userID)
+	if err != nil {
+		a.serverError(w, r, "Failed to create session.", err, "userID", userID)
+		return
+	}
+
+	http.SetCookie(w, a.sessionCookie(token, int(models.SessionLifetime.Seconds())))
+	http.Redirect(w, r, "/account", http.StatusSeeOther)
+}
+
+// pendingUser returns the user who has entered their password but still needs to provide a second
+// factor. If there is no such user, they are redirected to the login page and ok is false.
+func (a *Application) pendingUser(w http.ResponseWriter, r *http.Request) (user models.User, token string, ok bool) {
+	cookie, err := r.Cookie(pendingSessionCookieName)
+	if err != nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return models.User{}, "", false
+	}
+
+	user, err = a.Sessions.PendingUser(r.Context(), cookie.Value)
+	if err != nil {
+		if !errors.Is(err, models.ErrNoSession) {
+			a.serverError(w, r, "Failed to load pending session.", err)
+			return models.User{}, "", false
+		}
+
+		http.SetCookie(w, a.pendingSessionCookie("", -1))
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return models.User{}, "", false
+	}
+
+	return user, cookie.Value, true
+}
+
+func (a *Application) loginTwoFactorGet(w http.ResponseWriter, r *http.Request) {
+	if _, _, ok := a.pendingUser(w, r); !ok {
+		return
+	}
+
+	a.render(w, r, "login-two-factor.html", a.templateData(r))
+}
+
+func (a *Application) loginTwoFactorPost(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		a.badRequest(w, r)
+		return
+	}
+
+	user, pendingToken, ok := a.pendingUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := a.TwoFactor.Verify(r.Context(), user.ID, r.PostFormValue("code")); err != nil {
+		if errors.Is(err, models.ErrInvalidCode) {
+			data := a.templateData(r)
+			data.Errors = map[string]i18n.Message{"code": i18n.NewMessage("That code is not valid.")}
+
+			a.renderStatus(w, r, http.StatusUnprocessableEntity, "login-two-factor.html", data)
+			return
+		}
+
+		a.serverError(w, r, "Failed to verify two-factor code.", err, "userID", user.ID)
+		return
+	}
+
+	// The pending session is replaced rather than upgraded so its token can't be reused.
+	if err := a.Sessions.Delete(r.Context(), pendingToken); err != nil {
+		a.serverError(w, r, "Failed to delete pending session.", err, "userID", user.ID)
+		return
+	}
+
+	http.SetCookie(w, a.pendingSessionCookie("", -1))
+	a.startSession(w, r, user.ID)
+}
+
+func (a *Application) logoutPost(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		if err := a.Sessions.Delete(r.Context(), cookie.Value); err != nil {
+			a.serverError(w, r, "Failed to delete session.", err)
+			return
+		}
+	}
+
+	http.SetCookie(w, a.sessionCookie("", -1))
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (a *Application) verifyEmailGet(w http.ResponseWriter, r *http.Request) {
+	err := a.Users.VerifyEmail(r.Context(), r.PathValue("token"))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidToken) || errors.Is(err, models.ErrEmailTaken) {
+			a.renderStatus(w, r, http.StatusBadRequest, "verify-email-invalid.html", a.templateData(r))
+			return
+		}
+
+		a.serverError(w, r, "Failed to verify email.", err)
+		return
+	}
+
+	a.render(w, r, "verify-email-success.html", a.templateData(r))
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/cdriehuys/secret-santa/internal/application/testutils"
 	"github.com/cdriehuys/secret-santa/internal/models"
 	"github.com/cdriehuys/secret-santa/internal/models/mocks"
+	"github.com/google/uuid"
 )
 
 func TestApplication_registerGet(t *testing.T) {
@@ -164,3 +165,149 @@ func TestApplication_registerSuccess(t *testing.T) {
 		})
 	}
 }
+
+func TestApplication_loginPost(t *testing.T) {
+	testCases := []struct {
+		name            string
+		users           mocks.UserModel
+		sessions        mocks.SessionModel
+		wantStatus      int
+		wantRedirect    string
+		wantSessionUser uuid.UUID
+	}{
+		{
+			name:            "valid credentials",
+			users:           mocks.UserModel{AuthenticateUserID: testUser.ID},
+			wantStatus:      http.StatusSeeOther,
+			wantRedirect:    "/account",
+			wantSessionUser: testUser.ID,
+		},
+		{
+			name:       "invalid credentials",
+			users:      mocks.UserModel{AuthenticateError: models.ErrInvalidCredentials},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "authentication error",
+			users:      mocks.UserModel{AuthenticateError: errors.New("lookup failed")},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:       "session error",
+			users:      mocks.UserModel{AuthenticateUserID: testUser.ID},
+			sessions:   mocks.SessionModel{CreateError: errors.New("insert failed")},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			app := testutils.NewTestApplication(t)
+			app.Users = &tt.users
+			app.Sessions = &tt.sessions
+
+			ts := testutils.NewTestServer(t, app.Routes())
+			defer ts.Close()
+
+			form := csrfFormValues(t, app, ts, "/login")
+			form.Add("email", testUser.Email)
+			form.Add("password", "tops3cret")
+
+			res := ts.PostForm(t, "/login", form)
+
+			if res.Status != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, res.Status)
+			}
+
+			if got := res.Headers.Get("Location"); got != tt.wantRedirect {
+				t.Errorf("Expected redirect to %q, got %q", tt.wantRedirect, got)
+			}
+
+			if tt.users.AuthenticateEmail != testUser.Email || tt.users.AuthenticatePassword != "tops3cret" {
+				t.Errorf("Expected authentication with submitted credentials, got %q/%q", tt.users.AuthenticateEmail, tt.users.AuthenticatePassword)
+			}
+
+			var sessionUser uuid.UUID
+			for _, user := range tt.sessions.Sessions {
+				sessionUser = user.ID
+			}
+
+			if sessionUser != tt.wantSessionUser {
+				t.Errorf("Expected session for user %v, got %v", tt.wantSessionUser, sessionUser)
+			}
+		})
+	}
+}
+
+func TestApplication_logoutPost(t *testing.T) {
+	app := testutils.NewTestApplication(t)
+	ts := testutils.NewTestServer(t, app.Routes())
+	defer ts.Close()
+
+	logIn(t, app, ts, testUser)
+	sessions := app.Sessions.(*mocks.SessionModel)
+
+	form := csrfFormValues(t, app, ts, "/account")
+	res := ts.PostForm(t, "/logout", form)
+
+	if res.Status != http.StatusSeeOther {
+		t.Errorf("Expected status %d, got %d", http.StatusSeeOther, res.Status)
+	}
+
+	if len(sessions.Sessions) != 0 {
+		t.Errorf("Expected session to be deleted, found %v", sessions.Sessions)
+	}
+
+	res = ts.Get(t, "/account")
+	if got := res.Headers.Get("Location"); got != "/login" {
+		t.Errorf("Expected logged out user to be redirected to login, got %q", got)
+	}
+}
+
+func TestApplication_verifyEmailGet(t *testing.T) {
+	testCases := []struct {
+		name       string
+		users      mocks.UserModel
+		wantStatus int
+	}{
+		{
+			name:       "valid token",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "invalid token",
+			users:      mocks.UserModel{VerifyEmailError: models.ErrInvalidToken},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "email taken",
+			users:      mocks.UserModel{VerifyEmailError: models.ErrEmailTaken},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "verification error",
+			users:      mocks.UserModel{VerifyEmailError: errors.New("query failed")},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			app := testutils.NewTestApplication(t)
+			app.Users = &tt.users
+
+			ts := testutils.NewTestServer(t, app.Routes())
+			defer ts.Close()
+
+			res := ts.Get(t, "/verify-email/SECRET")
+
+			if res.Status != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, res.Status)
+			}
+
+			if got := tt.users.VerifiedToken; got != "SECRET" {
+				t.Errorf("Expected token %q to be verified, got %q", "SECRET", got)
+			}
+		})
+	}
+}
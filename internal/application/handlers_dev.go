@@ -0,0 +1,85 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cdriehuys/secret-santa/internal/email"
+)
+
+func (a *Application) devMailGet(w http.ResponseWriter, r *http.Request) {
+	messages, err := a.Mailbox.List()
+	if err != nil {
+		a.serverError(w, r, "Failed to list captured emails.", err)
+		return
+	}
+
+	data := a.templateData(r)
+	data.MailMessages = messages
+
+	a.render(w, r, "dev-mail.html", data)
+}
+
+func (a *Application) devMailMessageGet(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	message, err := a.Mailbox.Get(name)
+	if err != nil {
+		if errors.Is(err, email.ErrMessageNotFound) {
+			a.notFound(w, r)
+			return
+		}
+
+		a.serverError(w, r, "Failed to load captured email.", err, "name", name)
+		return
+	}
+
+	data := a.templateData(r)
+	data.MailMessage = message
+
+	a.render(w, r, "dev-mail-message.html", data)
+}
+
+// devReloadGet streams a server-sent event each time the templates are reloaded so the live reload
+// script can refresh the page.
+func (a *Application) devReloadGet(w http.ResponseWriter, r *http.Request) {
+	reloads, unsubscribe := a.TemplateReloads.Subscribe()
+	defer unsubscribe()
+
+	rc := http.NewResponseController(w)
+
+	// The stream stays open until the page is closed, so it's exempt from the server's write
+	// timeout.
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		a.Logger.ErrorContext(r.Context(), "Failed to clear the write deadline for the reload stream.", "error", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	if err := rc.Flush(); err != nil {
+		a.Logger.ErrorContext(r.Context(), "Failed to start reload stream.", "error", err)
+		return
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-a.ShuttingDown:
+			return
+		case <-reloads:
+			if _, err := fmt.Fprint(w, "event: reload\ndata: {}\n\n"); err != nil {
+				return
+			}
+
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
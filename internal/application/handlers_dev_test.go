@@ -0,0 +1,234 @@
+package application_test
+
+import (
+	"bufio"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cdriehuys/secret-santa/internal/application/testutils"
+	"github.com/cdriehuys/secret-santa/internal/email"
+)
+
+func TestApplication_devMail(t *testing.T) {
+	mailbox, err := email.NewMaildirMailer(slog.New(slog.DiscardHandler), t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create mailbox: %v", err)
+	}
+
+	msg := email.Message{
+		To:      "test@example.com",
+		From:    "no-reply@example.com",
+		Subject: "Verify Your Email",
+		Text:    "Visit http://localhost/verify-email/token",
+		HTML:    `<a href="http://localhost/verify-email/token">Verify</a>`,
+	}
+	if err := mailbox.Send(t.Context(), msg); err != nil {
+		t.Fatalf("failed to send email: %v", err)
+	}
+
+	stored, err := mailbox.List()
+	if err != nil || len(stored) != 1 {
+		t.Fatalf("Expected one stored message, got %v (%v)", stored, err)
+	}
+
+	app := testutils.NewTestApplication(t)
+	app.Mailbox = mailbox
+
+	ts := testutils.NewTestServer(t, app.Routes())
+	defer ts.Close()
+
+	list := ts.Get(t, "/dev/mail")
+	if list.Status != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, list.Status)
+	}
+
+	assertContains(t, list.Body, "Verify Your Email")
+	assertContains(t, list.Body, "/dev/mail/"+stored[0].Name)
+
+	detail := ts.Get(t, "/dev/mail/"+stored[0].Name)
+	if detail.Status != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, detail.Status)
+	}
+
+	assertContains(t, detail.Body, "Visit http://localhost/verify-email/token")
+	assertContains(t, detail.Body, "srcdoc=")
+
+	if res := ts.Get(t, "/dev/mail/missing.eml"); res.Status != http.StatusNotFound {
+		t.Errorf("Expected status %d for a missing message, got %d", http.StatusNotFound, res.Status)
+	}
+}
+
+func TestApplication_devMail_remote(t *testing.T) {
+	mailbox, err := email.NewMaildirMailer(slog.New(slog.DiscardHandler), t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create mailbox: %v", err)
+	}
+
+	app := testutils.NewTestApplication(t)
+	app.Mailbox = mailbox
+	app.TrustedProxies = []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}
+
+	ts := testutils.NewTestServer(t, app.Routes())
+	defer ts.Close()
+
+	// A request from another machine, directly and through a proxy on the same machine as the
+	// server.
+	direct := httptest.NewRequest(http.MethodGet, "/dev/mail", nil)
+	direct.RemoteAddr = "203.0.113.5:1234"
+
+	proxied, err := http.NewRequest(http.MethodGet, ts.URL+"/dev/mail", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+
+	proxied.Header.Set("X-Forwarded-For", "203.0.113.5")
+
+	rec := httptest.NewRecorder()
+	app.Routes().ServeHTTP(rec, direct)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for a remote client, got %d", http.StatusNotFound, rec.Code)
+	}
+
+	res, err := ts.Client().Do(proxied)
+	if err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status %d for a remote client behind a proxy, got %d", http.StatusNotFound, res.StatusCode)
+	}
+}
+
+func TestApplication_devMail_disabled(t *testing.T) {
+	app := testutils.NewTestApplication(t)
+
+	ts := testutils.NewTestServer(t, app.Routes())
+	defer ts.Close()
+
+	if res := ts.Get(t, "/dev/mail"); res.Status != http.StatusNotFound {
+		t.Errorf("Expected status %d without a mailbox, got %d", http.StatusNotFound, res.Status)
+	}
+}
+
+// fakeTemplateReloads lets tests trigger a template reload.
+type fakeTemplateReloads struct {
+	reloads chan struct{}
+}
+
+func (f *fakeTemplateReloads) Subscribe() (<-chan struct{}, func()) {
+	return f.reloads, func() {}
+}
+
+func TestApplication_devReload(t *testing.T) {
+	reloads := &fakeTemplateReloads{reloads: make(chan struct{}, 1)}
+
+	app := testutils.NewTestApplication(t)
+	app.TemplateReloads = reloads
+
+	ts := testutils.NewTestServer(t, app.Routes())
+	defer ts.Close()
+
+	page := ts.Get(t, "/login")
+	assertContains(t, page.Body, `new EventSource("/dev/reload")`)
+
+	res, err := ts.Client().Get(ts.URL + "/dev/reload")
+	if err != nil {
+		t.Fatalf("failed to connect to reload stream: %v", err)
+	}
+
+	defer res.Body.Close()
+
+	if got, want := res.Header.Get("Content-Type"), "text/event-stream"; got != want {
+		t.Errorf("Expected content type %q, got %q", want, got)
+	}
+
+	reloads.reloads <- struct{}{}
+
+	event, err := bufio.NewReader(res.Body).ReadString('\n')
+	if err != nil {
+		t.Fatalf("failed to read event: %v", err)
+	}
+
+	if want := "event: reload\n"; event != want {
+		t.Errorf("Expected %q, got %q", want, event)
+	}
+}
+
+func TestApplication_devReload_writeTimeout(t *testing.T) {
+	reloads := &fakeTemplateReloads{reloads: make(chan struct{}, 1)}
+
+	app := testutils.NewTestApplication(t)
+	app.TemplateReloads = reloads
+
+	ts := httptest.NewUnstartedServer(app.Routes())
+	ts.Config.WriteTimeout = 20 * time.Millisecond
+	ts.Start()
+	defer ts.Close()
+
+	res, err := ts.Client().Get(ts.URL + "/dev/reload")
+	if err != nil {
+		t.Fatalf("failed to connect to reload stream: %v", err)
+	}
+
+	defer res.Body.Close()
+
+	time.Sleep(50 * time.Millisecond)
+	reloads.reloads <- struct{}{}
+
+	event, err := bufio.NewReader(res.Body).ReadString('\n')
+	if err != nil {
+		t.Fatalf("Expected the stream to outlast the write timeout, got %v", err)
+	}
+
+	if want := "event: reload\n"; event != want {
+		t.Errorf("Expected %q, got %q", want, event)
+	}
+}
+
+func TestApplication_devReload_shuttingDown(t *testing.T) {
+	shuttingDown := make(chan struct{})
+
+	app := testutils.NewTestApplication(t)
+	app.TemplateReloads = &fakeTemplateReloads{reloads: make(chan struct{})}
+	app.ShuttingDown = shuttingDown
+
+	ts := testutils.NewTestServer(t, app.Routes())
+	defer ts.Close()
+
+	res, err := ts.Client().Get(ts.URL + "/dev/reload")
+	if err != nil {
+		t.Fatalf("failed to connect to reload stream: %v", err)
+	}
+
+	defer res.Body.Close()
+
+	close(shuttingDown)
+
+	if _, err := io.ReadAll(res.Body); err != nil {
+		t.Errorf("Expected the stream to end cleanly, got %v", err)
+	}
+}
+
+func TestApplication_devReload_disabled(t *testing.T) {
+	app := testutils.NewTestApplication(t)
+
+	ts := testutils.NewTestServer(t, app.Routes())
+	defer ts.Close()
+
+	if res := ts.Get(t, "/dev/reload"); res.Status != http.StatusNotFound {
+		t.Errorf("Expected status %d without live reload, got %d", http.StatusNotFound, res.Status)
+	}
+
+	page := ts.Get(t, "/login")
+	if strings.Contains(page.Body, "EventSource") {
+		t.Error("Expected page not to include the live reload script")
+	}
+}
@@ -0,0 +1,170 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// ReadinessCheck reports whether a dependency of the application, such as the database, is
+// available.
+type ReadinessCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// readinessTimeout bounds the readiness checks so a dependency that hangs fails the probe instead
+// of stalling it.
+const readinessTimeout = 2 * time.Second
+
+// readinessTTL is how long the results of the readiness checks are reused. Frequent probes from
+// several load balancers then cost one round of checks rather than one each.
+const readinessTTL = 5 * time.Second
+
+// readinessCache holds the latest results of the readiness checks. Its mutex is held while the
+// checks run, so concurrent probes wait for one round of checks instead of starting their own.
+type readinessCache struct {
+	mu        sync.Mutex
+	checkedAt time.Time
+	results   []readinessResult
+}
+
+type readinessResult struct {
+	name string
+	err  error
+}
+
+const (
+	healthOK          = "ok"
+	healthUnavailable = "unavailable"
+)
+
+type healthResponse struct {
+	Status string `json:"status"`
+
+	// Checks holds the result of each readiness check. The errors are logged rather than returned
+	// since they can describe the infrastructure behind the server.
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+type versionResponse struct {
+	Version  string `json:"version"`
+	Go       string `json:"go"`
+	Revision string `json:"revision,omitempty"`
+	Time     string `json:"time,omitempty"`
+	Modified bool   `json:"modified,omitempty"`
+}
+
+// healthzGet reports that the process is running. It doesn't check any dependencies, so an outage
+// of the database doesn't get the server restarted.
+func (a *Application) healthzGet(w http.ResponseWriter, r *http.Request) {
+	a.writeJSON(w, r, http.StatusOK, healthResponse{Status: healthOK})
+}
+
+// readyzGet reports whether the server can handle requests. It fails as soon as the server starts
+// shutting down so the load balancer stops sending it traffic. The dependency checks are cached for
+// readinessTTL.
+func (a *Application) readyzGet(w http.ResponseWriter, r *http.Request) {
+	response := healthResponse{Status: healthOK, Checks: make(map[string]string)}
+	record := func(name string, err error) {
+		if err == nil {
+			response.Checks[name] = healthOK
+			return
+		}
+
+		response.Checks[name] = healthUnavailable
+		response.Status = healthUnavailable
+	}
+
+	select {
+	case <-a.ShuttingDown:
+		record("server", errors.New("the server is shutting down"))
+	default:
+		record("server", nil)
+	}
+
+	for _, result := range a.readinessResults(r.Context()) {
+		record(result.name, result.err)
+	}
+
+	status := http.StatusOK
+	if response.Status != healthOK {
+		status = http.StatusServiceUnavailable
+	}
+
+	a.writeJSON(w, r, status, response)
+}
+
+// readinessResults returns the results of checking the templates and each of the readiness checks,
+// reusing the previous results if they are recent enough. Failures are logged when they are found.
+func (a *Application) readinessResults(ctx context.Context) []readinessResult {
+	a.readiness.mu.Lock()
+	defer a.readiness.mu.Unlock()
+
+	if a.readiness.results != nil && time.Since(a.readiness.checkedAt) < readinessTTL {
+		return a.readiness.results
+	}
+
+	// The results are shared with other probes, so they shouldn't fail because this probe's client
+	// went away.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), readinessTimeout)
+	defer cancel()
+
+	results := []readinessResult{{"templates", a.templatesErr()}}
+	for _, check := range a.ReadinessChecks {
+		results = append(results, readinessResult{check.Name, check.Check(ctx)})
+	}
+
+	for _, result := range results {
+		if result.err != nil {
+			a.Logger.WarnContext(ctx, "Readiness check failed.", "check", result.name, "error", result.err)
+		}
+	}
+
+	a.readiness.checkedAt = time.Now()
+	a.readiness.results = results
+
+	return results
+}
+
+// templatesErr reports a problem with the page templates. Templates that are rebuilt while the
+// server runs report whether their latest build failed.
+func (a *Application) templatesErr() error {
+	if a.Templates == nil {
+		return errors.New("no templates are loaded")
+	}
+
+	if templates, ok := a.Templates.(interface{ Err() error }); ok {
+		return templates.Err()
+	}
+
+	return nil
+}
+
+// versionGet describes the build of the running server.
+func (a *Application) versionGet(w http.ResponseWriter, r *http.Request) {
+	response := versionResponse{Version: "unknown", Go: runtime.Version()}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if info.Main.Version != "" {
+			response.Version = info.Main.Version
+		}
+
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				response.Revision = setting.Value
+			case "vcs.time":
+				response.Time = setting.Value
+			case "vcs.modified":
+				response.Modified = setting.Value == "true"
+			}
+		}
+	}
+
+	a.writeJSON(w, r, http.StatusOK, response)
+}
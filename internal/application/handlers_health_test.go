@@ -0,0 +1,196 @@
+package application_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cdriehuys/secret-santa/internal/application"
+	"github.com/cdriehuys/secret-santa/internal/application/testutils"
+)
+
+type healthBody struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+func TestApplication_healthz(t *testing.T) {
+	app := testutils.NewTestApplication(t)
+
+	ts := testutils.NewTestServer(t, app.Routes())
+	defer ts.Close()
+
+	res := ts.Get(t, "/healthz")
+	if res.Status != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, res.Status)
+	}
+
+	assertContains(t, res.Body, `"status":"ok"`)
+
+	for _, cookie := range res.Cookies {
+		t.Errorf("Expected probes not to set cookies, got %q", cookie.Name)
+	}
+}
+
+// brokenTemplates are templates whose latest rebuild failed.
+type brokenTemplates struct {
+	application.TemplateEngine
+}
+
+func (brokenTemplates) Err() error {
+	return errors.New("unexpected EOF")
+}
+
+func TestApplication_readyz(t *testing.T) {
+	passing := application.ReadinessCheck{Name: "database", Check: func(context.Context) error { return nil }}
+	failing := application.ReadinessCheck{Name: "mailer", Check: func(context.Context) error {
+		return errors.New("dial tcp 10.0.0.5:587: connection refused")
+	}}
+
+	closed := make(chan struct{})
+	close(closed)
+
+	testCases := []struct {
+		name         string
+		configure    func(*application.Application)
+		wantStatus   int
+		wantChecks   map[string]string
+		wantOverall  string
+		wantNoDetail string
+	}{
+		{
+			name: "ready",
+			configure: func(app *application.Application) {
+				app.ReadinessChecks = []application.ReadinessCheck{passing}
+			},
+			wantStatus:  http.StatusOK,
+			wantOverall: "ok",
+			wantChecks:  map[string]string{"server": "ok", "templates": "ok", "database": "ok"},
+		},
+		{
+			name: "failing check",
+			configure: func(app *application.Application) {
+				app.ReadinessChecks = []application.ReadinessCheck{passing, failing}
+			},
+			wantStatus:   http.StatusServiceUnavailable,
+			wantOverall:  "unavailable",
+			wantChecks:   map[string]string{"server": "ok", "templates": "ok", "database": "ok", "mailer": "unavailable"},
+			wantNoDetail: "10.0.0.5",
+		},
+		{
+			name: "broken templates",
+			configure: func(app *application.Application) {
+				app.Templates = brokenTemplates{app.Templates}
+			},
+			wantStatus:  http.StatusServiceUnavailable,
+			wantOverall: "unavailable",
+			wantChecks:  map[string]string{"server": "ok", "templates": "unavailable"},
+		},
+		{
+			name: "shutting down",
+			configure: func(app *application.Application) {
+				app.ShuttingDown = closed
+			},
+			wantStatus:  http.StatusServiceUnavailable,
+			wantOverall: "unavailable",
+			wantChecks:  map[string]string{"server": "unavailable", "templates": "ok"},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			app := testutils.NewTestApplication(t)
+			tt.configure(app)
+
+			ts := testutils.NewTestServer(t, app.Routes())
+			defer ts.Close()
+
+			res := ts.Get(t, "/readyz")
+			if res.Status != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, res.Status)
+			}
+
+			var body healthBody
+			if err := json.Unmarshal([]byte(res.Body), &body); err != nil {
+				t.Fatalf("failed to decode response %q: %v", res.Body, err)
+			}
+
+			if body.Status != tt.wantOverall {
+				t.Errorf("Expected overall status %q, got %q", tt.wantOverall, body.Status)
+			}
+
+			if !maps.Equal(body.Checks, tt.wantChecks) {
+				t.Errorf("Expected checks %v, got %v", tt.wantChecks, body.Checks)
+			}
+
+			if tt.wantNoDetail != "" && strings.Contains(res.Body, tt.wantNoDetail) {
+				t.Errorf("Expected the check's error not to be exposed, got %s", res.Body)
+			}
+		})
+	}
+}
+
+func TestApplication_readyz_cached(t *testing.T) {
+	var calls atomic.Int32
+
+	app := testutils.NewTestApplication(t)
+	app.ReadinessChecks = []application.ReadinessCheck{{Name: "database", Check: func(context.Context) error {
+		calls.Add(1)
+		return nil
+	}}}
+
+	shuttingDown := make(chan struct{})
+	app.ShuttingDown = shuttingDown
+
+	ts := testutils.NewTestServer(t, app.Routes())
+	defer ts.Close()
+
+	if res := ts.Get(t, "/readyz"); res.Status != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, res.Status)
+	}
+
+	close(shuttingDown)
+
+	// The dependency checks are reused, but shutting down is noticed immediately.
+	if res := ts.Get(t, "/readyz"); res.Status != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, res.Status)
+	}
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("Expected the check to run once, ran %d times", got)
+	}
+}
+
+func TestApplication_version(t *testing.T) {
+	app := testutils.NewTestApplication(t)
+
+	ts := testutils.NewTestServer(t, app.Routes())
+	defer ts.Close()
+
+	res := ts.Get(t, "/version")
+	if res.Status != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, res.Status)
+	}
+
+	var body struct {
+		Version string `json:"version"`
+		Go      string `json:"go"`
+	}
+	if err := json.Unmarshal([]byte(res.Body), &body); err != nil {
+		t.Fatalf("failed to decode response %q: %v", res.Body, err)
+	}
+
+	if body.Version == "" {
+		t.Error("Expected a version")
+	}
+
+	if body.Go != runtime.Version() {
+		t.Errorf("Expected Go version %q, got %q", runtime.Version(), body.Go)
+	}
+}
@@ -0,0 +1,111 @@
+package application
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/cdriehuys/secret-santa/internal/i18n"
+	"github.com/cdriehuys/secret-santa/internal/pairings"
+	"github.com/cdriehuys/secret-santa/internal/roster"
+)
+
+// maxImportBytes limits the size of imported CSV files. Even with long names and many exclusions,
+// the default limit of participants fits in a fraction of it.
+const maxImportBytes = 1 << 20
+
+type importForm struct {
+	// CSV is the imported file, which is posted back when confirming the preview.
+	CSV string
+
+	Participants []roster.Imported
+	RowErrors    []roster.ImportError
+}
+
+func (a *Application) pairingsImportGet(w http.ResponseWriter, r *http.Request) {
+	data := a.templateData(r)
+	data.Form = importForm{}
+
+	a.render(w, r, "pairings-import.html", data)
+}
+
+// pairingsImportPost previews the participants in an uploaded or pasted CSV file. Once the preview is
+// confirmed, the same file is posted back to draw the pairings. The form is parsed by
+// limitImportBody.
+func (a *Application) pairingsImportPost(w http.ResponseWriter, r *http.Request) {
+	renderErrors := func(form importForm, errs []roster.ImportError) {
+		form.RowErrors = errs
+
+		data := a.templateData(r)
+		data.Form = form
+
+		a.renderStatus(w, r, http.StatusUnprocessableEntity, "pairings-import.html", data)
+	}
+
+	if importTooLarge(r) {
+		renderErrors(importForm{}, []roster.ImportError{{Message: i18n.NewMessage("The file must not be larger than 1 MiB.")}})
+		return
+	}
+
+	form := importForm{CSV: r.PostFormValue("csv")}
+
+	if file, _, err := r.FormFile("file"); err == nil {
+		defer file.Close()
+
+		contents, err := io.ReadAll(file)
+		if err != nil {
+			a.badRequest(w, r)
+			return
+		}
+
+		form.CSV = string(contents)
+	}
+
+	if strings.TrimSpace(form.CSV) == "" {
+		renderErrors(form, []roster.ImportError{{Message: i18n.NewMessage("Choose a CSV file or paste the participants.")}})
+		return
+	}
+
+	participants, errs := roster.ParseCSV(strings.NewReader(form.CSV))
+	form.Participants = participants
+	if len(errs) > 0 {
+		renderErrors(form, errs)
+		return
+	}
+
+	restrictions, errs := roster.ValidateImported(participants, a.pairingLimits())
+	if len(errs) > 0 {
+		renderErrors(form, errs)
+		return
+	}
+
+	if r.PostFormValue("action") != "draw" {
+		data := a.templateData(r)
+		data.Form = form
+
+		a.render(w, r, "pairings-import.html", data)
+		return
+	}
+
+	pairs, err := a.PairingGenerator(r.Context(), restrictions, PairingOptions{})
+	if err != nil {
+		switch {
+		case errors.Is(err, pairings.ErrTooFewNodes):
+			renderErrors(form, []roster.ImportError{{Message: i18n.NewMessage("At least two participants are required.")}})
+		case errors.Is(err, pairings.ErrNoPath):
+			renderErrors(form, []roster.ImportError{{Message: i18n.NewMessage("No pairings satisfy the exclusions and households. Try removing some exclusions.")}})
+		case errors.Is(err, pairings.ErrSearchExhausted):
+			renderErrors(form, []roster.ImportError{{Message: i18n.NewMessage("Pairings couldn't be found in time. Try drawing again, or remove some exclusions.")}})
+		default:
+			a.serverError(w, r, "Failed to generate pairings.", err)
+		}
+
+		return
+	}
+
+	data := a.templateData(r)
+	data.Pairings = pairs
+
+	a.render(w, r, "pairings-results.html", data)
+}
@@ -0,0 +1,242 @@
+package application_test
+
+import (
+	"bytes"
+	"context"
+	"maps"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cdriehuys/secret-santa/internal/application"
+	"github.com/cdriehuys/secret-santa/internal/application/testutils"
+	"github.com/cdriehuys/secret-santa/internal/pairings"
+)
+
+func TestApplication_pairingsImportGet(t *testing.T) {
+	app := testutils.NewTestApplication(t)
+	ts := testutils.NewTestServer(t, app.Routes())
+	defer ts.Close()
+
+	res := ts.Get(t, "/pairings/import")
+
+	if res.Status != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, res.Status)
+	}
+
+	assertContains(t, res.Body, `enctype="multipart/form-data"`)
+}
+
+func TestApplication_pairingsImportPost(t *testing.T) {
+	pairs := []pairings.Pairing{{From: "Ross", To: "Joey"}, {From: "Joey", To: "Ross"}}
+
+	testCases := []struct {
+		name         string
+		form         url.Values
+		generatorErr error
+
+		wantStatus       int
+		wantBody         []string
+		wantRestrictions application.GiftRestrictions
+	}{
+		{
+			name: "preview",
+			form: url.Values{
+				"csv": {"\ufeffName;Email;Household;Exclusions;Notes\nRoss;ross@example.com;Geller;Chandler, Joey;Dinosaurs\n\nMonica;;Geller\nJoey\nChandler;;;Joey\nRachel\n"},
+			},
+			wantStatus: http.StatusOK,
+			wantBody: []string{
+				"5 participants found.",
+				"<td>2</td>\n      <td>Ross</td>\n      <td>ross@example.com</td>\n      <td>Geller</td>\n      <td>Chandler, Joey</td>",
+				"<td>4</td>\n      <td>Monica</td>",
+				`value="draw"`,
+			},
+		},
+		{
+			name: "draw",
+			form: url.Values{
+				"action": {"draw"},
+				"csv":    {"name,household,exclusions\nRoss,Geller,\"Chandler; Joey\"\nMonica,Geller\nJoey\nChandler,,Joey\nRachel"},
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   []string{`name="pair[0].from" value="Ross"`},
+			wantRestrictions: application.GiftRestrictions{
+				"Ross":     {"Chandler", "Joey", "Monica"},
+				"Monica":   {"Ross"},
+				"Joey":     nil,
+				"Chandler": {"Joey"},
+				"Rachel":   nil,
+			},
+		},
+		{
+			name:       "tab delimited",
+			form:       url.Values{"csv": {"name\texclusions\nRoss\tJoey\nJoey\t\nChandler\t"}},
+			wantStatus: http.StatusOK,
+			wantBody:   []string{"3 participants found.", "<td>Joey</td>"},
+		},
+		{
+			name:       "empty",
+			form:       url.Values{"csv": {"  "}},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   []string{"Choose a CSV file or paste the participants."},
+		},
+		{
+			name:       "header only",
+			form:       url.Values{"csv": {"name,email\n"}},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   []string{"The file doesn&#39;t contain any participants."},
+		},
+		{
+			name:       "missing name column",
+			form:       url.Values{"csv": {"person,email\nRoss,ross@example.com"}},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   []string{"include a &#34;name&#34; column"},
+		},
+		{
+			name:       "malformed CSV",
+			form:       url.Values{"csv": {"name,exclusions\nRoss,\"Joey\nJoey"}},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   []string{"Row 2: The row is not valid CSV"},
+		},
+		{
+			name: "invalid rows",
+			form: url.Values{
+				"csv": {"name,email,exclusions\nRoss,not-an-email,Monica\n,,Ross\nRoss\nJoey,,\"Ross,Chandler,Ross,Ross\""},
+			},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody: []string{
+				"Row 2, email: &#34;not-an-email&#34; is not a valid email address.",
+				"Row 2, exclusions: &#34;Monica&#34; is not a participant.",
+				"Row 3, name: Name is required.",
+				"Row 4, name: &#34;Ross&#34; is listed more than once.",
+				"Row 5, exclusions: There can be at most 3 exclusions.",
+			},
+		},
+		{
+			name: "no valid pairings",
+			form: url.Values{
+				"action": {"draw"},
+				"csv":    {"name,household\nRoss,Geller\nMonica,Geller\nJoey\nChandler"},
+			},
+			generatorErr: pairings.ErrNoPath,
+			wantStatus:   http.StatusUnprocessableEntity,
+			wantBody:     []string{"No pairings satisfy the exclusions and households."},
+		},
+		{
+			name: "search exhausted",
+			form: url.Values{
+				"action": {"draw"},
+				"csv":    {"name\nRoss\nMonica"},
+			},
+			generatorErr: pairings.ErrSearchExhausted,
+			wantStatus:   http.StatusUnprocessableEntity,
+			wantBody:     []string{"Pairings couldn&#39;t be found in time."},
+		},
+		{
+			name:       "household too large",
+			form:       url.Values{"csv": {"name,household\nRoss,Geller\nMonica,Geller\nJoey"}},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   []string{"The &#34;Geller&#34; household has 2 of the 3 participants."},
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotRestrictions application.GiftRestrictions
+
+			app := testutils.NewTestApplication(t)
+			app.PairingGenerator = func(_ context.Context, restrictions application.GiftRestrictions, _ application.PairingOptions) ([]pairings.Pairing, error) {
+				gotRestrictions = restrictions
+
+				return pairs, tt.generatorErr
+			}
+
+			ts := testutils.NewTestServer(t, app.Routes())
+			defer ts.Close()
+
+			form := csrfFormValues(t, app, ts, "/pairings/import")
+			maps.Copy(form, tt.form)
+
+			res := ts.PostForm(t, "/pairings/import", form)
+
+			if res.Status != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, res.Status)
+			}
+
+			for _, want := range tt.wantBody {
+				assertContains(t, res.Body, want)
+			}
+
+			if tt.wantRestrictions != nil && !maps.EqualFunc(gotRestrictions, tt.wantRestrictions, slices.Equal) {
+				t.Errorf("Expected restrictions %v, got %v", tt.wantRestrictions, gotRestrictions)
+			}
+		})
+	}
+}
+
+func TestApplication_pairingsImportPost_upload(t *testing.T) {
+	testCases := []struct {
+		name        string
+		contents    string
+		noCSRFToken bool
+		wantStatus  int
+		wantBody    string
+	}{
+		{
+			name:       "valid file",
+			contents:   "name\nRoss\nJoey\n",
+			wantStatus: http.StatusOK,
+			wantBody:   "2 participants found.",
+		},
+		{
+			name:        "missing CSRF token",
+			contents:    "name\nRoss\nJoey\n",
+			noCSRFToken: true,
+			wantStatus:  http.StatusBadRequest,
+			wantBody:    "Bad Request",
+		},
+		{
+			// The token can't be read from a form that is too large, but the file is still
+			// rejected with an explanation.
+			name:       "too large",
+			contents:   "name\n" + strings.Repeat("Ross\n", 300_000),
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   "The file must not be larger than 1 MiB.",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			app := testutils.NewTestApplication(t)
+			ts := testutils.NewTestServer(t, app.Routes())
+			defer ts.Close()
+
+			var body bytes.Buffer
+			writer := multipart.NewWriter(&body)
+
+			if !tt.noCSRFToken {
+				csrfToken := csrfFormValues(t, app, ts, "/pairings/import").Get("csrf_token")
+				if err := writer.WriteField("csrf_token", csrfToken); err != nil {
+					t.Fatalf("failed to write CSRF token: %v", err)
+				}
+			}
+
+			file, err := writer.CreateFormFile("file", "participants.csv")
+			if err != nil {
+				t.Fatalf("failed to create form file: %v", err)
+			}
+
+			file.Write([]byte(tt.contents))
+			writer.Close()
+
+			res := ts.Post(t, "/pairings/import", writer.FormDataContentType(), &body)
+
+			if res.Status != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, res.Status)
+			}
+
+			assertContains(t, res.Body, tt.wantBody)
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package application
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/cdriehuys/secret-santa/internal/i18n"
+	"github.com/cdriehuys/secret-santa/internal/models"
+)
+
+func (a *Application) accountTwoFactorGet(w http.ResponseWriter, r *http.Request) {
+	user, _ := authenticatedUser(r)
+
+	data, err := a.twoFactorTemplateData(r, user)
+	if err != nil {
+		a.serverError(w, r, "Failed to load two-factor settings.", err, "userID", user.ID)
+		return
+	}
+
+	a.render(w, r, "account-two-factor.html", data)
+}
+
+func (a *Application) accountTwoFactorPost(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		a.badRequest(w, r)
+		return
+	}
+
+	user, _ := authenticatedUser(r)
+
+	codes, err := a.TwoFactor.ConfirmEnrollment(r.Context(), user.ID, r.PostFormValue("code"))
+	if err != nil {
+		if errors.Is(err, models.ErrTwoFactorEnabled) {
+			http.Redirect(w, r, "/account/two-factor", http.StatusSeeOther)
+			return
+		}
+
+		if errors.Is(err, models.ErrInvalidCode) {
+			a.renderTwoFactorError(w, r, user)
+			return
+		}
+
+		a.serverError(w, r, "Failed to confirm two-factor enrollment.", err, "userID", user.ID)
+		return
+	}
+
+	// Recovery codes are only available now, so they are rendered directly instead of redirecting.
+	data := a.templateData(r)
+	data.RecoveryCodes = codes
+
+	a.render(w, r, "account-two-factor-recovery-codes.html", data)
+}
+
+func (a *Application) accountTwoFactorDisablePost(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		a.badRequest(w, r)
+		return
+	}
+
+	user, _ := authenticatedUser(r)
+
+	if err := a.TwoFactor.Disable(r.Context(), user.ID, r.PostFormValue("code")); err != nil {
+		if errors.Is(err, models.ErrInvalidCode) {
+			a.renderTwoFactorError(w, r, user)
+			return
+		}
+
+		a.serverError(w, r, "Failed to disable two-factor authentication.", err, "userID", user.ID)
+		return
+	}
+
+	http.Redirect(w, r, "/account/two-factor", http.StatusSeeOther)
+}
+
+func (a *Application) renderTwoFactorError(w http.ResponseWriter, r *http.Request, user models.User) {
+	data, err := a.twoFactorTemplateData(r, user)
+	if err != nil {
+		a.serverError(w, r, "Failed to load two-factor settings.", err, "userID", user.ID)
+		return
+	}
+
+	data.Errors = map[string]i18n.Message{"code": i18n.NewMessage("That code is not valid.")}
+
+	a.renderStatus(w, r, http.StatusUnprocessableEntity, "account-two-factor.html", data)
+}
+
+// twoFactorTemplateData describes the user's two-factor status, including how to enroll if they
+// have not yet done so.
+func (a *Application) twoFactorTemplateData(r *http.Request, user models.User) (TemplateData, error) {
+	data := a.templateData(r)
+
+	enabled, err := a.TwoFactor.Enabled(r.Context(), user.ID)
+	if err != nil {
+		return TemplateData{}, err
+	}
+
+	data.TwoFactorEnabled = enabled
+	if enabled {
+		return data, nil
+	}
+
+	enrollment, err := a.TwoFactor.BeginEnrollment(r.Context(), user.ID, user.Email)
+	if err != nil {
+		return TemplateData{}, err
+	}
+
+	data.TwoFactorEnrollment = enrollment
+
+	return data, nil
+}
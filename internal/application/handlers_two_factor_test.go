@@ -0,0 +1,256 @@
+package application_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/cdriehuys/secret-santa/internal/application/testutils"
+	"github.com/cdriehuys/secret-santa/internal/models"
+	"github.com/cdriehuys/secret-santa/internal/models/mocks"
+)
+
+func TestApplication_loginPost_twoFactor(t *testing.T) {
+	app := testutils.NewTestApplication(t)
+	sessions := mocks.SessionModel{}
+	app.Users = &mocks.UserModel{AuthenticateUserID: testUser.ID}
+	app.Sessions = &sessions
+	app.TwoFactor = &mocks.TwoFactorModel{EnabledReturn: true}
+
+	ts := testutils.NewTestServer(t, app.Routes())
+	defer ts.Close()
+
+	form := csrfFormValues(t, app, ts, "/login")
+	form.Add("email", testUser.Email)
+	form.Add("password", "tops3cret")
+
+	res := ts.PostForm(t, "/login", form)
+
+	if got := res.Headers.Get("Location"); got != "/login/two-factor" {
+		t.Errorf("Expected redirect to two-factor challenge, got %q", got)
+	}
+
+	if len(sessions.Sessions) != 0 {
+		t.Errorf("Expected no full session before second factor, got %v", sessions.Sessions)
+	}
+
+	if len(sessions.PendingSessions) != 1 {
+		t.Errorf("Expected one pending session, got %v", sessions.PendingSessions)
+	}
+}
+
+func TestApplication_loginTwoFactorPost(t *testing.T) {
+	testCases := []struct {
+		name         string
+		twoFactor    mocks.TwoFactorModel
+		pending      bool
+		wantStatus   int
+		wantRedirect string
+		wantSession  bool
+	}{
+		{
+			name:         "valid code",
+			pending:      true,
+			wantStatus:   http.StatusSeeOther,
+			wantRedirect: "/account",
+			wantSession:  true,
+		},
+		{
+			name:       "invalid code",
+			twoFactor:  mocks.TwoFactorModel{VerifyError: models.ErrInvalidCode},
+			pending:    true,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "verification error",
+			twoFactor:  mocks.TwoFactorModel{VerifyError: errors.New("query failed")},
+			pending:    true,
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:         "no pending session",
+			wantStatus:   http.StatusSeeOther,
+			wantRedirect: "/login",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			app := testutils.NewTestApplication(t)
+			sessions := mocks.SessionModel{}
+			app.Sessions = &sessions
+			app.TwoFactor = &tt.twoFactor
+
+			ts := testutils.NewTestServer(t, app.Routes())
+			defer ts.Close()
+
+			form := csrfFormValues(t, app, ts, "/login")
+			form.Add("code", "123456")
+
+			if tt.pending {
+				sessions.PendingSessions = map[string]models.User{"pending": testUser}
+				setCookie(t, ts, "two_factor_session", "pending")
+			}
+
+			res := ts.PostForm(t, "/login/two-factor", form)
+
+			if res.Status != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, res.Status)
+			}
+
+			if got := res.Headers.Get("Location"); got != tt.wantRedirect {
+				t.Errorf("Expected redirect to %q, got %q", tt.wantRedirect, got)
+			}
+
+			if tt.pending && tt.twoFactor.VerifiedUserID != testUser.ID {
+				t.Errorf("Expected code to be verified for user %v, got %v", testUser.ID, tt.twoFactor.VerifiedUserID)
+			}
+
+			if gotSession := len(sessions.Sessions) == 1; gotSession != tt.wantSession {
+				t.Errorf("Expected full session=%v, got %v", tt.wantSession, sessions.Sessions)
+			}
+
+			if tt.wantSession && len(sessions.PendingSessions) != 0 {
+				t.Errorf("Expected pending session to be deleted, got %v", sessions.PendingSessions)
+			}
+		})
+	}
+}
+
+func TestApplication_accountTwoFactorGet(t *testing.T) {
+	testCases := []struct {
+		name      string
+		twoFactor mocks.TwoFactorModel
+		want      string
+	}{
+		{
+			name: "not enrolled",
+			twoFactor: mocks.TwoFactorModel{
+				Enrollment: models.TwoFactorEnrollment{Secret: "ABCDEFGH", ProvisioningURI: "otpauth://totp/test"},
+			},
+			want: "ABCDEFGH",
+		},
+		{
+			name:      "enrolled",
+			twoFactor: mocks.TwoFactorModel{EnabledReturn: true},
+			want:      "Disable Two-Factor Authentication",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			app := testutils.NewTestApplication(t)
+			app.TwoFactor = &tt.twoFactor
+
+			ts := testutils.NewTestServer(t, app.Routes())
+			defer ts.Close()
+
+			logIn(t, app, ts, testUser)
+
+			res := ts.Get(t, "/account/two-factor")
+
+			if res.Status != http.StatusOK {
+				t.Errorf("Expected status %d, got %d", http.StatusOK, res.Status)
+			}
+
+			assertContains(t, res.Body, tt.want)
+		})
+	}
+}
+
+func TestApplication_accountTwoFactorPost(t *testing.T) {
+	testCases := []struct {
+		name       string
+		twoFactor  mocks.TwoFactorModel
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "valid code",
+			twoFactor:  mocks.TwoFactorModel{RecoveryCodes: []string{"AAAA-BBBB"}},
+			wantStatus: http.StatusOK,
+			wantBody:   "AAAA-BBBB",
+		},
+		{
+			name:       "invalid code",
+			twoFactor:  mocks.TwoFactorModel{ConfirmError: models.ErrInvalidCode},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   "That code is not valid.",
+		},
+		{
+			name:       "already enabled",
+			twoFactor:  mocks.TwoFactorModel{ConfirmError: models.ErrTwoFactorEnabled},
+			wantStatus: http.StatusSeeOther,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			app := testutils.NewTestApplication(t)
+			app.TwoFactor = &tt.twoFactor
+
+			ts := testutils.NewTestServer(t, app.Routes())
+			defer ts.Close()
+
+			logIn(t, app, ts, testUser)
+
+			form := csrfFormValues(t, app, ts, "/account")
+			form.Add("code", "123456")
+
+			res := ts.PostForm(t, "/account/two-factor", form)
+
+			if res.Status != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, res.Status)
+			}
+
+			if tt.twoFactor.ConfirmedCode != "123456" {
+				t.Errorf("Expected code %q to be confirmed, got %q", "123456", tt.twoFactor.ConfirmedCode)
+			}
+
+			assertContains(t, res.Body, tt.wantBody)
+		})
+	}
+}
+
+func TestApplication_accountTwoFactorDisablePost(t *testing.T) {
+	testCases := []struct {
+		name       string
+		twoFactor  mocks.TwoFactorModel
+		wantStatus int
+	}{
+		{
+			name:       "valid code",
+			wantStatus: http.StatusSeeOther,
+		},
+		{
+			name:       "invalid code",
+			twoFactor:  mocks.TwoFactorModel{EnabledReturn: true, DisableError: models.ErrInvalidCode},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			app := testutils.NewTestApplication(t)
+			app.TwoFactor = &tt.twoFactor
+
+			ts := testutils.NewTestServer(t, app.Routes())
+			defer ts.Close()
+
+			logIn(t, app, ts, testUser)
+
+			form := csrfFormValues(t, app, ts, "/account")
+			form.Add("code", "123456")
+
+			res := ts.PostForm(t, "/account/two-factor/disable", form)
+
+			if res.Status != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, res.Status)
+			}
+
+			if tt.twoFactor.DisabledCode != "123456" {
+				t.Errorf("Expected code %q to be used, got %q", "123456", tt.twoFactor.DisabledCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package application
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/text/language"
+)
+
+// DefaultLocale is used when a request doesn't prefer any of the supported locales. Its templates
+// live directly in the template directories rather than in a locale subdirectory.
+const DefaultLocale = "en"
+
+// SupportedLocales lists the locales that templates are translated into. The first one is the
+// default.
+var SupportedLocales = []string{DefaultLocale, "de", "es"}
+
+var localeMatcher = language.NewMatcher(func() []language.Tag {
+	tags := make([]language.Tag, len(SupportedLocales))
+	for i, locale := range SupportedLocales {
+		tags[i] = language.Make(locale)
+	}
+
+	return tags
+}())
+
+const localeCookieName = "locale"
+
+const localeKey = contextKey("locale")
+
+// WithLocale returns a copy of the context that renders templates, such as emails, in the given
+// locale.
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey, locale)
+}
+
+// requestLocale returns the locale chosen for the request by the negotiateLocale middleware.
+func requestLocale(ctx context.Context) string {
+	if locale, ok := ctx.Value(localeKey).(string); ok {
+		return locale
+	}
+
+	return DefaultLocale
+}
+
+// localizedTemplate returns the name of the locale's variant of a template. The template engines
+// fall back to the default template if it hasn't been translated.
+func localizedTemplate(locale string, name string) string {
+	if locale == DefaultLocale {
+		return name
+	}
+
+	return locale + "/" + name
+}
+
+// splitLocalizedTemplate is the inverse of localizedTemplate. It returns the locale and name of a
+// template.
+func splitLocalizedTemplate(name string) (string, string) {
+	if locale, rest, ok := strings.Cut(name, "/"); ok {
+		return locale, rest
+	}
+
+	return DefaultLocale, name
+}
+
+// negotiateLocale picks the locale used to render the response. A locale the user chose explicitly
+// takes precedence over the languages their browser asks for.
+func (a *Application) negotiateLocale(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var preference string
+		if cookie, err := r.Cookie(localeCookieName); err == nil {
+			preference = cookie.Value
+		}
+
+		_, index := language.MatchStrings(localeMatcher, preference, r.Header.Get("Accept-Language"))
+		locale := SupportedLocales[index]
+
+		w.Header().Add("Vary", "Accept-Language")
+		w.Header().Set("Content-Language", locale)
+
+		next.ServeHTTP(w, r.WithContext(WithLocale(r.Context(), locale)))
+	})
+}
+
+// localePost saves the user's preferred locale and sends them back to the page they came from.
+func (a *Application) localePost(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		a.badRequest(w, r)
+		return
+	}
+
+	locale := r.PostForm.Get("locale")
+	if !slices.Contains(SupportedLocales, locale) {
+		a.badRequest(w, r)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     localeCookieName,
+		Value:    locale,
+		Path:     "/",
+		MaxAge:   int((365 * 24 * time.Hour).Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   a.SecureCookies,
+	})
+
+	http.Redirect(w, r, localRedirect(r.Referer()), http.StatusSeeOther)
+}
+
+// localRedirect returns the path of a URL, such as the Referer header, so it can be redirected to
+// without sending the user to another site. If there is no usable path, it returns the home page.
+func localRedirect(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || !strings.HasPrefix(u.Path, "/") || strings.HasPrefix(u.Path, "//") {
+		return "/"
+	}
+
+	target := url.URL{Path: u.Path, RawQuery: u.RawQuery}
+
+	return target.String()
+}
@@ -0,0 +1,201 @@
+package application_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cdriehuys/secret-santa/internal/application"
+	"github.com/cdriehuys/secret-santa/internal/application/testutils"
+)
+
+func TestApplication_negotiateLocale(t *testing.T) {
+	testCases := []struct {
+		name           string
+		acceptLanguage string
+		cookie         string
+		wantLocale     string
+	}{
+		{
+			name:       "no preference",
+			wantLocale: "en",
+		},
+		{
+			name:           "supported language",
+			acceptLanguage: "de-DE,de;q=0.9,en;q=0.8",
+			wantLocale:     "de",
+		},
+		{
+			name:           "first supported language",
+			acceptLanguage: "fr-FR,fr;q=0.9,es;q=0.5,en;q=0.3",
+			wantLocale:     "es",
+		},
+		{
+			name:           "unsupported language",
+			acceptLanguage: "fr-FR",
+			wantLocale:     "en",
+		},
+		{
+			name:           "cookie overrides header",
+			acceptLanguage: "de",
+			cookie:         "es",
+			wantLocale:     "es",
+		},
+		{
+			name:           "unsupported cookie",
+			acceptLanguage: "de",
+			cookie:         "klingon",
+			wantLocale:     "de",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			templates := CapturingTemplateEngine[application.TemplateData]{}
+
+			app := testutils.NewTestApplication(t)
+			app.Templates = &templates
+
+			r := httptest.NewRequest(http.MethodGet, "/login", nil)
+			if tt.acceptLanguage != "" {
+				r.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: "locale", Value: tt.cookie})
+			}
+
+			w := httptest.NewRecorder()
+			app.Routes().ServeHTTP(w, r)
+
+			res := w.Result()
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d", http.StatusOK, res.StatusCode)
+			}
+
+			if got := res.Header.Get("Content-Language"); got != tt.wantLocale {
+				t.Errorf("Expected Content-Language %q, got %q", tt.wantLocale, got)
+			}
+
+			if got := templates.RenderedData.Locale; got != tt.wantLocale {
+				t.Errorf("Expected template locale %q, got %q", tt.wantLocale, got)
+			}
+
+			// Every locale shares the same page, which translates itself.
+			if templates.RenderedName != "login.html" {
+				t.Errorf("Expected template %q, got %q", "login.html", templates.RenderedName)
+			}
+		})
+	}
+}
+
+func TestApplication_negotiateLocale_translatedPage(t *testing.T) {
+	app := testutils.NewTestApplication(t)
+	ts := testutils.NewTestServer(t, app.Routes())
+	defer ts.Close()
+
+	setCookie(t, ts, "locale", "de")
+
+	res := ts.Get(t, "/login")
+
+	assertContains(t, res.Body, `<html lang="de">`)
+	assertContains(t, res.Body, "<h1>Anmelden</h1>")
+}
+
+func TestApplication_localePost(t *testing.T) {
+	testCases := []struct {
+		name         string
+		locale       string
+		referer      string
+		wantStatus   int
+		wantLocation string
+		wantCookie   string
+	}{
+		{
+			name:         "supported locale",
+			locale:       "de",
+			referer:      "/register",
+			wantStatus:   http.StatusSeeOther,
+			wantLocation: "/register",
+			wantCookie:   "de",
+		},
+		{
+			name:         "keeps query",
+			locale:       "es",
+			referer:      "/login?email=test",
+			wantStatus:   http.StatusSeeOther,
+			wantLocation: "/login?email=test",
+			wantCookie:   "es",
+		},
+		{
+			name:         "no referer",
+			locale:       "en",
+			wantStatus:   http.StatusSeeOther,
+			wantLocation: "/",
+			wantCookie:   "en",
+		},
+		{
+			name:         "external referer",
+			locale:       "de",
+			referer:      "https://evil.example.com/phish",
+			wantStatus:   http.StatusSeeOther,
+			wantLocation: "/phish",
+			wantCookie:   "de",
+		},
+		{
+			name:       "unsupported locale",
+			locale:     "fr",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			app := testutils.NewTestApplication(t)
+			ts := testutils.NewTestServer(t, app.Routes())
+			defer ts.Close()
+
+			form := csrfFormValues(t, app, ts, "/login")
+			form.Set("locale", tt.locale)
+
+			req, err := http.NewRequest(http.MethodPost, ts.URL+"/locale", strings.NewReader(form.Encode()))
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("Sec-Fetch-Site", "same-origin")
+			if referer, ok := strings.CutPrefix(tt.referer, "/"); ok {
+				req.Header.Set("Referer", ts.URL+"/"+referer)
+			} else if tt.referer != "" {
+				req.Header.Set("Referer", tt.referer)
+			}
+
+			rawRes, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatalf("failed to send request: %v", err)
+			}
+
+			defer rawRes.Body.Close()
+
+			res := testutils.MakeTestResponse(t, rawRes)
+			if res.Status != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, res.Status)
+			}
+
+			if got := res.Headers.Get("Location"); got != tt.wantLocation {
+				t.Errorf("Expected redirect to %q, got %q", tt.wantLocation, got)
+			}
+
+			var gotCookie string
+			for _, cookie := range res.Cookies {
+				if cookie.Name == "locale" {
+					gotCookie = cookie.Value
+				}
+			}
+
+			if gotCookie != tt.wantCookie {
+				t.Errorf("Expected locale cookie %q, got %q", tt.wantCookie, gotCookie)
+			}
+		})
+	}
+}
@@ -1,12 +1,41 @@
 package application
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/cdriehuys/secret-santa/internal/models"
+	"github.com/cdriehuys/secret-santa/internal/ratelimit"
 	"github.com/justinas/nosurf"
 )
 
+const (
+	sessionCookieName        = "session"
+	pendingSessionCookieName = "two_factor_session"
+)
+
+type contextKey string
+
+const (
+	authenticatedUserKey = contextKey("authenticatedUser")
+	importTooLargeKey    = contextKey("importTooLarge")
+)
+
+// authenticatedUser returns the user attached to the request by the authenticate middleware, and
+// whether there was one.
+func authenticatedUser(r *http.Request) (models.User, bool) {
+	user, ok := r.Context().Value(authenticatedUserKey).(models.User)
+
+	return user, ok
+}
+
 func (a *Application) RecoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
@@ -31,8 +60,214 @@ func (a *Application) preventCSRF(next http.Handler) http.Handler {
 	csrfHandler.SetBaseCookie(http.Cookie{
 		HttpOnly: true,
 		Path:     "/",
-		Secure:   false,
+		Secure:   a.SecureCookies,
 	})
+	csrfHandler.SetFailureHandler(http.HandlerFunc(a.badRequest))
+	csrfHandler.ExemptFunc(importTooLarge)
 
 	return csrfHandler
 }
+
+// limitImportBody caps the size of import requests, and parses the form before the CSRF check
+// reads its token. The token in a form that is too large can't be read, so those requests skip the
+// check. That is safe because the import handler only tells the user the file is too large.
+func (a *Application) limitImportBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes+64*1024)
+
+		var tooLarge *http.MaxBytesError
+		if err := r.ParseMultipartForm(maxImportBytes); errors.As(err, &tooLarge) {
+			r = r.WithContext(context.WithValue(r.Context(), importTooLargeKey, true))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// importTooLarge reports whether limitImportBody found the request too large to read.
+func importTooLarge(r *http.Request) bool {
+	tooLarge, _ := r.Context().Value(importTooLargeKey).(bool)
+
+	return tooLarge
+}
+
+// authenticate attaches the user who owns the request's session cookie, if any, to the request
+// context.
+func (a *Application) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(sessionCookieName)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, err := a.Sessions.User(r.Context(), cookie.Value)
+		if err != nil {
+			if !errors.Is(err, models.ErrNoSession) {
+				a.serverError(w, r, "Failed to load session.", err)
+				return
+			}
+
+			// Clear out the stale cookie so it isn't looked up on every request.
+			http.SetCookie(w, a.sessionCookie("", -1))
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), authenticatedUserKey, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requireAuthentication redirects anonymous users to the login page.
+func (a *Application) requireAuthentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := authenticatedUser(r); !ok {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		// Pages that depend on the logged in user should not be stored in shared caches.
+		w.Header().Add("Cache-Control", "no-store")
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimitKeyFunc identifies the bucket a request draws from. An empty key exempts the request
+// from the limit.
+type rateLimitKeyFunc func(r *http.Request) string
+
+// rateLimit rejects requests with a 429 response once the bucket identified by the key function
+// runs out. The name separates buckets for different routes that share the same key.
+func (a *Application) rateLimit(name string, limit ratelimit.Limit, key rateLimitKeyFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if a.RateLimiter == nil || !limit.Enabled() {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			value := key(r)
+			if value == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			allowed, retryAfter, err := a.RateLimiter.Allow(r.Context(), name+":"+value, limit)
+			if err != nil {
+				// Failing open keeps the site usable if the store is unavailable, at the cost of
+				// briefly losing protection.
+				a.Logger.ErrorContext(r.Context(), "Failed to check rate limit.", "limit", name, "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !allowed {
+				a.Logger.WarnContext(r.Context(), "Rate limited request.", "limit", name, "retryAfter", retryAfter)
+
+				seconds := int(retryAfter.Round(time.Second).Seconds())
+				w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+
+				data := a.templateData(r)
+				a.renderStatus(w, r, http.StatusTooManyRequests, "too-many-requests.html", data)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientIP keys rate limits by the address of the client. Requests through trusted proxies use the
+// last address in X-Forwarded-For that isn't a trusted proxy, since the addresses before it can be
+// forged by the client.
+func (a *Application) clientIP(r *http.Request) string {
+	addrPort, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	client := addrPort.Addr().Unmap()
+	if !a.isTrustedProxy(client) {
+		return client.String()
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for _, hop := range slices.Backward(forwarded) {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hop))
+		if err != nil {
+			break
+		}
+
+		client = addr.Unmap()
+		if !a.isTrustedProxy(client) {
+			break
+		}
+	}
+
+	return client.String()
+}
+
+// requireLoopback hides a route from clients on other machines by responding as if it didn't exist.
+// It's for development tools, which must not be reachable over the network even by mistake.
+func (a *Application) requireLoopback(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, err := netip.ParseAddr(a.clientIP(r))
+		if err != nil || !client.IsLoopback() {
+			a.notFound(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *Application) isTrustedProxy(addr netip.Addr) bool {
+	for _, prefix := range a.TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// formEmail keys rate limits by the email address submitted in the form, so attempts against a
+// single account are limited no matter how many addresses they come from.
+func formEmail(r *http.Request) string {
+	return strings.ToLower(strings.TrimSpace(r.PostFormValue("email")))
+}
+
+// pendingSessionToken keys rate limits by the session waiting on a second factor.
+func pendingSessionToken(r *http.Request) string {
+	cookie, err := r.Cookie(pendingSessionCookieName)
+	if err != nil {
+		return ""
+	}
+
+	return cookie.Value
+}
+
+// sessionCookie builds the cookie that carries a session token. A negative max age deletes the
+// cookie.
+func (a *Application) sessionCookie(token string, maxAge int) *http.Cookie {
+	return a.newSessionCookie(sessionCookieName, token, maxAge)
+}
+
+// pendingSessionCookie builds the cookie that carries the token for a session that is waiting on a
+// second factor. A negative max age deletes the cookie.
+func (a *Application) pendingSessionCookie(token string, maxAge int) *http.Cookie {
+	return a.newSessionCookie(pendingSessionCookieName, token, maxAge)
+}
+
+func (a *Application) newSessionCookie(name string, token string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   a.SecureCookies,
+	}
+}
@@ -1,11 +1,20 @@
 package application_test
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/cdriehuys/secret-santa/internal/application"
 	"github.com/cdriehuys/secret-santa/internal/application/testutils"
+	"github.com/cdriehuys/secret-santa/internal/models"
+	"github.com/cdriehuys/secret-santa/internal/models/mocks"
+	"github.com/cdriehuys/secret-santa/internal/ratelimit"
 )
 
 func TestApplication_RecoverPanic(t *testing.T) {
@@ -25,3 +34,220 @@ func TestApplication_RecoverPanic(t *testing.T) {
 		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, res.StatusCode)
 	}
 }
+
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Allow(context.Context, string, ratelimit.Limit) (bool, time.Duration, error) {
+	return false, 0, errors.New("store unavailable")
+}
+
+func TestApplication_rateLimit(t *testing.T) {
+	limit := ratelimit.Limit{Burst: 2, Every: time.Minute}
+
+	testCases := []struct {
+		name        string
+		store       ratelimit.Store
+		limits      application.RateLimits
+		emails      []string
+		wantStatus  int
+		wantLimited bool
+	}{
+		{
+			name:        "within limit",
+			store:       ratelimit.NewMemoryStore(),
+			limits:      application.RateLimits{LoginPerEmail: limit},
+			emails:      []string{"a@example.com", "a@example.com"},
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantLimited: false,
+		},
+		{
+			name:        "email over limit",
+			store:       ratelimit.NewMemoryStore(),
+			limits:      application.RateLimits{LoginPerEmail: limit},
+			emails:      []string{"a@example.com", "a@example.com", " A@example.com"},
+			wantLimited: true,
+		},
+		{
+			name:        "separate emails",
+			store:       ratelimit.NewMemoryStore(),
+			limits:      application.RateLimits{LoginPerEmail: limit},
+			emails:      []string{"a@example.com", "a@example.com", "b@example.com"},
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantLimited: false,
+		},
+		{
+			name:        "IP over limit",
+			store:       ratelimit.NewMemoryStore(),
+			limits:      application.RateLimits{LoginPerIP: limit},
+			emails:      []string{"a@example.com", "b@example.com", "c@example.com"},
+			wantLimited: true,
+		},
+		{
+			name:        "no store",
+			limits:      application.RateLimits{LoginPerIP: limit},
+			emails:      []string{"a@example.com", "a@example.com", "a@example.com"},
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantLimited: false,
+		},
+		{
+			name:        "store error fails open",
+			store:       failingRateLimitStore{},
+			limits:      application.RateLimits{LoginPerIP: limit},
+			emails:      []string{"a@example.com", "a@example.com", "a@example.com"},
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantLimited: false,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			app := testutils.NewTestApplication(t)
+			app.Users = &mocks.UserModel{AuthenticateError: models.ErrInvalidCredentials}
+			app.RateLimiter = tt.store
+			app.RateLimits = tt.limits
+
+			ts := testutils.NewTestServer(t, app.Routes())
+			defer ts.Close()
+
+			var res testutils.TestResponse
+			for _, email := range tt.emails {
+				form := csrfFormValues(t, app, ts, "/login")
+				form.Add("email", email)
+				form.Add("password", "wrong")
+
+				res = ts.PostForm(t, "/login", form)
+			}
+
+			if tt.wantLimited {
+				if res.Status != http.StatusTooManyRequests {
+					t.Fatalf("Expected status %d, got %d", http.StatusTooManyRequests, res.Status)
+				}
+
+				if got := res.Headers.Get("Retry-After"); got != "60" {
+					t.Errorf("Expected Retry-After of 60 seconds, got %q", got)
+				}
+
+				assertContains(t, res.Body, "Too Many Requests")
+			} else if res.Status != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, res.Status)
+			}
+		})
+	}
+}
+
+// keyRecordingStore allows every request and records the keys it was asked about.
+type keyRecordingStore struct {
+	keys []string
+}
+
+func (s *keyRecordingStore) Allow(_ context.Context, key string, _ ratelimit.Limit) (bool, time.Duration, error) {
+	s.keys = append(s.keys, key)
+
+	return true, 0, nil
+}
+
+func TestApplication_rateLimit_clientIP(t *testing.T) {
+	loopback := netip.MustParsePrefix("127.0.0.0/8")
+	private := netip.MustParsePrefix("10.0.0.0/8")
+
+	testCases := []struct {
+		name           string
+		trustedProxies []netip.Prefix
+		forwardedFor   string
+		wantIP         string
+	}{
+		{
+			name:         "untrusted peer",
+			forwardedFor: "203.0.113.7",
+			wantIP:       "127.0.0.1",
+		},
+		{
+			name:           "trusted proxy",
+			trustedProxies: []netip.Prefix{loopback},
+			forwardedFor:   "203.0.113.7",
+			wantIP:         "203.0.113.7",
+		},
+		{
+			name:           "forged addresses before the client",
+			trustedProxies: []netip.Prefix{loopback},
+			forwardedFor:   "198.51.100.1, 203.0.113.7",
+			wantIP:         "203.0.113.7",
+		},
+		{
+			name:           "chain of trusted proxies",
+			trustedProxies: []netip.Prefix{loopback, private},
+			forwardedFor:   "203.0.113.7, 10.0.0.2",
+			wantIP:         "203.0.113.7",
+		},
+		{
+			name:           "trusted proxy without header",
+			trustedProxies: []netip.Prefix{loopback},
+			wantIP:         "127.0.0.1",
+		},
+		{
+			name:           "malformed header",
+			trustedProxies: []netip.Prefix{loopback},
+			forwardedFor:   "203.0.113.7, not-an-address",
+			wantIP:         "127.0.0.1",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &keyRecordingStore{}
+
+			app := testutils.NewTestApplication(t)
+			app.Users = &mocks.UserModel{AuthenticateError: models.ErrInvalidCredentials}
+			app.RateLimiter = store
+			app.RateLimits = application.RateLimits{LoginPerIP: ratelimit.Limit{Burst: 1, Every: time.Minute}}
+			app.TrustedProxies = tt.trustedProxies
+
+			routes := app.Routes()
+			ts := testutils.NewTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.forwardedFor != "" {
+					r.Header.Set("X-Forwarded-For", tt.forwardedFor)
+				}
+
+				routes.ServeHTTP(w, r)
+			}))
+			defer ts.Close()
+
+			form := csrfFormValues(t, app, ts, "/login")
+			form.Add("email", "a@example.com")
+			form.Add("password", "wrong")
+
+			ts.PostForm(t, "/login", form)
+
+			var got []string
+			for _, key := range store.keys {
+				if ip, ok := strings.CutPrefix(key, "login-ip:"); ok {
+					got = append(got, ip)
+				}
+			}
+
+			if len(got) != 1 || got[0] != tt.wantIP {
+				t.Errorf("Expected requests to be limited by IP %q, got %v", tt.wantIP, got)
+			}
+		})
+	}
+}
+
+func TestApplication_preventCSRF_secureCookies(t *testing.T) {
+	for _, secure := range []bool{false, true} {
+		app := testutils.NewTestApplication(t)
+		app.SecureCookies = secure
+
+		ts := testutils.NewTestServer(t, app.Routes())
+		defer ts.Close()
+
+		res := ts.Get(t, "/login")
+		if len(res.Cookies) == 0 {
+			t.Fatal("Expected the CSRF cookie to be set")
+		}
+
+		for _, cookie := range res.Cookies {
+			if cookie.Secure != secure {
+				t.Errorf("Expected cookie %q to have Secure=%t, got %t", cookie.Name, secure, cookie.Secure)
+			}
+		}
+	}
+}
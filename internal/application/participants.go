@@ -0,0 +1,130 @@
+package application
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cdriehuys/secret-santa/internal/i18n"
+	"github.com/cdriehuys/secret-santa/internal/roster"
+)
+
+// pairingLimits returns the configured limits, using roster.DefaultLimits for any that are zero.
+func (a *Application) pairingLimits() roster.Limits {
+	limits := a.PairingLimits
+
+	if limits.MaxParticipants <= 0 {
+		limits.MaxParticipants = roster.DefaultLimits.MaxParticipants
+	}
+
+	if limits.MaxExclusions <= 0 {
+		limits.MaxExclusions = roster.DefaultLimits.MaxExclusions
+	}
+
+	return limits
+}
+
+// participantFields are format strings for the names of the fields that validation errors are
+// reported for. Name, Email, and Exclusions are formatted with the participant's index, and
+// Exclusion with the participant's index followed by the exclusion's index.
+type participantFields struct {
+	List       string
+	Name       string
+	Email      string
+	Exclusions string
+	Exclusion  string
+}
+
+// errorMap converts validation errors into messages keyed by the field they were found in.
+func (f participantFields) errorMap(errs []roster.Error) map[string]i18n.Message {
+	fieldErrors := make(map[string]i18n.Message, len(errs))
+
+	for _, err := range errs {
+		var key string
+		switch {
+		case err.Participant < 0:
+			key = f.List
+		case err.Field == "name":
+			key = fmt.Sprintf(f.Name, err.Participant)
+		case err.Field == "email":
+			key = fmt.Sprintf(f.Email, err.Participant)
+		case err.Exclusion < 0:
+			key = fmt.Sprintf(f.Exclusions, err.Participant)
+		default:
+			key = fmt.Sprintf(f.Exclusion, err.Participant, err.Exclusion)
+		}
+
+		fieldErrors[key] = err.Message
+	}
+
+	return fieldErrors
+}
+
+// participantFormKey matches the names of the participant form fields, "name[i]" and
+// "name[i].exclusion[j]".
+var participantFormKey = regexp.MustCompile(`^name\[(\d+)\](?:\.exclusion\[(\d+)\])?$`)
+
+// parseParticipantsForm reads the participants from the pairings form. Fields are ordered by their
+// indices, which don't need to be contiguous, so rows left blank or removed in the browser are
+// skipped.
+func parseParticipantsForm(form url.Values) []roster.Participant {
+	type row struct {
+		name       string
+		exclusions map[int]string
+	}
+
+	rows := make(map[int]*row)
+	for key := range form {
+		match := participantFormKey.FindStringSubmatch(key)
+		if match == nil {
+			continue
+		}
+
+		i, err := strconv.Atoi(match[1])
+		if err != nil {
+			continue
+		}
+
+		if rows[i] == nil {
+			rows[i] = &row{exclusions: make(map[int]string)}
+		}
+
+		value := strings.TrimSpace(form.Get(key))
+		if match[2] == "" {
+			rows[i].name = value
+			continue
+		}
+
+		j, err := strconv.Atoi(match[2])
+		if err != nil {
+			continue
+		}
+
+		if value != "" {
+			rows[i].exclusions[j] = value
+		}
+	}
+
+	var participants []roster.Participant
+	for _, i := range slices.Sorted(maps.Keys(rows)) {
+		row := rows[i]
+
+		// A row with exclusions but no name is kept so the missing name is reported.
+		if row.name == "" && len(row.exclusions) == 0 {
+			continue
+		}
+
+		p := roster.Participant{Name: row.name}
+		for _, j := range slices.Sorted(maps.Keys(row.exclusions)) {
+			p.Exclusions = append(p.Exclusions, row.exclusions[j])
+		}
+
+		participants = append(participants, p)
+	}
+
+	return participants
+}
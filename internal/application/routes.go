@@ -9,19 +9,114 @@ import (
 func (a *Application) Routes() http.Handler {
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("GET /pairings", a.pairingsGet)
-	mux.HandleFunc("POST /pairings", a.pairingsPost)
+	// The API is used by scripts rather than browsers, so it doesn't use sessions or CSRF protection.
+	mux.HandleFunc("POST /api/pairings", a.apiPairingsPost)
+	mux.HandleFunc("POST /api/participants/import", a.apiParticipantsImportPost)
+	mux.HandleFunc("GET /api/openapi.yaml", a.apiOpenAPIGet)
+
+	// Probes for the load balancer. Like the API, they don't use sessions or CSRF protection.
+	mux.HandleFunc("GET /healthz", a.healthzGet)
+	mux.HandleFunc("GET /readyz", a.readyzGet)
+	mux.HandleFunc("GET /version", a.versionGet)
 
 	// Middleware applied to dynamic requests, ie requests that depend on the user who sent them.
-	dynamic := alice.New(a.preventCSRF)
+	dynamic := alice.New(a.preventCSRF, a.authenticate)
 
 	mux.Handle("GET /{$}", dynamic.ThenFunc(a.homeGet))
+	mux.Handle("GET /pairings", dynamic.ThenFunc(a.pairingsGet))
+	mux.Handle("POST /pairings", dynamic.ThenFunc(a.pairingsPost))
+	mux.Handle("POST /pairings/export", dynamic.ThenFunc(a.pairingsExportPost))
+	mux.Handle("GET /pairings/import", dynamic.ThenFunc(a.pairingsImportGet))
+	mux.Handle("POST /pairings/import", alice.New(a.limitImportBody).Extend(dynamic).ThenFunc(a.pairingsImportPost))
+	mux.Handle("POST /locale", dynamic.ThenFunc(a.localePost))
+	mux.Handle("GET /login", dynamic.ThenFunc(a.loginGet))
+	mux.Handle("POST /login", dynamic.Append(
+		a.rateLimit("login-ip", a.RateLimits.LoginPerIP, a.clientIP),
+		a.rateLimit("login-email", a.RateLimits.LoginPerEmail, formEmail),
+	).ThenFunc(a.loginPost))
+	mux.Handle("GET /login/two-factor", dynamic.ThenFunc(a.loginTwoFactorGet))
+	mux.Handle("POST /login/two-factor", dynamic.Append(
+		a.rateLimit("two-factor-ip", a.RateLimits.TwoFactorPerIP, a.clientIP),
+		a.rateLimit("two-factor-session", a.RateLimits.TwoFactorPerSession, pendingSessionToken),
+	).ThenFunc(a.loginTwoFactorPost))
+	mux.Handle("POST /logout", dynamic.ThenFunc(a.logoutPost))
 	mux.Handle("GET /register", dynamic.ThenFunc(a.registerGet))
-	mux.Handle("POST /register", dynamic.ThenFunc(a.registerPost))
+	mux.Handle("POST /register", dynamic.Append(
+		a.rateLimit("register-ip", a.RateLimits.RegisterPerIP, a.clientIP),
+		a.rateLimit("register-email", a.RateLimits.RegisterPerEmail, formEmail),
+	).ThenFunc(a.registerPost))
 	mux.Handle("GET /register/success", dynamic.ThenFunc(a.registerSuccess))
+	mux.Handle("GET /verify-email/{token}", dynamic.ThenFunc(a.verifyEmailGet))
+	mux.Handle("GET /account/deleted", dynamic.ThenFunc(a.accountDeleted))
+
+	// Middleware applied to requests that are only available to logged in users.
+	protected := dynamic.Append(a.requireAuthentication)
+
+	mux.Handle("GET /account", protected.ThenFunc(a.accountGet))
+	mux.Handle("POST /account/delete", protected.ThenFunc(a.accountDeletePost))
+	mux.Handle("POST /account/email", protected.ThenFunc(a.accountEmailPost))
+	mux.Handle("GET /account/email/success", protected.ThenFunc(a.accountEmailSuccess))
+	mux.Handle("GET /account/export", protected.ThenFunc(a.accountExportGet))
+	mux.Handle("POST /account/password", protected.ThenFunc(a.accountPasswordPost))
+	mux.Handle("GET /account/password/success", protected.ThenFunc(a.accountPasswordSuccess))
+	mux.Handle("GET /account/two-factor", protected.ThenFunc(a.accountTwoFactorGet))
+	mux.Handle("POST /account/two-factor", protected.ThenFunc(a.accountTwoFactorPost))
+	mux.Handle("POST /account/two-factor/disable", protected.ThenFunc(a.accountTwoFactorDisablePost))
+
+	if a.Mailbox != nil {
+		devOnly := dynamic.Append(a.requireLoopback)
+
+		mux.Handle("GET /dev/mail", devOnly.ThenFunc(a.devMailGet))
+		mux.Handle("GET /dev/mail/{name}", devOnly.ThenFunc(a.devMailMessageGet))
+	}
+
+	if a.TemplateReloads != nil {
+		mux.HandleFunc("GET /dev/reload", a.devReloadGet)
+	}
+
+	// Requests that don't match any route get the site's 404 page. Forms posted to a missing page
+	// skip the CSRF check, since nothing is changed, so they get a 404 rather than a 400.
+	notFound := dynamic.ThenFunc(a.notFound)
+	notFoundUnsafe := alice.New(a.authenticate).ThenFunc(a.notFound)
+
+	routes := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !hasRoute(mux, r) {
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				notFound.ServeHTTP(w, r)
+			} else {
+				notFoundUnsafe.ServeHTTP(w, r)
+			}
+
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	})
 
 	// Middleware applied to all requests.
-	standard := alice.New(a.RecoverPanic)
+	standard := alice.New(a.RecoverPanic, a.negotiateLocale)
+
+	return standard.Then(routes)
+}
+
+// routeMethods are the methods that routes may be registered for.
+var routeMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// hasRoute reports whether the mux has a route for the request's path under any method. Requests
+// for a path that only has routes for other methods are left to the mux, which responds with 405
+// and an Allow header.
+func hasRoute(mux *http.ServeMux, r *http.Request) bool {
+	if _, pattern := mux.Handler(r); pattern != "" {
+		return true
+	}
+
+	probe := r.WithContext(r.Context())
+	for _, method := range routeMethods {
+		probe.Method = method
+		if _, pattern := mux.Handler(probe); pattern != "" {
+			return true
+		}
+	}
 
-	return standard.Then(mux)
+	return false
 }
@@ -0,0 +1,299 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"path"
+	"slices"
+	"time"
+
+	"github.com/cdriehuys/secret-santa/internal/email"
+	"github.com/cdriehuys/secret-santa/internal/i18n"
+	"github.com/cdriehuys/secret-santa/internal/models"
+	"github.com/cdriehuys/secret-santa/internal/pairings"
+	"github.com/cdriehuys/secret-santa/internal/roster"
+	"github.com/google/uuid"
+)
+
+// PageTemplates is a template engine that can list the pages it renders and the text they
+// translate.
+type PageTemplates interface {
+	TemplateEngine
+
+	Names() []string
+	Messages() []string
+}
+
+// EmailTemplates is an email template engine that can list the emails it renders, which of them
+// aren't translated, and the text they translate.
+type EmailTemplates interface {
+	EmailTemplateEngine
+
+	Names() []string
+	Untranslated() []string
+	Messages() []string
+}
+
+// pageFixture is a named set of representative data for rendering a page, filled in the same way
+// as the handler that renders it.
+type pageFixture struct {
+	name string
+	fill func(*TemplateData)
+}
+
+// staticFixtures are the fixtures for pages that don't render any data of their own.
+var staticFixtures = []pageFixture{{name: "static", fill: func(*TemplateData) {}}}
+
+var fixtureMailMessage = email.StoredMessage{
+	Message: email.Message{
+		To:      "santa@example.com",
+		From:    "no-reply@example.com",
+		Subject: "Verify Your Email",
+		Text:    "Visit https://example.com/verify-email/token",
+		HTML:    `<a href="https://example.com/verify-email/token">Verify</a>`,
+	},
+	Name: "1.eml",
+	Date: time.Date(2025, time.December, 25, 8, 0, 0, 0, time.UTC),
+}
+
+var fixtureParticipant = roster.Imported{
+	Participant: roster.Participant{Name: "Ross", Email: "ross@example.com", Household: "Geller", Exclusions: []string{"Joey", "Chandler"}},
+	Row:         2,
+}
+
+// pageFixtures lists the fixtures for every page. Pages that show different content depending on
+// their data have a fixture for each variant. New pages must be added here, or the template check
+// fails.
+var pageFixtures = map[string][]pageFixture{
+	"account.html": {{
+		name: "errors",
+		fill: func(data *TemplateData) {
+			data.Form = accountEmailForm{Email: "new@example.com"}
+			data.Errors = map[string]i18n.Message{
+				"email":            i18n.NewMessage("Please enter an email address."),
+				"current_password": i18n.NewMessage("Password is incorrect."),
+				"new_password":     i18n.NewMessage("Password must be at least %d characters.", MinPasswordLength),
+				"delete_password":  i18n.NewMessage("Password is incorrect."),
+			}
+		},
+	}},
+	"account-deleted.html":          staticFixtures,
+	"account-email-success.html":    staticFixtures,
+	"account-password-success.html": staticFixtures,
+	"account-two-factor.html": {{
+		name: "enrollment",
+		fill: func(data *TemplateData) {
+			data.TwoFactorEnrollment = models.TwoFactorEnrollment{
+				Secret:          "JBSWY3DPEHPK3PXP",
+				ProvisioningURI: "otpauth://totp/Secret%20Santa:santa@example.com?secret=JBSWY3DPEHPK3PXP",
+			}
+			data.Errors = map[string]i18n.Message{"code": i18n.NewMessage("That code is not valid.")}
+		},
+	}},
+	"account-two-factor-recovery-codes.html": {{
+		name: "codes",
+		fill: func(data *TemplateData) {
+			data.RecoveryCodes = []string{"aaaa-bbbb", "cccc-dddd"}
+		},
+	}},
+	"bad-request.html": staticFixtures,
+	"dev-mail.html": {{
+		name: "messages",
+		fill: func(data *TemplateData) {
+			data.MailMessages = []email.StoredMessage{fixtureMailMessage}
+		},
+	}},
+	"dev-mail-message.html": {{
+		name: "message",
+		fill: func(data *TemplateData) {
+			data.MailMessage = fixtureMailMessage
+		},
+	}},
+	"home.html": staticFixtures,
+	"login.html": {{
+		name: "errors",
+		fill: func(data *TemplateData) {
+			data.Form = loginForm{Email: "santa@example.com"}
+			data.Errors = map[string]i18n.Message{"form": i18n.NewMessage("Email or password is incorrect.")}
+		},
+	}},
+	"login-two-factor.html": {{
+		name: "errors",
+		fill: func(data *TemplateData) {
+			data.Errors = map[string]i18n.Message{"code": i18n.NewMessage("That code is not valid.")}
+		},
+	}},
+	"not-found.html": staticFixtures,
+	"pairings.html": {{
+		name: "errors",
+		fill: func(data *TemplateData) {
+			data.Form = newPairingsForm([]roster.Participant{{Name: "Ross", Exclusions: []string{"Monica"}}}, roster.DefaultLimits, 0)
+			data.Errors = map[string]i18n.Message{
+				"form":                 i18n.NewMessage("No pairings satisfy the exclusions. Try removing some exclusions."),
+				"name[0]":              i18n.NewMessage("%q is listed more than once.", "Ross"),
+				"name[0].exclusions":   i18n.NewMessage("There can be at most %d exclusions.", 3),
+				"name[0].exclusion[0]": i18n.NewMessage("%q is not a participant.", "Monica"),
+			}
+		},
+	}},
+	"pairings-import.html": {
+		{
+			name: "preview",
+			fill: func(data *TemplateData) {
+				data.Form = importForm{CSV: "name\nRoss", Participants: []roster.Imported{fixtureParticipant}}
+			},
+		},
+		{
+			name: "row errors",
+			fill: func(data *TemplateData) {
+				data.Form = importForm{CSV: "name\nRoss", RowErrors: []roster.ImportError{{Row: 2, Column: "name", Message: i18n.NewMessage("Name is required.")}}}
+			},
+		},
+	},
+	"pairings-results.html": {{
+		name: "pairings",
+		fill: fillPairings,
+	}},
+	"pairings-slips.html": {{
+		name: "pairings",
+		fill: fillPairings,
+	}},
+	"register.html":             staticFixtures,
+	"register-success.html":     staticFixtures,
+	"server-error.html":         staticFixtures,
+	"too-many-requests.html":    staticFixtures,
+	"verify-email-invalid.html": staticFixtures,
+	"verify-email-success.html": staticFixtures,
+}
+
+func fillPairings(data *TemplateData) {
+	data.Pairings = []pairings.Pairing{{From: "Ross", To: "Joey"}, {From: "Joey", To: "Ross"}}
+}
+
+// pageFixtureData returns the data for each of the page's fixtures, rendered both with and without
+// an authenticated user and keyed by a description of the variant. Pages without fixtures return an
+// error so that they aren't silently rendered with empty data.
+func pageFixtureData(page string) (map[string]TemplateData, error) {
+	fixtures, ok := pageFixtures[page]
+	if !ok {
+		return nil, fmt.Errorf("no fixture for %q", page)
+	}
+
+	variants := make(map[string]TemplateData, 2*len(fixtures))
+	for _, fixture := range fixtures {
+		for _, isAuthenticated := range []bool{false, true} {
+			data := TemplateData{
+				IsAuthenticated: isAuthenticated,
+				CSRFToken:       "csrf-token",
+				Errors:          map[string]i18n.Message{},
+				LiveReload:      true,
+			}
+
+			if isAuthenticated {
+				data.User = models.User{ID: uuid.New(), Email: "santa@example.com"}
+			}
+
+			fixture.fill(&data)
+			variants[fmt.Sprintf("%s, authenticated: %v", fixture.name, isAuthenticated)] = data
+		}
+	}
+
+	return variants, nil
+}
+
+// missingTranslations returns an error for each template that isn't translated into every supported
+// locale.
+func missingTranslations(kind string, names []string, untranslated []string) []error {
+	var errs []error
+	for _, name := range names {
+		locale, base := splitLocalizedTemplate(name)
+		if locale != DefaultLocale {
+			continue
+		}
+
+		for _, locale := range SupportedLocales[1:] {
+			localized := localizedTemplate(locale, base)
+			if !slices.Contains(names, localized) || slices.Contains(untranslated, localized) {
+				errs = append(errs, fmt.Errorf("missing translation: %s %q", kind, localized))
+			}
+		}
+	}
+
+	return errs
+}
+
+// missingMessages returns an error for each text that a supported locale doesn't translate.
+func missingMessages(kind string, messages []string) []error {
+	var errs []error
+	for _, msg := range messages {
+		for _, locale := range SupportedLocales[1:] {
+			if !i18n.Translated(locale, msg) {
+				errs = append(errs, fmt.Errorf("missing translation: %s text %q in %s", kind, msg, locale))
+			}
+		}
+	}
+
+	return errs
+}
+
+// emailFixture is representative data for rendering an email.
+var emailFixture = EmailTemplateData{
+	Preheader:        "A short summary of the email.",
+	VerificationLink: "https://example.com/verify-email/token",
+	Giver:            "Ross",
+	Recipient:        "Joey",
+}
+
+// CheckTemplates renders every page and email, in every locale, with representative data. It
+// returns the errors from every template that failed, such as ones referencing missing fields or
+// undefined templates, so they can be caught before someone visits the broken page. Text and emails
+// that haven't been translated into every supported locale are also reported, since a missing
+// translation would switch languages in the middle of a page.
+func CheckTemplates(pages PageTemplates, emails EmailTemplates) error {
+	errs := missingMessages("page", pages.Messages())
+	errs = append(errs, missingMessages("email", emails.Messages())...)
+	errs = append(errs, missingTranslations("email", emails.Names(), emails.Untranslated())...)
+
+	for _, page := range pages.Names() {
+		variants, err := pageFixtureData(page)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("page %q: %v", page, err))
+			continue
+		}
+
+		for _, locale := range SupportedLocales {
+			for _, variant := range slices.Sorted(maps.Keys(variants)) {
+				data := variants[variant]
+				data.Locale = locale
+
+				if err := pages.Render(io.Discard, page, data); err != nil {
+					errs = append(errs, fmt.Errorf("page %q in %s (%s): %v", page, locale, variant, err))
+				}
+			}
+		}
+	}
+
+	for _, name := range emails.Names() {
+		data := emailFixture
+		data.Locale, _ = splitLocalizedTemplate(name)
+
+		if err := emails.Render(io.Discard, name, data); err != nil {
+			errs = append(errs, fmt.Errorf("email %q: %v", name, err))
+		}
+
+		// The subject and preheader come from the plain text version of each email.
+		if path.Ext(name) != ".txt" {
+			continue
+		}
+
+		for _, block := range []string{"subject", "preheader"} {
+			if err := emails.RenderBlock(io.Discard, name, block, data); err != nil {
+				errs = append(errs, fmt.Errorf("email %q %s: %v", name, block, err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,109 @@
+package application_test
+
+import (
+	"io/fs"
+	"log/slog"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/cdriehuys/secret-santa/internal/application"
+	"github.com/cdriehuys/secret-santa/internal/templating"
+	"github.com/cdriehuys/secret-santa/ui"
+)
+
+// TestCheckTemplates_embedded catches mistakes in the real templates, such as references to missing
+// fields, without needing a test for every page.
+func TestCheckTemplates_embedded(t *testing.T) {
+	pageFS, err := fs.Sub(ui.FS, "templates")
+	if err != nil {
+		t.Fatalf("failed to load templates: %v", err)
+	}
+
+	pages, err := templating.NewTemplateCache(slog.New(slog.DiscardHandler), pageFS, templating.DefaultFuncs())
+	if err != nil {
+		t.Fatalf("failed to construct template cache: %v", err)
+	}
+
+	emailFS, err := fs.Sub(ui.EmailFS, "emails")
+	if err != nil {
+		t.Fatalf("failed to load email templates: %v", err)
+	}
+
+	emails, err := templating.NewEmailTemplateCache(slog.New(slog.DiscardHandler), emailFS)
+	if err != nil {
+		t.Fatalf("failed to construct email template cache: %v", err)
+	}
+
+	if err := application.CheckTemplates(pages, emails); err != nil {
+		t.Errorf("Expected templates to render, got:\n%v", err)
+	}
+}
+
+func TestCheckTemplates_broken(t *testing.T) {
+	pageFS := fstest.MapFS{
+		"base.html": &fstest.MapFile{
+			Data: []byte(`{{ define "main" }}{{ block "content" . }}{{ end }}{{ end }}`),
+		},
+		"pages/home.html": &fstest.MapFile{
+			Data: []byte(`{{ define "content" }}{{ .CSRFToken }} {{ T .Locale "Not translated" }}{{ end }}`),
+		},
+		"pages/register.html": &fstest.MapFile{
+			Data: []byte(`{{ define "content" }}{{ .User.Name }}{{ end }}`),
+		},
+		"pages/login.html": &fstest.MapFile{
+			Data: []byte(`{{ define "content" }}{{ template "nav" . }}{{ end }}`),
+		},
+		"pages/no-fixture.html": &fstest.MapFile{
+			Data: []byte(`{{ define "content" }}{{ .CSRFToken }}{{ end }}`),
+		},
+	}
+
+	emailFS := fstest.MapFS{
+		"base.txt": &fstest.MapFile{
+			Data: []byte(`{{ define "main" }}{{ block "content" . }}{{ end }}{{ end }}{{ define "preheader" }}{{ end }}`),
+		},
+		"subjects/ok.txt": &fstest.MapFile{
+			Data: []byte(`{{ define "subject" }}Hi{{ end }}{{ define "content" }}{{ .VerificationLink }}{{ end }}`),
+		},
+		"subjects/no-subject.txt": &fstest.MapFile{
+			Data: []byte(`{{ define "content" }}Hello{{ end }}`),
+		},
+	}
+
+	pages, err := templating.NewTemplateCache(slog.New(slog.DiscardHandler), pageFS, templating.DefaultFuncs())
+	if err != nil {
+		t.Fatalf("failed to construct template cache: %v", err)
+	}
+
+	emails, err := templating.NewEmailTemplateCache(slog.New(slog.DiscardHandler), emailFS)
+	if err != nil {
+		t.Fatalf("failed to construct email template cache: %v", err)
+	}
+
+	err = application.CheckTemplates(pages, emails)
+	if err == nil {
+		t.Fatal("Expected broken templates to be reported")
+	}
+
+	got := err.Error()
+	for _, want := range []string{
+		`"register.html"`,
+		`page "login.html" in de`,
+		`page "no-fixture.html": no fixture`,
+		`"no-subject.txt" subject`,
+		`missing translation: page text "Not translated" in de`,
+		`missing translation: page text "Not translated" in es`,
+		`missing translation: email "es/ok.txt"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected error to mention %s, got:\n%s", want, got)
+		}
+	}
+
+	for _, notWant := range []string{`"home.html"`, `"ok.txt"`} {
+		if strings.Contains(got, notWant) {
+			t.Errorf("Expected error not to mention %s, got:\n%s", notWant, got)
+		}
+	}
+}
@@ -13,13 +13,16 @@ import (
 	"testing"
 
 	"github.com/cdriehuys/secret-santa/internal/application"
+	"github.com/cdriehuys/secret-santa/internal/models/mocks"
 	"github.com/cdriehuys/secret-santa/internal/templating"
 	"github.com/cdriehuys/secret-santa/ui"
 )
 
 func NewTestApplication(t *testing.T) *application.Application {
 	app := &application.Application{
-		Logger: slog.New(slog.DiscardHandler),
+		Logger:    slog.New(slog.DiscardHandler),
+		Sessions:  &mocks.SessionModel{},
+		TwoFactor: &mocks.TwoFactorModel{},
 	}
 
 	// Default to using the embedded file system like production.
@@ -28,7 +31,7 @@ func NewTestApplication(t *testing.T) *application.Application {
 		t.Fatalf("failed to load templates from file system: %v", err)
 	}
 
-	templates, err := templating.NewTemplateCache(app.Logger, templateFS)
+	templates, err := templating.NewTemplateCache(app.Logger, templateFS, templating.DefaultFuncs())
 	if err != nil {
 		t.Fatalf("failed to construct template cache: %v", err)
 	}
@@ -90,9 +93,15 @@ func (ts *TestServer) Get(t *testing.T, path string) TestResponse {
 }
 
 func (ts *TestServer) PostForm(t *testing.T, path string, form url.Values) TestResponse {
-	req := ts.makeRequest(t, http.MethodPost, path, strings.NewReader(form.Encode()))
+	return ts.Post(t, path, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+}
+
+// Post sends a body of any content type, such as a multipart form, as if it came from a page on the
+// same site.
+func (ts *TestServer) Post(t *testing.T, path string, contentType string, body io.Reader) TestResponse {
+	req := ts.makeRequest(t, http.MethodPost, path, body)
 
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Content-Type", contentType)
 	req.Header.Set("Sec-Fetch-Site", "same-origin")
 
 	return ts.doRequest(t, req)
@@ -1,11 +1,14 @@
 package application_test
 
 import (
+	"net/http"
 	"net/url"
 	"testing"
 
 	"github.com/cdriehuys/secret-santa/internal/application"
 	"github.com/cdriehuys/secret-santa/internal/application/testutils"
+	"github.com/cdriehuys/secret-santa/internal/models"
+	"github.com/cdriehuys/secret-santa/internal/models/mocks"
 )
 
 func csrfFormValues(t *testing.T, app *application.Application, ts *testutils.TestServer, formURL string) url.Values {
@@ -25,3 +28,21 @@ func csrfFormValues(t *testing.T, app *application.Application, ts *testutils.Te
 
 	return form
 }
+
+// logIn seeds a session for the user and attaches its cookie to the test server's client.
+func logIn(t *testing.T, app *application.Application, ts *testutils.TestServer, user models.User) {
+	token := "test-session-" + user.ID.String()
+	app.Sessions = &mocks.SessionModel{Sessions: map[string]models.User{token: user}}
+
+	setCookie(t, ts, "session", token)
+}
+
+// setCookie adds a cookie to the test server client's jar.
+func setCookie(t *testing.T, ts *testutils.TestServer, name string, value string) {
+	serverURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatalf("failed to parse test server URL: %v", err)
+	}
+
+	ts.Client().Jar.SetCookies(serverURL, []*http.Cookie{{Name: name, Value: value, Path: "/"}})
+}
@@ -0,0 +1,435 @@
+// Package config describes the settings for the web server and loads them. Each setting has a
+// default that is overridden by an optional YAML file, then by an environment variable, and
+// finally by a flag.
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/mail"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/alexedwards/argon2id"
+	"github.com/cdriehuys/secret-santa/internal/email"
+	"github.com/cdriehuys/secret-santa/internal/roster"
+	"github.com/cdriehuys/secret-santa/internal/security"
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	// Addr is the address the server listens on.
+	Addr string `yaml:"addr"`
+
+	// BaseURL is the address the site is reached at, used to build the links in emails.
+	BaseURL string `yaml:"base_url"`
+
+	// Dev enables development tools that expose private data, like the viewer for emails saved to
+	// a Maildir. It's refused unless the base URL is on localhost.
+	Dev bool `yaml:"dev"`
+
+	// TrustedProxies are the addresses of proxies, like a load balancer, whose X-Forwarded-For
+	// header is trusted to identify the client.
+	TrustedProxies Prefixes `yaml:"trusted_proxies"`
+
+	Timeouts  Timeouts  `yaml:"timeouts"`
+	Log       Log       `yaml:"log"`
+	Database  Database  `yaml:"database"`
+	Email     Email     `yaml:"email"`
+	Cookies   Cookies   `yaml:"cookies"`
+	TwoFactor TwoFactor `yaml:"two_factor"`
+	Limits    Limits    `yaml:"limits"`
+	Argon2    Argon2    `yaml:"argon2"`
+	Templates Templates `yaml:"templates"`
+}
+
+// Timeouts bound how long the server spends on a request. A zero read, write, or idle timeout is
+// not enforced.
+type Timeouts struct {
+	Read  time.Duration `yaml:"read"`
+	Write time.Duration `yaml:"write"`
+	Idle  time.Duration `yaml:"idle"`
+
+	// Drain is how long the server keeps serving after it fails its readiness probe and before it
+	// stops accepting connections, which gives load balancers time to stop sending it requests.
+	Drain time.Duration `yaml:"drain"`
+
+	// Shutdown is how long in-flight requests and background workers get to finish when the server
+	// is stopped.
+	Shutdown time.Duration `yaml:"shutdown"`
+}
+
+type Log struct {
+	Level slog.Level `yaml:"level"`
+
+	// Format is "text" or "json".
+	Format string `yaml:"format"`
+}
+
+type Database struct {
+	// URL is the connection string for the database. It has no flag since it usually contains a
+	// password.
+	URL string `yaml:"url"`
+}
+
+// Email backends.
+const (
+	BackendConsole = "console"
+	BackendMaildir = "maildir"
+	BackendSMTP    = "smtp"
+)
+
+type Email struct {
+	// Backend chooses where emails go: "console" prints them, "maildir" saves them to Maildir for
+	// development, and "smtp" sends them through an SMTP server. If empty, it's chosen by whether
+	// Maildir or SMTP.Host are set.
+	Backend string `yaml:"backend"`
+
+	// Sender is the address emails are sent from.
+	Sender string `yaml:"sender"`
+
+	Maildir string `yaml:"maildir"`
+	SMTP    SMTP   `yaml:"smtp"`
+
+	// ReadinessCheck adds the mailer to the readiness probe, so the server is taken out of the load
+	// balancer while the SMTP server or Maildir can't be reached. It's off by default because
+	// emails wait in the outbox until the mailer is back.
+	ReadinessCheck bool `yaml:"readiness_check"`
+}
+
+type SMTP struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+
+	// Password has no flag so it doesn't show up in the process list.
+	Password string `yaml:"password"`
+
+	Auth string `yaml:"auth"`
+	TLS  string `yaml:"tls"`
+}
+
+// Prefixes is a list of IP address ranges. A single address is a range containing only itself. As
+// a flag or environment variable, the ranges are separated by commas.
+type Prefixes []netip.Prefix
+
+func (p *Prefixes) String() string {
+	if p == nil {
+		return ""
+	}
+
+	ranges := make([]string, len(*p))
+	for i, prefix := range *p {
+		ranges[i] = prefix.String()
+	}
+
+	return strings.Join(ranges, ",")
+}
+
+func (p *Prefixes) Set(value string) error {
+	var ranges []string
+	for r := range strings.SplitSeq(value, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			ranges = append(ranges, r)
+		}
+	}
+
+	return p.parse(ranges)
+}
+
+func (p *Prefixes) UnmarshalYAML(node *yaml.Node) error {
+	var ranges []string
+	if err := node.Decode(&ranges); err != nil {
+		return err
+	}
+
+	return p.parse(ranges)
+}
+
+func (p *Prefixes) parse(ranges []string) error {
+	prefixes := make(Prefixes, 0, len(ranges))
+	for _, r := range ranges {
+		if !strings.Contains(r, "/") {
+			addr, err := netip.ParseAddr(r)
+			if err != nil {
+				return err
+			}
+
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(r)
+		if err != nil {
+			return err
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	*p = prefixes
+
+	return nil
+}
+
+type Cookies struct {
+	// Secure limits cookies to HTTPS. It should be enabled whenever the site is served over HTTPS,
+	// including behind a proxy that terminates TLS.
+	Secure bool `yaml:"secure"`
+}
+
+type TwoFactor struct {
+	// Key encrypts the TOTP secrets stored in the database, so a copy of the database isn't enough
+	// to generate users' codes. It is 32 random bytes encoded as base64. Like the database URL, it
+	// has no flag.
+	Key string `yaml:"key"`
+}
+
+// DecodeKey returns the bytes of the TOTP secret key.
+func (t TwoFactor) DecodeKey() ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(t.Key)
+	if err != nil {
+		return nil, fmt.Errorf("TOTP key is not valid base64: %v", err)
+	}
+
+	if len(key) != security.SecretBoxKeySize {
+		return nil, fmt.Errorf("TOTP key must be %d bytes, got %d", security.SecretBoxKeySize, len(key))
+	}
+
+	return key, nil
+}
+
+type Limits struct {
+	MaxParticipants int `yaml:"max_participants"`
+	MaxExclusions   int `yaml:"max_exclusions"`
+}
+
+type Argon2 struct {
+	Memory      uint `yaml:"memory"`
+	Iterations  uint `yaml:"iterations"`
+	Parallelism uint `yaml:"parallelism"`
+}
+
+// Templates are development settings for editing templates without rebuilding.
+type Templates struct {
+	Live       string `yaml:"live"`
+	LiveEmail  string `yaml:"live_email"`
+	LiveReload bool   `yaml:"live_reload"`
+}
+
+// Default returns the settings used for anything that isn't configured. They suit local
+// development.
+func Default() Config {
+	return Config{
+		Addr:    ":8080",
+		BaseURL: "http://localhost:8080",
+		Timeouts: Timeouts{
+			Read:     10 * time.Second,
+			Write:    30 * time.Second,
+			Idle:     2 * time.Minute,
+			Drain:    5 * time.Second,
+			Shutdown: 15 * time.Second,
+		},
+		Log: Log{
+			Level:  slog.LevelInfo,
+			Format: "text",
+		},
+		Email: Email{
+			Sender: "no-reply@localhost",
+			SMTP: SMTP{
+				Port: 587,
+				Auth: string(email.AuthPlain),
+				TLS:  string(email.TLSModeStartTLS),
+			},
+		},
+		Limits: Limits{
+			MaxParticipants: roster.DefaultLimits.MaxParticipants,
+			MaxExclusions:   roster.DefaultLimits.MaxExclusions,
+		},
+		Argon2: Argon2{
+			Memory:      uint(argon2id.DefaultParams.Memory),
+			Iterations:  uint(argon2id.DefaultParams.Iterations),
+			Parallelism: uint(argon2id.DefaultParams.Parallelism),
+		},
+	}
+}
+
+func (c *Config) registerFlags(flags *flag.FlagSet) {
+	flags.StringVar(&c.Addr, "addr", c.Addr, "address the server listens on")
+	flags.StringVar(&c.BaseURL, "base-url", c.BaseURL, "address the site is reached at, used for links in emails")
+	flags.BoolVar(&c.Dev, "dev", c.Dev, "enable development tools like the /dev/mail viewer for -maildir; requires a base URL on localhost")
+	flags.Var(&c.TrustedProxies, "trusted-proxies", "comma-separated addresses or CIDR ranges of proxies whose X-Forwarded-For header identifies the client")
+	flags.DurationVar(&c.Timeouts.Read, "read-timeout", c.Timeouts.Read, "maximum time to read a request, including its body")
+	flags.DurationVar(&c.Timeouts.Write, "write-timeout", c.Timeouts.Write, "maximum time to write a response")
+	flags.DurationVar(&c.Timeouts.Idle, "idle-timeout", c.Timeouts.Idle, "how long an idle keep-alive connection is kept open")
+	flags.DurationVar(&c.Timeouts.Drain, "drain-timeout", c.Timeouts.Drain, "how long to keep serving after failing the readiness probe when stopping")
+	flags.DurationVar(&c.Timeouts.Shutdown, "shutdown-timeout", c.Timeouts.Shutdown, "how long to wait for in-flight requests and background workers when stopping")
+	flags.TextVar(&c.Log.Level, "log-level", c.Log.Level, "minimum level of logged messages: debug, info, warn, or error")
+	flags.StringVar(&c.Log.Format, "log-format", c.Log.Format, "format of logged messages: text or json")
+	flags.BoolVar(&c.Cookies.Secure, "secure-cookies", c.Cookies.Secure, "only send cookies over HTTPS")
+	flags.IntVar(&c.Limits.MaxParticipants, "max-participants", c.Limits.MaxParticipants, "maximum number of participants in a draw")
+	flags.IntVar(&c.Limits.MaxExclusions, "max-exclusions", c.Limits.MaxExclusions, "maximum number of people each participant can exclude")
+	flags.UintVar(&c.Argon2.Memory, "argon2-memory", c.Argon2.Memory, "memory in KiB used when hashing passwords")
+	flags.UintVar(&c.Argon2.Iterations, "argon2-iterations", c.Argon2.Iterations, "number of iterations used when hashing passwords")
+	flags.UintVar(&c.Argon2.Parallelism, "argon2-parallelism", c.Argon2.Parallelism, "number of threads used when hashing passwords")
+	flags.StringVar(&c.Templates.LiveEmail, "live-email-templates", c.Templates.LiveEmail, "load email templates from this path for each request instead of using the embedded templates")
+	flags.StringVar(&c.Templates.Live, "live-templates", c.Templates.Live, "load UI templates from this path and reload them when they change instead of using the embedded templates")
+	flags.BoolVar(&c.Templates.LiveReload, "live-reload", c.Templates.LiveReload, "refresh pages open in the browser when the live templates change; requires -live-templates")
+	flags.BoolVar(&c.Email.ReadinessCheck, "mailer-readiness-check", c.Email.ReadinessCheck, "fail the readiness probe while the SMTP server or Maildir can't be reached")
+	c.Email.RegisterFlags(flags)
+}
+
+// RegisterFlags adds flags for the email settings, using their current values as defaults.
+func (e *Email) RegisterFlags(flags *flag.FlagSet) {
+	flags.StringVar(&e.Backend, "email-backend", e.Backend, "where emails go: console, maildir, or smtp; chosen by whether -maildir or -smtp-host are set by default")
+	flags.StringVar(&e.Sender, "sender", e.Sender, "address emails are sent from")
+	flags.StringVar(&e.Maildir, "maildir", e.Maildir, "save emails to this Maildir for development; with -dev, the server shows them at /dev/mail")
+	flags.StringVar(&e.SMTP.Host, "smtp-host", e.SMTP.Host, "send email through this SMTP server")
+	flags.IntVar(&e.SMTP.Port, "smtp-port", e.SMTP.Port, "port of the SMTP server")
+	flags.StringVar(&e.SMTP.Username, "smtp-username", e.SMTP.Username, "username for the SMTP server; the password is read from SMTP_PASSWORD")
+	flags.StringVar(&e.SMTP.Auth, "smtp-auth", e.SMTP.Auth, "SMTP auth mechanism: plain or login")
+	flags.StringVar(&e.SMTP.TLS, "smtp-tls", e.SMTP.TLS, "SMTP encryption: starttls, implicit, or none")
+}
+
+// Validate checks every setting and returns all the problems found.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Addr == "" {
+		errs = append(errs, errors.New("addr is required"))
+	}
+
+	if u, err := url.Parse(c.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("base URL %q must be an absolute http or https URL", c.BaseURL))
+	} else if c.Dev && !isLocalHost(u.Hostname()) {
+		errs = append(errs, fmt.Errorf("dev mode exposes captured emails, so it can't be used with the base URL %q; the base URL must be on localhost", c.BaseURL))
+	}
+
+	if c.Timeouts.Read < 0 || c.Timeouts.Write < 0 || c.Timeouts.Idle < 0 || c.Timeouts.Drain < 0 {
+		errs = append(errs, errors.New("read, write, idle, and drain timeouts can't be negative"))
+	}
+
+	if c.Timeouts.Shutdown <= 0 {
+		errs = append(errs, errors.New("shutdown timeout must be positive"))
+	}
+
+	if c.Log.Format != "text" && c.Log.Format != "json" {
+		errs = append(errs, fmt.Errorf("unknown log format %q: expected text or json", c.Log.Format))
+	}
+
+	if err := c.Email.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if c.Email.ReadinessCheck && c.Email.Backend == BackendConsole {
+		errs = append(errs, errors.New("the mailer readiness check requires the maildir or smtp email backend"))
+	}
+
+	if c.TwoFactor.Key == "" {
+		errs = append(errs, fmt.Errorf("a TOTP key is required: set %s to 32 random bytes encoded as base64, such as the output of `openssl rand -base64 32`", TOTPKeyEnv))
+	} else if _, err := c.TwoFactor.DecodeKey(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if c.Limits.MaxParticipants < 2 {
+		errs = append(errs, errors.New("max participants must be at least 2"))
+	}
+
+	if c.Limits.MaxExclusions < 1 {
+		errs = append(errs, errors.New("max exclusions must be at least 1"))
+	}
+
+	if c.Argon2.Memory == 0 || c.Argon2.Memory > 1<<32-1 {
+		errs = append(errs, errors.New("argon2 memory must be between 1 and 4294967295 KiB"))
+	}
+
+	if c.Argon2.Iterations == 0 || c.Argon2.Iterations > 1<<32-1 {
+		errs = append(errs, errors.New("argon2 iterations must be between 1 and 4294967295"))
+	}
+
+	if c.Argon2.Parallelism == 0 || c.Argon2.Parallelism > 255 {
+		errs = append(errs, errors.New("argon2 parallelism must be between 1 and 255"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// isLocalHost reports whether the host name refers to the local machine.
+func isLocalHost(host string) bool {
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+
+	addr, err := netip.ParseAddr(host)
+
+	return err == nil && addr.IsLoopback()
+}
+
+// Validate checks the email settings, first choosing the backend if it's empty.
+func (e *Email) Validate() error {
+	if e.Backend == "" {
+		switch {
+		case e.Maildir != "":
+			e.Backend = BackendMaildir
+		case e.SMTP.Host != "":
+			e.Backend = BackendSMTP
+		default:
+			e.Backend = BackendConsole
+		}
+	}
+
+	var errs []error
+
+	switch e.Backend {
+	case BackendConsole:
+	case BackendMaildir:
+		if e.Maildir == "" {
+			errs = append(errs, errors.New("the maildir email backend requires a Maildir path"))
+		}
+	case BackendSMTP:
+		if e.SMTP.Host == "" {
+			errs = append(errs, errors.New("the smtp email backend requires an SMTP host"))
+		}
+
+		if e.SMTP.Port < 1 || e.SMTP.Port > 65535 {
+			errs = append(errs, fmt.Errorf("SMTP port %d is out of range", e.SMTP.Port))
+		}
+
+		switch email.AuthMechanism(e.SMTP.Auth) {
+		case email.AuthPlain, email.AuthLogin:
+		default:
+			errs = append(errs, fmt.Errorf("unknown SMTP auth mechanism %q: expected plain or login", e.SMTP.Auth))
+		}
+
+		switch email.TLSMode(e.SMTP.TLS) {
+		case email.TLSModeStartTLS, email.TLSModeImplicit, email.TLSModeNone:
+		default:
+			errs = append(errs, fmt.Errorf("unknown SMTP TLS mode %q: expected starttls, implicit, or none", e.SMTP.TLS))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown email backend %q: expected console, maildir, or smtp", e.Backend))
+	}
+
+	if address, err := mail.ParseAddress(e.Sender); err != nil || address.Address != e.Sender {
+		errs = append(errs, fmt.Errorf("sender %q is not a valid email address", e.Sender))
+	}
+
+	return errors.Join(errs...)
+}
+
+// NewLogger creates a logger that writes to w with the configured level and format.
+func (l Log) NewLogger(w io.Writer) *slog.Logger {
+	options := &slog.HandlerOptions{Level: l.Level}
+
+	if l.Format == "json" {
+		return slog.New(slog.NewJSONHandler(w, options))
+	}
+
+	return slog.New(slog.NewTextHandler(w, options))
+}
@@ -0,0 +1,276 @@
+package config_test
+
+import (
+	"encoding/base64"
+	"flag"
+	"io"
+	"log/slog"
+	"maps"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cdriehuys/secret-santa/internal/config"
+)
+
+func envMap(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+}
+
+func writeConfigFile(t *testing.T, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	return path
+}
+
+// testTOTPKey is a valid TOTP key. Every configuration needs one, so load provides it unless the
+// test sets its own.
+var testTOTPKey = base64.StdEncoding.EncodeToString(make([]byte, 32))
+
+func load(t *testing.T, args []string, env map[string]string) (config.Config, error) {
+	t.Helper()
+
+	env = maps.Clone(env)
+	if env == nil {
+		env = make(map[string]string)
+	}
+
+	if _, ok := env[config.TOTPKeyEnv]; !ok {
+		env[config.TOTPKeyEnv] = testTOTPKey
+	}
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+
+	var cfg config.Config
+	loader := config.NewLoader(&cfg, flags)
+	if err := flags.Parse(args); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	err := loader.Load(envMap(env))
+
+	return cfg, err
+}
+
+func TestLoader_Load(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		cfg, err := load(t, nil, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		want := config.Default()
+		want.Email.Backend = config.BackendConsole
+		want.TwoFactor.Key = testTOTPKey
+
+		if !reflect.DeepEqual(cfg, want) {
+			t.Errorf("Expected defaults %+v, got %+v", want, cfg)
+		}
+	})
+
+	t.Run("precedence", func(t *testing.T) {
+		path := writeConfigFile(t, `
+addr: ":9000"
+base_url: https://santa.example.com
+log:
+  level: debug
+  format: json
+email:
+  sender: santa@example.com
+limits:
+  max_participants: 20
+timeouts:
+  shutdown: 1m
+trusted_proxies:
+  - 10.0.0.0/8
+`)
+
+		env := map[string]string{
+			"SECRET_SANTA_ADDR":            ":9001",
+			"SECRET_SANTA_LOG_FORMAT":      "text",
+			"SECRET_SANTA_TRUSTED_PROXIES": "192.168.1.1, 172.16.0.0/12",
+			"DB_CONN":                      "postgres://localhost/santa",
+		}
+
+		cfg, err := load(t, []string{"-config", path, "-addr", ":9002"}, env)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if cfg.Addr != ":9002" {
+			t.Errorf("Expected the flag to set addr, got %q", cfg.Addr)
+		}
+
+		if cfg.Log.Format != "text" {
+			t.Errorf("Expected the environment to set the log format, got %q", cfg.Log.Format)
+		}
+
+		if cfg.Log.Level != slog.LevelDebug {
+			t.Errorf("Expected the file to set the log level, got %v", cfg.Log.Level)
+		}
+
+		if cfg.BaseURL != "https://santa.example.com" || cfg.Email.Sender != "santa@example.com" || cfg.Limits.MaxParticipants != 20 {
+			t.Errorf("Expected the file to set the base URL, sender, and limits, got %+v", cfg)
+		}
+
+		if cfg.Timeouts.Shutdown != time.Minute {
+			t.Errorf("Expected the file to set the shutdown timeout, got %v", cfg.Timeouts.Shutdown)
+		}
+
+		if cfg.Limits.MaxExclusions != config.Default().Limits.MaxExclusions {
+			t.Errorf("Expected settings missing from the file to keep their defaults, got %d max exclusions", cfg.Limits.MaxExclusions)
+		}
+
+		wantProxies := config.Prefixes{netip.MustParsePrefix("192.168.1.1/32"), netip.MustParsePrefix("172.16.0.0/12")}
+		if !reflect.DeepEqual(cfg.TrustedProxies, wantProxies) {
+			t.Errorf("Expected the environment to set the trusted proxies %v, got %v", wantProxies, cfg.TrustedProxies)
+		}
+
+		if cfg.Database.URL != "postgres://localhost/santa" {
+			t.Errorf("Expected the database URL from DB_CONN, got %q", cfg.Database.URL)
+		}
+	})
+
+	t.Run("dev mode", func(t *testing.T) {
+		for _, baseURL := range []string{"http://localhost:8080", "http://santa.localhost", "http://127.0.0.1:8080", "http://[::1]:8080"} {
+			cfg, err := load(t, []string{"-dev", "-base-url", baseURL}, nil)
+			if err != nil {
+				t.Errorf("Unexpected error for %q: %v", baseURL, err)
+			}
+
+			if !cfg.Dev {
+				t.Errorf("Expected dev mode for %q", baseURL)
+			}
+		}
+	})
+
+	t.Run("config file from environment", func(t *testing.T) {
+		path := writeConfigFile(t, "addr: \":9000\"\n")
+
+		cfg, err := load(t, nil, map[string]string{"SECRET_SANTA_CONFIG": path})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if cfg.Addr != ":9000" {
+			t.Errorf("Expected addr from the config file, got %q", cfg.Addr)
+		}
+	})
+
+	t.Run("email backend", func(t *testing.T) {
+		testCases := []struct {
+			args []string
+			want string
+		}{
+			{args: []string{"-maildir", "/tmp/mail"}, want: config.BackendMaildir},
+			{args: []string{"-smtp-host", "smtp.example.com"}, want: config.BackendSMTP},
+			{args: []string{"-email-backend", "console", "-smtp-host", "smtp.example.com"}, want: config.BackendConsole},
+		}
+
+		for _, tt := range testCases {
+			cfg, err := load(t, tt.args, map[string]string{"SMTP_PASSWORD": "hunter2"})
+			if err != nil {
+				t.Fatalf("Unexpected error for %v: %v", tt.args, err)
+			}
+
+			if cfg.Email.Backend != tt.want {
+				t.Errorf("Expected backend %q for %v, got %q", tt.want, tt.args, cfg.Email.Backend)
+			}
+
+			if cfg.Email.SMTP.Password != "hunter2" {
+				t.Errorf("Expected the SMTP password from SMTP_PASSWORD, got %q", cfg.Email.SMTP.Password)
+			}
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		testCases := []struct {
+			name    string
+			file    string
+			args    []string
+			env     map[string]string
+			wantErr []string
+		}{
+			{
+				name:    "unknown file setting",
+				file:    "adr: \":9000\"\n",
+				wantErr: []string{"field adr not found"},
+			},
+			{
+				name:    "invalid trusted proxy",
+				file:    "trusted_proxies: [10.0.0.0/33]\n",
+				wantErr: []string{"10.0.0.0/33"},
+			},
+			{
+				name:    "dev mode with a public base URL",
+				args:    []string{"-dev", "-base-url", "https://santa.example.com"},
+				wantErr: []string{"dev mode exposes captured emails"},
+			},
+			{
+				name:    "mailer readiness check without a mailer",
+				args:    []string{"-mailer-readiness-check"},
+				wantErr: []string{"mailer readiness check requires"},
+			},
+			{
+				name:    "missing TOTP key",
+				env:     map[string]string{"SECRET_SANTA_TOTP_KEY": ""},
+				wantErr: []string{"a TOTP key is required"},
+			},
+			{
+				name:    "short TOTP key",
+				env:     map[string]string{"SECRET_SANTA_TOTP_KEY": base64.StdEncoding.EncodeToString(make([]byte, 16))},
+				wantErr: []string{"TOTP key must be 32 bytes"},
+			},
+			{
+				name:    "invalid environment variable",
+				env:     map[string]string{"SECRET_SANTA_SMTP_PORT": "twenty-five"},
+				wantErr: []string{"SECRET_SANTA_SMTP_PORT"},
+			},
+			{
+				name: "invalid settings",
+				args: []string{"-base-url", "localhost:8080", "-log-format", "xml", "-email-backend", "smtp", "-max-participants", "1", "-shutdown-timeout", "0s", "-drain-timeout", "-1s"},
+				wantErr: []string{
+					"base URL",
+					"log format",
+					"requires an SMTP host",
+					"max participants",
+					"shutdown timeout",
+					"drain timeouts can't be negative",
+				},
+			},
+		}
+
+		for _, tt := range testCases {
+			t.Run(tt.name, func(t *testing.T) {
+				args := tt.args
+				if tt.file != "" {
+					args = append([]string{"-config", writeConfigFile(t, tt.file)}, args...)
+				}
+
+				_, err := load(t, args, tt.env)
+				if err == nil {
+					t.Fatal("Expected an error")
+				}
+
+				for _, want := range tt.wantErr {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("Expected error to contain %q, got:\n%v", want, err)
+					}
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts the name of the environment variable for each flag. The rest of the name is the
+// flag's name in upper case with dashes replaced by underscores, so -smtp-host is set by
+// SECRET_SANTA_SMTP_HOST.
+const EnvPrefix = "SECRET_SANTA_"
+
+// Settings without a flag are read from these environment variables, which predate the others.
+const (
+	DatabaseURLEnv  = "DB_CONN"
+	SMTPPasswordEnv = "SMTP_PASSWORD"
+)
+
+// TOTPKeyEnv sets the key that encrypts TOTP secrets. It has no flag so it doesn't show up in the
+// process list.
+const TOTPKeyEnv = EnvPrefix + "TOTP_KEY"
+
+// Loader fills in a Config from a file, the environment, and flags.
+type Loader struct {
+	cfg   *Config
+	flags *flag.FlagSet
+	path  string
+}
+
+// NewLoader adds flags for every setting, including -config to choose the YAML file, to the flag
+// set. After the flags are parsed, Load fills in cfg.
+func NewLoader(cfg *Config, flags *flag.FlagSet) *Loader {
+	*cfg = Default()
+
+	l := &Loader{cfg: cfg, flags: flags}
+	flags.StringVar(&l.path, "config", "", "read settings from this YAML file; flags and environment variables override it")
+	cfg.registerFlags(flags)
+
+	return l
+}
+
+// Load builds the configuration from the defaults, the YAML file named by -config or
+// SECRET_SANTA_CONFIG, environment variables, and the parsed flags, each overriding the last. The
+// result is validated.
+func (l *Loader) Load(lookupEnv func(string) (string, bool)) error {
+	// The flags were parsed into the config, so their values are saved and reapplied on top of
+	// everything else.
+	flagValues := make(map[string]string)
+	l.flags.Visit(func(f *flag.Flag) {
+		flagValues[f.Name] = f.Value.String()
+	})
+
+	*l.cfg = Default()
+
+	path := l.path
+	if path == "" {
+		path, _ = lookupEnv(EnvName("config"))
+	}
+
+	if path != "" {
+		if err := l.readFile(path); err != nil {
+			return err
+		}
+	}
+
+	var errs []error
+	l.flags.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" {
+			return
+		}
+
+		if value, ok := lookupEnv(EnvName(f.Name)); ok {
+			if err := l.flags.Set(f.Name, value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", EnvName(f.Name), err))
+			}
+		}
+	})
+
+	if value, ok := lookupEnv(DatabaseURLEnv); ok {
+		l.cfg.Database.URL = value
+	}
+
+	if value, ok := lookupEnv(SMTPPasswordEnv); ok {
+		l.cfg.Email.SMTP.Password = value
+	}
+
+	if value, ok := lookupEnv(TOTPKeyEnv); ok {
+		l.cfg.TwoFactor.Key = value
+	}
+
+	for name, value := range flagValues {
+		if name == "config" {
+			continue
+		}
+
+		if err := l.flags.Set(name, value); err != nil {
+			errs = append(errs, fmt.Errorf("-%s: %v", name, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	return l.cfg.Validate()
+}
+
+func (l *Loader) readFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %v", err)
+	}
+
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(l.cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("reading config file %s: %v", path, err)
+	}
+
+	return nil
+}
+
+// EnvName returns the environment variable that sets a flag.
+func EnvName(flagName string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
@@ -5,12 +5,10 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"time"
 )
 
-var (
-	messageSeparator = strings.Repeat("*", 80)
-	bodySeparator    = strings.Repeat("-", 80)
-)
+var messageSeparator = strings.Repeat("*", 80)
 
 // ConsoleMailer writes emails to the given output stream for use in development.
 type ConsoleMailer struct {
@@ -22,14 +20,20 @@ func NewConsoleMailer(w io.Writer) *ConsoleMailer {
 	return &ConsoleMailer{w}
 }
 
-// Send writes the contents of the email to the mailer's output.
-func (m *ConsoleMailer) Send(ctx context.Context, to string, from string, subject string, body string) error {
+// Send writes the email to the mailer's output in the same MIME format it would be delivered in.
+func (m *ConsoleMailer) Send(ctx context.Context, msg Message) error {
+	env, err := parseEnvelope(msg)
+	if err != nil {
+		return err
+	}
+
+	data, err := formatMessage(msg, env, time.Now())
+	if err != nil {
+		return err
+	}
+
 	fmt.Fprintf(m.w, "\n\n%s\n", messageSeparator)
-	fmt.Fprintf(m.w, "To: %s\n", to)
-	fmt.Fprintf(m.w, "From: %s\n", from)
-	fmt.Fprintf(m.w, "Subject: %s\n", subject)
-	fmt.Fprintln(m.w, bodySeparator)
-	fmt.Fprintln(m.w, body)
+	fmt.Fprintf(m.w, "%s\n", data)
 	fmt.Fprintln(m.w, messageSeparator)
 
 	return nil
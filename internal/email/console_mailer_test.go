@@ -1,6 +1,11 @@
 package email_test
 
 import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
 	"strings"
 	"testing"
 
@@ -9,18 +14,58 @@ import (
 
 func TestConsoleMailer_Send(t *testing.T) {
 	testCases := []struct {
-		name    string
-		to      string
-		from    string
-		subject string
-		body    string
+		name          string
+		msg           email.Message
+		wantParts     map[string]string
+		wantMessageID string
+		notHeaders    []string
 	}{
 		{
-			name:    "simple message",
-			to:      "test@example.com",
-			from:    "no-reply@localhost",
-			subject: "Test Message",
-			body:    "Hello, World!",
+			name: "text only",
+			msg: email.Message{
+				To:      "test@example.com",
+				From:    "no-reply@localhost",
+				Subject: "Test Message",
+				Text:    "Hello, World!",
+			},
+			wantParts: map[string]string{"text/plain": "Hello, World!"},
+		},
+		{
+			name: "stable message ID",
+			msg: email.Message{
+				ID:      "1234",
+				To:      "test@example.com",
+				From:    "no-reply@localhost",
+				Subject: "Test Message",
+				Text:    "Hello, World!",
+			},
+			wantParts:     map[string]string{"text/plain": "Hello, World!"},
+			wantMessageID: "<1234@localhost>",
+		},
+		{
+			name: "subject with line break",
+			msg: email.Message{
+				To:      "test@example.com",
+				From:    "no-reply@localhost",
+				Subject: "Test\r\nBcc: victim@example.com",
+				Text:    "Hello, World!",
+			},
+			wantParts:  map[string]string{"text/plain": "Hello, World!"},
+			notHeaders: []string{"\nBcc:"},
+		},
+		{
+			name: "text and HTML",
+			msg: email.Message{
+				To:      "test@example.com",
+				From:    "no-reply@localhost",
+				Subject: "Test Message",
+				Text:    "Hello, World!",
+				HTML:    "<p>Hello, <strong>World</strong>!</p>",
+			},
+			wantParts: map[string]string{
+				"text/plain": "Hello, World!",
+				"text/html":  "<p>Hello, <strong>World</strong>!</p>",
+			},
 		},
 	}
 
@@ -29,27 +74,96 @@ func TestConsoleMailer_Send(t *testing.T) {
 			var writer strings.Builder
 			mailer := email.NewConsoleMailer(&writer)
 
-			if err := mailer.Send(t.Context(), tt.to, tt.from, tt.subject, tt.body); err != nil {
+			if err := mailer.Send(t.Context(), tt.msg); err != nil {
 				t.Fatalf("mailer failed: %v", err)
 			}
 
 			output := writer.String()
 
-			if !strings.Contains(output, tt.to) {
-				t.Errorf("Expected to find %q in output:\n%s", tt.to, output)
+			for _, notWant := range tt.notHeaders {
+				if strings.Contains(output, notWant) {
+					t.Errorf("Expected not to find %q in output:\n%s", notWant, output)
+				}
 			}
 
-			if !strings.Contains(output, tt.from) {
-				t.Errorf("Expected to find %q in output:\n%s", tt.from, output)
+			for _, want := range []string{tt.msg.To, tt.msg.From, strings.Join(strings.Fields(tt.msg.Subject), " ")} {
+				if !strings.Contains(output, want) {
+					t.Errorf("Expected to find %q in output:\n%s", want, output)
+				}
 			}
 
-			if !strings.Contains(output, tt.subject) {
-				t.Errorf("Expected to find %q in output:\n%s", tt.subject, output)
+			start := strings.Index(output, "Date:")
+			end := strings.LastIndex(output, "\n*")
+			if start == -1 || end < start {
+				t.Fatalf("Could not find message in output:\n%s", output)
 			}
 
-			if !strings.Contains(output, tt.body) {
-				t.Errorf("Expected to find %q in output:\n%s", tt.body, output)
+			if tt.wantMessageID != "" && !strings.Contains(output, "Message-ID: "+tt.wantMessageID) {
+				t.Errorf("Expected message ID %q in output:\n%s", tt.wantMessageID, output)
+			}
+
+			gotParts := messageParts(t, output[start:end])
+			if len(gotParts) != len(tt.wantParts) {
+				t.Errorf("Expected %d parts, got %d: %v", len(tt.wantParts), len(gotParts), gotParts)
+			}
+
+			for contentType, want := range tt.wantParts {
+				if got := gotParts[contentType]; got != want {
+					t.Errorf("Expected %s part %q, got %q", contentType, want, got)
+				}
 			}
 		})
 	}
 }
+
+// messageParts parses a MIME message and returns the decoded body of each part by content type.
+func messageParts(t *testing.T, raw string) map[string]string {
+	t.Helper()
+
+	msg, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("failed to parse message: %v\n%s", err, raw)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("invalid content type: %v", err)
+	}
+
+	if mediaType != "multipart/alternative" {
+		body, err := io.ReadAll(quotedprintable.NewReader(msg.Body))
+		if err != nil {
+			t.Fatalf("failed to read body: %v", err)
+		}
+
+		return map[string]string{mediaType: strings.TrimRight(string(body), "\r\n")}
+	}
+
+	parts := make(map[string]string)
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		// NextRawPart leaves the transfer encoding in place so it's decoded explicitly.
+		part, err := reader.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			t.Fatalf("failed to read part: %v", err)
+		}
+
+		partType, _, err := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if err != nil {
+			t.Fatalf("invalid part content type: %v", err)
+		}
+
+		body, err := io.ReadAll(quotedprintable.NewReader(part))
+		if err != nil {
+			t.Fatalf("failed to read part: %v", err)
+		}
+
+		parts[partType] = strings.TrimRight(string(body), "\r\n")
+	}
+
+	return parts
+}
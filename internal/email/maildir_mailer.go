@@ -0,0 +1,245 @@
+package email
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrMessageNotFound = errors.New("message not found")
+
+// StoredMessage is a message that was saved by a MaildirMailer.
+type StoredMessage struct {
+	Message
+
+	// Name identifies the message within the mailbox.
+	Name string
+	Date time.Time
+}
+
+// MaildirMailer saves each email as an .eml file in a Maildir so messages can be inspected with a
+// mail client or in the browser during development. Messages are written to the tmp directory and
+// then moved into new so readers never see a partial message.
+type MaildirMailer struct {
+	logger *slog.Logger
+	dir    string
+}
+
+// NewMaildirMailer creates a mailer that writes to the Maildir at dir, creating it if needed.
+func NewMaildirMailer(logger *slog.Logger, dir string) (*MaildirMailer, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, fmt.Errorf("creating maildir: %v", err)
+		}
+	}
+
+	return &MaildirMailer{logger: logger, dir: dir}, nil
+}
+
+// Ping checks that the Maildir's directories still exist.
+func (m *MaildirMailer) Ping(ctx context.Context) error {
+	for _, sub := range []string{"tmp", "new"} {
+		if _, err := os.Stat(filepath.Join(m.dir, sub)); err != nil {
+			return fmt.Errorf("checking maildir: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// Send saves the email to the Maildir in the same format it would be delivered in.
+func (m *MaildirMailer) Send(ctx context.Context, msg Message) error {
+	env, err := parseEnvelope(msg)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	data, err := formatMessage(msg, env, now)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d.%s.secret-santa.eml", now.UnixNano(), rand.Text()[:8])
+
+	tmpPath := filepath.Join(m.dir, "tmp", name)
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("writing message: %v", err)
+	}
+
+	if err := os.Rename(tmpPath, filepath.Join(m.dir, "new", name)); err != nil {
+		return fmt.Errorf("delivering message to maildir: %v", err)
+	}
+
+	return nil
+}
+
+// List returns the messages in the Maildir, newest first. Files that can't be read as messages,
+// such as ones added by hand, are logged and skipped.
+func (m *MaildirMailer) List() ([]StoredMessage, error) {
+	var messages []StoredMessage
+
+	for _, sub := range []string{"new", "cur"} {
+		entries, err := os.ReadDir(filepath.Join(m.dir, sub))
+		if err != nil {
+			return nil, fmt.Errorf("reading maildir: %v", err)
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+
+			msg, err := m.read(filepath.Join(sub, entry.Name()), entry.Name())
+			if err != nil {
+				m.logger.Warn("Skipped unreadable message in the Maildir.", "name", entry.Name(), "error", err)
+				continue
+			}
+
+			messages = append(messages, msg)
+		}
+	}
+
+	// The Date header only has second precision, so fall back to the name, which starts with the
+	// time the message was saved in nanoseconds.
+	slices.SortFunc(messages, func(a, b StoredMessage) int {
+		if byDate := b.Date.Compare(a.Date); byDate != 0 {
+			return byDate
+		}
+
+		return strings.Compare(b.Name, a.Name)
+	})
+
+	return messages, nil
+}
+
+// Get returns the message with the given name. If there is no such message, ErrMessageNotFound is
+// returned.
+func (m *MaildirMailer) Get(name string) (StoredMessage, error) {
+	// Names come from URLs, so make sure they can't be used to read arbitrary files.
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return StoredMessage{}, ErrMessageNotFound
+	}
+
+	for _, sub := range []string{"new", "cur"} {
+		msg, err := m.read(filepath.Join(sub, name), name)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+
+		return msg, err
+	}
+
+	return StoredMessage{}, ErrMessageNotFound
+}
+
+func (m *MaildirMailer) read(path string, name string) (StoredMessage, error) {
+	f, err := os.Open(filepath.Join(m.dir, path))
+	if err != nil {
+		return StoredMessage{}, err
+	}
+
+	defer f.Close()
+
+	msg, err := parseStoredMessage(f)
+	if err != nil {
+		return StoredMessage{}, fmt.Errorf("parsing message %q: %v", name, err)
+	}
+
+	msg.Name = name
+
+	return msg, nil
+}
+
+// parseStoredMessage reads a message in the format written by formatMessage.
+func parseStoredMessage(r io.Reader) (StoredMessage, error) {
+	raw, err := mail.ReadMessage(r)
+	if err != nil {
+		return StoredMessage{}, err
+	}
+
+	var decoder mime.WordDecoder
+	subject, err := decoder.DecodeHeader(raw.Header.Get("Subject"))
+	if err != nil {
+		return StoredMessage{}, fmt.Errorf("decoding subject: %v", err)
+	}
+
+	date, err := raw.Header.Date()
+	if err != nil {
+		return StoredMessage{}, fmt.Errorf("parsing date: %v", err)
+	}
+
+	stored := StoredMessage{
+		Message: Message{
+			ID:      strings.Trim(raw.Header.Get("Message-ID"), "<>"),
+			To:      raw.Header.Get("To"),
+			From:    raw.Header.Get("From"),
+			Subject: subject,
+		},
+		Date: date,
+	}
+
+	mediaType, params, err := mime.ParseMediaType(raw.Header.Get("Content-Type"))
+	if err != nil {
+		return StoredMessage{}, fmt.Errorf("parsing content type: %v", err)
+	}
+
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		stored.Text, err = readPart(raw.Body, raw.Header.Get("Content-Transfer-Encoding"))
+
+		return stored, err
+	}
+
+	parts := multipart.NewReader(raw.Body, params["boundary"])
+	for {
+		part, err := parts.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return StoredMessage{}, fmt.Errorf("reading message part: %v", err)
+		}
+
+		body, err := readPart(part, part.Header.Get("Content-Transfer-Encoding"))
+		if err != nil {
+			return StoredMessage{}, err
+		}
+
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		switch partType {
+		case "text/plain":
+			stored.Text = body
+		case "text/html":
+			stored.HTML = body
+		}
+	}
+
+	return stored, nil
+}
+
+func readPart(r io.Reader, encoding string) (string, error) {
+	if strings.EqualFold(encoding, "quoted-printable") {
+		r = quotedprintable.NewReader(r)
+	}
+
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("reading message body: %v", err)
+	}
+
+	return strings.ReplaceAll(string(body), "\r\n", "\n"), nil
+}
@@ -0,0 +1,157 @@
+package email_test
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cdriehuys/secret-santa/internal/email"
+)
+
+func TestMaildirMailer(t *testing.T) {
+	dir := t.TempDir()
+
+	mailer, err := email.NewMaildirMailer(slog.New(slog.DiscardHandler), dir)
+	if err != nil {
+		t.Fatalf("failed to create mailer: %v", err)
+	}
+
+	messages := []email.Message{
+		{
+			To:      "first@example.com",
+			From:    "no-reply@example.com",
+			Subject: "First",
+			Text:    "First message",
+		},
+		{
+			To:      "second@example.com",
+			From:    "no-reply@example.com",
+			Subject: "Second ✓",
+			Text:    "Second message\nwith two lines",
+			HTML:    "<p>Second message</p>",
+		},
+	}
+
+	for _, msg := range messages {
+		if err := mailer.Send(t.Context(), msg); err != nil {
+			t.Fatalf("Send returned an error: %v", err)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "new", "*.eml"))
+	if err != nil || len(files) != len(messages) {
+		t.Fatalf("Expected %d .eml files in new, got %v (%v)", len(messages), files, err)
+	}
+
+	if tmp, _ := os.ReadDir(filepath.Join(dir, "tmp")); len(tmp) != 0 {
+		t.Errorf("Expected tmp to be empty, got %d entries", len(tmp))
+	}
+
+	stored, err := mailer.List()
+	if err != nil {
+		t.Fatalf("List returned an error: %v", err)
+	}
+
+	if len(stored) != len(messages) {
+		t.Fatalf("Expected %d messages, got %d", len(messages), len(stored))
+	}
+
+	// Newest messages are listed first.
+	latest := stored[0]
+	want := messages[1]
+
+	if latest.Subject != want.Subject {
+		t.Errorf("Expected subject %q, got %q", want.Subject, latest.Subject)
+	}
+
+	if latest.To != "<second@example.com>" {
+		t.Errorf("Expected recipient %q, got %q", "<second@example.com>", latest.To)
+	}
+
+	if latest.Text != want.Text {
+		t.Errorf("Expected text %q, got %q", want.Text, latest.Text)
+	}
+
+	if latest.HTML != want.HTML {
+		t.Errorf("Expected HTML %q, got %q", want.HTML, latest.HTML)
+	}
+
+	got, err := mailer.Get(latest.Name)
+	if err != nil {
+		t.Fatalf("Get returned an error: %v", err)
+	}
+
+	if got.Subject != latest.Subject || got.Text != latest.Text {
+		t.Errorf("Expected Get to return %#v, got %#v", latest, got)
+	}
+}
+
+func TestMaildirMailer_List_unreadable(t *testing.T) {
+	dir := t.TempDir()
+
+	mailer, err := email.NewMaildirMailer(slog.New(slog.DiscardHandler), dir)
+	if err != nil {
+		t.Fatalf("failed to create mailer: %v", err)
+	}
+
+	msg := email.Message{To: "test@example.com", From: "no-reply@example.com", Subject: "Readable", Text: "Body"}
+	if err := mailer.Send(t.Context(), msg); err != nil {
+		t.Fatalf("Send returned an error: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "cur", "broken.eml"), []byte("not a header\r\n\r\n"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	stored, err := mailer.List()
+	if err != nil {
+		t.Fatalf("List returned an error: %v", err)
+	}
+
+	if len(stored) != 1 || stored[0].Subject != "Readable" {
+		t.Errorf("Expected only the readable message, got %#v", stored)
+	}
+}
+
+func TestMaildirMailer_Get_notFound(t *testing.T) {
+	dir := t.TempDir()
+
+	mailer, err := email.NewMaildirMailer(slog.New(slog.DiscardHandler), dir)
+	if err != nil {
+		t.Fatalf("failed to create mailer: %v", err)
+	}
+
+	// A file outside of the maildir that must not be readable through Get.
+	if err := os.WriteFile(filepath.Join(dir, "secret.eml"), []byte("Subject: secret\r\n\r\n"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	for _, name := range []string{"", "missing.eml", "../secret.eml", "..", "."} {
+		if _, err := mailer.Get(name); !errors.Is(err, email.ErrMessageNotFound) {
+			t.Errorf("Expected ErrMessageNotFound for %q, got %v", name, err)
+		}
+	}
+}
+
+func TestMaildirMailer_Ping(t *testing.T) {
+	dir := t.TempDir()
+
+	mailer, err := email.NewMaildirMailer(slog.New(slog.DiscardHandler), dir)
+	if err != nil {
+		t.Fatalf("failed to create mailer: %v", err)
+	}
+
+	if err := mailer.Ping(t.Context()); err != nil {
+		t.Errorf("Expected ping to succeed, got %v", err)
+	}
+
+	if err := os.RemoveAll(filepath.Join(dir, "new")); err != nil {
+		t.Fatalf("failed to remove directory: %v", err)
+	}
+
+	if err := mailer.Ping(t.Context()); err == nil {
+		t.Error("Expected ping to fail without the new directory")
+	}
+}
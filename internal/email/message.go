@@ -0,0 +1,150 @@
+package email
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Message is an email with a plain text body and an optional HTML alternative.
+type Message struct {
+	// ID uniquely identifies the message. It is used for the Message-ID header so that a message
+	// sent more than once, such as when retrying after an ambiguous failure, can be recognized as a
+	// duplicate. A random ID is used if it is empty.
+	ID string
+
+	To      string
+	From    string
+	Subject string
+
+	Text string
+	HTML string
+}
+
+// envelope holds the parsed addresses of a message.
+type envelope struct {
+	from *mail.Address
+	to   *mail.Address
+}
+
+func parseEnvelope(msg Message) (envelope, error) {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return envelope{}, fmt.Errorf("invalid recipient address %q: %v", msg.To, err)
+	}
+
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return envelope{}, fmt.Errorf("invalid sender address %q: %v", msg.From, err)
+	}
+
+	return envelope{from: from, to: to}, nil
+}
+
+// formatMessage encodes the message as described by RFC 5322. Messages with an HTML body are sent
+// as multipart/alternative so clients that can't display HTML fall back to the text.
+func formatMessage(msg Message, env envelope, now time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+
+	writeHeader(&buf, "Date", now.Format(time.RFC1123Z))
+	writeHeader(&buf, "Message-ID", messageID(msg.ID, env.from.Address))
+	writeHeader(&buf, "From", env.from.String())
+	writeHeader(&buf, "To", env.to.String())
+	// Collapse whitespace so a subject containing a line break can't inject headers.
+	subject := strings.Join(strings.Fields(msg.Subject), " ")
+	writeHeader(&buf, "Subject", mime.QEncoding.Encode("utf-8", subject))
+	writeHeader(&buf, "MIME-Version", "1.0")
+
+	if msg.HTML == "" {
+		writeHeader(&buf, "Content-Type", `text/plain; charset="utf-8"`)
+		writeHeader(&buf, "Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+
+		if err := writeQuotedPrintable(&buf, msg.Text); err != nil {
+			return nil, err
+		}
+
+		return buf.Bytes(), nil
+	}
+
+	parts := multipart.NewWriter(&buf)
+	writeHeader(&buf, "Content-Type", mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": parts.Boundary()}))
+	buf.WriteString("\r\n")
+
+	// Clients prefer the last alternative they can display, so HTML goes after the text.
+	alternatives := []struct {
+		contentType string
+		body        string
+	}{
+		{`text/plain; charset="utf-8"`, msg.Text},
+		{`text/html; charset="utf-8"`, msg.HTML},
+	}
+
+	for _, alternative := range alternatives {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", alternative.contentType)
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+
+		part, err := parts.CreatePart(header)
+		if err != nil {
+			return nil, fmt.Errorf("creating message part: %v", err)
+		}
+
+		if err := writeQuotedPrintable(part, alternative.body); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := parts.Close(); err != nil {
+		return nil, fmt.Errorf("closing multipart message: %v", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeHeader(buf *bytes.Buffer, name string, value string) {
+	fmt.Fprintf(buf, "%s: %s\r\n", name, value)
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	encoder := quotedprintable.NewWriter(w)
+	if _, err := encoder.Write([]byte(normalizeNewlines(body))); err != nil {
+		return fmt.Errorf("encoding message body: %v", err)
+	}
+
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("encoding message body: %v", err)
+	}
+
+	return nil
+}
+
+// messageID builds a globally unique message ID from the message's ID and the domain of the
+// sender.
+func messageID(id string, sender string) string {
+	if id == "" {
+		id = rand.Text()
+	}
+
+	domain := "localhost"
+	if at := strings.LastIndex(sender, "@"); at != -1 {
+		domain = sender[at+1:]
+	}
+
+	return fmt.Sprintf("<%s@%s>", id, domain)
+}
+
+// normalizeNewlines converts line endings to the CRLF required by SMTP.
+func normalizeNewlines(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+
+	return strings.ReplaceAll(s, "\n", "\r\n")
+}
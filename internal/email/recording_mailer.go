@@ -0,0 +1,107 @@
+package email
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// RecordingMailer keeps every message it is asked to send in memory so tests can inspect them. It is
+// safe for concurrent use.
+type RecordingMailer struct {
+	mu       sync.Mutex
+	messages []Message
+
+	// Err, if set, is returned from every call to Send. Messages are recorded either way.
+	Err error
+}
+
+// Send records the message.
+func (m *RecordingMailer) Send(ctx context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, msg)
+
+	return m.Err
+}
+
+// Messages returns all recorded messages in the order they were sent.
+func (m *RecordingMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return slices.Clone(m.messages)
+}
+
+// To returns the messages sent to the given address in the order they were sent.
+func (m *RecordingMailer) To(address string) []Message {
+	return m.filter(func(msg Message) bool {
+		return msg.To == address
+	})
+}
+
+// WithSubject returns the messages with the given subject in the order they were sent.
+func (m *RecordingMailer) WithSubject(subject string) []Message {
+	return m.filter(func(msg Message) bool {
+		return msg.Subject == subject
+	})
+}
+
+// Last returns the most recently sent message, and whether there was one.
+func (m *RecordingMailer) Last() (Message, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.messages) == 0 {
+		return Message{}, false
+	}
+
+	return m.messages[len(m.messages)-1], true
+}
+
+// Reset forgets all recorded messages.
+func (m *RecordingMailer) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = nil
+}
+
+func (m *RecordingMailer) filter(match func(Message) bool) []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var matches []Message
+	for _, msg := range m.messages {
+		if match(msg) {
+			matches = append(matches, msg)
+		}
+	}
+
+	return matches
+}
+
+var linkPattern = regexp.MustCompile(`https?://[^\s"'<>]+`)
+
+// ExtractLinks returns the http and https URLs in the text in the order they appear. Punctuation at
+// the end of a link is assumed to belong to the surrounding sentence.
+func ExtractLinks(text string) []string {
+	links := linkPattern.FindAllString(text, -1)
+	for i, link := range links {
+		links[i] = strings.TrimRight(link, ".,;:!?)")
+	}
+
+	return links
+}
+
+// Links returns the URLs in the message's text body, or its HTML body if there is no text.
+func (m Message) Links() []string {
+	if m.Text != "" {
+		return ExtractLinks(m.Text)
+	}
+
+	return ExtractLinks(m.HTML)
+}
@@ -0,0 +1,116 @@
+package email_test
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/cdriehuys/secret-santa/internal/email"
+)
+
+func TestRecordingMailer(t *testing.T) {
+	var mailer email.RecordingMailer
+
+	if _, ok := mailer.Last(); ok {
+		t.Error("Expected no last message before sending")
+	}
+
+	messages := []email.Message{
+		{To: "a@example.com", Subject: "Welcome"},
+		{To: "b@example.com", Subject: "Welcome"},
+		{To: "a@example.com", Subject: "Goodbye"},
+	}
+
+	for _, msg := range messages {
+		if err := mailer.Send(t.Context(), msg); err != nil {
+			t.Fatalf("Send returned an error: %v", err)
+		}
+	}
+
+	if got := mailer.Messages(); !slices.Equal(got, messages) {
+		t.Errorf("Expected messages %v, got %v", messages, got)
+	}
+
+	if got := mailer.To("a@example.com"); !slices.Equal(got, []email.Message{messages[0], messages[2]}) {
+		t.Errorf("Unexpected messages to a@example.com: %v", got)
+	}
+
+	if got := mailer.WithSubject("Welcome"); !slices.Equal(got, messages[:2]) {
+		t.Errorf("Unexpected messages with subject Welcome: %v", got)
+	}
+
+	if got, ok := mailer.Last(); !ok || got != messages[2] {
+		t.Errorf("Expected last message %v, got %v", messages[2], got)
+	}
+
+	mailer.Reset()
+	if got := mailer.Messages(); len(got) != 0 {
+		t.Errorf("Expected no messages after reset, got %v", got)
+	}
+}
+
+func TestRecordingMailer_Err(t *testing.T) {
+	mailer := email.RecordingMailer{Err: errors.New("send failed")}
+
+	if err := mailer.Send(t.Context(), email.Message{To: "a@example.com"}); err == nil {
+		t.Error("Expected configured error to be returned")
+	}
+
+	if got := len(mailer.Messages()); got != 1 {
+		t.Errorf("Expected failed send to be recorded, got %d messages", got)
+	}
+}
+
+func TestRecordingMailer_concurrent(t *testing.T) {
+	var mailer email.RecordingMailer
+	var wg sync.WaitGroup
+
+	for i := range 50 {
+		wg.Go(func() {
+			mailer.Send(t.Context(), email.Message{To: fmt.Sprintf("%d@example.com", i)})
+			mailer.To("1@example.com")
+		})
+	}
+
+	wg.Wait()
+
+	if got := len(mailer.Messages()); got != 50 {
+		t.Errorf("Expected 50 messages, got %d", got)
+	}
+}
+
+func TestMessage_Links(t *testing.T) {
+	testCases := []struct {
+		name string
+		msg  email.Message
+		want []string
+	}{
+		{
+			name: "text links",
+			msg: email.Message{
+				Text: "Visit https://example.com/verify-email/abc123.\nOr http://localhost:8080/ for help.",
+				HTML: `<a href="https://example.com/ignored">ignored</a>`,
+			},
+			want: []string{"https://example.com/verify-email/abc123", "http://localhost:8080/"},
+		},
+		{
+			name: "HTML links when there is no text",
+			msg:  email.Message{HTML: `<a href="https://example.com/verify-email/abc123">Verify</a>`},
+			want: []string{"https://example.com/verify-email/abc123"},
+		},
+		{
+			name: "no links",
+			msg:  email.Message{Text: "Hello"},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.msg.Links(); !slices.Equal(got, tt.want) {
+				t.Errorf("Expected links %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
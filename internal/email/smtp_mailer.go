@@ -0,0 +1,305 @@
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// TLSMode controls how the connection to an SMTP server is encrypted.
+type TLSMode string
+
+const (
+	// TLSModeStartTLS connects in plain text and requires the server to upgrade the connection
+	// with STARTTLS before anything else is sent.
+	TLSModeStartTLS TLSMode = "starttls"
+
+	// TLSModeImplicit establishes TLS before speaking SMTP, usually on port 465.
+	TLSModeImplicit TLSMode = "implicit"
+
+	// TLSModeNone never encrypts the connection. It is only suitable for local development
+	// servers.
+	TLSModeNone TLSMode = "none"
+)
+
+// AuthMechanism is the SASL mechanism used to log in to an SMTP server.
+type AuthMechanism string
+
+const (
+	AuthPlain AuthMechanism = "plain"
+	AuthLogin AuthMechanism = "login"
+)
+
+// DefaultSMTPTimeout bounds how long sending a single message may take when the context passed to
+// Send has no earlier deadline.
+const DefaultSMTPTimeout = 30 * time.Second
+
+type SMTPConfig struct {
+	Host string
+	Port int
+
+	// Username and Password are used to authenticate if Username is not empty.
+	Username string
+	Password string
+	Auth     AuthMechanism
+
+	TLS TLSMode
+
+	// TLSConfig overrides the configuration used for TLS connections. The server name defaults to
+	// Host.
+	TLSConfig *tls.Config
+
+	// Timeout overrides DefaultSMTPTimeout.
+	Timeout time.Duration
+
+	// LocalName is the host name sent in the EHLO command. Defaults to "localhost".
+	LocalName string
+}
+
+// SMTPMailer delivers emails through an SMTP server, opening a new connection for each message.
+type SMTPMailer struct {
+	config SMTPConfig
+
+	// now is used for the Date header.
+	now func() time.Time
+}
+
+// NewSMTPMailer creates a mailer that sends messages through the server described by config. An
+// error is returned if the TLS mode or auth mechanism is not recognized.
+func NewSMTPMailer(config SMTPConfig) (*SMTPMailer, error) {
+	if config.TLS == "" {
+		config.TLS = TLSModeStartTLS
+	}
+
+	if config.Auth == "" {
+		config.Auth = AuthPlain
+	}
+
+	if config.Timeout == 0 {
+		config.Timeout = DefaultSMTPTimeout
+	}
+
+	if config.LocalName == "" {
+		config.LocalName = "localhost"
+	}
+
+	switch config.TLS {
+	case TLSModeStartTLS, TLSModeImplicit, TLSModeNone:
+	default:
+		return nil, fmt.Errorf("unknown SMTP TLS mode %q", config.TLS)
+	}
+
+	switch config.Auth {
+	case AuthPlain, AuthLogin:
+	default:
+		return nil, fmt.Errorf("unknown SMTP auth mechanism %q", config.Auth)
+	}
+
+	return &SMTPMailer{config: config, now: time.Now}, nil
+}
+
+// Send delivers the email to the SMTP server. The context bounds the entire exchange with the
+// server, and cancelling it aborts the connection.
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	env, err := parseEnvelope(msg)
+	if err != nil {
+		return err
+	}
+
+	data, err := formatMessage(msg, env, m.now())
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.config.Timeout)
+	defer cancel()
+
+	conn, err := m.dial(ctx)
+	if err != nil {
+		return fmt.Errorf("connecting to SMTP server: %v", err)
+	}
+
+	defer conn.Close()
+
+	// net/smtp doesn't accept a context, so interrupt any blocked reads or writes on the underlying
+	// connection once the context is done.
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	if err := m.send(conn, env, data); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return fmt.Errorf("sending email: %w", ctxErr)
+		}
+
+		return fmt.Errorf("sending email: %v", err)
+	}
+
+	return nil
+}
+
+// Ping checks that the SMTP server is answering by waiting for its greeting and sending a NOOP
+// before quitting. It doesn't authenticate or send anything.
+func (m *SMTPMailer) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, m.config.Timeout)
+	defer cancel()
+
+	conn, err := m.dial(ctx)
+	if err != nil {
+		return fmt.Errorf("connecting to SMTP server: %v", err)
+	}
+
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	client, err := smtp.NewClient(conn, m.config.Host)
+	if err != nil {
+		return fmt.Errorf("reading SMTP greeting: %v", err)
+	}
+
+	defer client.Close()
+
+	if err := client.Noop(); err != nil {
+		return fmt.Errorf("checking SMTP server: %v", err)
+	}
+
+	return client.Quit()
+}
+
+func (m *SMTPMailer) dial(ctx context.Context) (net.Conn, error) {
+	addr := net.JoinHostPort(m.config.Host, strconv.Itoa(m.config.Port))
+
+	if m.config.TLS == TLSModeImplicit {
+		dialer := tls.Dialer{Config: m.tlsConfig()}
+
+		return dialer.DialContext(ctx, "tcp", addr)
+	}
+
+	var dialer net.Dialer
+
+	return dialer.DialContext(ctx, "tcp", addr)
+}
+
+func (m *SMTPMailer) send(conn net.Conn, env envelope, data []byte) error {
+	client, err := smtp.NewClient(conn, m.config.Host)
+	if err != nil {
+		return err
+	}
+
+	defer client.Close()
+
+	if err := client.Hello(m.config.LocalName); err != nil {
+		return err
+	}
+
+	if m.config.TLS == TLSModeStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return errors.New("server does not support STARTTLS")
+		}
+
+		if err := client.StartTLS(m.tlsConfig()); err != nil {
+			return fmt.Errorf("starting TLS: %v", err)
+		}
+	}
+
+	if m.config.Username != "" {
+		if err := client.Auth(m.auth()); err != nil {
+			return fmt.Errorf("authenticating: %v", err)
+		}
+	}
+
+	if err := client.Mail(env.from.Address); err != nil {
+		return err
+	}
+
+	if err := client.Rcpt(env.to.Address); err != nil {
+		return err
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+func (m *SMTPMailer) tlsConfig() *tls.Config {
+	config := &tls.Config{}
+	if m.config.TLSConfig != nil {
+		config = m.config.TLSConfig.Clone()
+	}
+
+	if config.ServerName == "" {
+		config.ServerName = m.config.Host
+	}
+
+	return config
+}
+
+func (m *SMTPMailer) auth() smtp.Auth {
+	if m.config.Auth == AuthLogin {
+		return &loginAuth{host: m.config.Host, username: m.config.Username, password: m.config.Password}
+	}
+
+	return smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host)
+}
+
+// loginAuth implements the non-standard but widely deployed LOGIN mechanism which net/smtp does not
+// provide.
+type loginAuth struct {
+	host     string
+	username string
+	password string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	// Match PlainAuth in refusing to send credentials over an unencrypted connection to anything
+	// but the local machine.
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+
+	switch string(fromServer) {
+	case "Username:":
+		return []byte(a.username), nil
+	case "Password:":
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("unexpected LOGIN challenge %q", fromServer)
+	}
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
@@ -0,0 +1,494 @@
+package email_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"errors"
+	"io"
+	"math/big"
+	"mime"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cdriehuys/secret-santa/internal/email"
+)
+
+// fakeSMTPServer is a minimal SMTP server that records what a client sends it.
+type fakeSMTPServer struct {
+	listener  net.Listener
+	tlsConfig *tls.Config
+
+	implicitTLS bool
+	startTLS    bool
+	silent      bool
+
+	mu       sync.Mutex
+	auth     string
+	username string
+	password string
+	usedTLS  bool
+	from     string
+	to       []string
+	data     string
+	noops    int
+}
+
+func newFakeSMTPServer(t *testing.T, configure func(*fakeSMTPServer)) (*fakeSMTPServer, *x509.CertPool) {
+	cert, pool := selfSignedCertificate(t)
+
+	s := &fakeSMTPServer{tlsConfig: &tls.Config{Certificates: []tls.Certificate{cert}}}
+	if configure != nil {
+		configure(s)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	if s.implicitTLS {
+		listener = tls.NewListener(listener, s.tlsConfig)
+	}
+
+	s.listener = listener
+	t.Cleanup(func() { listener.Close() })
+
+	go s.serve()
+
+	return s, pool
+}
+
+func (s *fakeSMTPServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeSMTPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeSMTPServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	if s.silent {
+		io.Copy(io.Discard, conn)
+		return
+	}
+
+	if _, ok := conn.(*tls.Conn); ok {
+		s.mu.Lock()
+		s.usedTLS = true
+		s.mu.Unlock()
+	}
+
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 fake.example.com ESMTP")
+
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+
+		command, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(command) {
+		case "EHLO":
+			text.PrintfLine("250-fake.example.com")
+			if s.startTLS {
+				text.PrintfLine("250-STARTTLS")
+			}
+			text.PrintfLine("250 AUTH PLAIN LOGIN")
+		case "STARTTLS":
+			text.PrintfLine("220 Ready to start TLS")
+
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+
+			conn = tlsConn
+			text = textproto.NewConn(conn)
+
+			s.mu.Lock()
+			s.usedTLS = true
+			s.mu.Unlock()
+		case "AUTH":
+			if !s.handleAuth(text, arg) {
+				return
+			}
+		case "MAIL":
+			s.mu.Lock()
+			s.from = arg
+			s.mu.Unlock()
+			text.PrintfLine("250 OK")
+		case "RCPT":
+			s.mu.Lock()
+			s.to = append(s.to, arg)
+			s.mu.Unlock()
+			text.PrintfLine("250 OK")
+		case "DATA":
+			text.PrintfLine("354 Go ahead")
+
+			data, err := io.ReadAll(text.DotReader())
+			if err != nil {
+				return
+			}
+
+			s.mu.Lock()
+			s.data = string(data)
+			s.mu.Unlock()
+			text.PrintfLine("250 Queued")
+		case "NOOP":
+			s.mu.Lock()
+			s.noops++
+			s.mu.Unlock()
+			text.PrintfLine("250 OK")
+		case "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("502 Unknown command")
+		}
+	}
+}
+
+func (s *fakeSMTPServer) handleAuth(text *textproto.Conn, arg string) bool {
+	mechanism, initial, _ := strings.Cut(arg, " ")
+
+	var username, password string
+
+	switch mechanism {
+	case "PLAIN":
+		decoded, err := base64.StdEncoding.DecodeString(initial)
+		if err != nil {
+			return false
+		}
+
+		parts := strings.Split(string(decoded), "\x00")
+		if len(parts) != 3 {
+			return false
+		}
+
+		username, password = parts[1], parts[2]
+	case "LOGIN":
+		var ok bool
+		if username, ok = loginChallenge(text, "Username:"); !ok {
+			return false
+		}
+
+		if password, ok = loginChallenge(text, "Password:"); !ok {
+			return false
+		}
+	default:
+		text.PrintfLine("504 Unsupported mechanism")
+		return true
+	}
+
+	s.mu.Lock()
+	s.auth = mechanism
+	s.username = username
+	s.password = password
+	s.mu.Unlock()
+
+	text.PrintfLine("235 Authenticated")
+
+	return true
+}
+
+func loginChallenge(text *textproto.Conn, prompt string) (string, bool) {
+	text.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte(prompt)))
+
+	line, err := text.ReadLine()
+	if err != nil {
+		return "", false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(line)
+	if err != nil {
+		return "", false
+	}
+
+	return string(decoded), true
+}
+
+func selfSignedCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	parsed, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(parsed)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+func newSMTPMailer(t *testing.T, config email.SMTPConfig) *email.SMTPMailer {
+	mailer, err := email.NewSMTPMailer(config)
+	if err != nil {
+		t.Fatalf("failed to create SMTP mailer: %v", err)
+	}
+
+	return mailer
+}
+
+func TestNewSMTPMailer_invalidConfig(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config email.SMTPConfig
+	}{
+		{name: "unknown TLS mode", config: email.SMTPConfig{TLS: "sometimes"}},
+		{name: "unknown auth mechanism", config: email.SMTPConfig{Auth: "cram-md5"}},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := email.NewSMTPMailer(tt.config); err == nil {
+				t.Error("Expected an error, got nil")
+			}
+		})
+	}
+}
+
+func TestSMTPMailer_Send(t *testing.T) {
+	testCases := []struct {
+		name      string
+		server    func(*fakeSMTPServer)
+		config    email.SMTPConfig
+		wantAuth  string
+		wantTLS   bool
+		wantError bool
+	}{
+		{
+			name:     "STARTTLS with PLAIN auth",
+			server:   func(s *fakeSMTPServer) { s.startTLS = true },
+			config:   email.SMTPConfig{TLS: email.TLSModeStartTLS, Auth: email.AuthPlain, Username: "user", Password: "hunter2"},
+			wantAuth: "PLAIN",
+			wantTLS:  true,
+		},
+		{
+			name:     "implicit TLS with LOGIN auth",
+			server:   func(s *fakeSMTPServer) { s.implicitTLS = true },
+			config:   email.SMTPConfig{TLS: email.TLSModeImplicit, Auth: email.AuthLogin, Username: "user", Password: "hunter2"},
+			wantAuth: "LOGIN",
+			wantTLS:  true,
+		},
+		{
+			name:   "plain text without auth",
+			config: email.SMTPConfig{TLS: email.TLSModeNone},
+		},
+		{
+			name:      "STARTTLS required but unsupported",
+			config:    email.SMTPConfig{TLS: email.TLSModeStartTLS},
+			wantError: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			server, pool := newFakeSMTPServer(t, tt.server)
+
+			config := tt.config
+			config.Host = "127.0.0.1"
+			config.Port = server.port()
+			config.TLSConfig = &tls.Config{RootCAs: pool}
+
+			mailer := newSMTPMailer(t, config)
+
+			msg := email.Message{
+				To:      "Recipient <to@example.com>",
+				From:    "no-reply@example.com",
+				Subject: "Hello ✓",
+				Text:    "Line one\nLine two",
+				HTML:    "<p>Line one</p><p>Line two</p>",
+			}
+
+			err := mailer.Send(t.Context(), msg)
+			if tt.wantError {
+				if err == nil {
+					t.Fatal("Expected an error, got nil")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Send returned an error: %v", err)
+			}
+
+			server.mu.Lock()
+			defer server.mu.Unlock()
+
+			if server.auth != tt.wantAuth {
+				t.Errorf("Expected auth mechanism %q, got %q", tt.wantAuth, server.auth)
+			}
+
+			if tt.wantAuth != "" && (server.username != "user" || server.password != "hunter2") {
+				t.Errorf("Expected credentials user/hunter2, got %s/%s", server.username, server.password)
+			}
+
+			if server.usedTLS != tt.wantTLS {
+				t.Errorf("Expected TLS %v, got %v", tt.wantTLS, server.usedTLS)
+			}
+
+			if want := "FROM:<no-reply@example.com>"; server.from != want {
+				t.Errorf("Expected MAIL %q, got %q", want, server.from)
+			}
+
+			if want := []string{"TO:<to@example.com>"}; len(server.to) != 1 || server.to[0] != want[0] {
+				t.Errorf("Expected RCPT %v, got %v", want, server.to)
+			}
+
+			assertValidMessage(t, server.data)
+		})
+	}
+}
+
+func assertValidMessage(t *testing.T, data string) {
+	t.Helper()
+
+	msg, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to parse delivered message: %v\n%s", err, data)
+	}
+
+	for _, header := range []string{"Date", "Message-Id", "From", "To", "Subject", "Mime-Version", "Content-Type"} {
+		if msg.Header.Get(header) == "" {
+			t.Errorf("Expected %s header in message:\n%s", header, data)
+		}
+	}
+
+	if _, err := msg.Header.Date(); err != nil {
+		t.Errorf("Expected a valid Date header: %v", err)
+	}
+
+	var decoder mime.WordDecoder
+	subject, err := decoder.DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "Hello ✓" {
+		t.Errorf("Expected subject %q, got %q (%v)", "Hello ✓", subject, err)
+	}
+
+	// The server's dot reader has already translated line endings back to plain newlines.
+	parts := messageParts(t, data)
+
+	if want := "Line one\nLine two"; parts["text/plain"] != want {
+		t.Errorf("Expected text body %q, got %q", want, parts["text/plain"])
+	}
+
+	if want := "<p>Line one</p><p>Line two</p>"; parts["text/html"] != want {
+		t.Errorf("Expected HTML body %q, got %q", want, parts["text/html"])
+	}
+}
+
+func TestSMTPMailer_Send_contextTimeout(t *testing.T) {
+	server, _ := newFakeSMTPServer(t, func(s *fakeSMTPServer) { s.silent = true })
+
+	mailer := newSMTPMailer(t, email.SMTPConfig{
+		Host: "127.0.0.1",
+		Port: server.port(),
+		TLS:  email.TLSModeNone,
+	})
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := mailer.Send(ctx, email.Message{To: "to@example.com", From: "from@example.com", Subject: "Subject", Text: "Body"})
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded error, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected send to give up promptly, took %v", elapsed)
+	}
+}
+
+func TestSMTPMailer_Send_invalidAddress(t *testing.T) {
+	mailer := newSMTPMailer(t, email.SMTPConfig{Host: "127.0.0.1", Port: 1})
+
+	msg := email.Message{To: "not an address", From: "from@example.com", Subject: "Subject", Text: "Body"}
+	if err := mailer.Send(t.Context(), msg); err == nil {
+		t.Error("Expected an error for an invalid recipient")
+	}
+}
+
+func TestSMTPMailer_Ping(t *testing.T) {
+	server, _ := newFakeSMTPServer(t, nil)
+
+	mailer := newSMTPMailer(t, email.SMTPConfig{
+		Host: "127.0.0.1",
+		Port: server.port(),
+		TLS:  email.TLSModeNone,
+	})
+
+	if err := mailer.Ping(t.Context()); err != nil {
+		t.Errorf("Expected ping to succeed, got %v", err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	if server.noops != 1 {
+		t.Errorf("Expected ping to send a NOOP, got %d", server.noops)
+	}
+
+	if server.from != "" || len(server.to) > 0 {
+		t.Errorf("Expected ping not to send anything, got from %q to %v", server.from, server.to)
+	}
+}
+
+func TestSMTPMailer_Ping_unresponsive(t *testing.T) {
+	server, _ := newFakeSMTPServer(t, func(s *fakeSMTPServer) { s.silent = true })
+
+	mailer := newSMTPMailer(t, email.SMTPConfig{
+		Host: "127.0.0.1",
+		Port: server.port(),
+		TLS:  email.TLSModeNone,
+	})
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := mailer.Ping(ctx); err == nil {
+		t.Error("Expected ping to fail when the server doesn't greet the client")
+	}
+}
@@ -0,0 +1,154 @@
+package i18n
+
+var german = map[string]string{
+	// Navigation
+	"Home":            "Startseite",
+	"Account":         "Konto",
+	"Log Out":         "Abmelden",
+	"Log In":          "Anmelden",
+	"Register":        "Registrieren",
+	"Language":        "Sprache",
+	"Change Language": "Sprache ändern",
+
+	// Account
+	"Account Deleted": "Konto gelöscht",
+	"Your account and the data associated with it have been deleted.": "Dein Konto und die damit verbundenen Daten wurden gelöscht.",
+	"Confirm Your New Email": "Bestätige deine neue E-Mail-Adresse",
+	"Please check the inbox of your new email address to finish changing your email.": "Bitte prüfe das Postfach deiner neuen E-Mail-Adresse, um die Änderung abzuschließen.",
+	"Back to account":                  "Zurück zum Konto",
+	"Password Changed":                 "Passwort geändert",
+	"Your password has been updated.":  "Dein Passwort wurde aktualisiert.",
+	"You are logged in as %s.":         "Du bist als %s angemeldet.",
+	"Change Email":                     "E-Mail-Adresse ändern",
+	"New email:":                       "Neue E-Mail-Adresse:",
+	"Change Password":                  "Passwort ändern",
+	"Current password:":                "Aktuelles Passwort:",
+	"New password:":                    "Neues Passwort:",
+	"Manage two-factor authentication": "Zwei-Faktor-Authentifizierung verwalten",
+	"Your Data":                        "Deine Daten",
+	"Download a copy of your data":     "Eine Kopie deiner Daten herunterladen",
+	"Delete Account":                   "Konto löschen",
+	"Deleting your account is permanent and cannot be undone.": "Das Löschen deines Kontos ist endgültig und kann nicht rückgängig gemacht werden.",
+	"Password:":                                "Passwort:",
+	"Please enter an email address.":           "Bitte gib eine E-Mail-Adresse ein.",
+	"Password must be at least %d characters.": "Das Passwort muss mindestens %d Zeichen lang sein.",
+	"Password is incorrect.":                   "Das Passwort ist falsch.",
+
+	// Two-factor authentication
+	"Two-Factor Authentication": "Zwei-Faktor-Authentifizierung",
+	"Recovery Codes":            "Wiederherstellungscodes",
+	"Two-factor authentication is now enabled. If you lose access to your authenticator app, you can log in with one of these codes instead. Each code can only be used once.": "Die Zwei-Faktor-Authentifizierung ist jetzt aktiviert. Falls du keinen Zugriff mehr auf deine Authenticator-App hast, kannst du dich stattdessen mit einem dieser Codes anmelden. Jeder Code kann nur einmal verwendet werden.",
+	"Save these codes somewhere safe. They will not be shown again.": "Bewahre diese Codes sicher auf. Sie werden nicht noch einmal angezeigt.",
+	"Two-factor authentication is enabled for your account.":         "Die Zwei-Faktor-Authentifizierung ist für dein Konto aktiviert.",
+	"Disable":                           "Deaktivieren",
+	"Code:":                             "Code:",
+	"Disable Two-Factor Authentication": "Zwei-Faktor-Authentifizierung deaktivieren",
+	"Add your account to an authenticator app using the link or secret below, then enter the code it shows to finish setting up two-factor authentication.": "Füge dein Konto mit dem Link oder dem Schlüssel unten zu einer Authenticator-App hinzu und gib dann den angezeigten Code ein, um die Zwei-Faktor-Authentifizierung einzurichten.",
+	"Open in authenticator app":        "In der Authenticator-App öffnen",
+	"Secret:":                          "Schlüssel:",
+	"Enable Two-Factor Authentication": "Zwei-Faktor-Authentifizierung aktivieren",
+	"Enter the code from your authenticator app, or one of your recovery codes.": "Gib den Code aus deiner Authenticator-App oder einen deiner Wiederherstellungscodes ein.",
+	"Verify":                  "Bestätigen",
+	"That code is not valid.": "Dieser Code ist ungültig.",
+
+	// Login and registration
+	"Email:":                          "E-Mail:",
+	"Email or password is incorrect.": "E-Mail-Adresse oder Passwort ist falsch.",
+	"Registered Successfully":         "Erfolgreich registriert",
+	"Please check your email to finish the registration process.": "Bitte prüfe dein E-Mail-Postfach, um die Registrierung abzuschließen.",
+	"Invalid Link": "Ungültiger Link",
+	"This verification link is invalid or has expired.": "Dieser Bestätigungslink ist ungültig oder abgelaufen.",
+	"Email Verified": "E-Mail bestätigt",
+	"Thanks for confirming your email address.": "Danke, dass du deine E-Mail-Adresse bestätigt hast.",
+	"Log in": "Anmelden",
+
+	// Errors
+	"Bad Request": "Ungültige Anfrage",
+	"We couldn't understand your request. Please go back and try again.": "Wir konnten deine Anfrage nicht verstehen. Bitte gehe zurück und versuche es erneut.",
+	"Page Not Found": "Seite nicht gefunden",
+	"The page you were looking for doesn't exist.":               "Die gesuchte Seite existiert nicht.",
+	"Something Went Wrong":                                       "Etwas ist schiefgelaufen",
+	"We couldn't complete your request. Please try again later.": "Wir konnten deine Anfrage nicht bearbeiten. Bitte versuche es später erneut.",
+	"Too Many Requests":                                          "Zu viele Anfragen",
+	"You've made too many attempts in a short time. Please wait a few minutes and try again.": "Du hast es in kurzer Zeit zu oft versucht. Bitte warte ein paar Minuten und versuche es erneut.",
+
+	// Pairings
+	"Hello, World!":     "Hallo, Welt!",
+	"Generate Pairings": "Paarungen erstellen",
+	"Enter everyone taking part. Exclusions are people a participant must not draw, such as their partner.": "Trage alle Teilnehmenden ein. Ausschlüsse sind Personen, die eine Person nicht ziehen darf, zum Beispiel die eigene Partnerin oder der eigene Partner.",
+	"Name:":                                  "Name:",
+	"Exclusion:":                             "Ausschluss:",
+	"Add Participant":                        "Person hinzufügen",
+	"Draw Pairings":                          "Paarungen auslosen",
+	"Import participants from a spreadsheet": "Teilnehmende aus einer Tabelle importieren",
+	"Pairings":                               "Paarungen",
+	"Giver":                                  "Schenkende Person",
+	"Recipient":                              "Beschenkte Person",
+	"Download CSV":                           "CSV herunterladen",
+	"Download JSON":                          "JSON herunterladen",
+	"Print Slips":                            "Zettel drucken",
+	"Draw again":                             "Neu auslosen",
+	"Secret Santa Slips":                     "Wichtelzettel",
+	"Cut along the dashed lines and hand each person their slip.": "Schneide entlang der gestrichelten Linien und gib jeder Person ihren Zettel.",
+	"Print":                             "Drucken",
+	"%s, you are the Secret Santa for:": "%s, du beschenkst:",
+	"At least two participants are required.":                                           "Es werden mindestens zwei Teilnehmende benötigt.",
+	"No pairings satisfy the exclusions. Try removing some exclusions.":                 "Keine Paarung erfüllt die Ausschlüsse. Entferne einige Ausschlüsse.",
+	"No pairings satisfy the exclusions and households. Try removing some exclusions.":  "Keine Paarung erfüllt die Ausschlüsse und Haushalte. Entferne einige Ausschlüsse.",
+	"Pairings couldn't be found in time. Try drawing again, or remove some exclusions.": "Es konnten nicht rechtzeitig Paarungen gefunden werden. Lose erneut aus oder entferne einige Ausschlüsse.",
+
+	// Participants
+	"There can be at most %d participants.":                      "Es können höchstens %d Personen teilnehmen.",
+	"Name is required.":                                          "Der Name ist erforderlich.",
+	"%q is listed more than once.":                               "%q ist mehrfach aufgeführt.",
+	"%q is not a valid email address.":                           "%q ist keine gültige E-Mail-Adresse.",
+	"There can be at most %d exclusions.":                        "Es sind höchstens %d Ausschlüsse möglich.",
+	"%q is not a participant.":                                   "%q nimmt nicht teil.",
+	"Participants are already excluded from drawing themselves.": "Niemand kann sich selbst ziehen, dafür ist kein Ausschluss nötig.",
+	"The %q household has %d of the %d participants. A household can have at most half of the participants.": "Der Haushalt %q hat %d der %d Teilnehmenden. Ein Haushalt kann höchstens die Hälfte der Teilnehmenden umfassen.",
+	"%q excludes everyone else, so they can't give a gift.":                                                  "%q schließt alle anderen aus und kann deshalb niemanden beschenken.",
+	"Everyone else excludes %q, so no one can give them a gift.":                                             "Alle anderen schließen %q aus, daher kann niemand diese Person beschenken.",
+
+	// Import
+	"Import Participants":     "Teilnehmende importieren",
+	"%d participant found.":   "%d Person gefunden.",
+	"%d participants found.":  "%d Personen gefunden.",
+	"Row":                     "Zeile",
+	"Name":                    "Name",
+	"Email":                   "E-Mail",
+	"Household":               "Haushalt",
+	"Exclusions":              "Ausschlüsse",
+	"Import a different file": "Andere Datei importieren",
+	"Upload a CSV file exported from a spreadsheet, or paste its contents. The first row must name the columns:": "Lade eine CSV-Datei aus einer Tabellenkalkulation hoch oder füge ihren Inhalt ein. Die erste Zeile muss die Spalten benennen:",
+	"(required)": "(erforderlich)",
+	"people in the same household never draw each other":                     "Personen im selben Haushalt ziehen sich nie gegenseitig",
+	"names the participant must not draw, separated by commas or semicolons": "Namen, die die Person nicht ziehen darf, getrennt durch Kommas oder Semikolons",
+	"File:":                          "Datei:",
+	"Or paste the participants:":     "Oder füge die Teilnehmenden ein:",
+	"Preview":                        "Vorschau",
+	"Row %d: %s":                     "Zeile %d: %s",
+	"Row %d, %s: %s":                 "Zeile %d, %s: %s",
+	"The file could not be read: %v": "Die Datei konnte nicht gelesen werden: %v",
+	"The file is empty.":             "Die Datei ist leer.",
+	`The first row must name the columns and include a "name" column.`: `Die erste Zeile muss die Spalten benennen und eine Spalte "name" enthalten.`,
+	"The file doesn't contain any participants.":                       "Die Datei enthält keine Teilnehmenden.",
+	"The row is not valid CSV: %v":                                     "Die Zeile ist kein gültiges CSV: %v",
+	"The file is not valid CSV: %v":                                    "Die Datei ist kein gültiges CSV: %v",
+	"The file must not be larger than 1 MiB.":                          "Die Datei darf nicht größer als 1 MiB sein.",
+	"Choose a CSV file or paste the participants.":                     "Wähle eine CSV-Datei aus oder füge die Teilnehmenden ein.",
+
+	// API
+	"Requests must be sent as application/json.":                                                  "Anfragen müssen als application/json gesendet werden.",
+	"Request body must not be larger than %d bytes.":                                              "Der Anfragetext darf nicht größer als %d Bytes sein.",
+	"The request body is not valid JSON: %v":                                                      "Der Anfragetext ist kein gültiges JSON: %v",
+	"The request contains invalid participants.":                                                  "Die Anfrage enthält ungültige Teilnehmende.",
+	"Pairings couldn't be found in time. Try again with another seed, or remove some exclusions.": "Es konnten nicht rechtzeitig Paarungen gefunden werden. Versuche es mit einem anderen Seed erneut oder entferne einige Ausschlüsse.",
+	"Failed to generate pairings.":                                                                "Die Paarungen konnten nicht erstellt werden.",
+	"Files must be sent as text/csv.":                                                             "Dateien müssen als text/csv gesendet werden.",
+	"The request body could not be read.":                                                         "Der Anfragetext konnte nicht gelesen werden.",
+	"The file contains invalid participants.":                                                     "Die Datei enthält ungültige Teilnehmende.",
+
+	// Emails
+	"Thanks,":   "Vielen Dank,",
+	"The Elves": "Die Wichtel",
+}
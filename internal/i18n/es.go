@@ -0,0 +1,154 @@
+package i18n
+
+var spanish = map[string]string{
+	// Navigation
+	"Home":            "Inicio",
+	"Account":         "Cuenta",
+	"Log Out":         "Cerrar sesión",
+	"Log In":          "Iniciar sesión",
+	"Register":        "Registrarse",
+	"Language":        "Idioma",
+	"Change Language": "Cambiar idioma",
+
+	// Account
+	"Account Deleted": "Cuenta eliminada",
+	"Your account and the data associated with it have been deleted.": "Tu cuenta y los datos asociados a ella se han eliminado.",
+	"Confirm Your New Email": "Confirma tu nuevo correo electrónico",
+	"Please check the inbox of your new email address to finish changing your email.": "Revisa la bandeja de entrada de tu nuevo correo electrónico para terminar el cambio.",
+	"Back to account":                  "Volver a la cuenta",
+	"Password Changed":                 "Contraseña cambiada",
+	"Your password has been updated.":  "Tu contraseña se ha actualizado.",
+	"You are logged in as %s.":         "Has iniciado sesión como %s.",
+	"Change Email":                     "Cambiar correo electrónico",
+	"New email:":                       "Nuevo correo electrónico:",
+	"Change Password":                  "Cambiar contraseña",
+	"Current password:":                "Contraseña actual:",
+	"New password:":                    "Nueva contraseña:",
+	"Manage two-factor authentication": "Gestionar la autenticación en dos pasos",
+	"Your Data":                        "Tus datos",
+	"Download a copy of your data":     "Descargar una copia de tus datos",
+	"Delete Account":                   "Eliminar cuenta",
+	"Deleting your account is permanent and cannot be undone.": "Eliminar tu cuenta es permanente y no se puede deshacer.",
+	"Password:":                                "Contraseña:",
+	"Please enter an email address.":           "Introduce una dirección de correo electrónico.",
+	"Password must be at least %d characters.": "La contraseña debe tener al menos %d caracteres.",
+	"Password is incorrect.":                   "La contraseña es incorrecta.",
+
+	// Two-factor authentication
+	"Two-Factor Authentication": "Autenticación en dos pasos",
+	"Recovery Codes":            "Códigos de recuperación",
+	"Two-factor authentication is now enabled. If you lose access to your authenticator app, you can log in with one of these codes instead. Each code can only be used once.": "La autenticación en dos pasos ya está activada. Si pierdes el acceso a tu aplicación de autenticación, puedes iniciar sesión con uno de estos códigos. Cada código solo se puede usar una vez.",
+	"Save these codes somewhere safe. They will not be shown again.": "Guarda estos códigos en un lugar seguro. No se volverán a mostrar.",
+	"Two-factor authentication is enabled for your account.":         "La autenticación en dos pasos está activada para tu cuenta.",
+	"Disable":                           "Desactivar",
+	"Code:":                             "Código:",
+	"Disable Two-Factor Authentication": "Desactivar la autenticación en dos pasos",
+	"Add your account to an authenticator app using the link or secret below, then enter the code it shows to finish setting up two-factor authentication.": "Añade tu cuenta a una aplicación de autenticación con el enlace o la clave de abajo y luego introduce el código que muestre para terminar de configurar la autenticación en dos pasos.",
+	"Open in authenticator app":        "Abrir en la aplicación de autenticación",
+	"Secret:":                          "Clave:",
+	"Enable Two-Factor Authentication": "Activar la autenticación en dos pasos",
+	"Enter the code from your authenticator app, or one of your recovery codes.": "Introduce el código de tu aplicación de autenticación o uno de tus códigos de recuperación.",
+	"Verify":                  "Verificar",
+	"That code is not valid.": "Ese código no es válido.",
+
+	// Login and registration
+	"Email:":                          "Correo electrónico:",
+	"Email or password is incorrect.": "El correo electrónico o la contraseña son incorrectos.",
+	"Registered Successfully":         "Registro completado",
+	"Please check your email to finish the registration process.": "Revisa tu correo electrónico para terminar el proceso de registro.",
+	"Invalid Link": "Enlace no válido",
+	"This verification link is invalid or has expired.": "Este enlace de verificación no es válido o ha caducado.",
+	"Email Verified": "Correo electrónico verificado",
+	"Thanks for confirming your email address.": "Gracias por confirmar tu dirección de correo electrónico.",
+	"Log in": "Iniciar sesión",
+
+	// Errors
+	"Bad Request": "Solicitud no válida",
+	"We couldn't understand your request. Please go back and try again.": "No pudimos entender tu solicitud. Vuelve atrás e inténtalo de nuevo.",
+	"Page Not Found": "Página no encontrada",
+	"The page you were looking for doesn't exist.":               "La página que buscas no existe.",
+	"Something Went Wrong":                                       "Algo salió mal",
+	"We couldn't complete your request. Please try again later.": "No pudimos completar tu solicitud. Inténtalo de nuevo más tarde.",
+	"Too Many Requests":                                          "Demasiadas solicitudes",
+	"You've made too many attempts in a short time. Please wait a few minutes and try again.": "Has hecho demasiados intentos en poco tiempo. Espera unos minutos y vuelve a intentarlo.",
+
+	// Pairings
+	"Hello, World!":     "¡Hola, mundo!",
+	"Generate Pairings": "Generar parejas",
+	"Enter everyone taking part. Exclusions are people a participant must not draw, such as their partner.": "Introduce a todas las personas que participan. Las exclusiones son personas que un participante no puede sacar, como su pareja.",
+	"Name:":                                  "Nombre:",
+	"Exclusion:":                             "Exclusión:",
+	"Add Participant":                        "Añadir participante",
+	"Draw Pairings":                          "Sortear parejas",
+	"Import participants from a spreadsheet": "Importar participantes desde una hoja de cálculo",
+	"Pairings":                               "Parejas",
+	"Giver":                                  "Quien regala",
+	"Recipient":                              "Quien recibe",
+	"Download CSV":                           "Descargar CSV",
+	"Download JSON":                          "Descargar JSON",
+	"Print Slips":                            "Imprimir papeletas",
+	"Draw again":                             "Sortear de nuevo",
+	"Secret Santa Slips":                     "Papeletas del amigo invisible",
+	"Cut along the dashed lines and hand each person their slip.": "Recorta por las líneas discontinuas y entrega a cada persona su papeleta.",
+	"Print":                             "Imprimir",
+	"%s, you are the Secret Santa for:": "%s, eres el amigo invisible de:",
+	"At least two participants are required.":                                           "Se necesitan al menos dos participantes.",
+	"No pairings satisfy the exclusions. Try removing some exclusions.":                 "Ninguna combinación de parejas cumple las exclusiones. Prueba a quitar algunas exclusiones.",
+	"No pairings satisfy the exclusions and households. Try removing some exclusions.":  "Ninguna combinación de parejas cumple las exclusiones y los hogares. Prueba a quitar algunas exclusiones.",
+	"Pairings couldn't be found in time. Try drawing again, or remove some exclusions.": "No se encontraron parejas a tiempo. Vuelve a sortear o quita algunas exclusiones.",
+
+	// Participants
+	"There can be at most %d participants.":                      "Puede haber como máximo %d participantes.",
+	"Name is required.":                                          "El nombre es obligatorio.",
+	"%q is listed more than once.":                               "%q aparece más de una vez.",
+	"%q is not a valid email address.":                           "%q no es una dirección de correo electrónico válida.",
+	"There can be at most %d exclusions.":                        "Puede haber como máximo %d exclusiones.",
+	"%q is not a participant.":                                   "%q no es un participante.",
+	"Participants are already excluded from drawing themselves.": "Nadie puede sacarse a sí mismo, así que no hace falta esta exclusión.",
+	"The %q household has %d of the %d participants. A household can have at most half of the participants.": "El hogar %q tiene %d de los %d participantes. Un hogar puede tener como máximo la mitad de los participantes.",
+	"%q excludes everyone else, so they can't give a gift.":                                                  "%q excluye a todos los demás, así que no puede hacer ningún regalo.",
+	"Everyone else excludes %q, so no one can give them a gift.":                                             "Todos los demás excluyen a %q, así que nadie puede hacerle un regalo.",
+
+	// Import
+	"Import Participants":     "Importar participantes",
+	"%d participant found.":   "%d participante encontrado.",
+	"%d participants found.":  "%d participantes encontrados.",
+	"Row":                     "Fila",
+	"Name":                    "Nombre",
+	"Email":                   "Correo electrónico",
+	"Household":               "Hogar",
+	"Exclusions":              "Exclusiones",
+	"Import a different file": "Importar otro archivo",
+	"Upload a CSV file exported from a spreadsheet, or paste its contents. The first row must name the columns:": "Sube un archivo CSV exportado de una hoja de cálculo o pega su contenido. La primera fila debe nombrar las columnas:",
+	"(required)": "(obligatoria)",
+	"people in the same household never draw each other":                     "las personas del mismo hogar nunca se sacan entre sí",
+	"names the participant must not draw, separated by commas or semicolons": "nombres que el participante no puede sacar, separados por comas o punto y coma",
+	"File:":                          "Archivo:",
+	"Or paste the participants:":     "O pega los participantes:",
+	"Preview":                        "Vista previa",
+	"Row %d: %s":                     "Fila %d: %s",
+	"Row %d, %s: %s":                 "Fila %d, %s: %s",
+	"The file could not be read: %v": "No se pudo leer el archivo: %v",
+	"The file is empty.":             "El archivo está vacío.",
+	`The first row must name the columns and include a "name" column.`: `La primera fila debe nombrar las columnas e incluir una columna "name".`,
+	"The file doesn't contain any participants.":                       "El archivo no contiene participantes.",
+	"The row is not valid CSV: %v":                                     "La fila no es un CSV válido: %v",
+	"The file is not valid CSV: %v":                                    "El archivo no es un CSV válido: %v",
+	"The file must not be larger than 1 MiB.":                          "El archivo no puede superar 1 MiB.",
+	"Choose a CSV file or paste the participants.":                     "Elige un archivo CSV o pega los participantes.",
+
+	// API
+	"Requests must be sent as application/json.":                                                  "Las solicitudes deben enviarse como application/json.",
+	"Request body must not be larger than %d bytes.":                                              "El cuerpo de la solicitud no puede superar los %d bytes.",
+	"The request body is not valid JSON: %v":                                                      "El cuerpo de la solicitud no es un JSON válido: %v",
+	"The request contains invalid participants.":                                                  "La solicitud contiene participantes no válidos.",
+	"Pairings couldn't be found in time. Try again with another seed, or remove some exclusions.": "No se encontraron parejas a tiempo. Inténtalo de nuevo con otra semilla o quita algunas exclusiones.",
+	"Failed to generate pairings.":                                                                "No se pudieron generar las parejas.",
+	"Files must be sent as text/csv.":                                                             "Los archivos deben enviarse como text/csv.",
+	"The request body could not be read.":                                                         "No se pudo leer el cuerpo de la solicitud.",
+	"The file contains invalid participants.":                                                     "El archivo contiene participantes no válidos.",
+
+	// Emails
+	"Thanks,":   "Gracias,",
+	"The Elves": "Los Elfos",
+}
@@ -0,0 +1,99 @@
+// Package i18n translates the text shown to users. Text is written in English in the code and
+// templates, and the English text is the key for its translations, so text that hasn't been
+// translated is shown in English rather than as a placeholder.
+package i18n
+
+import (
+	"fmt"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/message/catalog"
+)
+
+// translations maps each locale other than English to the translations of its text, keyed by the
+// English format.
+var translations = map[string]map[string]string{
+	"de": german,
+	"es": spanish,
+}
+
+var messages = newCatalog()
+
+func newCatalog() catalog.Catalog {
+	builder := catalog.NewBuilder(catalog.Fallback(language.English))
+	for locale, formats := range translations {
+		tag := language.MustParse(locale)
+		for format, translation := range formats {
+			if err := builder.SetString(tag, format, translation); err != nil {
+				panic(fmt.Sprintf("invalid %s translation of %q: %v", locale, format, err))
+			}
+		}
+	}
+
+	return builder
+}
+
+// Sprintf formats the locale's translation of format with the arguments. Numbers are written the
+// way the locale writes them, and arguments that are translatable, such as a Message, are
+// translated too. Formats without a translation, and unknown locales, use the English format.
+func Sprintf(locale string, format string, args ...any) string {
+	translated := make([]any, len(args))
+	for i, arg := range args {
+		if arg, ok := arg.(Translatable); ok {
+			translated[i] = arg.Translate(locale)
+			continue
+		}
+
+		translated[i] = arg
+	}
+
+	printer := message.NewPrinter(language.Make(locale), message.Catalog(messages))
+
+	return printer.Sprintf(format, translated...)
+}
+
+// Translated reports whether the locale has its own translation of the format. English text is
+// always translated.
+func Translated(locale string, format string) bool {
+	formats, ok := translations[locale]
+	if !ok {
+		return locale == "en"
+	}
+
+	_, ok = formats[format]
+
+	return ok
+}
+
+// Translatable is implemented by text that can be shown in any locale.
+type Translatable interface {
+	Translate(locale string) string
+}
+
+// Message is text for a user that is only translated once it's known who is reading it, such as a
+// validation error that may be shown on a page or returned by the API.
+type Message struct {
+	// Format is the English text. It may contain fmt verbs, which are filled in from Args.
+	Format string
+	Args   []any
+}
+
+// NewMessage returns a message with the English format and its arguments.
+func NewMessage(format string, args ...any) Message {
+	return Message{Format: format, Args: args}
+}
+
+// String returns the message in English.
+func (m Message) String() string {
+	return fmt.Sprintf(m.Format, m.Args...)
+}
+
+// Translate returns the message in the locale. The zero Message translates to an empty string.
+func (m Message) Translate(locale string) string {
+	if m.Format == "" {
+		return ""
+	}
+
+	return Sprintf(locale, m.Format, m.Args...)
+}
@@ -0,0 +1,111 @@
+package i18n_test
+
+import (
+	"testing"
+
+	"github.com/cdriehuys/secret-santa/internal/i18n"
+)
+
+func TestSprintf(t *testing.T) {
+	tests := []struct {
+		name   string
+		locale string
+		format string
+		args   []any
+		want   string
+	}{
+		{
+			name:   "english",
+			locale: "en",
+			format: "Log In",
+			want:   "Log In",
+		},
+		{
+			name:   "translated",
+			locale: "de",
+			format: "Log In",
+			want:   "Anmelden",
+		},
+		{
+			name:   "regional variant",
+			locale: "es-MX",
+			format: "Log In",
+			want:   "Iniciar sesión",
+		},
+		{
+			name:   "unknown locale",
+			locale: "fr",
+			format: "Log In",
+			want:   "Log In",
+		},
+		{
+			name:   "untranslated",
+			locale: "de",
+			format: "Not translated %d",
+			args:   []any{1},
+			want:   "Not translated 1",
+		},
+		{
+			name:   "arguments",
+			locale: "es",
+			format: "%q is not a participant.",
+			args:   []any{"Ross"},
+			want:   `"Ross" no es un participante.`,
+		},
+		{
+			name:   "localized numbers",
+			locale: "de",
+			format: "Request body must not be larger than %d bytes.",
+			args:   []any{1048576},
+			want:   "Der Anfragetext darf nicht größer als 1.048.576 Bytes sein.",
+		},
+		{
+			name:   "translated arguments",
+			locale: "de",
+			format: "Row %d: %s",
+			args:   []any{2, i18n.NewMessage("Name is required.")},
+			want:   "Zeile 2: Der Name ist erforderlich.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := i18n.Sprintf(tt.locale, tt.format, tt.args...); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMessage(t *testing.T) {
+	msg := i18n.NewMessage("There can be at most %d participants.", 1000)
+
+	if got, want := msg.String(), "There can be at most 1000 participants."; got != want {
+		t.Errorf("Expected String() to return %q, got %q", want, got)
+	}
+
+	if got, want := msg.Translate("de"), "Es können höchstens 1.000 Personen teilnehmen."; got != want {
+		t.Errorf("Expected Translate() to return %q, got %q", want, got)
+	}
+
+	if got := (i18n.Message{}).Translate("de"); got != "" {
+		t.Errorf("Expected the zero message to translate to an empty string, got %q", got)
+	}
+}
+
+func TestTranslated(t *testing.T) {
+	tests := []struct {
+		locale string
+		format string
+		want   bool
+	}{
+		{locale: "en", format: "Anything", want: true},
+		{locale: "de", format: "Log In", want: true},
+		{locale: "de", format: "Not translated", want: false},
+		{locale: "fr", format: "Log In", want: false},
+	}
+	for _, tt := range tests {
+		if got := i18n.Translated(tt.locale, tt.format); got != tt.want {
+			t.Errorf("Translated(%q, %q) = %v, want %v", tt.locale, tt.format, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package i18n
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+)
+
+// Every locale must translate the same text, so text added for one locale isn't forgotten in the
+// others.
+func TestTranslationsMatch(t *testing.T) {
+	for locale, formats := range translations {
+		for other, otherFormats := range translations {
+			for format := range formats {
+				if _, ok := otherFormats[format]; !ok {
+					t.Errorf("%q is translated into %s but not %s", format, locale, other)
+				}
+			}
+		}
+	}
+}
+
+var verbPattern = regexp.MustCompile(`%[-+# 0]*[0-9]*(\.[0-9]+)?[a-zA-Z%]`)
+
+// Translations must use the same verbs in the same order as the English text, or their arguments
+// would be lost or misformatted.
+func TestTranslationsVerbs(t *testing.T) {
+	for locale, formats := range translations {
+		for format, translation := range formats {
+			want := verbPattern.FindAllString(format, -1)
+			if got := verbPattern.FindAllString(translation, -1); !slices.Equal(got, want) {
+				t.Errorf("%s translation of %q uses the verbs %q instead of %q", locale, format, got, want)
+			}
+		}
+	}
+}
@@ -2,11 +2,16 @@ package models
 
 import (
 	"context"
+	"errors"
 
 	"github.com/cdriehuys/secret-santa/internal/models/queries"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// uniqueViolation is the Postgres error code for a violated unique constraint.
+const uniqueViolation = "23505"
+
 type DB interface {
 	queries.DBTX
 
@@ -27,3 +32,9 @@ type PoolWrapper struct {
 func (w PoolWrapper) Begin(ctx context.Context) (Transaction, error) {
 	return w.Pool.Begin(ctx)
 }
+
+// isUniqueViolation reports whether err is from a statement that violated a unique constraint.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
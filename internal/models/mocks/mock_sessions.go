@@ -0,0 +1,91 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/cdriehuys/secret-santa/internal/models"
+	"github.com/google/uuid"
+)
+
+// SessionModel is an in-memory session store. Sessions created through it are immediately
+// available to look up.
+type SessionModel struct {
+	Sessions        map[string]models.User
+	PendingSessions map[string]models.User
+
+	CreateToken string
+	CreateError error
+
+	DeletedToken string
+	DeleteError  error
+
+	UserError error
+}
+
+func (m *SessionModel) Create(_ context.Context, userID uuid.UUID) (string, error) {
+	if m.CreateError != nil {
+		return "", m.CreateError
+	}
+
+	if m.Sessions == nil {
+		m.Sessions = make(map[string]models.User)
+	}
+
+	token := m.CreateToken
+	if token == "" {
+		token = "session-token"
+	}
+
+	m.Sessions[token] = models.User{ID: userID}
+
+	return token, nil
+}
+
+func (m *SessionModel) CreatePending(_ context.Context, userID uuid.UUID) (string, error) {
+	if m.CreateError != nil {
+		return "", m.CreateError
+	}
+
+	if m.PendingSessions == nil {
+		m.PendingSessions = make(map[string]models.User)
+	}
+
+	token := "pending-" + m.CreateToken
+	m.PendingSessions[token] = models.User{ID: userID}
+
+	return token, nil
+}
+
+func (m *SessionModel) Delete(_ context.Context, token string) error {
+	m.DeletedToken = token
+	delete(m.Sessions, token)
+	delete(m.PendingSessions, token)
+
+	return m.DeleteError
+}
+
+func (m *SessionModel) PendingUser(_ context.Context, token string) (models.User, error) {
+	if m.UserError != nil {
+		return models.User{}, m.UserError
+	}
+
+	user, exists := m.PendingSessions[token]
+	if !exists {
+		return models.User{}, models.ErrNoSession
+	}
+
+	return user, nil
+}
+
+func (m *SessionModel) User(_ context.Context, token string) (models.User, error) {
+	if m.UserError != nil {
+		return models.User{}, m.UserError
+	}
+
+	user, exists := m.Sessions[token]
+	if !exists {
+		return models.User{}, models.ErrNoSession
+	}
+
+	return user, nil
+}
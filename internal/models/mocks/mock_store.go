@@ -0,0 +1,384 @@
+package mocks
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cdriehuys/secret-santa/internal/models"
+	"github.com/cdriehuys/secret-santa/internal/models/queries"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var errRawSQL = errors.New("the in-memory store doesn't run SQL")
+
+// Store is an in-memory stand-in for the database that covers the queries used to register users,
+// verify their email, and deliver email from the outbox. It lets the real models be tested from end
+// to end without Postgres.
+//
+// Transactions aren't isolated: changes are visible immediately and aren't undone by a rollback.
+type Store struct {
+	mu sync.Mutex
+
+	Users  map[uuid.UUID]queries.User
+	Keys   []queries.EmailVerificationKey
+	Outbox []queries.EmailOutbox
+}
+
+var (
+	_ models.DB            = (*Store)(nil)
+	_ models.UserQueries   = (*Store)(nil)
+	_ models.OutboxQueries = (*Store)(nil)
+)
+
+func (s *Store) Begin(context.Context) (models.Transaction, error) {
+	return storeTx{s}, nil
+}
+
+func (s *Store) Exec(context.Context, string, ...any) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, errRawSQL
+}
+
+func (s *Store) Query(context.Context, string, ...any) (pgx.Rows, error) {
+	return nil, errRawSQL
+}
+
+func (s *Store) QueryRow(context.Context, string, ...any) pgx.Row {
+	return errRow{}
+}
+
+func (s *Store) WithTx(queries.DBTX) models.UserQueries {
+	return s
+}
+
+// CountUnusedRecoveryCodes always returns 0 since the store doesn't keep two-factor settings.
+func (s *Store) CountUnusedRecoveryCodes(context.Context, uuid.UUID) (int64, error) {
+	return 0, nil
+}
+
+func (s *Store) DeleteEmailVerificationKeysForUser(_ context.Context, userID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Keys = slices.DeleteFunc(s.Keys, func(key queries.EmailVerificationKey) bool {
+		return key.UserID == userID
+	})
+
+	return nil
+}
+
+func (s *Store) DeleteOtherSessionsForUser(context.Context, queries.DeleteOtherSessionsForUserParams) error {
+	return nil
+}
+
+func (s *Store) DeleteOutboxEmailsForUser(_ context.Context, userID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Outbox = slices.DeleteFunc(s.Outbox, func(outboxEmail queries.EmailOutbox) bool {
+		return outboxEmail.UserID == userID
+	})
+
+	return nil
+}
+
+func (s *Store) DeleteUser(_ context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.Users, id)
+
+	return nil
+}
+
+func (s *Store) GetEmailVerificationKey(_ context.Context, params queries.GetEmailVerificationKeyParams) (queries.EmailVerificationKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range s.Keys {
+		if key.TokenHash == params.TokenHash && key.CreatedAt.Time.After(params.CreatedAfter.Time) {
+			return key, nil
+		}
+	}
+
+	return queries.EmailVerificationKey{}, pgx.ErrNoRows
+}
+
+func (s *Store) GetUserByID(_ context.Context, id uuid.UUID) (queries.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.Users[id]
+	if !ok {
+		return queries.User{}, pgx.ErrNoRows
+	}
+
+	return user, nil
+}
+
+// GetUserTOTPStatus always returns pgx.ErrNoRows since the store doesn't keep two-factor settings.
+func (s *Store) GetUserTOTPStatus(context.Context, uuid.UUID) (queries.GetUserTOTPStatusRow, error) {
+	return queries.GetUserTOTPStatusRow{}, pgx.ErrNoRows
+}
+
+func (s *Store) GetVerifiedUserByEmail(_ context.Context, email string) (queries.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.Users {
+		if user.Email == email && user.EmailVerified {
+			return user, nil
+		}
+	}
+
+	return queries.User{}, pgx.ErrNoRows
+}
+
+func (s *Store) InsertEmailVerificationKey(_ context.Context, params queries.InsertEmailVerificationKeyParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Keys = append(s.Keys, queries.EmailVerificationKey{
+		ID:        int32(len(s.Keys) + 1),
+		UserID:    params.UserID,
+		Email:     params.Email,
+		TokenHash: params.TokenHash,
+		CreatedAt: now(),
+	})
+
+	return nil
+}
+
+func (s *Store) InsertNewUser(_ context.Context, params queries.InsertNewUserParams) (queries.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Users == nil {
+		s.Users = make(map[uuid.UUID]queries.User)
+	}
+
+	user := queries.User{
+		ID:           params.ID,
+		Email:        params.Email,
+		PasswordHash: params.PasswordHash,
+		CreatedAt:    now(),
+		UpdatedAt:    now(),
+	}
+	s.Users[user.ID] = user
+
+	return user, nil
+}
+
+func (s *Store) InsertOutboxEmail(_ context.Context, params queries.InsertOutboxEmailParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Outbox = append(s.Outbox, queries.EmailOutbox{
+		ID:            params.ID,
+		UserID:        params.UserID,
+		Recipient:     params.Recipient,
+		Sender:        params.Sender,
+		Subject:       params.Subject,
+		TextBody:      params.TextBody,
+		HtmlBody:      params.HtmlBody,
+		NextAttemptAt: now(),
+		CreatedAt:     now(),
+	})
+
+	return nil
+}
+
+func (s *Store) ListEmailVerificationKeysForUser(_ context.Context, userID uuid.UUID) ([]queries.EmailVerificationKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keys []queries.EmailVerificationKey
+	for _, key := range s.Keys {
+		if key.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, nil
+}
+
+func (s *Store) ListOutboxEmailsForUser(_ context.Context, userID uuid.UUID) ([]queries.ListOutboxEmailsForUserRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var emails []queries.ListOutboxEmailsForUserRow
+	for _, outboxEmail := range s.Outbox {
+		if outboxEmail.UserID == userID {
+			emails = append(emails, queries.ListOutboxEmailsForUserRow{
+				ID:        outboxEmail.ID,
+				Recipient: outboxEmail.Recipient,
+				Subject:   outboxEmail.Subject,
+				Attempts:  outboxEmail.Attempts,
+				SentAt:    outboxEmail.SentAt,
+				DeadAt:    outboxEmail.DeadAt,
+				CreatedAt: outboxEmail.CreatedAt,
+			})
+		}
+	}
+
+	return emails, nil
+}
+
+func (s *Store) ListSessionsForUser(context.Context, uuid.UUID) ([]queries.Session, error) {
+	return nil, nil
+}
+
+func (s *Store) SetUserEmailVerified(_ context.Context, params queries.SetUserEmailVerifiedParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.Users[params.ID]
+	if !ok {
+		return nil
+	}
+
+	user.Email = params.Email
+	user.EmailVerified = true
+	s.Users[user.ID] = user
+
+	return nil
+}
+
+func (s *Store) UpdateUserPassword(_ context.Context, params queries.UpdateUserPasswordParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.Users[params.ID]
+	if !ok {
+		return nil
+	}
+
+	user.PasswordHash = params.PasswordHash
+	s.Users[user.ID] = user
+
+	return nil
+}
+
+func (s *Store) VerifiedEmailExists(_ context.Context, email string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.Users {
+		if user.Email == email && user.EmailVerified {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// ClaimOutboxEmails claims every due email up to the batch size. Emails are claimed in the order
+// they were added rather than by their next attempt.
+func (s *Store) ClaimOutboxEmails(_ context.Context, params queries.ClaimOutboxEmailsParams) ([]queries.EmailOutbox, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var claimed []queries.EmailOutbox
+	for i, outboxEmail := range s.Outbox {
+		if len(claimed) == int(params.BatchSize) {
+			break
+		}
+
+		if outboxEmail.SentAt.Valid || outboxEmail.DeadAt.Valid || outboxEmail.NextAttemptAt.Time.After(params.Now.Time) {
+			continue
+		}
+
+		outboxEmail.Attempts++
+		outboxEmail.NextAttemptAt = params.LeaseUntil
+		s.Outbox[i] = outboxEmail
+
+		claimed = append(claimed, outboxEmail)
+	}
+
+	return claimed, nil
+}
+
+func (s *Store) DeleteFinishedOutboxEmails(_ context.Context, before pgtype.Timestamptz) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := len(s.Outbox)
+	s.Outbox = slices.DeleteFunc(s.Outbox, func(outboxEmail queries.EmailOutbox) bool {
+		return (outboxEmail.SentAt.Valid && outboxEmail.SentAt.Time.Before(before.Time)) ||
+			(outboxEmail.DeadAt.Valid && outboxEmail.DeadAt.Time.Before(before.Time))
+	})
+
+	return int64(count - len(s.Outbox)), nil
+}
+
+func (s *Store) MarkOutboxEmailDead(_ context.Context, params queries.MarkOutboxEmailDeadParams) error {
+	s.updateOutboxEmail(params.ID, func(outboxEmail *queries.EmailOutbox) {
+		outboxEmail.DeadAt = now()
+		outboxEmail.LastError = params.LastError
+		outboxEmail.TextBody = ""
+		outboxEmail.HtmlBody = ""
+	})
+
+	return nil
+}
+
+func (s *Store) MarkOutboxEmailFailed(_ context.Context, params queries.MarkOutboxEmailFailedParams) error {
+	s.updateOutboxEmail(params.ID, func(outboxEmail *queries.EmailOutbox) {
+		outboxEmail.NextAttemptAt = params.NextAttemptAt
+		outboxEmail.LastError = params.LastError
+	})
+
+	return nil
+}
+
+func (s *Store) MarkOutboxEmailSent(_ context.Context, id uuid.UUID) error {
+	s.updateOutboxEmail(id, func(outboxEmail *queries.EmailOutbox) {
+		if !outboxEmail.SentAt.Valid {
+			outboxEmail.SentAt = now()
+			outboxEmail.TextBody = ""
+			outboxEmail.HtmlBody = ""
+		}
+	})
+
+	return nil
+}
+
+func (s *Store) updateOutboxEmail(id uuid.UUID, update func(*queries.EmailOutbox)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.Outbox {
+		if s.Outbox[i].ID == id {
+			update(&s.Outbox[i])
+		}
+	}
+}
+
+// storeTx is a transaction on a Store. Since the store applies changes immediately, committing and
+// rolling back do nothing beyond closing the transaction.
+type storeTx struct {
+	*Store
+}
+
+func (storeTx) Commit(context.Context) error {
+	return nil
+}
+
+func (storeTx) Rollback(context.Context) error {
+	return pgx.ErrTxClosed
+}
+
+type errRow struct{}
+
+func (errRow) Scan(...any) error {
+	return errRawSQL
+}
+
+func now() pgtype.Timestamptz {
+	return pgtype.Timestamptz{Time: time.Now(), Valid: true}
+}
@@ -0,0 +1,54 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/cdriehuys/secret-santa/internal/models"
+	"github.com/google/uuid"
+)
+
+type TwoFactorModel struct {
+	EnabledReturn bool
+	EnabledError  error
+
+	Enrollment      models.TwoFactorEnrollment
+	EnrollmentError error
+
+	ConfirmedCode string
+	RecoveryCodes []string
+	ConfirmError  error
+
+	DisabledCode string
+	DisableError error
+
+	VerifiedUserID uuid.UUID
+	VerifiedCode   string
+	VerifyError    error
+}
+
+func (m *TwoFactorModel) BeginEnrollment(_ context.Context, _ uuid.UUID, _ string) (models.TwoFactorEnrollment, error) {
+	return m.Enrollment, m.EnrollmentError
+}
+
+func (m *TwoFactorModel) ConfirmEnrollment(_ context.Context, _ uuid.UUID, code string) ([]string, error) {
+	m.ConfirmedCode = code
+
+	return m.RecoveryCodes, m.ConfirmError
+}
+
+func (m *TwoFactorModel) Disable(_ context.Context, _ uuid.UUID, code string) error {
+	m.DisabledCode = code
+
+	return m.DisableError
+}
+
+func (m *TwoFactorModel) Enabled(_ context.Context, _ uuid.UUID) (bool, error) {
+	return m.EnabledReturn, m.EnabledError
+}
+
+func (m *TwoFactorModel) Verify(_ context.Context, userID uuid.UUID, code string) error {
+	m.VerifiedUserID = userID
+	m.VerifiedCode = code
+
+	return m.VerifyError
+}
@@ -4,11 +4,74 @@ import (
 	"context"
 
 	"github.com/cdriehuys/secret-santa/internal/models"
+	"github.com/google/uuid"
 )
 
 type UserModel struct {
+	AuthenticateEmail    string
+	AuthenticatePassword string
+	AuthenticateUserID   uuid.UUID
+	AuthenticateError    error
+
+	ChangedEmailUserID uuid.UUID
+	ChangedEmail       string
+	ChangeEmailError   error
+
+	ChangedPasswordUserID  uuid.UUID
+	ChangedPasswordSession string
+	ChangedPasswordFrom    string
+	ChangedPasswordTo      string
+	ChangePasswordError    error
+
+	DeletedUserID  uuid.UUID
+	DeletePassword string
+	DeleteError    error
+
+	ExportedUserID uuid.UUID
+	ExportedData   models.UserData
+	ExportError    error
+
 	RegisterError  error
 	RegisteredUser models.NewUser
+
+	VerifiedToken    string
+	VerifyEmailError error
+}
+
+func (m *UserModel) Authenticate(_ context.Context, email string, password string) (uuid.UUID, error) {
+	m.AuthenticateEmail = email
+	m.AuthenticatePassword = password
+
+	return m.AuthenticateUserID, m.AuthenticateError
+}
+
+func (m *UserModel) ChangeEmail(_ context.Context, userID uuid.UUID, email string) error {
+	m.ChangedEmailUserID = userID
+	m.ChangedEmail = email
+
+	return m.ChangeEmailError
+}
+
+func (m *UserModel) ChangePassword(_ context.Context, userID uuid.UUID, currentSession string, currentPassword string, newPassword string) error {
+	m.ChangedPasswordUserID = userID
+	m.ChangedPasswordSession = currentSession
+	m.ChangedPasswordFrom = currentPassword
+	m.ChangedPasswordTo = newPassword
+
+	return m.ChangePasswordError
+}
+
+func (m *UserModel) Delete(_ context.Context, userID uuid.UUID, password string) error {
+	m.DeletedUserID = userID
+	m.DeletePassword = password
+
+	return m.DeleteError
+}
+
+func (m *UserModel) ExportData(_ context.Context, userID uuid.UUID) (models.UserData, error) {
+	m.ExportedUserID = userID
+
+	return m.ExportedData, m.ExportError
 }
 
 func (m *UserModel) Register(_ context.Context, user models.NewUser) error {
@@ -16,3 +79,9 @@ func (m *UserModel) Register(_ context.Context, user models.NewUser) error {
 
 	return m.RegisterError
 }
+
+func (m *UserModel) VerifyEmail(_ context.Context, token string) error {
+	m.VerifiedToken = token
+
+	return m.VerifyEmailError
+}
@@ -0,0 +1,268 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/cdriehuys/secret-santa/internal/email"
+	"github.com/cdriehuys/secret-santa/internal/models/queries"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Defaults for OutboxWorker.
+const (
+	DefaultOutboxBatchSize    = 10
+	DefaultOutboxPollInterval = 5 * time.Second
+	DefaultOutboxLease        = 5 * time.Minute
+	DefaultOutboxMaxAttempts  = 8
+	DefaultOutboxBaseBackoff  = 30 * time.Second
+	DefaultOutboxMaxBackoff   = 6 * time.Hour
+	DefaultOutboxRetention    = 30 * 24 * time.Hour
+	DefaultOutboxPurgeEvery   = time.Hour
+)
+
+type Emailer interface {
+	Send(ctx context.Context, msg email.Message) error
+}
+
+// OutboxEnqueuer is implemented by query sets that can add emails to the outbox, allowing an email
+// to be enqueued in the same transaction as the change that triggers it.
+type OutboxEnqueuer interface {
+	InsertOutboxEmail(context.Context, queries.InsertOutboxEmailParams) error
+}
+
+type OutboxQueries interface {
+	ClaimOutboxEmails(context.Context, queries.ClaimOutboxEmailsParams) ([]queries.EmailOutbox, error)
+	DeleteFinishedOutboxEmails(context.Context, pgtype.Timestamptz) (int64, error)
+	MarkOutboxEmailDead(context.Context, queries.MarkOutboxEmailDeadParams) error
+	MarkOutboxEmailFailed(context.Context, queries.MarkOutboxEmailFailedParams) error
+	MarkOutboxEmailSent(context.Context, uuid.UUID) error
+}
+
+// enqueueEmail adds the message to the outbox to be delivered by an OutboxWorker. The email is
+// deleted along with the user it was sent for.
+func enqueueEmail(ctx context.Context, q OutboxEnqueuer, userID uuid.UUID, msg email.Message) error {
+	params := queries.InsertOutboxEmailParams{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Recipient: msg.To,
+		Sender:    msg.From,
+		Subject:   msg.Subject,
+		TextBody:  msg.Text,
+		HtmlBody:  msg.HTML,
+	}
+	if err := q.InsertOutboxEmail(ctx, params); err != nil {
+		return fmt.Errorf("failed to enqueue email: %v", err)
+	}
+
+	return nil
+}
+
+// OutboxWorker delivers emails from the outbox. Failed deliveries are retried with exponential
+// backoff until MaxAttempts is reached, at which point the email is marked dead and left in the
+// table for inspection.
+//
+// The body of an email is cleared once it is sent or marked dead since it can contain a link with a
+// token. The rest of the row is deleted after Retention.
+//
+// Delivery is at least once: if the worker stops after sending an email but before recording it as
+// sent, the email is sent again with the same Message-ID once its lease expires.
+type OutboxWorker struct {
+	logger  *slog.Logger
+	emailer Emailer
+
+	q OutboxQueries
+
+	BatchSize    int
+	PollInterval time.Duration
+
+	// Lease is how long a claimed email is reserved for this worker before another worker may
+	// retry it.
+	Lease time.Duration
+
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+
+	// Retention is how long sent and dead emails are kept. They are deleted every PurgeEvery.
+	Retention  time.Duration
+	PurgeEvery time.Duration
+
+	// Now returns the current time. If nil, time.Now is used.
+	Now func() time.Time
+}
+
+func NewOutboxWorker(logger *slog.Logger, emailer Emailer, queries OutboxQueries) *OutboxWorker {
+	return &OutboxWorker{
+		logger:       logger,
+		emailer:      emailer,
+		q:            queries,
+		BatchSize:    DefaultOutboxBatchSize,
+		PollInterval: DefaultOutboxPollInterval,
+		Lease:        DefaultOutboxLease,
+		MaxAttempts:  DefaultOutboxMaxAttempts,
+		BaseBackoff:  DefaultOutboxBaseBackoff,
+		MaxBackoff:   DefaultOutboxMaxBackoff,
+		Retention:    DefaultOutboxRetention,
+		PurgeEvery:   DefaultOutboxPurgeEvery,
+	}
+}
+
+// Run delivers emails until the context is cancelled.
+func (w *OutboxWorker) Run(ctx context.Context) error {
+	w.logger.InfoContext(ctx, "Starting email outbox worker.", "pollInterval", w.PollInterval)
+
+	ticker := time.NewTicker(w.PollInterval)
+	defer ticker.Stop()
+
+	var lastPurge time.Time
+
+	for {
+		if now := w.now(); now.Sub(lastPurge) >= w.PurgeEvery {
+			if _, err := w.Purge(ctx); err != nil {
+				w.logger.ErrorContext(ctx, "Failed to purge finished emails.", "error", err)
+			}
+
+			lastPurge = now
+		}
+
+		// Keep going without waiting as long as there are full batches to work through.
+		for {
+			delivered, err := w.DeliverPending(ctx)
+			if err != nil {
+				w.logger.ErrorContext(ctx, "Failed to deliver pending emails.", "error", err)
+				break
+			}
+
+			if delivered < w.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			w.logger.InfoContext(ctx, "Stopped email outbox worker.")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverPending attempts to deliver a single batch of emails that are due and returns how many
+// emails were claimed.
+func (w *OutboxWorker) DeliverPending(ctx context.Context) (int, error) {
+	now := w.now()
+
+	params := queries.ClaimOutboxEmailsParams{
+		LeaseUntil: pgtype.Timestamptz{Time: now.Add(w.Lease), Valid: true},
+		Now:        pgtype.Timestamptz{Time: now, Valid: true},
+		BatchSize:  int32(w.BatchSize),
+	}
+	emails, err := w.q.ClaimOutboxEmails(ctx, params)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim outbox emails: %v", err)
+	}
+
+	for _, outboxEmail := range emails {
+		if err := w.deliver(ctx, outboxEmail); err != nil {
+			return len(emails), err
+		}
+	}
+
+	return len(emails), nil
+}
+
+// Purge deletes emails that were sent or marked dead longer than Retention ago and returns how many
+// were deleted.
+func (w *OutboxWorker) Purge(ctx context.Context) (int64, error) {
+	before := pgtype.Timestamptz{Time: w.now().Add(-w.Retention), Valid: true}
+
+	deleted, err := w.q.DeleteFinishedOutboxEmails(ctx, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete finished outbox emails: %v", err)
+	}
+
+	if deleted > 0 {
+		w.logger.InfoContext(ctx, "Purged finished emails.", "count", deleted)
+	}
+
+	return deleted, nil
+}
+
+// deliver sends a claimed email and records the outcome. Only failures to record the outcome are
+// returned since a failed send is handled by scheduling a retry.
+func (w *OutboxWorker) deliver(ctx context.Context, outboxEmail queries.EmailOutbox) error {
+	msg := email.Message{
+		ID:      outboxEmail.ID.String(),
+		To:      outboxEmail.Recipient,
+		From:    outboxEmail.Sender,
+		Subject: outboxEmail.Subject,
+		Text:    outboxEmail.TextBody,
+		HTML:    outboxEmail.HtmlBody,
+	}
+
+	sendErr := w.emailer.Send(ctx, msg)
+	if sendErr == nil {
+		if err := w.q.MarkOutboxEmailSent(ctx, outboxEmail.ID); err != nil {
+			return fmt.Errorf("failed to mark email as sent: %v", err)
+		}
+
+		w.logger.InfoContext(ctx, "Delivered email.", "emailID", outboxEmail.ID, "attempts", outboxEmail.Attempts)
+
+		return nil
+	}
+
+	attempts := int(outboxEmail.Attempts)
+	if attempts >= w.MaxAttempts {
+		params := queries.MarkOutboxEmailDeadParams{
+			ID:        outboxEmail.ID,
+			LastError: sendErr.Error(),
+		}
+		if err := w.q.MarkOutboxEmailDead(ctx, params); err != nil {
+			return fmt.Errorf("failed to mark email as dead: %v", err)
+		}
+
+		w.logger.ErrorContext(ctx, "Giving up on delivering email.", "emailID", outboxEmail.ID, "attempts", attempts, "error", sendErr)
+
+		return nil
+	}
+
+	nextAttempt := w.now().Add(w.backoff(attempts))
+
+	params := queries.MarkOutboxEmailFailedParams{
+		ID:            outboxEmail.ID,
+		NextAttemptAt: pgtype.Timestamptz{Time: nextAttempt, Valid: true},
+		LastError:     sendErr.Error(),
+	}
+	if err := w.q.MarkOutboxEmailFailed(ctx, params); err != nil {
+		return fmt.Errorf("failed to schedule email retry: %v", err)
+	}
+
+	w.logger.WarnContext(ctx, "Failed to deliver email, will retry.", "emailID", outboxEmail.ID, "attempts", attempts, "nextAttempt", nextAttempt, "error", sendErr)
+
+	return nil
+}
+
+// backoff returns how long to wait before retrying after the given number of attempts.
+func (w *OutboxWorker) backoff(attempts int) time.Duration {
+	delay := w.BaseBackoff
+	for range attempts - 1 {
+		delay *= 2
+		if delay >= w.MaxBackoff {
+			return w.MaxBackoff
+		}
+	}
+
+	return min(delay, w.MaxBackoff)
+}
+
+func (w *OutboxWorker) now() time.Time {
+	if w.Now == nil {
+		return time.Now()
+	}
+
+	return w.Now()
+}
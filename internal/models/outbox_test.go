@@ -0,0 +1,286 @@
+package models_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/cdriehuys/secret-santa/internal/email"
+	"github.com/cdriehuys/secret-santa/internal/models"
+	"github.com/cdriehuys/secret-santa/internal/models/queries"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type MockOutboxQueries struct {
+	claimParams queries.ClaimOutboxEmailsParams
+	claimReturn []queries.EmailOutbox
+	claimError  error
+
+	purgeBefore []pgtype.Timestamptz
+	purgeReturn int64
+	purgeError  error
+
+	deadParams   []queries.MarkOutboxEmailDeadParams
+	deadError    error
+	failedParams []queries.MarkOutboxEmailFailedParams
+	failedError  error
+	sentIDs      []uuid.UUID
+	sentError    error
+}
+
+func (q *MockOutboxQueries) ClaimOutboxEmails(ctx context.Context, params queries.ClaimOutboxEmailsParams) ([]queries.EmailOutbox, error) {
+	q.claimParams = params
+
+	return q.claimReturn, q.claimError
+}
+
+func (q *MockOutboxQueries) DeleteFinishedOutboxEmails(ctx context.Context, before pgtype.Timestamptz) (int64, error) {
+	q.purgeBefore = append(q.purgeBefore, before)
+
+	return q.purgeReturn, q.purgeError
+}
+
+func (q *MockOutboxQueries) MarkOutboxEmailDead(ctx context.Context, params queries.MarkOutboxEmailDeadParams) error {
+	q.deadParams = append(q.deadParams, params)
+
+	return q.deadError
+}
+
+func (q *MockOutboxQueries) MarkOutboxEmailFailed(ctx context.Context, params queries.MarkOutboxEmailFailedParams) error {
+	q.failedParams = append(q.failedParams, params)
+
+	return q.failedError
+}
+
+func (q *MockOutboxQueries) MarkOutboxEmailSent(ctx context.Context, id uuid.UUID) error {
+	q.sentIDs = append(q.sentIDs, id)
+
+	return q.sentError
+}
+
+func TestOutboxWorker_DeliverPending(t *testing.T) {
+	now := time.Date(2025, time.December, 1, 12, 0, 0, 0, time.UTC)
+	outboxEmail := queries.EmailOutbox{
+		ID:        uuid.New(),
+		Recipient: "test@example.com",
+		Sender:    "no-reply@example.com",
+		Subject:   "Hello",
+		TextBody:  "Hello, World!",
+		HtmlBody:  "<p>Hello, World!</p>",
+		Attempts:  1,
+	}
+
+	withAttempts := func(attempts int32) queries.EmailOutbox {
+		e := outboxEmail
+		e.Attempts = attempts
+		return e
+	}
+
+	testCases := []struct {
+		name          string
+		maxBackoff    time.Duration
+		queries       MockOutboxQueries
+		sendError     error
+		wantDelivered int
+		wantSent      bool
+		wantRetryAt   time.Time
+		wantDead      bool
+		wantErr       error
+	}{
+		{
+			name:          "nothing pending",
+			wantDelivered: 0,
+		},
+		{
+			name:          "successful delivery",
+			queries:       MockOutboxQueries{claimReturn: []queries.EmailOutbox{outboxEmail}},
+			wantDelivered: 1,
+			wantSent:      true,
+		},
+		{
+			name:          "first failure",
+			queries:       MockOutboxQueries{claimReturn: []queries.EmailOutbox{outboxEmail}},
+			sendError:     errors.New("connection refused"),
+			wantDelivered: 1,
+			wantRetryAt:   now.Add(models.DefaultOutboxBaseBackoff),
+		},
+		{
+			name:          "later failure backs off",
+			queries:       MockOutboxQueries{claimReturn: []queries.EmailOutbox{withAttempts(3)}},
+			sendError:     errors.New("connection refused"),
+			wantDelivered: 1,
+			wantRetryAt:   now.Add(4 * models.DefaultOutboxBaseBackoff),
+		},
+		{
+			name:          "backoff is capped",
+			maxBackoff:    10 * time.Minute,
+			queries:       MockOutboxQueries{claimReturn: []queries.EmailOutbox{withAttempts(models.DefaultOutboxMaxAttempts - 1)}},
+			sendError:     errors.New("connection refused"),
+			wantDelivered: 1,
+			wantRetryAt:   now.Add(10 * time.Minute),
+		},
+		{
+			name:          "final failure",
+			queries:       MockOutboxQueries{claimReturn: []queries.EmailOutbox{withAttempts(models.DefaultOutboxMaxAttempts)}},
+			sendError:     errors.New("connection refused"),
+			wantDelivered: 1,
+			wantDead:      true,
+		},
+		{
+			name:    "claim error",
+			queries: MockOutboxQueries{claimError: errors.New("query failed")},
+			wantErr: errAny,
+		},
+		{
+			name: "mark sent error",
+			queries: MockOutboxQueries{
+				claimReturn: []queries.EmailOutbox{outboxEmail},
+				sentError:   errors.New("update failed"),
+			},
+			wantDelivered: 1,
+			wantSent:      true,
+			wantErr:       errAny,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			emailer := email.RecordingMailer{Err: tt.sendError}
+			worker := models.NewOutboxWorker(slog.New(slog.DiscardHandler), &emailer, &tt.queries)
+			worker.Now = func() time.Time { return now }
+
+			if tt.maxBackoff != 0 {
+				worker.MaxBackoff = tt.maxBackoff
+			}
+
+			delivered, err := worker.DeliverPending(t.Context())
+
+			assertError(t, tt.wantErr, err)
+
+			if delivered != tt.wantDelivered {
+				t.Errorf("Expected %d emails claimed, got %d", tt.wantDelivered, delivered)
+			}
+
+			if got := tt.queries.claimParams.LeaseUntil.Time; tt.wantErr == nil && !got.Equal(now.Add(models.DefaultOutboxLease)) {
+				t.Errorf("Expected lease until %v, got %v", now.Add(models.DefaultOutboxLease), got)
+			}
+
+			if tt.wantDelivered > 0 {
+				sent := emailer.Messages()
+				if len(sent) != 1 {
+					t.Fatalf("Expected one send attempt, got %d", len(sent))
+				}
+
+				msg := sent[0]
+				want := email.Message{
+					ID:      outboxEmail.ID.String(),
+					To:      outboxEmail.Recipient,
+					From:    outboxEmail.Sender,
+					Subject: outboxEmail.Subject,
+					Text:    outboxEmail.TextBody,
+					HTML:    outboxEmail.HtmlBody,
+				}
+				if msg != want {
+					t.Errorf("Expected message %#v, got %#v", want, msg)
+				}
+			}
+
+			if gotSent := len(tt.queries.sentIDs) == 1; gotSent != tt.wantSent {
+				t.Errorf("Expected sent=%v, got marked sent %v", tt.wantSent, tt.queries.sentIDs)
+			}
+
+			if gotDead := len(tt.queries.deadParams) == 1; gotDead != tt.wantDead {
+				t.Errorf("Expected dead=%v, got marked dead %v", tt.wantDead, tt.queries.deadParams)
+			}
+
+			if tt.wantRetryAt.IsZero() {
+				if len(tt.queries.failedParams) != 0 {
+					t.Errorf("Expected no retry, got %v", tt.queries.failedParams)
+				}
+			} else {
+				if len(tt.queries.failedParams) != 1 {
+					t.Fatalf("Expected a retry to be scheduled, got %v", tt.queries.failedParams)
+				}
+
+				failed := tt.queries.failedParams[0]
+				if !failed.NextAttemptAt.Time.Equal(tt.wantRetryAt) {
+					t.Errorf("Expected retry at %v, got %v", tt.wantRetryAt, failed.NextAttemptAt.Time)
+				}
+
+				if failed.LastError == "" {
+					t.Error("Expected the failure to be recorded")
+				}
+			}
+		})
+	}
+}
+
+func TestOutboxWorker_Run(t *testing.T) {
+	outboxQueries := MockOutboxQueries{claimReturn: []queries.EmailOutbox{{ID: uuid.New()}}}
+	var emailer email.RecordingMailer
+
+	worker := models.NewOutboxWorker(slog.New(slog.DiscardHandler), &emailer, &outboxQueries)
+	worker.BatchSize = 2
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	if err := worker.Run(ctx); err != nil {
+		t.Fatalf("Run returned an error: %v", err)
+	}
+
+	if got := len(emailer.Messages()); got != 1 {
+		t.Errorf("Expected pending email to be delivered before stopping, got %d sends", got)
+	}
+
+	if got := len(outboxQueries.purgeBefore); got != 1 {
+		t.Errorf("Expected finished emails to be purged when starting, got %d purges", got)
+	}
+}
+
+func TestOutboxWorker_Purge(t *testing.T) {
+	now := time.Date(2025, time.December, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name        string
+		queries     MockOutboxQueries
+		wantDeleted int64
+		wantErr     error
+	}{
+		{
+			name:        "deletes finished emails",
+			queries:     MockOutboxQueries{purgeReturn: 3},
+			wantDeleted: 3,
+		},
+		{
+			name:    "delete error",
+			queries: MockOutboxQueries{purgeError: errors.New("delete failed")},
+			wantErr: errAny,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			worker := models.NewOutboxWorker(slog.New(slog.DiscardHandler), &email.RecordingMailer{}, &tt.queries)
+			worker.Now = func() time.Time { return now }
+			worker.Retention = 24 * time.Hour
+
+			deleted, err := worker.Purge(t.Context())
+
+			assertError(t, tt.wantErr, err)
+
+			if deleted != tt.wantDeleted {
+				t.Errorf("Expected %d deleted emails, got %d", tt.wantDeleted, deleted)
+			}
+
+			want := []pgtype.Timestamptz{{Time: now.Add(-24 * time.Hour), Valid: true}}
+			if !slices.Equal(tt.queries.purgeBefore, want) {
+				t.Errorf("Expected emails finished before %v to be deleted, got %v", want, tt.queries.purgeBefore)
+			}
+		})
+	}
+}
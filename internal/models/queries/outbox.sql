@@ -0,0 +1,59 @@
+-- name: ClaimOutboxEmails :many
+-- Claims emails that are due for delivery by pushing their next attempt back by the lease. If the
+-- worker dies before recording the result, the emails become due again once the lease expires.
+UPDATE email_outbox
+SET attempts = attempts + 1,
+    next_attempt_at = @lease_until
+WHERE id IN (
+    SELECT pending.id FROM email_outbox pending
+    WHERE pending.sent_at IS NULL
+        AND pending.dead_at IS NULL
+        AND pending.next_attempt_at <= sqlc.arg(now)::timestamptz
+    ORDER BY pending.next_attempt_at
+    LIMIT sqlc.arg(batch_size)::int
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: DeleteFinishedOutboxEmails :execrows
+-- Deletes emails that were sent or given up on before the cutoff.
+DELETE FROM email_outbox
+WHERE sent_at < sqlc.arg(before)::timestamptz
+    OR dead_at < sqlc.arg(before)::timestamptz;
+
+-- name: DeleteOutboxEmailsForUser :exec
+-- Deletes every email sent for a user, including ones still waiting to be delivered.
+DELETE FROM email_outbox
+WHERE user_id = @user_id;
+
+-- name: InsertOutboxEmail :exec
+INSERT INTO email_outbox (id, user_id, recipient, sender, subject, text_body, html_body)
+VALUES ($1, $2, $3, $4, $5, $6, $7);
+
+-- name: ListOutboxEmailsForUser :many
+-- Lists the emails sent for a user without their bodies, which can contain tokens.
+SELECT id, recipient, subject, attempts, sent_at, dead_at, created_at FROM email_outbox
+WHERE user_id = @user_id
+ORDER BY created_at;
+
+-- name: MarkOutboxEmailDead :exec
+-- The bodies are cleared since they can contain links with tokens that shouldn't be kept at rest.
+UPDATE email_outbox
+SET dead_at = now(),
+    text_body = '',
+    html_body = '',
+    last_error = @last_error
+WHERE id = @id;
+
+-- name: MarkOutboxEmailFailed :exec
+UPDATE email_outbox
+SET next_attempt_at = @next_attempt_at,
+    last_error = @last_error
+WHERE id = @id;
+
+-- name: MarkOutboxEmailSent :exec
+UPDATE email_outbox
+SET sent_at = now(),
+    text_body = '',
+    html_body = ''
+WHERE id = @id AND sent_at IS NULL;
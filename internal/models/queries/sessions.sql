@@ -0,0 +1,24 @@
+-- name: DeleteSession :exec
+DELETE FROM sessions
+WHERE token_hash = @token_hash;
+
+-- name: GetSessionUser :one
+SELECT sqlc.embed(users) FROM sessions
+JOIN users ON users.id = sessions.user_id
+WHERE sessions.token_hash = @token_hash
+    AND sessions.two_factor_pending = @two_factor_pending
+    AND sessions.expires_at > now();
+
+-- name: InsertSession :exec
+INSERT INTO sessions(token_hash, user_id, expires_at, two_factor_pending)
+VALUES (@token_hash, @user_id, @expires_at, @two_factor_pending);
+
+-- name: ListSessionsForUser :many
+SELECT * FROM sessions
+WHERE user_id = @user_id
+ORDER BY created_at;
+
+-- name: DeleteOtherSessionsForUser :exec
+DELETE FROM sessions
+WHERE user_id = @user_id
+    AND token_hash <> @keep_token_hash;
@@ -0,0 +1,43 @@
+-- name: ConfirmUserTOTP :exec
+UPDATE user_totp
+SET confirmed_at = now(), last_used_step = @last_used_step
+WHERE user_id = @user_id;
+
+-- name: CountUnusedRecoveryCodes :one
+SELECT count(*) FROM totp_recovery_codes
+WHERE user_id = @user_id AND used_at IS NULL;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM totp_recovery_codes
+WHERE user_id = @user_id;
+
+-- name: DeleteUserTOTP :exec
+DELETE FROM user_totp
+WHERE user_id = @user_id;
+
+-- name: GetUserTOTP :one
+SELECT * FROM user_totp
+WHERE user_id = @user_id;
+
+-- name: GetUserTOTPStatus :one
+-- Returns the state of a user's enrollment without the secret.
+SELECT confirmed_at, created_at FROM user_totp
+WHERE user_id = @user_id;
+
+-- name: InsertRecoveryCode :exec
+INSERT INTO totp_recovery_codes(user_id, code_hash)
+VALUES (@user_id, @code_hash);
+
+-- name: InsertUserTOTP :exec
+INSERT INTO user_totp(user_id, secret)
+VALUES (@user_id, @secret);
+
+-- name: UpdateTOTPLastUsedStep :execrows
+UPDATE user_totp
+SET last_used_step = @last_used_step
+WHERE user_id = @user_id AND last_used_step < @last_used_step;
+
+-- name: UseRecoveryCode :execrows
+UPDATE totp_recovery_codes
+SET used_at = now()
+WHERE user_id = @user_id AND code_hash = @code_hash AND used_at IS NULL;
@@ -1,12 +1,47 @@
+-- name: DeleteEmailVerificationKeysForUser :exec
+DELETE FROM email_verification_keys
+WHERE user_id = @user_id;
+
+-- name: DeleteUser :exec
+DELETE FROM users
+WHERE id = @id;
+
+-- name: GetEmailVerificationKey :one
+SELECT * FROM email_verification_keys
+WHERE token_hash = @token_hash AND created_at > @created_after;
+
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = @id;
+
+-- name: GetVerifiedUserByEmail :one
+SELECT * FROM users
+WHERE email = @email AND email_verified;
+
 -- name: InsertEmailVerificationKey :exec
-INSERT INTO email_verification_keys(user_id, email, token)
-VALUES (@user_id, @email, @token);
+INSERT INTO email_verification_keys(user_id, email, token_hash)
+VALUES (@user_id, @email, @token_hash);
 
 -- name: InsertNewUser :one
 INSERT INTO users (id, email, password_hash)
 VALUES (@id, @email, @password_hash)
 RETURNING *;
 
+-- name: ListEmailVerificationKeysForUser :many
+SELECT * FROM email_verification_keys
+WHERE user_id = @user_id
+ORDER BY created_at;
+
+-- name: SetUserEmailVerified :exec
+UPDATE users
+SET email = @email, email_verified = TRUE
+WHERE id = @id;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET password_hash = @password_hash
+WHERE id = @id;
+
 -- name: VerifiedEmailExists :one
 SELECT EXISTS(
     SELECT 1 FROM users
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/cdriehuys/secret-santa/internal/models/queries"
+	"github.com/cdriehuys/secret-santa/internal/security"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// SessionLifetime is how long a user stays logged in after authenticating.
+const SessionLifetime = 7 * 24 * time.Hour
+
+// PendingSessionLifetime is how long a user has to provide their second factor after entering their
+// password.
+const PendingSessionLifetime = 10 * time.Minute
+
+var ErrNoSession = errors.New("no active session")
+
+type SessionQueries interface {
+	DeleteSession(context.Context, string) error
+	GetSessionUser(context.Context, queries.GetSessionUserParams) (queries.GetSessionUserRow, error)
+	InsertSession(context.Context, queries.InsertSessionParams) error
+}
+
+type SessionModel struct {
+	logger         *slog.Logger
+	tokenGenerator TokenGenerator
+
+	q SessionQueries
+}
+
+func NewSessionModel(logger *slog.Logger, tokenGenerator TokenGenerator, queries SessionQueries) *SessionModel {
+	return &SessionModel{
+		logger:         logger,
+		tokenGenerator: tokenGenerator,
+		q:              queries,
+	}
+}
+
+// Create starts a new session for the given user and returns the token identifying it.
+func (m *SessionModel) Create(ctx context.Context, userID uuid.UUID) (string, error) {
+	return m.create(ctx, userID, false, SessionLifetime)
+}
+
+// CreatePending starts a short-lived session for a user who has provided their password but still
+// needs to provide a second factor. Pending sessions are not returned by User.
+func (m *SessionModel) CreatePending(ctx context.Context, userID uuid.UUID) (string, error) {
+	return m.create(ctx, userID, true, PendingSessionLifetime)
+}
+
+func (m *SessionModel) create(ctx context.Context, userID uuid.UUID, twoFactorPending bool, lifetime time.Duration) (string, error) {
+	token := m.tokenGenerator.Generate()
+
+	params := queries.InsertSessionParams{
+		TokenHash:        security.HashToken(token),
+		UserID:           userID,
+		ExpiresAt:        pgtype.Timestamptz{Time: time.Now().Add(lifetime), Valid: true},
+		TwoFactorPending: twoFactorPending,
+	}
+	if err := m.q.InsertSession(ctx, params); err != nil {
+		return "", fmt.Errorf("failed to persist session: %v", err)
+	}
+
+	m.logger.DebugContext(ctx, "Created session.", "userID", userID, "twoFactorPending", twoFactorPending)
+
+	return token, nil
+}
+
+// User returns the user who owns the session identified by the token. If the session does not
+// exist or has expired, ErrNoSession is returned.
+func (m *SessionModel) User(ctx context.Context, token string) (User, error) {
+	return m.user(ctx, token, false)
+}
+
+// PendingUser returns the user who owns the pending session identified by the token. If the
+// session does not exist, has expired, or is not pending, ErrNoSession is returned.
+func (m *SessionModel) PendingUser(ctx context.Context, token string) (User, error) {
+	return m.user(ctx, token, true)
+}
+
+func (m *SessionModel) user(ctx context.Context, token string, twoFactorPending bool) (User, error) {
+	params := queries.GetSessionUserParams{
+		TokenHash:        security.HashToken(token),
+		TwoFactorPending: twoFactorPending,
+	}
+	row, err := m.q.GetSessionUser(ctx, params)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return User{}, ErrNoSession
+		}
+
+		return User{}, fmt.Errorf("failed to retrieve session: %v", err)
+	}
+
+	return userFromRow(row.User), nil
+}
+
+// Delete ends the session identified by the token.
+func (m *SessionModel) Delete(ctx context.Context, token string) error {
+	if err := m.q.DeleteSession(ctx, security.HashToken(token)); err != nil {
+		return fmt.Errorf("failed to delete session: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,200 @@
+package models_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/cdriehuys/secret-santa/internal/models"
+	"github.com/cdriehuys/secret-santa/internal/models/queries"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type MockSessionQueries struct {
+	deletedToken string
+	deleteError  error
+
+	getSessionUserParams queries.GetSessionUserParams
+	getSessionUserReturn queries.GetSessionUserRow
+	getSessionUserError  error
+
+	insertSessionParams queries.InsertSessionParams
+	insertSessionError  error
+}
+
+func (q *MockSessionQueries) DeleteSession(ctx context.Context, token string) error {
+	q.deletedToken = token
+
+	return q.deleteError
+}
+
+func (q *MockSessionQueries) GetSessionUser(ctx context.Context, params queries.GetSessionUserParams) (queries.GetSessionUserRow, error) {
+	q.getSessionUserParams = params
+
+	return q.getSessionUserReturn, q.getSessionUserError
+}
+
+func (q *MockSessionQueries) InsertSession(ctx context.Context, params queries.InsertSessionParams) error {
+	q.insertSessionParams = params
+
+	return q.insertSessionError
+}
+
+func TestSessionModel_Create(t *testing.T) {
+	userID := uuid.New()
+
+	testCases := []struct {
+		name      string
+		queries   MockSessionQueries
+		wantToken string
+		wantErr   error
+	}{
+		{
+			name:      "success",
+			wantToken: mockToken,
+		},
+		{
+			name: "insert error",
+			queries: MockSessionQueries{
+				insertSessionError: errInsert,
+			},
+			wantErr: errAny,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions := models.NewSessionModel(slog.New(slog.DiscardHandler), &ConstantTokenGenerator{token: mockToken}, &tt.queries)
+
+			before := time.Now()
+			token, err := sessions.Create(t.Context(), userID)
+
+			assertError(t, tt.wantErr, err)
+
+			if token != tt.wantToken {
+				t.Errorf("Expected token %q, got %q", tt.wantToken, token)
+			}
+
+			params := tt.queries.insertSessionParams
+			if params.TokenHash != mockTokenHash || params.UserID != userID || params.TwoFactorPending {
+				t.Errorf("Expected session for user %v with token hash %q, got %#v", userID, mockTokenHash, params)
+			}
+
+			if expires := params.ExpiresAt.Time; expires.Before(before.Add(models.SessionLifetime)) {
+				t.Errorf("Expected session to expire after %v, got %v", before.Add(models.SessionLifetime), expires)
+			}
+		})
+	}
+}
+
+func TestSessionModel_CreatePending(t *testing.T) {
+	userID := uuid.New()
+	sessionQueries := MockSessionQueries{}
+	sessions := models.NewSessionModel(slog.New(slog.DiscardHandler), &ConstantTokenGenerator{token: mockToken}, &sessionQueries)
+
+	before := time.Now()
+	token, err := sessions.CreatePending(t.Context(), userID)
+	if err != nil {
+		t.Fatalf("CreatePending returned an error: %v", err)
+	}
+
+	if token != mockToken {
+		t.Errorf("Expected token %q, got %q", mockToken, token)
+	}
+
+	params := sessionQueries.insertSessionParams
+	if !params.TwoFactorPending || params.UserID != userID {
+		t.Errorf("Expected pending session for user %v, got %#v", userID, params)
+	}
+
+	if expires := params.ExpiresAt.Time; expires.After(before.Add(models.SessionLifetime)) || expires.Before(before.Add(models.PendingSessionLifetime)) {
+		t.Errorf("Expected pending session to expire after %v, got %v", models.PendingSessionLifetime, expires)
+	}
+}
+
+func TestSessionModel_PendingUser(t *testing.T) {
+	user := queries.User{ID: uuid.New(), Email: "test@example.com"}
+	sessionQueries := MockSessionQueries{getSessionUserReturn: queries.GetSessionUserRow{User: user}}
+	sessions := models.NewSessionModel(slog.New(slog.DiscardHandler), &ConstantTokenGenerator{}, &sessionQueries)
+
+	got, err := sessions.PendingUser(t.Context(), mockToken)
+	if err != nil {
+		t.Fatalf("PendingUser returned an error: %v", err)
+	}
+
+	if got.ID != user.ID {
+		t.Errorf("Expected user %v, got %v", user.ID, got.ID)
+	}
+
+	if params := sessionQueries.getSessionUserParams; !params.TwoFactorPending || params.TokenHash != mockTokenHash {
+		t.Errorf("Expected lookup of pending session %q, got %#v", mockTokenHash, params)
+	}
+}
+
+func TestSessionModel_User(t *testing.T) {
+	user := queries.User{ID: uuid.New(), Email: "test@example.com"}
+
+	testCases := []struct {
+		name     string
+		queries  MockSessionQueries
+		wantUser models.User
+		wantErr  error
+	}{
+		{
+			name: "active session",
+			queries: MockSessionQueries{
+				getSessionUserReturn: queries.GetSessionUserRow{User: user},
+			},
+			wantUser: models.User{ID: user.ID, Email: user.Email},
+		},
+		{
+			name: "missing session",
+			queries: MockSessionQueries{
+				getSessionUserError: pgx.ErrNoRows,
+			},
+			wantErr: models.ErrNoSession,
+		},
+		{
+			name: "query error",
+			queries: MockSessionQueries{
+				getSessionUserError: errors.New("query failed"),
+			},
+			wantErr: errAny,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions := models.NewSessionModel(slog.New(slog.DiscardHandler), &ConstantTokenGenerator{}, &tt.queries)
+
+			user, err := sessions.User(t.Context(), mockToken)
+
+			assertError(t, tt.wantErr, err)
+
+			if user != tt.wantUser {
+				t.Errorf("Expected user %#v, got %#v", tt.wantUser, user)
+			}
+
+			want := queries.GetSessionUserParams{TokenHash: mockTokenHash}
+			if got := tt.queries.getSessionUserParams; got != want {
+				t.Errorf("Expected session lookup %#v, got %#v", want, got)
+			}
+		})
+	}
+}
+
+func TestSessionModel_Delete(t *testing.T) {
+	sessionQueries := MockSessionQueries{}
+	sessions := models.NewSessionModel(slog.New(slog.DiscardHandler), &ConstantTokenGenerator{}, &sessionQueries)
+
+	if err := sessions.Delete(t.Context(), mockToken); err != nil {
+		t.Fatalf("Delete returned an error: %v", err)
+	}
+
+	if sessionQueries.deletedToken != mockTokenHash {
+		t.Errorf("Expected token hash %q to be deleted, got %q", mockTokenHash, sessionQueries.deletedToken)
+	}
+}
@@ -0,0 +1,309 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/cdriehuys/secret-santa/internal/models/queries"
+	"github.com/cdriehuys/secret-santa/internal/security"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// RecoveryCodeCount is the number of recovery codes issued when two-factor authentication is
+// enabled.
+const RecoveryCodeCount = 10
+
+var (
+	ErrInvalidCode      = errors.New("invalid two-factor code")
+	ErrTwoFactorEnabled = errors.New("two-factor authentication is already enabled")
+)
+
+type OneTimePasswords interface {
+	GenerateRecoveryCodes(n int) []string
+	GenerateSecret() string
+	ProvisioningURI(secret string, account string) string
+	Validate(secret string, code string) (int64, bool)
+}
+
+// SecretSealer encrypts the TOTP secrets stored in the database, binding each one to the user it
+// belongs to.
+type SecretSealer interface {
+	Seal(plaintext string, associated []byte) string
+	Open(sealed string, associated []byte) (string, error)
+}
+
+type TwoFactorQueries interface {
+	WithTx(tx queries.DBTX) TwoFactorQueries
+
+	ConfirmUserTOTP(context.Context, queries.ConfirmUserTOTPParams) error
+	DeleteRecoveryCodes(context.Context, uuid.UUID) error
+	DeleteUserTOTP(context.Context, uuid.UUID) error
+	GetUserTOTP(context.Context, uuid.UUID) (queries.UserTOTP, error)
+	InsertRecoveryCode(context.Context, queries.InsertRecoveryCodeParams) error
+	InsertUserTOTP(context.Context, queries.InsertUserTOTPParams) error
+	UpdateTOTPLastUsedStep(context.Context, queries.UpdateTOTPLastUsedStepParams) (int64, error)
+	UseRecoveryCode(context.Context, queries.UseRecoveryCodeParams) (int64, error)
+}
+
+type TwoFactorQueriesWrapper struct {
+	*queries.Queries
+}
+
+func (w TwoFactorQueriesWrapper) WithTx(tx queries.DBTX) TwoFactorQueries {
+	return TwoFactorQueriesWrapper{w.Queries.WithTx(tx.(pgx.Tx))}
+}
+
+// TwoFactorEnrollment holds what a user needs to add their account to an authenticator app.
+type TwoFactorEnrollment struct {
+	Secret          string
+	ProvisioningURI string
+}
+
+type TwoFactorModel struct {
+	logger  *slog.Logger
+	otp     OneTimePasswords
+	secrets SecretSealer
+
+	db DB
+	q  TwoFactorQueries
+}
+
+func NewTwoFactorModel(logger *slog.Logger, otp OneTimePasswords, secrets SecretSealer, db DB, queries TwoFactorQueries) *TwoFactorModel {
+	return &TwoFactorModel{
+		logger:  logger,
+		otp:     otp,
+		secrets: secrets,
+		db:      db,
+		q:       queries,
+	}
+}
+
+// Enabled reports whether the user has finished enrolling in two-factor authentication.
+func (m *TwoFactorModel) Enabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	totp, err := m.q.GetUserTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("failed to retrieve TOTP settings: %v", err)
+	}
+
+	return totp.ConfirmedAt.Valid, nil
+}
+
+// BeginEnrollment returns the secret the user should add to their authenticator app. The secret is
+// reused until enrollment is confirmed so reloading the enrollment page doesn't invalidate an app
+// that has already been set up. If the user is already enrolled, ErrTwoFactorEnabled is returned.
+func (m *TwoFactorModel) BeginEnrollment(ctx context.Context, userID uuid.UUID, account string) (TwoFactorEnrollment, error) {
+	var secret string
+
+	totp, err := m.q.GetUserTOTP(ctx, userID)
+	switch {
+	case err == nil && totp.ConfirmedAt.Valid:
+		return TwoFactorEnrollment{}, ErrTwoFactorEnabled
+	case err == nil:
+		if secret, err = m.secret(totp); err != nil {
+			return TwoFactorEnrollment{}, err
+		}
+	case errors.Is(err, pgx.ErrNoRows):
+		secret = m.otp.GenerateSecret()
+
+		params := queries.InsertUserTOTPParams{
+			UserID: userID,
+			Secret: m.secrets.Seal(secret, userID[:]),
+		}
+		if err := m.q.InsertUserTOTP(ctx, params); err != nil {
+			return TwoFactorEnrollment{}, fmt.Errorf("failed to persist TOTP secret: %v", err)
+		}
+
+		m.logger.DebugContext(ctx, "Started two-factor enrollment.", "userID", userID)
+	default:
+		return TwoFactorEnrollment{}, fmt.Errorf("failed to retrieve TOTP settings: %v", err)
+	}
+
+	enrollment := TwoFactorEnrollment{
+		Secret:          secret,
+		ProvisioningURI: m.otp.ProvisioningURI(secret, account),
+	}
+
+	return enrollment, nil
+}
+
+// ConfirmEnrollment enables two-factor authentication if the code matches the secret from
+// BeginEnrollment. The returned recovery codes are not stored in a recoverable form, so they must be
+// shown to the user now.
+func (m *TwoFactorModel) ConfirmEnrollment(ctx context.Context, userID uuid.UUID, code string) (codes []string, retErr error) {
+	tx, err := m.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("starting transaction: %v", err)
+	}
+
+	defer func() {
+		if txErr := tx.Rollback(ctx); txErr != nil && !errors.Is(txErr, pgx.ErrTxClosed) {
+			retErr = errors.Join(retErr, txErr)
+		}
+	}()
+
+	txQueries := m.q.WithTx(tx)
+
+	totp, err := txQueries.GetUserTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInvalidCode
+		}
+
+		return nil, fmt.Errorf("failed to retrieve TOTP settings: %v", err)
+	}
+
+	if totp.ConfirmedAt.Valid {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	secret, err := m.secret(totp)
+	if err != nil {
+		return nil, err
+	}
+
+	step, ok := m.otp.Validate(secret, code)
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+
+	confirmParams := queries.ConfirmUserTOTPParams{
+		UserID:       userID,
+		LastUsedStep: step,
+	}
+	if err := txQueries.ConfirmUserTOTP(ctx, confirmParams); err != nil {
+		return nil, fmt.Errorf("failed to confirm TOTP: %v", err)
+	}
+
+	codes = m.otp.GenerateRecoveryCodes(RecoveryCodeCount)
+	for _, recoveryCode := range codes {
+		params := queries.InsertRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: security.HashToken(security.NormalizeRecoveryCode(recoveryCode)),
+		}
+		if err := txQueries.InsertRecoveryCode(ctx, params); err != nil {
+			return nil, fmt.Errorf("failed to insert recovery code: %v", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit two-factor enrollment: %v", err)
+	}
+
+	m.logger.InfoContext(ctx, "Enabled two-factor authentication.", "userID", userID)
+
+	return codes, nil
+}
+
+// Verify checks a one time password or unused recovery code for the user. Each one time password
+// and recovery code is only accepted once. If the code is not accepted, ErrInvalidCode is returned.
+func (m *TwoFactorModel) Verify(ctx context.Context, userID uuid.UUID, code string) error {
+	totp, err := m.q.GetUserTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrInvalidCode
+		}
+
+		return fmt.Errorf("failed to retrieve TOTP settings: %v", err)
+	}
+
+	if !totp.ConfirmedAt.Valid {
+		return ErrInvalidCode
+	}
+
+	secret, err := m.secret(totp)
+	if err != nil {
+		return err
+	}
+
+	if step, ok := m.otp.Validate(secret, code); ok {
+		params := queries.UpdateTOTPLastUsedStepParams{
+			UserID:       userID,
+			LastUsedStep: step,
+		}
+		updated, err := m.q.UpdateTOTPLastUsedStep(ctx, params)
+		if err != nil {
+			return fmt.Errorf("failed to record used TOTP code: %v", err)
+		}
+
+		// The update only applies to newer steps, so nothing being updated means the code has
+		// already been used.
+		if updated == 0 {
+			m.logger.WarnContext(ctx, "Rejected reused TOTP code.", "userID", userID)
+
+			return ErrInvalidCode
+		}
+
+		return nil
+	}
+
+	params := queries.UseRecoveryCodeParams{
+		UserID:   userID,
+		CodeHash: security.HashToken(security.NormalizeRecoveryCode(code)),
+	}
+	used, err := m.q.UseRecoveryCode(ctx, params)
+	if err != nil {
+		return fmt.Errorf("failed to use recovery code: %v", err)
+	}
+
+	if used == 0 {
+		return ErrInvalidCode
+	}
+
+	m.logger.InfoContext(ctx, "Used recovery code.", "userID", userID)
+
+	return nil
+}
+
+// secret decrypts the user's TOTP secret.
+func (m *TwoFactorModel) secret(totp queries.UserTOTP) (string, error) {
+	secret, err := m.secrets.Open(totp.Secret, totp.UserID[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt TOTP secret: %v", err)
+	}
+
+	return secret, nil
+}
+
+// Disable turns off two-factor authentication for the user after verifying a code, removing their
+// secret and recovery codes.
+func (m *TwoFactorModel) Disable(ctx context.Context, userID uuid.UUID, code string) (retErr error) {
+	if err := m.Verify(ctx, userID, code); err != nil {
+		return err
+	}
+
+	tx, err := m.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("starting transaction: %v", err)
+	}
+
+	defer func() {
+		if txErr := tx.Rollback(ctx); txErr != nil && !errors.Is(txErr, pgx.ErrTxClosed) {
+			retErr = errors.Join(retErr, txErr)
+		}
+	}()
+
+	txQueries := m.q.WithTx(tx)
+
+	if err := txQueries.DeleteRecoveryCodes(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %v", err)
+	}
+
+	if err := txQueries.DeleteUserTOTP(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete TOTP settings: %v", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit disabling two-factor authentication: %v", err)
+	}
+
+	m.logger.InfoContext(ctx, "Disabled two-factor authentication.", "userID", userID)
+
+	return nil
+}
@@ -0,0 +1,455 @@
+package models_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/cdriehuys/secret-santa/internal/models"
+	"github.com/cdriehuys/secret-santa/internal/models/queries"
+	"github.com/cdriehuys/secret-santa/internal/security"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const mockSecret = "MOCKSECRET"
+const mockValidCode = "123456"
+const mockCodeStep = 42
+
+// FakeOneTimePasswords accepts a single valid code.
+type FakeOneTimePasswords struct {
+	recoveryCodes []string
+}
+
+func (o *FakeOneTimePasswords) GenerateRecoveryCodes(n int) []string {
+	return o.recoveryCodes
+}
+
+func (o *FakeOneTimePasswords) GenerateSecret() string {
+	return mockSecret
+}
+
+func (o *FakeOneTimePasswords) ProvisioningURI(secret string, account string) string {
+	return "otpauth://totp/" + account + "?secret=" + secret
+}
+
+func (o *FakeOneTimePasswords) Validate(secret string, code string) (int64, bool) {
+	if secret == mockSecret && code == mockValidCode {
+		return mockCodeStep, true
+	}
+
+	return 0, false
+}
+
+type MockTwoFactorQueries struct {
+	confirmParams queries.ConfirmUserTOTPParams
+	confirmError  error
+
+	deletedRecoveryCodes uuid.UUID
+	deletedTOTP          uuid.UUID
+
+	getUserTOTPReturn queries.UserTOTP
+	getUserTOTPError  error
+
+	insertedRecoveryCodes []queries.InsertRecoveryCodeParams
+
+	insertedTOTP    queries.InsertUserTOTPParams
+	insertTOTPError error
+
+	updateStepParams queries.UpdateTOTPLastUsedStepParams
+	updateStepReturn int64
+
+	useRecoveryCodeParams queries.UseRecoveryCodeParams
+	useRecoveryCodeReturn int64
+}
+
+func (q *MockTwoFactorQueries) WithTx(queries.DBTX) models.TwoFactorQueries {
+	return q
+}
+
+func (q *MockTwoFactorQueries) ConfirmUserTOTP(ctx context.Context, params queries.ConfirmUserTOTPParams) error {
+	q.confirmParams = params
+
+	return q.confirmError
+}
+
+func (q *MockTwoFactorQueries) DeleteRecoveryCodes(ctx context.Context, userID uuid.UUID) error {
+	q.deletedRecoveryCodes = userID
+
+	return nil
+}
+
+func (q *MockTwoFactorQueries) DeleteUserTOTP(ctx context.Context, userID uuid.UUID) error {
+	q.deletedTOTP = userID
+
+	return nil
+}
+
+func (q *MockTwoFactorQueries) GetUserTOTP(ctx context.Context, userID uuid.UUID) (queries.UserTOTP, error) {
+	return q.getUserTOTPReturn, q.getUserTOTPError
+}
+
+func (q *MockTwoFactorQueries) InsertRecoveryCode(ctx context.Context, params queries.InsertRecoveryCodeParams) error {
+	q.insertedRecoveryCodes = append(q.insertedRecoveryCodes, params)
+
+	return nil
+}
+
+func (q *MockTwoFactorQueries) InsertUserTOTP(ctx context.Context, params queries.InsertUserTOTPParams) error {
+	q.insertedTOTP = params
+
+	return q.insertTOTPError
+}
+
+func (q *MockTwoFactorQueries) UpdateTOTPLastUsedStep(ctx context.Context, params queries.UpdateTOTPLastUsedStepParams) (int64, error) {
+	q.updateStepParams = params
+
+	return q.updateStepReturn, nil
+}
+
+func (q *MockTwoFactorQueries) UseRecoveryCode(ctx context.Context, params queries.UseRecoveryCodeParams) (int64, error) {
+	q.useRecoveryCodeParams = params
+
+	return q.useRecoveryCodeReturn, nil
+}
+
+// testSecrets encrypts TOTP secrets with a fixed key. Secrets in the mock queries that aren't
+// sealed are read as they are, like secrets stored before encryption was added.
+var testSecrets = func() *security.SecretBox {
+	box, err := security.NewSecretBox(bytes.Repeat([]byte{1}, security.SecretBoxKeySize))
+	if err != nil {
+		panic(err)
+	}
+
+	return box
+}()
+
+var confirmedAt = pgtype.Timestamptz{Time: time.Date(2025, time.December, 1, 0, 0, 0, 0, time.UTC), Valid: true}
+
+func TestTwoFactorModel_Enabled(t *testing.T) {
+	testCases := []struct {
+		name    string
+		queries MockTwoFactorQueries
+		want    bool
+		wantErr error
+	}{
+		{
+			name:    "not enrolled",
+			queries: MockTwoFactorQueries{getUserTOTPError: pgx.ErrNoRows},
+		},
+		{
+			name:    "enrollment pending",
+			queries: MockTwoFactorQueries{getUserTOTPReturn: queries.UserTOTP{Secret: mockSecret}},
+		},
+		{
+			name:    "enrolled",
+			queries: MockTwoFactorQueries{getUserTOTPReturn: queries.UserTOTP{Secret: mockSecret, ConfirmedAt: confirmedAt}},
+			want:    true,
+		},
+		{
+			name:    "query error",
+			queries: MockTwoFactorQueries{getUserTOTPError: errors.New("query failed")},
+			wantErr: errAny,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			twoFactor := models.NewTwoFactorModel(slog.New(slog.DiscardHandler), &FakeOneTimePasswords{}, testSecrets, &MockDB{}, &tt.queries)
+
+			got, err := twoFactor.Enabled(t.Context(), uuid.New())
+
+			assertError(t, tt.wantErr, err)
+
+			if got != tt.want {
+				t.Errorf("Expected enabled=%v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTwoFactorModel_BeginEnrollment(t *testing.T) {
+	userID := uuid.New()
+
+	testCases := []struct {
+		name         string
+		queries      MockTwoFactorQueries
+		wantSecret   string
+		wantInserted bool
+		wantErr      error
+	}{
+		{
+			name:         "new enrollment",
+			queries:      MockTwoFactorQueries{getUserTOTPError: pgx.ErrNoRows},
+			wantSecret:   mockSecret,
+			wantInserted: true,
+		},
+		{
+			name:       "reuses pending secret",
+			queries:    MockTwoFactorQueries{getUserTOTPReturn: queries.UserTOTP{Secret: "EXISTING"}},
+			wantSecret: "EXISTING",
+		},
+		{
+			name:    "already enrolled",
+			queries: MockTwoFactorQueries{getUserTOTPReturn: queries.UserTOTP{Secret: mockSecret, ConfirmedAt: confirmedAt}},
+			wantErr: models.ErrTwoFactorEnabled,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			twoFactor := models.NewTwoFactorModel(slog.New(slog.DiscardHandler), &FakeOneTimePasswords{}, testSecrets, &MockDB{}, &tt.queries)
+
+			enrollment, err := twoFactor.BeginEnrollment(t.Context(), userID, "test@example.com")
+
+			assertError(t, tt.wantErr, err)
+
+			if enrollment.Secret != tt.wantSecret {
+				t.Errorf("Expected secret %q, got %q", tt.wantSecret, enrollment.Secret)
+			}
+
+			if tt.wantSecret != "" && enrollment.ProvisioningURI == "" {
+				t.Error("Expected a provisioning URI.")
+			}
+
+			inserted := tt.queries.insertedTOTP.UserID == userID
+			if inserted != tt.wantInserted {
+				t.Fatalf("Expected secret inserted=%v, got %#v", tt.wantInserted, tt.queries.insertedTOTP)
+			}
+
+			if inserted {
+				stored := tt.queries.insertedTOTP.Secret
+				if stored == mockSecret {
+					t.Fatal("Expected the stored secret to be encrypted")
+				}
+
+				if secret, err := testSecrets.Open(stored, userID[:]); err != nil || secret != mockSecret {
+					t.Errorf("Expected the stored secret to decrypt to %q, got %q (%v)", mockSecret, secret, err)
+				}
+			}
+		})
+	}
+}
+
+func TestTwoFactorModel_ConfirmEnrollment(t *testing.T) {
+	userID := uuid.New()
+	recoveryCodes := []string{"AAAA-BBBB", "CCCC-DDDD"}
+
+	testCases := []struct {
+		name        string
+		tx          MockTX
+		queries     MockTwoFactorQueries
+		code        string
+		wantCodes   []string
+		wantConfirm queries.ConfirmUserTOTPParams
+		wantCommit  bool
+		wantErr     error
+	}{
+		{
+			name:        "valid code",
+			queries:     MockTwoFactorQueries{getUserTOTPReturn: queries.UserTOTP{Secret: mockSecret}},
+			code:        mockValidCode,
+			wantCodes:   recoveryCodes,
+			wantConfirm: queries.ConfirmUserTOTPParams{UserID: userID, LastUsedStep: mockCodeStep},
+			wantCommit:  true,
+		},
+		{
+			name:    "invalid code",
+			queries: MockTwoFactorQueries{getUserTOTPReturn: queries.UserTOTP{Secret: mockSecret}},
+			code:    "000000",
+			wantErr: models.ErrInvalidCode,
+		},
+		{
+			name:    "enrollment not started",
+			queries: MockTwoFactorQueries{getUserTOTPError: pgx.ErrNoRows},
+			code:    mockValidCode,
+			wantErr: models.ErrInvalidCode,
+		},
+		{
+			name:    "already enrolled",
+			queries: MockTwoFactorQueries{getUserTOTPReturn: queries.UserTOTP{Secret: mockSecret, ConfirmedAt: confirmedAt}},
+			code:    mockValidCode,
+			wantErr: models.ErrTwoFactorEnabled,
+		},
+		{
+			name:        "commit error",
+			tx:          MockTX{commitError: errors.New("commit failed")},
+			queries:     MockTwoFactorQueries{getUserTOTPReturn: queries.UserTOTP{Secret: mockSecret}},
+			code:        mockValidCode,
+			wantConfirm: queries.ConfirmUserTOTPParams{UserID: userID, LastUsedStep: mockCodeStep},
+			wantErr:     errAny,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			db := MockDB{txFactory: func() models.Transaction { return &tt.tx }}
+			otp := FakeOneTimePasswords{recoveryCodes: recoveryCodes}
+			twoFactor := models.NewTwoFactorModel(slog.New(slog.DiscardHandler), &otp, testSecrets, &db, &tt.queries)
+
+			codes, err := twoFactor.ConfirmEnrollment(t.Context(), userID, tt.code)
+
+			assertError(t, tt.wantErr, err)
+
+			if len(codes) != len(tt.wantCodes) {
+				t.Errorf("Expected recovery codes %v, got %v", tt.wantCodes, codes)
+			}
+
+			if got := tt.queries.confirmParams; got != tt.wantConfirm {
+				t.Errorf("Expected confirmation %#v, got %#v", tt.wantConfirm, got)
+			}
+
+			if tt.wantCommit != tt.tx.committed {
+				t.Errorf("Expected tx.committed=%v, got %v", tt.wantCommit, tt.tx.committed)
+			}
+
+			if tt.wantCommit {
+				for i, inserted := range tt.queries.insertedRecoveryCodes {
+					want := security.HashToken(security.NormalizeRecoveryCode(recoveryCodes[i]))
+					if inserted.CodeHash != want {
+						t.Errorf("Expected recovery code %d to be stored as %q, got %q", i, want, inserted.CodeHash)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestTwoFactorModel_Verify(t *testing.T) {
+	userID := uuid.New()
+	enrolled := queries.UserTOTP{UserID: userID, Secret: mockSecret, ConfirmedAt: confirmedAt}
+
+	sealed := enrolled
+	sealed.Secret = testSecrets.Seal(mockSecret, userID[:])
+
+	// A secret sealed for another user can't be used.
+	otherUserID := uuid.New()
+	moved := enrolled
+	moved.Secret = testSecrets.Seal(mockSecret, otherUserID[:])
+
+	testCases := []struct {
+		name             string
+		queries          MockTwoFactorQueries
+		code             string
+		wantStepUpdate   bool
+		wantRecoveryCode string
+		wantErr          error
+	}{
+		{
+			name:           "valid code",
+			queries:        MockTwoFactorQueries{getUserTOTPReturn: enrolled, updateStepReturn: 1},
+			code:           mockValidCode,
+			wantStepUpdate: true,
+		},
+		{
+			name:           "encrypted secret",
+			queries:        MockTwoFactorQueries{getUserTOTPReturn: sealed, updateStepReturn: 1},
+			code:           mockValidCode,
+			wantStepUpdate: true,
+		},
+		{
+			name:    "secret sealed for another user",
+			queries: MockTwoFactorQueries{getUserTOTPReturn: moved},
+			code:    mockValidCode,
+			wantErr: errAny,
+		},
+		{
+			name:           "replayed code",
+			queries:        MockTwoFactorQueries{getUserTOTPReturn: enrolled, updateStepReturn: 0},
+			code:           mockValidCode,
+			wantStepUpdate: true,
+			wantErr:        models.ErrInvalidCode,
+		},
+		{
+			name:             "unused recovery code",
+			queries:          MockTwoFactorQueries{getUserTOTPReturn: enrolled, useRecoveryCodeReturn: 1},
+			code:             "aaaa-bbbb",
+			wantRecoveryCode: security.HashToken("AAAABBBB"),
+		},
+		{
+			name:             "unknown or used recovery code",
+			queries:          MockTwoFactorQueries{getUserTOTPReturn: enrolled},
+			code:             "aaaa-bbbb",
+			wantRecoveryCode: security.HashToken("AAAABBBB"),
+			wantErr:          models.ErrInvalidCode,
+		},
+		{
+			name:    "not enrolled",
+			queries: MockTwoFactorQueries{getUserTOTPReturn: queries.UserTOTP{Secret: mockSecret}},
+			code:    mockValidCode,
+			wantErr: models.ErrInvalidCode,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			twoFactor := models.NewTwoFactorModel(slog.New(slog.DiscardHandler), &FakeOneTimePasswords{}, testSecrets, &MockDB{}, &tt.queries)
+
+			err := twoFactor.Verify(t.Context(), userID, tt.code)
+
+			assertError(t, tt.wantErr, err)
+
+			wantStep := queries.UpdateTOTPLastUsedStepParams{}
+			if tt.wantStepUpdate {
+				wantStep = queries.UpdateTOTPLastUsedStepParams{UserID: userID, LastUsedStep: mockCodeStep}
+			}
+
+			if got := tt.queries.updateStepParams; got != wantStep {
+				t.Errorf("Expected step update %#v, got %#v", wantStep, got)
+			}
+
+			if got := tt.queries.useRecoveryCodeParams.CodeHash; got != tt.wantRecoveryCode {
+				t.Errorf("Expected recovery code lookup %q, got %q", tt.wantRecoveryCode, got)
+			}
+		})
+	}
+}
+
+func TestTwoFactorModel_Disable(t *testing.T) {
+	userID := uuid.New()
+	enrolled := queries.UserTOTP{UserID: userID, Secret: mockSecret, ConfirmedAt: confirmedAt}
+
+	testCases := []struct {
+		name        string
+		code        string
+		wantDeleted bool
+		wantErr     error
+	}{
+		{
+			name:        "valid code",
+			code:        mockValidCode,
+			wantDeleted: true,
+		},
+		{
+			name:    "invalid code",
+			code:    "000000",
+			wantErr: models.ErrInvalidCode,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := MockTX{}
+			db := MockDB{txFactory: func() models.Transaction { return &tx }}
+			twoFactorQueries := MockTwoFactorQueries{getUserTOTPReturn: enrolled, updateStepReturn: 1}
+			twoFactor := models.NewTwoFactorModel(slog.New(slog.DiscardHandler), &FakeOneTimePasswords{}, testSecrets, &db, &twoFactorQueries)
+
+			err := twoFactor.Disable(t.Context(), userID, tt.code)
+
+			assertError(t, tt.wantErr, err)
+
+			deleted := twoFactorQueries.deletedTOTP == userID && twoFactorQueries.deletedRecoveryCodes == userID
+			if deleted != tt.wantDeleted {
+				t.Errorf("Expected two-factor settings deleted=%v, got %v", tt.wantDeleted, deleted)
+			}
+
+			if tx.committed != tt.wantDeleted {
+				t.Errorf("Expected tx.committed=%v, got %v", tt.wantDeleted, tx.committed)
+			}
+		})
+	}
+}
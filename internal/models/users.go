@@ -5,12 +5,92 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 
+	"github.com/cdriehuys/secret-santa/internal/email"
 	"github.com/cdriehuys/secret-santa/internal/models/queries"
+	"github.com/cdriehuys/secret-santa/internal/security"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
+// EmailVerificationKeyLifetime is how long a link to verify an email address remains usable.
+const EmailVerificationKeyLifetime = 24 * time.Hour
+
+// dummyPasswordHash is checked when logging in with an unknown email so the attempt takes about as
+// long as one with a wrong password, and response times don't reveal which emails have accounts.
+// It uses argon2id's default memory and iterations, and no password matches it.
+const dummyPasswordHash = "$argon2id$v=19$m=65536,t=1,p=2$nryHmMCu3esFDPAb3L9XMA$rX7PF1vCfAAAJcicDvOIAGd51LRe/TDhcrJreZ+3UVM"
+
+var (
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrInvalidToken       = errors.New("invalid or expired token")
+	ErrEmailTaken         = errors.New("email is already in use by another account")
+)
+
+// User is the public representation of a user account.
+type User struct {
+	ID    uuid.UUID
+	Email string
+}
+
+func userFromRow(row queries.User) User {
+	return User{
+		ID:    row.ID,
+		Email: row.Email,
+	}
+}
+
+// UserData is everything stored about a user, suitable for handing to the user themselves. Secrets
+// such as the password hash, tokens, the TOTP secret, and the bodies of emails, which can contain
+// tokens, are deliberately excluded.
+type UserData struct {
+	User                      UserDataAccount        `json:"user"`
+	PendingEmailVerifications []UserDataPendingEmail `json:"pending_email_verifications"`
+	Sessions                  []UserDataSession      `json:"sessions"`
+	Emails                    []UserDataEmail        `json:"emails"`
+	TwoFactor                 UserDataTwoFactor      `json:"two_factor"`
+}
+
+type UserDataAccount struct {
+	ID            uuid.UUID `json:"id"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+type UserDataPendingEmail struct {
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type UserDataSession struct {
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// UserDataEmail is an email that is queued or was sent for the user. SentAt and FailedAt are nil
+// until the email is delivered or given up on.
+type UserDataEmail struct {
+	ID        uuid.UUID  `json:"id"`
+	Recipient string     `json:"recipient"`
+	Subject   string     `json:"subject"`
+	Attempts  int        `json:"attempts"`
+	CreatedAt time.Time  `json:"created_at"`
+	SentAt    *time.Time `json:"sent_at"`
+	FailedAt  *time.Time `json:"failed_at"`
+}
+
+// UserDataTwoFactor is the user's two-factor enrollment. CreatedAt is nil if they never started
+// enrolling.
+type UserDataTwoFactor struct {
+	Enabled                bool       `json:"enabled"`
+	CreatedAt              *time.Time `json:"created_at"`
+	RecoveryCodesRemaining int        `json:"recovery_codes_remaining"`
+}
+
 type NewUser struct {
 	Email    string
 	Password string
@@ -19,22 +99,42 @@ type NewUser struct {
 type PasswordHasher interface {
 	Hash(password string) (string, error)
 	ComparePasswordAndHash(password string, hash string) (bool, error)
+	NeedsRehash(hash string) (bool, error)
 }
 
 type TokenGenerator interface {
 	Generate() string
 }
 
+// EmailVerifier composes the emails sent while verifying addresses. The messages are added to the
+// outbox rather than sent directly so they are only delivered if the surrounding transaction
+// commits.
 type EmailVerifier interface {
-	DuplicateRegistration(ctx context.Context, email string) error
-	NewEmail(ctx context.Context, email string, token string) error
+	ChangeEmail(ctx context.Context, address string, token string) (email.Message, error)
+	DuplicateRegistration(ctx context.Context, address string) (email.Message, error)
+	NewEmail(ctx context.Context, address string, token string) (email.Message, error)
 }
 
 type UserQueries interface {
 	WithTx(tx queries.DBTX) UserQueries
 
+	CountUnusedRecoveryCodes(context.Context, uuid.UUID) (int64, error)
+	DeleteEmailVerificationKeysForUser(context.Context, uuid.UUID) error
+	DeleteOtherSessionsForUser(context.Context, queries.DeleteOtherSessionsForUserParams) error
+	DeleteOutboxEmailsForUser(context.Context, uuid.UUID) error
+	DeleteUser(context.Context, uuid.UUID) error
+	GetEmailVerificationKey(context.Context, queries.GetEmailVerificationKeyParams) (queries.EmailVerificationKey, error)
+	GetUserByID(context.Context, uuid.UUID) (queries.User, error)
+	GetUserTOTPStatus(context.Context, uuid.UUID) (queries.GetUserTOTPStatusRow, error)
+	GetVerifiedUserByEmail(context.Context, string) (queries.User, error)
 	InsertEmailVerificationKey(context.Context, queries.InsertEmailVerificationKeyParams) error
 	InsertNewUser(context.Context, queries.InsertNewUserParams) (queries.User, error)
+	InsertOutboxEmail(context.Context, queries.InsertOutboxEmailParams) error
+	ListEmailVerificationKeysForUser(context.Context, uuid.UUID) ([]queries.EmailVerificationKey, error)
+	ListOutboxEmailsForUser(context.Context, uuid.UUID) ([]queries.ListOutboxEmailsForUserRow, error)
+	ListSessionsForUser(context.Context, uuid.UUID) ([]queries.Session, error)
+	SetUserEmailVerified(context.Context, queries.SetUserEmailVerifiedParams) error
+	UpdateUserPassword(context.Context, queries.UpdateUserPasswordParams) error
 	VerifiedEmailExists(context.Context, string) (bool, error)
 }
 
@@ -101,8 +201,25 @@ func (m *UserModel) Register(ctx context.Context, user NewUser) (retErr error) {
 	if emailAlreadyVerified {
 		m.logger.DebugContext(ctx, "Registration is for an email that has already been verified.")
 
-		// Let the transaction roll back because there are no changes.
-		return m.emailVerifier.DuplicateRegistration(ctx, user.Email)
+		owner, err := txQueries.GetVerifiedUserByEmail(ctx, user.Email)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve owner of duplicate email: %v", err)
+		}
+
+		msg, err := m.emailVerifier.DuplicateRegistration(ctx, user.Email)
+		if err != nil {
+			return fmt.Errorf("failed to compose duplicate registration email: %v", err)
+		}
+
+		if err := enqueueEmail(ctx, txQueries, owner.ID, msg); err != nil {
+			return err
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			return fmt.Errorf("failed to commit duplicate registration email: %v", err)
+		}
+
+		return nil
 	}
 
 	userID := uuid.New()
@@ -121,9 +238,9 @@ func (m *UserModel) Register(ctx context.Context, user NewUser) (retErr error) {
 	verificationToken := m.tokenGenerator.Generate()
 
 	emailVerificationParams := queries.InsertEmailVerificationKeyParams{
-		UserID: userID,
-		Email:  user.Email,
-		Token:  verificationToken,
+		UserID:    userID,
+		Email:     user.Email,
+		TokenHash: security.HashToken(verificationToken),
 	}
 	if err := txQueries.InsertEmailVerificationKey(ctx, emailVerificationParams); err != nil {
 		return fmt.Errorf("failed to insert email verification key: %v", err)
@@ -131,8 +248,13 @@ func (m *UserModel) Register(ctx context.Context, user NewUser) (retErr error) {
 
 	m.logger.DebugContext(ctx, "Persisted email verification key.", "userID", userID)
 
-	if err := m.emailVerifier.NewEmail(ctx, user.Email, verificationToken); err != nil {
-		return fmt.Errorf("failed to send email verification: %v", err)
+	msg, err := m.emailVerifier.NewEmail(ctx, user.Email, verificationToken)
+	if err != nil {
+		return fmt.Errorf("failed to compose email verification: %v", err)
+	}
+
+	if err := enqueueEmail(ctx, txQueries, userID, msg); err != nil {
+		return err
 	}
 
 	if err := tx.Commit(ctx); err != nil {
@@ -143,3 +265,429 @@ func (m *UserModel) Register(ctx context.Context, user NewUser) (retErr error) {
 
 	return nil
 }
+
+// Authenticate returns the ID of the user with the given verified email if the password matches.
+// If there is no such user or the password is incorrect, ErrInvalidCredentials is returned.
+func (m *UserModel) Authenticate(ctx context.Context, email string, password string) (uuid.UUID, error) {
+	user, err := m.q.GetVerifiedUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// The result doesn't matter, only the time it takes.
+			_, _ = m.hasher.ComparePasswordAndHash(password, dummyPasswordHash)
+
+			return uuid.UUID{}, ErrInvalidCredentials
+		}
+
+		return uuid.UUID{}, fmt.Errorf("failed to retrieve user: %v", err)
+	}
+
+	matches, err := m.hasher.ComparePasswordAndHash(password, user.PasswordHash)
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("failed to compare password: %v", err)
+	}
+
+	if !matches {
+		return uuid.UUID{}, ErrInvalidCredentials
+	}
+
+	// A failed upgrade shouldn't prevent the user from logging in since the existing hash is still
+	// valid. The upgrade will be retried on their next login.
+	if err := m.upgradePasswordHash(ctx, user, password); err != nil {
+		m.logger.WarnContext(ctx, "Failed to upgrade password hash.", "userID", user.ID, "error", err)
+	}
+
+	return user.ID, nil
+}
+
+// upgradePasswordHash replaces the user's password hash if it was created with outdated parameters.
+func (m *UserModel) upgradePasswordHash(ctx context.Context, user queries.User, password string) error {
+	needsRehash, err := m.hasher.NeedsRehash(user.PasswordHash)
+	if err != nil {
+		return fmt.Errorf("checking hash parameters: %v", err)
+	}
+
+	if !needsRehash {
+		return nil
+	}
+
+	passwordHash, err := m.hasher.Hash(password)
+	if err != nil {
+		return fmt.Errorf("hashing password: %v", err)
+	}
+
+	params := queries.UpdateUserPasswordParams{
+		ID:           user.ID,
+		PasswordHash: passwordHash,
+	}
+	if err := m.q.UpdateUserPassword(ctx, params); err != nil {
+		return fmt.Errorf("updating password hash: %v", err)
+	}
+
+	m.logger.InfoContext(ctx, "Upgraded password hash.", "userID", user.ID)
+
+	return nil
+}
+
+// VerifyEmail consumes an email verification token, marking the associated email as verified and
+// making it the user's email address.
+func (m *UserModel) VerifyEmail(ctx context.Context, token string) (retErr error) {
+	tx, err := m.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("starting transaction: %v", err)
+	}
+
+	defer func() {
+		if txErr := tx.Rollback(ctx); txErr != nil && !errors.Is(txErr, pgx.ErrTxClosed) {
+			retErr = errors.Join(retErr, txErr)
+		}
+	}()
+
+	txQueries := m.q.WithTx(tx)
+
+	keyParams := queries.GetEmailVerificationKeyParams{
+		TokenHash:    security.HashToken(token),
+		CreatedAfter: pgtype.Timestamptz{Time: time.Now().Add(-EmailVerificationKeyLifetime), Valid: true},
+	}
+	key, err := txQueries.GetEmailVerificationKey(ctx, keyParams)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrInvalidToken
+		}
+
+		return fmt.Errorf("failed to retrieve email verification key: %v", err)
+	}
+
+	existingUser, err := txQueries.GetVerifiedUserByEmail(ctx, key.Email)
+	if err == nil && existingUser.ID != key.UserID {
+		return ErrEmailTaken
+	}
+
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("failed to check for duplicate email: %v", err)
+	}
+
+	verifyParams := queries.SetUserEmailVerifiedParams{
+		ID:    key.UserID,
+		Email: key.Email,
+	}
+	if err := txQueries.SetUserEmailVerified(ctx, verifyParams); err != nil {
+		// Another account may have verified the address since it was checked above.
+		if isUniqueViolation(err) {
+			return ErrEmailTaken
+		}
+
+		return fmt.Errorf("failed to mark email as verified: %v", err)
+	}
+
+	// Any other outstanding keys are for addresses the user has since replaced.
+	if err := txQueries.DeleteEmailVerificationKeysForUser(ctx, key.UserID); err != nil {
+		return fmt.Errorf("failed to delete email verification keys: %v", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit email verification: %v", err)
+	}
+
+	m.logger.InfoContext(ctx, "Verified email address.", "userID", key.UserID)
+
+	return nil
+}
+
+// ChangePassword replaces the user's password if the provided current password is correct. If it
+// is not, ErrInvalidCredentials is returned. Every session except the one identified by
+// currentSession is ended, so anyone else using the old password is logged out.
+func (m *UserModel) ChangePassword(ctx context.Context, userID uuid.UUID, currentSession string, currentPassword string, newPassword string) (retErr error) {
+	user, err := m.q.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve user: %v", err)
+	}
+
+	matches, err := m.hasher.ComparePasswordAndHash(currentPassword, user.PasswordHash)
+	if err != nil {
+		return fmt.Errorf("failed to compare password: %v", err)
+	}
+
+	if !matches {
+		return ErrInvalidCredentials
+	}
+
+	passwordHash, err := m.hasher.Hash(newPassword)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %v", err)
+	}
+
+	tx, err := m.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("starting transaction: %v", err)
+	}
+
+	defer func() {
+		if txErr := tx.Rollback(ctx); txErr != nil && !errors.Is(txErr, pgx.ErrTxClosed) {
+			retErr = errors.Join(retErr, txErr)
+		}
+	}()
+
+	txQueries := m.q.WithTx(tx)
+
+	params := queries.UpdateUserPasswordParams{
+		ID:           userID,
+		PasswordHash: passwordHash,
+	}
+	if err := txQueries.UpdateUserPassword(ctx, params); err != nil {
+		return fmt.Errorf("failed to update password: %v", err)
+	}
+
+	sessionParams := queries.DeleteOtherSessionsForUserParams{
+		UserID:        userID,
+		KeepTokenHash: security.HashToken(currentSession),
+	}
+	if err := txQueries.DeleteOtherSessionsForUser(ctx, sessionParams); err != nil {
+		return fmt.Errorf("failed to end other sessions: %v", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit password change: %v", err)
+	}
+
+	m.logger.InfoContext(ctx, "Changed user password.", "userID", userID)
+
+	return nil
+}
+
+// ChangeEmail begins the process of changing a user's email address. A verification link is sent
+// to the new address, and the user's email is only replaced once that link is followed.
+func (m *UserModel) ChangeEmail(ctx context.Context, userID uuid.UUID, email string) (retErr error) {
+	tx, err := m.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("starting transaction: %v", err)
+	}
+
+	defer func() {
+		if txErr := tx.Rollback(ctx); txErr != nil && !errors.Is(txErr, pgx.ErrTxClosed) {
+			retErr = errors.Join(retErr, txErr)
+		}
+	}()
+
+	txQueries := m.q.WithTx(tx)
+
+	user, err := txQueries.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve user: %v", err)
+	}
+
+	if user.Email == email {
+		m.logger.DebugContext(ctx, "Requested email matches current email.", "userID", userID)
+
+		return nil
+	}
+
+	emailAlreadyVerified, err := txQueries.VerifiedEmailExists(ctx, email)
+	if err != nil {
+		return fmt.Errorf("failed to check for duplicate email: %v", err)
+	}
+
+	if emailAlreadyVerified {
+		m.logger.DebugContext(ctx, "Email change is for an email that has already been verified.", "userID", userID)
+
+		owner, err := txQueries.GetVerifiedUserByEmail(ctx, email)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve owner of duplicate email: %v", err)
+		}
+
+		// Behave the same as a new address so the form can't be used to discover accounts.
+		msg, err := m.emailVerifier.DuplicateRegistration(ctx, email)
+		if err != nil {
+			return fmt.Errorf("failed to compose duplicate registration email: %v", err)
+		}
+
+		if err := enqueueEmail(ctx, txQueries, owner.ID, msg); err != nil {
+			return err
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			return fmt.Errorf("failed to commit duplicate registration email: %v", err)
+		}
+
+		return nil
+	}
+
+	verificationToken := m.tokenGenerator.Generate()
+
+	emailVerificationParams := queries.InsertEmailVerificationKeyParams{
+		UserID:    userID,
+		Email:     email,
+		TokenHash: security.HashToken(verificationToken),
+	}
+	if err := txQueries.InsertEmailVerificationKey(ctx, emailVerificationParams); err != nil {
+		return fmt.Errorf("failed to insert email verification key: %v", err)
+	}
+
+	msg, err := m.emailVerifier.ChangeEmail(ctx, email, verificationToken)
+	if err != nil {
+		return fmt.Errorf("failed to compose email verification: %v", err)
+	}
+
+	if err := enqueueEmail(ctx, txQueries, userID, msg); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit email change: %v", err)
+	}
+
+	m.logger.InfoContext(ctx, "Requested email change.", "userID", userID)
+
+	return nil
+}
+
+// ExportData collects all the personal data stored for a user.
+func (m *UserModel) ExportData(ctx context.Context, userID uuid.UUID) (UserData, error) {
+	user, err := m.q.GetUserByID(ctx, userID)
+	if err != nil {
+		return UserData{}, fmt.Errorf("failed to retrieve user: %v", err)
+	}
+
+	keys, err := m.q.ListEmailVerificationKeysForUser(ctx, userID)
+	if err != nil {
+		return UserData{}, fmt.Errorf("failed to list email verification keys: %v", err)
+	}
+
+	sessions, err := m.q.ListSessionsForUser(ctx, userID)
+	if err != nil {
+		return UserData{}, fmt.Errorf("failed to list sessions: %v", err)
+	}
+
+	emails, err := m.q.ListOutboxEmailsForUser(ctx, userID)
+	if err != nil {
+		return UserData{}, fmt.Errorf("failed to list emails: %v", err)
+	}
+
+	twoFactor, err := m.exportTwoFactor(ctx, userID)
+	if err != nil {
+		return UserData{}, err
+	}
+
+	data := UserData{
+		User: UserDataAccount{
+			ID:            user.ID,
+			Email:         user.Email,
+			EmailVerified: user.EmailVerified,
+			CreatedAt:     user.CreatedAt.Time,
+			UpdatedAt:     user.UpdatedAt.Time,
+		},
+		PendingEmailVerifications: make([]UserDataPendingEmail, 0, len(keys)),
+		Sessions:                  make([]UserDataSession, 0, len(sessions)),
+		Emails:                    make([]UserDataEmail, 0, len(emails)),
+		TwoFactor:                 twoFactor,
+	}
+
+	for _, key := range keys {
+		data.PendingEmailVerifications = append(data.PendingEmailVerifications, UserDataPendingEmail{
+			Email:     key.Email,
+			CreatedAt: key.CreatedAt.Time,
+		})
+	}
+
+	for _, session := range sessions {
+		data.Sessions = append(data.Sessions, UserDataSession{
+			CreatedAt: session.CreatedAt.Time,
+			ExpiresAt: session.ExpiresAt.Time,
+		})
+	}
+
+	for _, outboxEmail := range emails {
+		data.Emails = append(data.Emails, UserDataEmail{
+			ID:        outboxEmail.ID,
+			Recipient: outboxEmail.Recipient,
+			Subject:   outboxEmail.Subject,
+			Attempts:  int(outboxEmail.Attempts),
+			CreatedAt: outboxEmail.CreatedAt.Time,
+			SentAt:    optionalTime(outboxEmail.SentAt),
+			FailedAt:  optionalTime(outboxEmail.DeadAt),
+		})
+	}
+
+	return data, nil
+}
+
+// exportTwoFactor describes the user's two-factor enrollment without the secret or recovery codes.
+func (m *UserModel) exportTwoFactor(ctx context.Context, userID uuid.UUID) (UserDataTwoFactor, error) {
+	totp, err := m.q.GetUserTOTPStatus(ctx, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return UserDataTwoFactor{}, nil
+	}
+
+	if err != nil {
+		return UserDataTwoFactor{}, fmt.Errorf("failed to retrieve TOTP settings: %v", err)
+	}
+
+	remaining, err := m.q.CountUnusedRecoveryCodes(ctx, userID)
+	if err != nil {
+		return UserDataTwoFactor{}, fmt.Errorf("failed to count recovery codes: %v", err)
+	}
+
+	return UserDataTwoFactor{
+		Enabled:                totp.ConfirmedAt.Valid,
+		CreatedAt:              optionalTime(totp.CreatedAt),
+		RecoveryCodesRemaining: int(remaining),
+	}, nil
+}
+
+// optionalTime returns nil for a NULL timestamp.
+func optionalTime(t pgtype.Timestamptz) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+
+	return &t.Time
+}
+
+// Delete permanently removes a user's account if the provided password is correct. Records that
+// belong only to the user, such as sessions and email verification keys, are removed along with
+// it. The emails sent for the user are deleted in the same transaction, including ones that are
+// still waiting to be delivered, so nothing is sent to the address afterwards. If the password is
+// incorrect, ErrInvalidCredentials is returned.
+func (m *UserModel) Delete(ctx context.Context, userID uuid.UUID, password string) (retErr error) {
+	tx, err := m.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("starting transaction: %v", err)
+	}
+
+	defer func() {
+		if txErr := tx.Rollback(ctx); txErr != nil && !errors.Is(txErr, pgx.ErrTxClosed) {
+			retErr = errors.Join(retErr, txErr)
+		}
+	}()
+
+	txQueries := m.q.WithTx(tx)
+
+	user, err := txQueries.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve user: %v", err)
+	}
+
+	matches, err := m.hasher.ComparePasswordAndHash(password, user.PasswordHash)
+	if err != nil {
+		return fmt.Errorf("failed to compare password: %v", err)
+	}
+
+	if !matches {
+		return ErrInvalidCredentials
+	}
+
+	if err := txQueries.DeleteOutboxEmailsForUser(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete user's emails: %v", err)
+	}
+
+	if err := txQueries.DeleteUser(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete user: %v", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit user deletion: %v", err)
+	}
+
+	m.logger.InfoContext(ctx, "Deleted user.", "userID", userID)
+
+	return nil
+}
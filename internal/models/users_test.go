@@ -4,17 +4,27 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"reflect"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/cdriehuys/secret-santa/internal/email"
 	"github.com/cdriehuys/secret-santa/internal/models"
 	"github.com/cdriehuys/secret-santa/internal/models/queries"
+	"github.com/cdriehuys/secret-santa/internal/security"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 const mockHashValue = "hashed"
 const mockToken = "secret-token"
 
+var mockTokenHash = security.HashToken(mockToken)
+
 var defaultNewUser = models.NewUser{
 	Email:    "test@example.com",
 	Password: "tops3cret",
@@ -26,16 +36,27 @@ var errRollback = errors.New("rollback failed")
 type ConstantHasher struct {
 	HashError    error
 	CompareError error
+
+	NeedsRehashReturn bool
+	NeedsRehashError  error
+
+	compared bool
 }
 
 func (h ConstantHasher) Hash(string) (string, error) {
 	return mockHashValue, h.HashError
 }
 
-func (h ConstantHasher) ComparePasswordAndHash(password string, hash string) (bool, error) {
+func (h *ConstantHasher) ComparePasswordAndHash(password string, hash string) (bool, error) {
+	h.compared = true
+
 	return password == hash, h.CompareError
 }
 
+func (h ConstantHasher) NeedsRehash(string) (bool, error) {
+	return h.NeedsRehashReturn, h.NeedsRehashError
+}
+
 type ConstantTokenGenerator struct {
 	token string
 }
@@ -45,6 +66,10 @@ func (g *ConstantTokenGenerator) Generate() string {
 }
 
 type MockEmailVerifier struct {
+	changeEmailEmail string
+	changeEmailToken string
+	changeEmailError error
+
 	duplicateRegistrationEmail string
 	duplicateRegistrationError error
 
@@ -53,19 +78,72 @@ type MockEmailVerifier struct {
 	newEmailError error
 }
 
-func (v *MockEmailVerifier) DuplicateRegistration(ctx context.Context, email string) error {
-	v.duplicateRegistrationEmail = email
-	return v.duplicateRegistrationError
+func (v *MockEmailVerifier) ChangeEmail(ctx context.Context, address string, token string) (email.Message, error) {
+	v.changeEmailEmail = address
+	v.changeEmailToken = token
+
+	return email.Message{To: address, Subject: "change"}, v.changeEmailError
+}
+
+func (v *MockEmailVerifier) DuplicateRegistration(ctx context.Context, address string) (email.Message, error) {
+	v.duplicateRegistrationEmail = address
+
+	return email.Message{To: address, Subject: "duplicate"}, v.duplicateRegistrationError
 }
 
-func (v *MockEmailVerifier) NewEmail(ctx context.Context, email string, token string) error {
-	v.newEmailEmail = email
+func (v *MockEmailVerifier) NewEmail(ctx context.Context, address string, token string) (email.Message, error) {
+	v.newEmailEmail = address
 	v.newEmailToken = token
 
-	return v.newEmailError
+	return email.Message{To: address, Subject: "new"}, v.newEmailError
 }
 
 type MockUserQueries struct {
+	countUnusedRecoveryCodesReturn int64
+	countUnusedRecoveryCodesError  error
+
+	deletedEmailVerificationKeysUser uuid.UUID
+	deleteEmailVerificationKeysError error
+
+	deletedOutboxEmailsUser uuid.UUID
+	deleteOutboxEmailsError error
+
+	deletedUserID   uuid.UUID
+	deleteUserError error
+
+	deleteOtherSessionsParams queries.DeleteOtherSessionsForUserParams
+	deleteOtherSessionsError  error
+
+	listEmailVerificationKeysReturn []queries.EmailVerificationKey
+	listEmailVerificationKeysError  error
+
+	listOutboxEmailsReturn []queries.ListOutboxEmailsForUserRow
+	listOutboxEmailsError  error
+
+	listSessionsReturn []queries.Session
+	listSessionsError  error
+
+	getEmailVerificationKeyParams queries.GetEmailVerificationKeyParams
+	getEmailVerificationKeyReturn queries.EmailVerificationKey
+	getEmailVerificationKeyError  error
+
+	getUserByIDID     uuid.UUID
+	getUserByIDReturn queries.User
+	getUserByIDError  error
+
+	getUserTOTPStatusReturn queries.GetUserTOTPStatusRow
+	getUserTOTPStatusError  error
+
+	getVerifiedUserByEmailEmail  string
+	getVerifiedUserByEmailReturn queries.User
+	getVerifiedUserByEmailError  error
+
+	setUserEmailVerifiedParams queries.SetUserEmailVerifiedParams
+	setUserEmailVerifiedError  error
+
+	updateUserPasswordParams queries.UpdateUserPasswordParams
+	updateUserPasswordError  error
+
 	insertEmailVerificationKeyError error
 	insertEmailVerificationParams   queries.InsertEmailVerificationKeyParams
 
@@ -73,6 +151,9 @@ type MockUserQueries struct {
 	insertNewUserReturnError error
 	insertNewUserParams      queries.InsertNewUserParams
 
+	insertOutboxEmailParams []queries.InsertOutboxEmailParams
+	insertOutboxEmailError  error
+
 	verifiedEmailExistsEmail  string
 	verifiedEmailExistsReturn bool
 	verifiedEmailExistsError  error
@@ -82,6 +163,56 @@ func (q *MockUserQueries) WithTx(queries.DBTX) models.UserQueries {
 	return q
 }
 
+func (q *MockUserQueries) CountUnusedRecoveryCodes(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return q.countUnusedRecoveryCodesReturn, q.countUnusedRecoveryCodesError
+}
+
+func (q *MockUserQueries) DeleteEmailVerificationKeysForUser(ctx context.Context, userID uuid.UUID) error {
+	q.deletedEmailVerificationKeysUser = userID
+
+	return q.deleteEmailVerificationKeysError
+}
+
+func (q *MockUserQueries) DeleteOtherSessionsForUser(ctx context.Context, params queries.DeleteOtherSessionsForUserParams) error {
+	q.deleteOtherSessionsParams = params
+
+	return q.deleteOtherSessionsError
+}
+
+func (q *MockUserQueries) DeleteOutboxEmailsForUser(ctx context.Context, userID uuid.UUID) error {
+	q.deletedOutboxEmailsUser = userID
+
+	return q.deleteOutboxEmailsError
+}
+
+func (q *MockUserQueries) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	q.deletedUserID = id
+
+	return q.deleteUserError
+}
+
+func (q *MockUserQueries) GetEmailVerificationKey(ctx context.Context, params queries.GetEmailVerificationKeyParams) (queries.EmailVerificationKey, error) {
+	q.getEmailVerificationKeyParams = params
+
+	return q.getEmailVerificationKeyReturn, q.getEmailVerificationKeyError
+}
+
+func (q *MockUserQueries) GetUserByID(ctx context.Context, id uuid.UUID) (queries.User, error) {
+	q.getUserByIDID = id
+
+	return q.getUserByIDReturn, q.getUserByIDError
+}
+
+func (q *MockUserQueries) GetUserTOTPStatus(ctx context.Context, userID uuid.UUID) (queries.GetUserTOTPStatusRow, error) {
+	return q.getUserTOTPStatusReturn, q.getUserTOTPStatusError
+}
+
+func (q *MockUserQueries) GetVerifiedUserByEmail(ctx context.Context, email string) (queries.User, error) {
+	q.getVerifiedUserByEmailEmail = email
+
+	return q.getVerifiedUserByEmailReturn, q.getVerifiedUserByEmailError
+}
+
 func (q *MockUserQueries) InsertEmailVerificationKey(ctx context.Context, params queries.InsertEmailVerificationKeyParams) error {
 	q.insertEmailVerificationParams = params
 
@@ -94,6 +225,56 @@ func (q *MockUserQueries) InsertNewUser(ctx context.Context, params queries.Inse
 	return q.insertNewUserReturnUser, q.insertNewUserReturnError
 }
 
+func (q *MockUserQueries) InsertOutboxEmail(ctx context.Context, params queries.InsertOutboxEmailParams) error {
+	q.insertOutboxEmailParams = append(q.insertOutboxEmailParams, params)
+
+	return q.insertOutboxEmailError
+}
+
+// enqueuedSubjects returns the subject of each email added to the outbox.
+func (q *MockUserQueries) enqueuedSubjects() []string {
+	var subjects []string
+	for _, params := range q.insertOutboxEmailParams {
+		subjects = append(subjects, params.Subject)
+	}
+
+	return subjects
+}
+
+// enqueuedUsers returns the user each email added to the outbox was sent for.
+func (q *MockUserQueries) enqueuedUsers() []uuid.UUID {
+	var users []uuid.UUID
+	for _, params := range q.insertOutboxEmailParams {
+		users = append(users, params.UserID)
+	}
+
+	return users
+}
+
+func (q *MockUserQueries) ListEmailVerificationKeysForUser(ctx context.Context, userID uuid.UUID) ([]queries.EmailVerificationKey, error) {
+	return q.listEmailVerificationKeysReturn, q.listEmailVerificationKeysError
+}
+
+func (q *MockUserQueries) ListOutboxEmailsForUser(ctx context.Context, userID uuid.UUID) ([]queries.ListOutboxEmailsForUserRow, error) {
+	return q.listOutboxEmailsReturn, q.listOutboxEmailsError
+}
+
+func (q *MockUserQueries) ListSessionsForUser(ctx context.Context, userID uuid.UUID) ([]queries.Session, error) {
+	return q.listSessionsReturn, q.listSessionsError
+}
+
+func (q *MockUserQueries) SetUserEmailVerified(ctx context.Context, params queries.SetUserEmailVerifiedParams) error {
+	q.setUserEmailVerifiedParams = params
+
+	return q.setUserEmailVerifiedError
+}
+
+func (q *MockUserQueries) UpdateUserPassword(ctx context.Context, params queries.UpdateUserPasswordParams) error {
+	q.updateUserPasswordParams = params
+
+	return q.updateUserPasswordError
+}
+
 func (q *MockUserQueries) VerifiedEmailExists(ctx context.Context, email string) (bool, error) {
 	q.verifiedEmailExistsEmail = email
 
@@ -101,6 +282,8 @@ func (q *MockUserQueries) VerifiedEmailExists(ctx context.Context, email string)
 }
 
 func TestUserModel_Register(t *testing.T) {
+	ownerID := uuid.New()
+
 	testCases := []struct {
 		name                             string
 		emailVerifier                    MockEmailVerifier
@@ -116,6 +299,7 @@ func TestUserModel_Register(t *testing.T) {
 		wantNewEmailNotification         string
 		wantNewEmailToken                string
 		wantDuplicateEmailNotification   string
+		wantEnqueued                     []string
 		wantTxRollback                   bool
 		wantTxCommit                     bool
 		wantErr                          bool
@@ -187,8 +371,8 @@ func TestUserModel_Register(t *testing.T) {
 				PasswordHash: mockHashValue,
 			},
 			wantInsertedEmailVerificationKey: queries.InsertEmailVerificationKeyParams{
-				Email: defaultNewUser.Email,
-				Token: mockToken,
+				Email:     defaultNewUser.Email,
+				TokenHash: mockTokenHash,
 			},
 			wantTxRollback: true,
 			wantErr:        true,
@@ -208,8 +392,8 @@ func TestUserModel_Register(t *testing.T) {
 				PasswordHash: mockHashValue,
 			},
 			wantInsertedEmailVerificationKey: queries.InsertEmailVerificationKeyParams{
-				Email: defaultNewUser.Email,
-				Token: mockToken,
+				Email:     defaultNewUser.Email,
+				TokenHash: mockTokenHash,
 			},
 			wantNewEmailNotification: defaultNewUser.Email,
 			wantNewEmailToken:        mockToken,
@@ -231,23 +415,50 @@ func TestUserModel_Register(t *testing.T) {
 				PasswordHash: mockHashValue,
 			},
 			wantInsertedEmailVerificationKey: queries.InsertEmailVerificationKeyParams{
-				Email: defaultNewUser.Email,
-				Token: mockToken,
+				Email:     defaultNewUser.Email,
+				TokenHash: mockTokenHash,
 			},
 			wantNewEmailNotification: defaultNewUser.Email,
 			wantNewEmailToken:        mockToken,
+			wantEnqueued:             []string{"new"},
+			wantTxRollback:           true,
+			wantErr:                  true,
+		},
+		{
+			name: "new user email enqueue fail",
+			tokenGenerator: ConstantTokenGenerator{
+				token: mockToken,
+			},
+			queries: MockUserQueries{
+				insertOutboxEmailError: errInsert,
+			},
+			newUser:                defaultNewUser,
+			wantVerifiedEmailCheck: defaultNewUser.Email,
+			wantInsertedUser: queries.InsertNewUserParams{
+				Email:        defaultNewUser.Email,
+				PasswordHash: mockHashValue,
+			},
+			wantInsertedEmailVerificationKey: queries.InsertEmailVerificationKeyParams{
+				Email:     defaultNewUser.Email,
+				TokenHash: mockTokenHash,
+			},
+			wantNewEmailNotification: defaultNewUser.Email,
+			wantNewEmailToken:        mockToken,
+			wantEnqueued:             []string{"new"},
 			wantTxRollback:           true,
 			wantErr:                  true,
 		},
 		{
 			name: "duplicate user registration",
 			queries: MockUserQueries{
-				verifiedEmailExistsReturn: true,
+				getVerifiedUserByEmailReturn: queries.User{ID: ownerID},
+				verifiedEmailExistsReturn:    true,
 			},
 			newUser:                        defaultNewUser,
 			wantVerifiedEmailCheck:         defaultNewUser.Email,
 			wantDuplicateEmailNotification: defaultNewUser.Email,
-			wantTxRollback:                 true,
+			wantEnqueued:                   []string{"duplicate"},
+			wantTxCommit:                   true,
 		},
 		{
 			name: "duplicate user notification error",
@@ -255,7 +466,8 @@ func TestUserModel_Register(t *testing.T) {
 				duplicateRegistrationError: errors.New("notification failed"),
 			},
 			queries: MockUserQueries{
-				verifiedEmailExistsReturn: true,
+				getVerifiedUserByEmailReturn: queries.User{ID: ownerID},
+				verifiedEmailExistsReturn:    true,
 			},
 			newUser:                        defaultNewUser,
 			wantVerifiedEmailCheck:         defaultNewUser.Email,
@@ -275,11 +487,12 @@ func TestUserModel_Register(t *testing.T) {
 				PasswordHash: mockHashValue,
 			},
 			wantInsertedEmailVerificationKey: queries.InsertEmailVerificationKeyParams{
-				Email: defaultNewUser.Email,
-				Token: mockToken,
+				Email:     defaultNewUser.Email,
+				TokenHash: mockTokenHash,
 			},
 			wantNewEmailNotification: defaultNewUser.Email,
 			wantNewEmailToken:        mockToken,
+			wantEnqueued:             []string{"new"},
 			wantTxCommit:             true,
 		},
 	}
@@ -334,12 +547,24 @@ func TestUserModel_Register(t *testing.T) {
 				t.Errorf("User ID %v for email verification does not match inserted user %v", got, tt.queries.insertNewUserParams.ID)
 			}
 
+			// Emails are sent for the new user, or for the owner of an address that's already verified.
+			wantEnqueuedFor := tt.queries.insertNewUserParams.ID
+			if tt.queries.verifiedEmailExistsReturn {
+				wantEnqueuedFor = tt.queries.getVerifiedUserByEmailReturn.ID
+			}
+
+			for _, got := range tt.queries.enqueuedUsers() {
+				if got != wantEnqueuedFor {
+					t.Errorf("Expected email to be enqueued for user %v, got %v", wantEnqueuedFor, got)
+				}
+			}
+
 			if got := tt.queries.insertEmailVerificationParams; got.Email != tt.wantInsertedEmailVerificationKey.Email {
 				t.Errorf("Expected verification email %q, got %q", tt.wantInsertedEmailVerificationKey.Email, got.Email)
 			}
 
-			if got := tt.queries.insertEmailVerificationParams; got.Token != tt.wantInsertedEmailVerificationKey.Token {
-				t.Errorf("Expected verification token %q, got %q", tt.wantInsertedEmailVerificationKey.Token, got.Token)
+			if got := tt.queries.insertEmailVerificationParams; got.TokenHash != tt.wantInsertedEmailVerificationKey.TokenHash {
+				t.Errorf("Expected verification token hash %q, got %q", tt.wantInsertedEmailVerificationKey.TokenHash, got.TokenHash)
 			}
 
 			if got := tt.emailVerifier.duplicateRegistrationEmail; got != tt.wantDuplicateEmailNotification {
@@ -353,6 +578,723 @@ func TestUserModel_Register(t *testing.T) {
 			if got := tt.emailVerifier.newEmailToken; got != tt.wantNewEmailToken {
 				t.Errorf("Expected email verification token %q, got %q", tt.wantNewEmailToken, got)
 			}
+
+			if got := tt.queries.enqueuedSubjects(); !slices.Equal(got, tt.wantEnqueued) {
+				t.Errorf("Expected enqueued emails %v, got %v", tt.wantEnqueued, got)
+			}
+		})
+	}
+}
+
+func TestUserModel_Authenticate(t *testing.T) {
+	userID := uuid.New()
+
+	testCases := []struct {
+		name       string
+		hasher     ConstantHasher
+		queries    MockUserQueries
+		email      string
+		password   string
+		wantUserID uuid.UUID
+		wantRehash queries.UpdateUserPasswordParams
+		wantHashed bool
+		wantErr    error
+	}{
+		{
+			name: "valid credentials",
+			queries: MockUserQueries{
+				getVerifiedUserByEmailReturn: queries.User{ID: userID, PasswordHash: "tops3cret"},
+			},
+			email:      "test@example.com",
+			password:   "tops3cret",
+			wantUserID: userID,
+		},
+		{
+			name: "outdated hash is upgraded",
+			hasher: ConstantHasher{
+				NeedsRehashReturn: true,
+			},
+			queries: MockUserQueries{
+				getVerifiedUserByEmailReturn: queries.User{ID: userID, PasswordHash: "tops3cret"},
+			},
+			email:      "test@example.com",
+			password:   "tops3cret",
+			wantUserID: userID,
+			wantRehash: queries.UpdateUserPasswordParams{ID: userID, PasswordHash: mockHashValue},
+		},
+		{
+			name: "failed upgrade still authenticates",
+			hasher: ConstantHasher{
+				NeedsRehashReturn: true,
+			},
+			queries: MockUserQueries{
+				getVerifiedUserByEmailReturn: queries.User{ID: userID, PasswordHash: "tops3cret"},
+				updateUserPasswordError:      errors.New("update failed"),
+			},
+			email:      "test@example.com",
+			password:   "tops3cret",
+			wantUserID: userID,
+			wantRehash: queries.UpdateUserPasswordParams{ID: userID, PasswordHash: mockHashValue},
+		},
+		{
+			name: "wrong password is not upgraded",
+			hasher: ConstantHasher{
+				NeedsRehashReturn: true,
+			},
+			queries: MockUserQueries{
+				getVerifiedUserByEmailReturn: queries.User{ID: userID, PasswordHash: "tops3cret"},
+			},
+			email:    "test@example.com",
+			password: "wrong",
+			wantErr:  models.ErrInvalidCredentials,
+		},
+		{
+			name: "unknown email",
+			queries: MockUserQueries{
+				getVerifiedUserByEmailError: pgx.ErrNoRows,
+			},
+			email:      "test@example.com",
+			password:   "tops3cret",
+			wantHashed: true,
+			wantErr:    models.ErrInvalidCredentials,
+		},
+		{
+			name: "wrong password",
+			queries: MockUserQueries{
+				getVerifiedUserByEmailReturn: queries.User{ID: userID, PasswordHash: "tops3cret"},
+			},
+			email:    "test@example.com",
+			password: "wrong",
+			wantErr:  models.ErrInvalidCredentials,
+		},
+		{
+			name: "query error",
+			queries: MockUserQueries{
+				getVerifiedUserByEmailError: errors.New("query failed"),
+			},
+			email:    "test@example.com",
+			password: "tops3cret",
+			wantErr:  errAny,
+		},
+		{
+			name: "compare error",
+			hasher: ConstantHasher{
+				CompareError: errors.New("bad hash"),
+			},
+			queries: MockUserQueries{
+				getVerifiedUserByEmailReturn: queries.User{ID: userID, PasswordHash: "tops3cret"},
+			},
+			email:    "test@example.com",
+			password: "tops3cret",
+			wantErr:  errAny,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			users := models.NewUserModel(slog.New(slog.DiscardHandler), &MockEmailVerifier{}, &tt.hasher, &ConstantTokenGenerator{}, &MockDB{}, &tt.queries)
+
+			gotUserID, err := users.Authenticate(t.Context(), tt.email, tt.password)
+
+			assertError(t, tt.wantErr, err)
+
+			if gotUserID != tt.wantUserID {
+				t.Errorf("Expected user ID %v, got %v", tt.wantUserID, gotUserID)
+			}
+
+			if got := tt.queries.getVerifiedUserByEmailEmail; got != tt.email {
+				t.Errorf("Expected lookup of email %q, got %q", tt.email, got)
+			}
+
+			if got := tt.queries.updateUserPasswordParams; got != tt.wantRehash {
+				t.Errorf("Expected password hash update %#v, got %#v", tt.wantRehash, got)
+			}
+
+			if tt.wantHashed && !tt.hasher.compared {
+				t.Error("Expected the password to be checked against a hash")
+			}
+		})
+	}
+}
+
+func TestUserModel_VerifyEmail(t *testing.T) {
+	userID := uuid.New()
+	key := queries.EmailVerificationKey{UserID: userID, Email: "new@example.com", TokenHash: mockTokenHash}
+
+	testCases := []struct {
+		name            string
+		tx              MockTX
+		queries         MockUserQueries
+		wantVerified    queries.SetUserEmailVerifiedParams
+		wantKeysDeleted uuid.UUID
+		wantTxCommit    bool
+		wantErr         error
+	}{
+		{
+			name: "valid token",
+			queries: MockUserQueries{
+				getEmailVerificationKeyReturn: key,
+				getVerifiedUserByEmailError:   pgx.ErrNoRows,
+			},
+			wantVerified:    queries.SetUserEmailVerifiedParams{ID: userID, Email: key.Email},
+			wantKeysDeleted: userID,
+			wantTxCommit:    true,
+		},
+		{
+			name: "email already verified by same user",
+			queries: MockUserQueries{
+				getEmailVerificationKeyReturn: key,
+				getVerifiedUserByEmailReturn:  queries.User{ID: userID},
+			},
+			wantVerified:    queries.SetUserEmailVerifiedParams{ID: userID, Email: key.Email},
+			wantKeysDeleted: userID,
+			wantTxCommit:    true,
+		},
+		{
+			name: "unknown token",
+			queries: MockUserQueries{
+				getEmailVerificationKeyError: pgx.ErrNoRows,
+			},
+			wantErr: models.ErrInvalidToken,
+		},
+		{
+			name: "email taken by another user",
+			queries: MockUserQueries{
+				getEmailVerificationKeyReturn: key,
+				getVerifiedUserByEmailReturn:  queries.User{ID: uuid.New()},
+			},
+			wantErr: models.ErrEmailTaken,
+		},
+		{
+			name: "email verified concurrently by another user",
+			queries: MockUserQueries{
+				getEmailVerificationKeyReturn: key,
+				getVerifiedUserByEmailError:   pgx.ErrNoRows,
+				setUserEmailVerifiedError:     &pgconn.PgError{Code: "23505", ConstraintName: "users_email_verified_key"},
+			},
+			wantVerified: queries.SetUserEmailVerifiedParams{ID: userID, Email: key.Email},
+			wantErr:      models.ErrEmailTaken,
+		},
+		{
+			name: "update fails",
+			queries: MockUserQueries{
+				getEmailVerificationKeyReturn: key,
+				getVerifiedUserByEmailError:   pgx.ErrNoRows,
+				setUserEmailVerifiedError:     errors.New("update failed"),
+			},
+			wantVerified: queries.SetUserEmailVerifiedParams{ID: userID, Email: key.Email},
+			wantErr:      errAny,
+		},
+		{
+			name: "commit fails",
+			tx: MockTX{
+				commitError: errors.New("commit failed"),
+			},
+			queries: MockUserQueries{
+				getEmailVerificationKeyReturn: key,
+				getVerifiedUserByEmailError:   pgx.ErrNoRows,
+			},
+			wantVerified:    queries.SetUserEmailVerifiedParams{ID: userID, Email: key.Email},
+			wantKeysDeleted: userID,
+			wantErr:         errAny,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			db := MockDB{txFactory: func() models.Transaction { return &tt.tx }}
+			users := models.NewUserModel(slog.New(slog.DiscardHandler), &MockEmailVerifier{}, &ConstantHasher{}, &ConstantTokenGenerator{}, &db, &tt.queries)
+
+			err := users.VerifyEmail(t.Context(), mockToken)
+
+			assertError(t, tt.wantErr, err)
+
+			if got := tt.queries.getEmailVerificationKeyParams.TokenHash; got != mockTokenHash {
+				t.Errorf("Expected lookup of token hash %q, got %q", mockTokenHash, got)
+			}
+
+			if got := tt.queries.setUserEmailVerifiedParams; got != tt.wantVerified {
+				t.Errorf("Expected verified email %#v, got %#v", tt.wantVerified, got)
+			}
+
+			if got := tt.queries.deletedEmailVerificationKeysUser; got != tt.wantKeysDeleted {
+				t.Errorf("Expected keys deleted for user %v, got %v", tt.wantKeysDeleted, got)
+			}
+
+			if tt.wantTxCommit != tt.tx.committed {
+				t.Errorf("Expected tx.committed=%v, got %v", tt.wantTxCommit, tt.tx.committed)
+			}
+		})
+	}
+}
+
+func TestUserModel_ChangePassword(t *testing.T) {
+	userID := uuid.New()
+	wantSessionsDeleted := queries.DeleteOtherSessionsForUserParams{UserID: userID, KeepTokenHash: mockTokenHash}
+
+	testCases := []struct {
+		name                string
+		hasher              ConstantHasher
+		tx                  MockTX
+		queries             MockUserQueries
+		currentPassword     string
+		wantUpdate          queries.UpdateUserPasswordParams
+		wantSessionsDeleted queries.DeleteOtherSessionsForUserParams
+		wantTxCommit        bool
+		wantErr             error
+	}{
+		{
+			name: "correct current password",
+			queries: MockUserQueries{
+				getUserByIDReturn: queries.User{ID: userID, PasswordHash: "old"},
+			},
+			currentPassword:     "old",
+			wantUpdate:          queries.UpdateUserPasswordParams{ID: userID, PasswordHash: mockHashValue},
+			wantSessionsDeleted: wantSessionsDeleted,
+			wantTxCommit:        true,
+		},
+		{
+			name: "incorrect current password",
+			queries: MockUserQueries{
+				getUserByIDReturn: queries.User{ID: userID, PasswordHash: "old"},
+			},
+			currentPassword: "wrong",
+			wantErr:         models.ErrInvalidCredentials,
+		},
+		{
+			name: "hash error",
+			hasher: ConstantHasher{
+				HashError: errors.New("hash failed"),
+			},
+			queries: MockUserQueries{
+				getUserByIDReturn: queries.User{ID: userID, PasswordHash: "old"},
+			},
+			currentPassword: "old",
+			wantErr:         errAny,
+		},
+		{
+			name: "update error",
+			queries: MockUserQueries{
+				getUserByIDReturn:       queries.User{ID: userID, PasswordHash: "old"},
+				updateUserPasswordError: errors.New("update failed"),
+			},
+			currentPassword: "old",
+			wantUpdate:      queries.UpdateUserPasswordParams{ID: userID, PasswordHash: mockHashValue},
+			wantErr:         errAny,
+		},
+		{
+			name: "session deletion error",
+			queries: MockUserQueries{
+				getUserByIDReturn:        queries.User{ID: userID, PasswordHash: "old"},
+				deleteOtherSessionsError: errors.New("delete failed"),
+			},
+			currentPassword:     "old",
+			wantUpdate:          queries.UpdateUserPasswordParams{ID: userID, PasswordHash: mockHashValue},
+			wantSessionsDeleted: wantSessionsDeleted,
+			wantErr:             errAny,
+		},
+		{
+			name: "commit error",
+			tx: MockTX{
+				commitError: errors.New("commit failed"),
+			},
+			queries: MockUserQueries{
+				getUserByIDReturn: queries.User{ID: userID, PasswordHash: "old"},
+			},
+			currentPassword:     "old",
+			wantUpdate:          queries.UpdateUserPasswordParams{ID: userID, PasswordHash: mockHashValue},
+			wantSessionsDeleted: wantSessionsDeleted,
+			wantErr:             errAny,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			db := MockDB{txFactory: func() models.Transaction { return &tt.tx }}
+			users := models.NewUserModel(slog.New(slog.DiscardHandler), &MockEmailVerifier{}, &tt.hasher, &ConstantTokenGenerator{}, &db, &tt.queries)
+
+			err := users.ChangePassword(t.Context(), userID, mockToken, tt.currentPassword, "n3w-password")
+
+			assertError(t, tt.wantErr, err)
+
+			if got := tt.queries.getUserByIDID; got != userID {
+				t.Errorf("Expected lookup of user %v, got %v", userID, got)
+			}
+
+			if got := tt.queries.updateUserPasswordParams; got != tt.wantUpdate {
+				t.Errorf("Expected password update %#v, got %#v", tt.wantUpdate, got)
+			}
+
+			if got := tt.queries.deleteOtherSessionsParams; got != tt.wantSessionsDeleted {
+				t.Errorf("Expected other sessions deleted with %#v, got %#v", tt.wantSessionsDeleted, got)
+			}
+
+			if tt.tx.committed != tt.wantTxCommit {
+				t.Errorf("Expected transaction commit=%v, got %v", tt.wantTxCommit, tt.tx.committed)
+			}
+		})
+	}
+}
+
+func TestUserModel_ChangeEmail(t *testing.T) {
+	userID := uuid.New()
+	currentUser := queries.User{ID: userID, Email: "old@example.com"}
+	newEmail := "new@example.com"
+	ownerID := uuid.New()
+
+	testCases := []struct {
+		name               string
+		emailVerifier      MockEmailVerifier
+		tx                 MockTX
+		queries            MockUserQueries
+		email              string
+		wantInsertedKey    queries.InsertEmailVerificationKeyParams
+		wantChangeEmail    string
+		wantDuplicateEmail string
+		wantEnqueued       []string
+		wantEnqueuedFor    uuid.UUID
+		wantTxCommit       bool
+		wantErr            error
+	}{
+		{
+			name:            "new address",
+			queries:         MockUserQueries{getUserByIDReturn: currentUser},
+			email:           newEmail,
+			wantInsertedKey: queries.InsertEmailVerificationKeyParams{UserID: userID, Email: newEmail, TokenHash: mockTokenHash},
+			wantChangeEmail: newEmail,
+			wantEnqueued:    []string{"change"},
+			wantTxCommit:    true,
+		},
+		{
+			name:    "unchanged address",
+			queries: MockUserQueries{getUserByIDReturn: currentUser},
+			email:   currentUser.Email,
+		},
+		{
+			name: "address in use",
+			queries: MockUserQueries{
+				getUserByIDReturn:            currentUser,
+				getVerifiedUserByEmailReturn: queries.User{ID: ownerID},
+				verifiedEmailExistsReturn:    true,
+			},
+			email:              newEmail,
+			wantDuplicateEmail: newEmail,
+			wantEnqueued:       []string{"duplicate"},
+			wantEnqueuedFor:    ownerID,
+			wantTxCommit:       true,
+		},
+		{
+			name: "enqueue fails",
+			queries: MockUserQueries{
+				getUserByIDReturn:      currentUser,
+				insertOutboxEmailError: errInsert,
+			},
+			email:           newEmail,
+			wantInsertedKey: queries.InsertEmailVerificationKeyParams{UserID: userID, Email: newEmail, TokenHash: mockTokenHash},
+			wantChangeEmail: newEmail,
+			wantEnqueued:    []string{"change"},
+			wantErr:         errAny,
+		},
+		{
+			name: "notification fails",
+			emailVerifier: MockEmailVerifier{
+				changeEmailError: errors.New("send failed"),
+			},
+			queries:         MockUserQueries{getUserByIDReturn: currentUser},
+			email:           newEmail,
+			wantInsertedKey: queries.InsertEmailVerificationKeyParams{UserID: userID, Email: newEmail, TokenHash: mockTokenHash},
+			wantChangeEmail: newEmail,
+			wantErr:         errAny,
+		},
+		{
+			name: "key insert fails",
+			queries: MockUserQueries{
+				getUserByIDReturn:               currentUser,
+				insertEmailVerificationKeyError: errors.New("insert failed"),
+			},
+			email:           newEmail,
+			wantInsertedKey: queries.InsertEmailVerificationKeyParams{UserID: userID, Email: newEmail, TokenHash: mockTokenHash},
+			wantErr:         errAny,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			db := MockDB{txFactory: func() models.Transaction { return &tt.tx }}
+			users := models.NewUserModel(slog.New(slog.DiscardHandler), &tt.emailVerifier, &ConstantHasher{}, &ConstantTokenGenerator{token: mockToken}, &db, &tt.queries)
+
+			err := users.ChangeEmail(t.Context(), userID, tt.email)
+
+			assertError(t, tt.wantErr, err)
+
+			if got := tt.queries.insertEmailVerificationParams; got != tt.wantInsertedKey {
+				t.Errorf("Expected inserted key %#v, got %#v", tt.wantInsertedKey, got)
+			}
+
+			if got := tt.emailVerifier.changeEmailEmail; got != tt.wantChangeEmail {
+				t.Errorf("Expected change email notification to %q, got %q", tt.wantChangeEmail, got)
+			}
+
+			if got := tt.emailVerifier.duplicateRegistrationEmail; got != tt.wantDuplicateEmail {
+				t.Errorf("Expected duplicate notification to %q, got %q", tt.wantDuplicateEmail, got)
+			}
+
+			if got := tt.queries.enqueuedSubjects(); !slices.Equal(got, tt.wantEnqueued) {
+				t.Errorf("Expected enqueued emails %v, got %v", tt.wantEnqueued, got)
+			}
+
+			wantEnqueuedFor := tt.wantEnqueuedFor
+			if wantEnqueuedFor == uuid.Nil {
+				wantEnqueuedFor = userID
+			}
+
+			for _, got := range tt.queries.enqueuedUsers() {
+				if got != wantEnqueuedFor {
+					t.Errorf("Expected email to be enqueued for user %v, got %v", wantEnqueuedFor, got)
+				}
+			}
+
+			if tt.wantTxCommit != tt.tx.committed {
+				t.Errorf("Expected tx.committed=%v, got %v", tt.wantTxCommit, tt.tx.committed)
+			}
 		})
 	}
 }
+
+func TestUserModel_ExportData(t *testing.T) {
+	userID := uuid.New()
+	created := time.Date(2025, time.December, 1, 12, 0, 0, 0, time.UTC)
+	timestamp := pgtype.Timestamptz{Time: created, Valid: true}
+	emailID := uuid.New()
+	queuedID := uuid.New()
+
+	testCases := []struct {
+		name     string
+		queries  MockUserQueries
+		wantData models.UserData
+		wantErr  error
+	}{
+		{
+			name: "full export",
+			queries: MockUserQueries{
+				getUserByIDReturn: queries.User{
+					ID:            userID,
+					Email:         "test@example.com",
+					EmailVerified: true,
+					PasswordHash:  "secret-hash",
+					CreatedAt:     timestamp,
+					UpdatedAt:     timestamp,
+				},
+				listEmailVerificationKeysReturn: []queries.EmailVerificationKey{
+					{UserID: userID, Email: "new@example.com", TokenHash: "secret-token-hash", CreatedAt: timestamp},
+				},
+				listSessionsReturn: []queries.Session{
+					{TokenHash: "secret-session-hash", UserID: userID, CreatedAt: timestamp, ExpiresAt: timestamp},
+				},
+				listOutboxEmailsReturn: []queries.ListOutboxEmailsForUserRow{
+					{ID: emailID, Recipient: "test@example.com", Subject: "Verify Your Email", Attempts: 1, SentAt: timestamp, CreatedAt: timestamp},
+					{ID: queuedID, Recipient: "new@example.com", Subject: "Verify Your New Email", CreatedAt: timestamp},
+				},
+				getUserTOTPStatusReturn:        queries.GetUserTOTPStatusRow{ConfirmedAt: timestamp, CreatedAt: timestamp},
+				countUnusedRecoveryCodesReturn: 7,
+			},
+			wantData: models.UserData{
+				User: models.UserDataAccount{
+					ID:            userID,
+					Email:         "test@example.com",
+					EmailVerified: true,
+					CreatedAt:     created,
+					UpdatedAt:     created,
+				},
+				PendingEmailVerifications: []models.UserDataPendingEmail{{Email: "new@example.com", CreatedAt: created}},
+				Sessions:                  []models.UserDataSession{{CreatedAt: created, ExpiresAt: created}},
+				Emails: []models.UserDataEmail{
+					{ID: emailID, Recipient: "test@example.com", Subject: "Verify Your Email", Attempts: 1, CreatedAt: created, SentAt: &created},
+					{ID: queuedID, Recipient: "new@example.com", Subject: "Verify Your New Email", CreatedAt: created},
+				},
+				TwoFactor: models.UserDataTwoFactor{Enabled: true, CreatedAt: &created, RecoveryCodesRemaining: 7},
+			},
+		},
+		{
+			name: "two-factor enrollment started",
+			queries: MockUserQueries{
+				getUserByIDReturn:       queries.User{ID: userID, CreatedAt: timestamp, UpdatedAt: timestamp},
+				getUserTOTPStatusReturn: queries.GetUserTOTPStatusRow{CreatedAt: timestamp},
+			},
+			wantData: models.UserData{
+				User:                      models.UserDataAccount{ID: userID, CreatedAt: created, UpdatedAt: created},
+				PendingEmailVerifications: []models.UserDataPendingEmail{},
+				Sessions:                  []models.UserDataSession{},
+				Emails:                    []models.UserDataEmail{},
+				TwoFactor:                 models.UserDataTwoFactor{CreatedAt: &created},
+			},
+		},
+		{
+			name: "two-factor never set up",
+			queries: MockUserQueries{
+				getUserByIDReturn:      queries.User{ID: userID, CreatedAt: timestamp, UpdatedAt: timestamp},
+				getUserTOTPStatusError: pgx.ErrNoRows,
+			},
+			wantData: models.UserData{
+				User:                      models.UserDataAccount{ID: userID, CreatedAt: created, UpdatedAt: created},
+				PendingEmailVerifications: []models.UserDataPendingEmail{},
+				Sessions:                  []models.UserDataSession{},
+				Emails:                    []models.UserDataEmail{},
+			},
+		},
+		{
+			name: "user lookup error",
+			queries: MockUserQueries{
+				getUserByIDError: errors.New("query failed"),
+			},
+			wantErr: errAny,
+		},
+		{
+			name: "session lookup error",
+			queries: MockUserQueries{
+				listSessionsError: errors.New("query failed"),
+			},
+			wantErr: errAny,
+		},
+		{
+			name: "email lookup error",
+			queries: MockUserQueries{
+				listOutboxEmailsError: errors.New("query failed"),
+			},
+			wantErr: errAny,
+		},
+		{
+			name: "two-factor lookup error",
+			queries: MockUserQueries{
+				getUserTOTPStatusError: errors.New("query failed"),
+			},
+			wantErr: errAny,
+		},
+		{
+			name: "recovery code count error",
+			queries: MockUserQueries{
+				countUnusedRecoveryCodesError: errors.New("query failed"),
+			},
+			wantErr: errAny,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			users := models.NewUserModel(slog.New(slog.DiscardHandler), &MockEmailVerifier{}, &ConstantHasher{}, &ConstantTokenGenerator{}, &MockDB{}, &tt.queries)
+
+			data, err := users.ExportData(t.Context(), userID)
+
+			assertError(t, tt.wantErr, err)
+
+			if tt.wantErr == nil && !reflect.DeepEqual(data, tt.wantData) {
+				t.Errorf("Expected data %#v, got %#v", tt.wantData, data)
+			}
+		})
+	}
+}
+
+func TestUserModel_Delete(t *testing.T) {
+	userID := uuid.New()
+
+	testCases := []struct {
+		name              string
+		queries           MockUserQueries
+		tx                MockTX
+		password          string
+		wantEmailsDeleted uuid.UUID
+		wantDeleted       uuid.UUID
+		wantTxCommit      bool
+		wantErr           error
+	}{
+		{
+			name: "correct password",
+			queries: MockUserQueries{
+				getUserByIDReturn: queries.User{ID: userID, PasswordHash: "tops3cret"},
+			},
+			password:          "tops3cret",
+			wantEmailsDeleted: userID,
+			wantDeleted:       userID,
+			wantTxCommit:      true,
+		},
+		{
+			name: "incorrect password",
+			queries: MockUserQueries{
+				getUserByIDReturn: queries.User{ID: userID, PasswordHash: "tops3cret"},
+			},
+			password: "wrong",
+			wantErr:  models.ErrInvalidCredentials,
+		},
+		{
+			name: "delete emails error",
+			queries: MockUserQueries{
+				getUserByIDReturn:       queries.User{ID: userID, PasswordHash: "tops3cret"},
+				deleteOutboxEmailsError: errors.New("delete failed"),
+			},
+			password:          "tops3cret",
+			wantEmailsDeleted: userID,
+			wantErr:           errAny,
+		},
+		{
+			name: "delete error",
+			queries: MockUserQueries{
+				getUserByIDReturn: queries.User{ID: userID, PasswordHash: "tops3cret"},
+				deleteUserError:   errors.New("delete failed"),
+			},
+			password:          "tops3cret",
+			wantEmailsDeleted: userID,
+			wantDeleted:       userID,
+			wantErr:           errAny,
+		},
+		{
+			name: "commit error",
+			queries: MockUserQueries{
+				getUserByIDReturn: queries.User{ID: userID, PasswordHash: "tops3cret"},
+			},
+			tx:                MockTX{commitError: errors.New("commit failed")},
+			password:          "tops3cret",
+			wantEmailsDeleted: userID,
+			wantDeleted:       userID,
+			wantErr:           errAny,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			db := MockDB{txFactory: func() models.Transaction { return &tt.tx }}
+			users := models.NewUserModel(slog.New(slog.DiscardHandler), &MockEmailVerifier{}, &ConstantHasher{}, &ConstantTokenGenerator{}, &db, &tt.queries)
+
+			err := users.Delete(t.Context(), userID, tt.password)
+
+			assertError(t, tt.wantErr, err)
+
+			if got := tt.queries.deletedOutboxEmailsUser; got != tt.wantEmailsDeleted {
+				t.Errorf("Expected emails for user %v to be deleted, got %v", tt.wantEmailsDeleted, got)
+			}
+
+			if got := tt.queries.deletedUserID; got != tt.wantDeleted {
+				t.Errorf("Expected user %v to be deleted, got %v", tt.wantDeleted, got)
+			}
+
+			if tt.tx.committed != tt.wantTxCommit {
+				t.Errorf("Expected tx.committed=%v, got %v", tt.wantTxCommit, tt.tx.committed)
+			}
+		})
+	}
+}
+
+// errAny can be used as an expected error when the specific error does not matter.
+var errAny = errors.New("any error")
+
+func assertError(t *testing.T, want error, got error) {
+	t.Helper()
+
+	switch {
+	case want == nil && got != nil:
+		t.Fatalf("Unexpected error: %v", got)
+	case want != nil && got == nil:
+		t.Fatalf("Expected error %v, got nil", want)
+	case want != nil && want != errAny && !errors.Is(got, want):
+		t.Fatalf("Expected error %v, got %v", want, got)
+	}
+}
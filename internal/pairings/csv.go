@@ -0,0 +1,31 @@
+package pairings
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+// WriteCSV writes the pairings as CSV with a header row naming the giver and recipient columns.
+// Names are escaped so spreadsheet apps don't run them as formulas.
+func WriteCSV(w io.Writer, pairs []Pairing) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"giver", "recipient"})
+	for _, pair := range pairs {
+		writer.Write([]string{SpreadsheetSafe(pair.From), SpreadsheetSafe(pair.To)})
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// SpreadsheetSafe keeps spreadsheet apps from running a CSV value as a formula. Values that start
+// with a character that begins a formula are prefixed with a quote, which makes the cell plain text.
+func SpreadsheetSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+
+	return value
+}
@@ -0,0 +1,26 @@
+package pairings_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cdriehuys/secret-santa/internal/pairings"
+)
+
+func TestWriteCSV(t *testing.T) {
+	pairs := []pairings.Pairing{
+		{From: "Ross", To: "=HYPERLINK(\"http://evil.example\")"},
+		{From: "+Monica", To: "-Joey"},
+		{From: "@Chandler", To: "Ross"},
+	}
+
+	var buf bytes.Buffer
+	if err := pairings.WriteCSV(&buf, pairs); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := "giver,recipient\nRoss,\"'=HYPERLINK(\"\"http://evil.example\"\")\"\n'+Monica,'-Joey\n'@Chandler,Ross\n"
+	if buf.String() != want {
+		t.Errorf("Expected output:\n%s\nGot:\n%s", want, buf.String())
+	}
+}
@@ -1,8 +1,10 @@
 package pairings
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"slices"
 )
 
@@ -11,8 +13,18 @@ var (
 
 	ErrTooFewNodes = fmt.Errorf("%w: graph must contain at least two nodes", ErrNotSolvable)
 	ErrNoPath      = fmt.Errorf("%w: could not find a path that includes every node", ErrNotSolvable)
+
+	// ErrSearchExhausted means the search gave up before finding pairings or proving that there
+	// are none. Another random source may still find pairings.
+	ErrSearchExhausted = errors.New("gave up searching for pairings")
 )
 
+// DefaultMaxSteps bounds the search for pairings when no other limit is given. Each step tries one
+// recipient for one gifter. A step takes a few microseconds in a graph of a hundred nodes, so the
+// search gives up in well under a second. Graphs that have pairings usually need little more than
+// one step per node.
+const DefaultMaxSteps = 100_000
+
 type Graph struct {
 	nodes map[string]map[string]struct{}
 }
@@ -49,14 +61,41 @@ type Random interface {
 // Pairings generates a random list of pairings such that every node in the graph is both a gifter
 // and recipient, and a pairing is only created if there is an edge between the gifter and the
 // recipient.
-func (g *Graph) Pairings(rand Random) ([]Pairing, error) {
+//
+// Finding pairings is a search for a cycle through every node, which can take exponential time.
+// Graphs where some node can't give or can't receive are rejected with ErrNoPath up front. Otherwise
+// the search takes at most maxSteps steps, or DefaultMaxSteps if maxSteps isn't positive, before it
+// gives up with ErrSearchExhausted. It also stops with the context's error if the context is done.
+func (g *Graph) Pairings(ctx context.Context, rand Random, maxSteps int) ([]Pairing, error) {
 	if len(g.nodes) < 2 {
 		return nil, ErrTooFewNodes
 	}
 
-	var search func(start string, currentPerson string, visited map[string]struct{}) ([]Pairing, error)
+	if err := g.checkDegrees(); err != nil {
+		return nil, err
+	}
+
+	if maxSteps <= 0 {
+		maxSteps = DefaultMaxSteps
+	}
+
+	steps := 0
+	visited := make(map[string]struct{}, len(g.nodes))
+
+	var search func(start string, currentPerson string) ([]Pairing, error)
+	search = func(start string, currentPerson string) ([]Pairing, error) {
+		steps++
+		if steps > maxSteps {
+			return nil, ErrSearchExhausted
+		}
+
+		// Checking the context on every step would dominate the cost of small steps.
+		if steps%1024 == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
 
-	search = func(start string, currentPerson string, visited map[string]struct{}) ([]Pairing, error) {
 		nextCandidates := g.nextCandidates(currentPerson, start, visited)
 		shuffle(nextCandidates, rand)
 
@@ -71,9 +110,10 @@ func (g *Graph) Pairings(rand Random) ([]Pairing, error) {
 		}
 
 		for _, nextPerson := range nextCandidates {
-			nextVisited := copyVisitedWithAddition(visited, nextPerson)
+			visited[nextPerson] = struct{}{}
+			nextSolution, err := search(start, nextPerson)
+			delete(visited, nextPerson)
 
-			nextSolution, err := search(start, nextPerson, nextVisited)
 			if err == nil {
 				solution := make([]Pairing, len(nextSolution)+1)
 				solution[0] = Pairing{From: currentPerson, To: nextPerson}
@@ -83,27 +123,49 @@ func (g *Graph) Pairings(rand Random) ([]Pairing, error) {
 
 				return solution, nil
 			}
+
+			if !errors.Is(err, ErrNoPath) {
+				return nil, err
+			}
 		}
 
 		// Every possible path from the current person has been tried, so this solution is invalid.
 		return nil, ErrNoPath
 	}
 
-	allNodes := make([]string, 0, len(g.nodes))
-	for k := range g.nodes {
-		allNodes = append(allNodes, k)
-	}
-
+	// Sorting before shuffling makes the result depend only on the random source rather than on map
+	// iteration order, so a draw can be reproduced from its seed.
+	allNodes := slices.Sorted(maps.Keys(g.nodes))
 	shuffle(allNodes, rand)
 
-	for _, topLevelStart := range allNodes {
-		solution, err := search(topLevelStart, topLevelStart, map[string]struct{}{})
-		if err == nil {
-			return solution, nil
+	// A cycle through every node passes through the first one, so there is no need to search from
+	// any other starting node if this one fails.
+	return search(allNodes[0], allNodes[0])
+}
+
+// checkDegrees returns ErrNoPath if a node has no one to give to or no one to receive from, since
+// no cycle can pass through it. This catches the most common impossible draws without a search.
+func (g *Graph) checkDegrees() error {
+	nodes := slices.Sorted(maps.Keys(g.nodes))
+
+	received := make(map[string]struct{}, len(g.nodes))
+	for _, node := range nodes {
+		if len(g.nodes[node]) == 0 {
+			return fmt.Errorf("%w: %q can't give to anyone", ErrNoPath, node)
+		}
+
+		for recipient := range g.nodes[node] {
+			received[recipient] = struct{}{}
+		}
+	}
+
+	for _, node := range nodes {
+		if _, ok := received[node]; !ok {
+			return fmt.Errorf("%w: no one can give to %q", ErrNoPath, node)
 		}
 	}
 
-	return nil, ErrNoPath
+	return nil
 }
 
 func (g *Graph) nextCandidates(gifter string, start string, visited map[string]struct{}) []string {
@@ -118,6 +180,8 @@ func (g *Graph) nextCandidates(gifter string, start string, visited map[string]s
 		}
 	}
 
+	slices.Sort(candidates)
+
 	return candidates
 }
 
@@ -126,14 +190,3 @@ func shuffle(list []string, rand Random) {
 		list[i], list[j] = list[j], list[i]
 	})
 }
-
-func copyVisitedWithAddition(visited map[string]struct{}, addition string) map[string]struct{} {
-	copy := make(map[string]struct{}, len(visited)+1)
-	for v, s := range visited {
-		copy[v] = s
-	}
-
-	copy[addition] = struct{}{}
-
-	return copy
-}
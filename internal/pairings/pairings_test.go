@@ -1,11 +1,13 @@
 package pairings_test
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math/rand"
 	"slices"
 	"testing"
+	"time"
 
 	"github.com/cdriehuys/secret-santa/internal/pairings"
 )
@@ -45,7 +47,7 @@ func TestGraph_Pairings_visitsAll(t *testing.T) {
 	for _, tt := range testCases {
 		t.Run(tt.name, func(t *testing.T) {
 			graph := pairings.NewGraphFromExclusions(tt.nodes)
-			pairs, err := graph.Pairings(fixedRandom())
+			pairs, err := graph.Pairings(context.Background(), fixedRandom(), 0)
 
 			if err != nil {
 				t.Fatalf("Unable to generate pairings: %v", err)
@@ -110,12 +112,42 @@ func TestGraph_Pairings_errorCases(t *testing.T) {
 			},
 			wantError: pairings.ErrNoPath,
 		},
+		{
+			name: "node with no recipients",
+			nodes: map[string][]string{
+				"Percy":  {"Edward", "Bella"},
+				"Edward": nil,
+				"Bella":  nil,
+			},
+			wantError: pairings.ErrNoPath,
+		},
+		{
+			name: "node with no givers",
+			nodes: map[string][]string{
+				"Percy":  {"Jacob"},
+				"Edward": {"Jacob"},
+				"Bella":  {"Jacob"},
+				"Jacob":  nil,
+			},
+			wantError: pairings.ErrNoPath,
+		},
+		{
+			name: "no cycle despite edges",
+			nodes: map[string][]string{
+				"A": {"C", "D"},
+				"B": {"C", "D"},
+				"C": {"A", "B"},
+				"D": {"A", "B"},
+				"E": nil,
+			},
+			wantError: pairings.ErrNoPath,
+		},
 	}
 
 	for _, tt := range testCases {
 		t.Run(tt.name, func(t *testing.T) {
 			graph := pairings.NewGraphFromExclusions(tt.nodes)
-			_, err := graph.Pairings(fixedRandom())
+			_, err := graph.Pairings(context.Background(), fixedRandom(), 0)
 
 			if err == nil {
 				t.Error("No error was returned")
@@ -128,6 +160,83 @@ func TestGraph_Pairings_errorCases(t *testing.T) {
 	}
 }
 
+func TestGraph_Pairings_reproducible(t *testing.T) {
+	nodes := make(map[string][]string)
+	for i := range 10 {
+		nodes[fmt.Sprintf("N%d", i)] = nil
+	}
+
+	first, err := pairings.NewGraphFromExclusions(nodes).Pairings(context.Background(), fixedRandom(), 0)
+	if err != nil {
+		t.Fatalf("Pairings() failed: %v", err)
+	}
+
+	// Map iteration order differs between graphs, so repeat a few times to make it likely that a
+	// dependency on it would be caught.
+	for range 10 {
+		got, err := pairings.NewGraphFromExclusions(nodes).Pairings(context.Background(), fixedRandom(), 0)
+		if err != nil {
+			t.Fatalf("Pairings() failed: %v", err)
+		}
+
+		if !slices.Equal(got, first) {
+			t.Fatalf("Expected the same seed to produce %v, got %v", first, got)
+		}
+	}
+}
+
+// impossibleGraph returns a graph that passes the up front checks but has no solution. Half the
+// nodes, the "left" nodes, may only give to the other half, and every right node may give to
+// anyone. There are more left nodes than right nodes, so some left node must give to another one.
+// Proving that takes an exponential search.
+func impossibleGraph(size int) *pairings.Graph {
+	nodes := make(map[string][]string)
+	var left []string
+	for i := range size/2 + 1 {
+		left = append(left, fmt.Sprintf("L%d", i))
+	}
+
+	for _, node := range left {
+		nodes[node] = left
+	}
+
+	for i := range size - len(left) {
+		nodes[fmt.Sprintf("R%d", i)] = nil
+	}
+
+	return pairings.NewGraphFromExclusions(nodes)
+}
+
+func TestGraph_Pairings_searchExhausted(t *testing.T) {
+	_, err := impossibleGraph(40).Pairings(context.Background(), fixedRandom(), 1000)
+	if !errors.Is(err, pairings.ErrSearchExhausted) {
+		t.Errorf("Expected error %v, got %v", pairings.ErrSearchExhausted, err)
+	}
+}
+
+func TestGraph_Pairings_canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := impossibleGraph(40).Pairings(ctx, fixedRandom(), 1_000_000)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected error %v, got %v", context.Canceled, err)
+	}
+}
+
+func TestGraph_Pairings_defaultBudget(t *testing.T) {
+	start := time.Now()
+
+	_, err := impossibleGraph(100).Pairings(context.Background(), fixedRandom(), 0)
+	if !errors.Is(err, pairings.ErrSearchExhausted) {
+		t.Errorf("Expected error %v, got %v", pairings.ErrSearchExhausted, err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the default budget to give up quickly, took %v", elapsed)
+	}
+}
+
 func BenchmarkGraph_Pairings_NoExclusions(b *testing.B) {
 	nodes := make(map[string][]string)
 	for i := range 10 {
@@ -138,7 +247,7 @@ func BenchmarkGraph_Pairings_NoExclusions(b *testing.B) {
 	rand := fixedRandom()
 
 	for b.Loop() {
-		graph.Pairings(rand)
+		graph.Pairings(context.Background(), rand, 0)
 	}
 }
 
@@ -155,7 +264,7 @@ func BenchmarkGraph_Pairings_PartneredExclusions(b *testing.B) {
 	rand := fixedRandom()
 
 	for b.Loop() {
-		graph.Pairings(rand)
+		graph.Pairings(context.Background(), rand, 0)
 	}
 }
 
@@ -0,0 +1,120 @@
+// Package ratelimit implements token bucket rate limiting with pluggable storage for the buckets.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit describes a token bucket. The bucket starts full with Burst tokens, each request takes one
+// token, and one token is added back every Every until the bucket is full again.
+type Limit struct {
+	Burst int
+	Every time.Duration
+}
+
+// Enabled reports whether the limit restricts anything. The zero value is disabled.
+func (l Limit) Enabled() bool {
+	return l.Burst > 0 && l.Every > 0
+}
+
+// Store tracks token buckets by key.
+type Store interface {
+	// Allow takes a token from the bucket for key if one is available. If not, it returns false and
+	// how long until a token will be available.
+	Allow(ctx context.Context, key string, limit Limit) (allowed bool, retryAfter time.Duration, err error)
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+
+	// limit is the limit the bucket was last used with, so it can be refilled by cleanup.
+	limit Limit
+}
+
+// MemoryStore keeps buckets in memory. It is only suitable when a single process serves all
+// requests.
+type MemoryStore struct {
+	// Now returns the current time. If nil, time.Now is used.
+	Now func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	calls   int
+}
+
+// cleanupInterval is how many calls to Allow happen between sweeps for full buckets.
+const cleanupInterval = 1000
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket)}
+}
+
+func (s *MemoryStore) Allow(_ context.Context, key string, limit Limit) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+
+	if s.buckets == nil {
+		s.buckets = make(map[string]*bucket)
+	}
+
+	s.calls++
+	if s.calls%cleanupInterval == 0 {
+		s.cleanup(now)
+	}
+
+	b, exists := s.buckets[key]
+	if !exists {
+		b = &bucket{tokens: float64(limit.Burst), last: now, limit: limit}
+		s.buckets[key] = b
+	}
+
+	b.limit = limit
+	refill(b, now)
+
+	if b.tokens < 1 {
+		missing := 1 - b.tokens
+		retryAfter := time.Duration(math.Ceil(missing * float64(limit.Every)))
+
+		return false, retryAfter, nil
+	}
+
+	b.tokens--
+
+	return true, 0, nil
+}
+
+// cleanup removes buckets that have refilled completely since they are equivalent to a new bucket.
+// Each bucket is refilled at the rate of its own limit, so one store can be shared by several
+// limits.
+func (s *MemoryStore) cleanup(now time.Time) {
+	for key, b := range s.buckets {
+		refill(b, now)
+		if b.tokens >= float64(b.limit.Burst) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func (s *MemoryStore) now() time.Time {
+	if s.Now == nil {
+		return time.Now()
+	}
+
+	return s.Now()
+}
+
+func refill(b *bucket, now time.Time) {
+	elapsed := now.Sub(b.last)
+	if elapsed <= 0 {
+		return
+	}
+
+	b.tokens = math.Min(float64(b.limit.Burst), b.tokens+elapsed.Seconds()/b.limit.Every.Seconds())
+	b.last = now
+}
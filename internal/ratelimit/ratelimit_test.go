@@ -0,0 +1,154 @@
+package ratelimit_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cdriehuys/secret-santa/internal/ratelimit"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestMemoryStore_Allow(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, time.December, 1, 0, 0, 0, 0, time.UTC)}
+	store := ratelimit.NewMemoryStore()
+	store.Now = clock.Now
+
+	limit := ratelimit.Limit{Burst: 2, Every: time.Minute}
+
+	allow := func(key string) (bool, time.Duration) {
+		allowed, retryAfter, err := store.Allow(t.Context(), key, limit)
+		if err != nil {
+			t.Fatalf("Allow returned an error: %v", err)
+		}
+
+		return allowed, retryAfter
+	}
+
+	for i := range limit.Burst {
+		if allowed, _ := allow("a"); !allowed {
+			t.Fatalf("Expected request %d to be allowed within burst", i)
+		}
+	}
+
+	allowed, retryAfter := allow("a")
+	if allowed {
+		t.Fatal("Expected request beyond burst to be rejected")
+	}
+
+	if retryAfter != time.Minute {
+		t.Errorf("Expected retry after %v, got %v", time.Minute, retryAfter)
+	}
+
+	if allowed, _ := allow("b"); !allowed {
+		t.Error("Expected a different key to have its own bucket")
+	}
+
+	clock.Advance(30 * time.Second)
+	if allowed, retryAfter := allow("a"); allowed || retryAfter != 30*time.Second {
+		t.Errorf("Expected rejection with 30s retry after a partial refill, got allowed=%v retryAfter=%v", allowed, retryAfter)
+	}
+
+	clock.Advance(30 * time.Second)
+	if allowed, _ := allow("a"); !allowed {
+		t.Error("Expected request to be allowed after a token refilled")
+	}
+
+	clock.Advance(time.Hour)
+	for i := range limit.Burst {
+		if allowed, _ := allow("a"); !allowed {
+			t.Errorf("Expected request %d to be allowed after the bucket refilled", i)
+		}
+	}
+
+	if allowed, _ := allow("a"); allowed {
+		t.Error("Expected refilled bucket to be capped at the burst size")
+	}
+}
+
+func TestMemoryStore_Allow_mixedLimits(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, time.December, 1, 0, 0, 0, 0, time.UTC)}
+	store := ratelimit.NewMemoryStore()
+	store.Now = clock.Now
+
+	slow := ratelimit.Limit{Burst: 3, Every: time.Hour}
+	fast := ratelimit.Limit{Burst: 20, Every: time.Second}
+
+	calls := 0
+	allow := func(key string, limit ratelimit.Limit) bool {
+		calls++
+
+		allowed, _, err := store.Allow(t.Context(), key, limit)
+		if err != nil {
+			t.Fatalf("Allow returned an error: %v", err)
+		}
+
+		return allowed
+	}
+
+	for range slow.Burst {
+		allow("slow", slow)
+	}
+
+	// Enough time for the fast limit to refill a bucket, but not the slow one.
+	clock.Advance(time.Minute)
+
+	// Requests under the fast limit trigger a sweep for full buckets.
+	for i := 0; calls < 1000; i++ {
+		allow(fmt.Sprintf("fast-%d", i), fast)
+	}
+
+	if allow("slow", slow) {
+		t.Error("Expected the slow bucket to survive a sweep triggered under a faster limit")
+	}
+}
+
+func TestMemoryStore_Allow_concurrent(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	store.Now = func() time.Time { return time.Unix(0, 0) }
+
+	limit := ratelimit.Limit{Burst: 10, Every: time.Hour}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowedCount := 0
+
+	for range 50 {
+		wg.Go(func() {
+			allowed, _, _ := store.Allow(t.Context(), "key", limit)
+			if allowed {
+				mu.Lock()
+				allowedCount++
+				mu.Unlock()
+			}
+		})
+	}
+
+	wg.Wait()
+
+	if allowedCount != limit.Burst {
+		t.Errorf("Expected exactly %d requests to be allowed, got %d", limit.Burst, allowedCount)
+	}
+}
+
+func TestLimit_Enabled(t *testing.T) {
+	if (ratelimit.Limit{}).Enabled() {
+		t.Error("Expected zero limit to be disabled")
+	}
+
+	if !(ratelimit.Limit{Burst: 1, Every: time.Second}).Enabled() {
+		t.Error("Expected limit with burst and interval to be enabled")
+	}
+}
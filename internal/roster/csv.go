@@ -0,0 +1,212 @@
+package roster
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/csv"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/cdriehuys/secret-santa/internal/i18n"
+)
+
+// importColumns maps the accepted CSV headers to the participant fields they fill in.
+var importColumns = map[string]string{
+	"name":       "name",
+	"email":      "email",
+	"household":  "household",
+	"exclusions": "exclusions",
+	"exclusion":  "exclusions",
+}
+
+// Imported is a participant read from a row of a CSV file.
+type Imported struct {
+	Participant
+
+	// Row is the line number of the participant in the file.
+	Row int
+}
+
+// ImportError describes a problem with an imported file. Row and Column are empty if the problem
+// is with the file as a whole.
+type ImportError struct {
+	Row     int
+	Column  string
+	Message i18n.Message
+}
+
+// String returns the error, including its location, in English.
+func (e ImportError) String() string {
+	return e.located().String()
+}
+
+// Translate returns the error, including its location, in the locale.
+func (e ImportError) Translate(locale string) string {
+	return e.located().Translate(locale)
+}
+
+// located returns the message prefixed with the row and column it applies to.
+func (e ImportError) located() i18n.Message {
+	switch {
+	case e.Row == 0:
+		return e.Message
+	case e.Column == "":
+		return i18n.NewMessage("Row %d: %s", e.Row, e.Message)
+	default:
+		return i18n.NewMessage("Row %d, %s: %s", e.Row, e.Column, e.Message)
+	}
+}
+
+// ParseCSV reads participants from a CSV file exported from a spreadsheet. The first
+// row names the columns, in any order: "name" is required, while "email", "household", and
+// "exclusions" are optional and other columns are ignored. Exclusions are separated by commas or
+// semicolons within their cell. Commas, semicolons, and tabs are accepted as delimiters since
+// spreadsheets export with different ones depending on their locale.
+func ParseCSV(r io.Reader) ([]Imported, []ImportError) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, []ImportError{{Message: i18n.NewMessage("The file could not be read: %v", err)}}
+	}
+
+	// Spreadsheets often start UTF-8 files with a byte order mark, which would otherwise become part
+	// of the first header.
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = sniffDelimiter(data)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, []ImportError{{Message: i18n.NewMessage("The file is empty.")}}
+	}
+	if err != nil {
+		return nil, []ImportError{csvError(err)}
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		if field, ok := importColumns[strings.ToLower(strings.TrimSpace(name))]; ok {
+			columns[field] = i
+		}
+	}
+
+	if _, ok := columns["name"]; !ok {
+		return nil, []ImportError{{Message: i18n.NewMessage(`The first row must name the columns and include a "name" column.`)}}
+	}
+
+	var participants []Imported
+	var errs []ImportError
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// A parse error can leave the reader in the middle of a quoted field, so the rows that
+			// follow can't be trusted.
+			errs = append(errs, csvError(err))
+			break
+		}
+
+		cell := func(field string) string {
+			i, ok := columns[field]
+			if !ok || i >= len(record) {
+				return ""
+			}
+
+			return strings.TrimSpace(record[i])
+		}
+
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		row, _ := reader.FieldPos(0)
+		p := Imported{
+			Participant: Participant{
+				Name:      cell("name"),
+				Email:     cell("email"),
+				Household: cell("household"),
+			},
+			Row: row,
+		}
+
+		for _, exclusion := range strings.FieldsFunc(cell("exclusions"), isExclusionSeparator) {
+			if exclusion = strings.TrimSpace(exclusion); exclusion != "" {
+				p.Exclusions = append(p.Exclusions, exclusion)
+			}
+		}
+
+		participants = append(participants, p)
+	}
+
+	if len(participants) == 0 && len(errs) == 0 {
+		errs = append(errs, ImportError{Message: i18n.NewMessage("The file doesn't contain any participants.")})
+	}
+
+	return participants, errs
+}
+
+// sniffDelimiter guesses the delimiter of a CSV file from the most common candidate in its first
+// line, defaulting to a comma.
+func sniffDelimiter(data []byte) rune {
+	firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+
+	delimiter, count := ',', bytes.Count(firstLine, []byte(","))
+	for _, candidate := range []rune{';', '\t'} {
+		if n := bytes.Count(firstLine, []byte(string(candidate))); n > count {
+			delimiter, count = candidate, n
+		}
+	}
+
+	return delimiter
+}
+
+func isExclusionSeparator(r rune) bool {
+	return r == ',' || r == ';'
+}
+
+func csvError(err error) ImportError {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return ImportError{Row: parseErr.StartLine, Message: i18n.NewMessage("The row is not valid CSV: %v", parseErr.Err)}
+	}
+
+	return ImportError{Message: i18n.NewMessage("The file is not valid CSV: %v", err)}
+}
+
+// ValidateImported validates imported participants like any others, but reports problems by the row
+// they were found in.
+func ValidateImported(participants []Imported, limits Limits) (map[string][]string, []ImportError) {
+	plain := make([]Participant, len(participants))
+	for i, p := range participants {
+		plain[i] = p.Participant
+	}
+
+	restrictions, errs := Validate(plain, limits)
+
+	importErrs := make([]ImportError, len(errs))
+	for i, err := range errs {
+		if err.Participant < 0 {
+			importErrs[i] = ImportError{Message: err.Message}
+			continue
+		}
+
+		importErrs[i] = ImportError{
+			Row:     participants[err.Participant].Row,
+			Column:  err.Field,
+			Message: err.Message,
+		}
+	}
+
+	// Problems are found one check at a time, but are easier to fix in the order of the file.
+	slices.SortStableFunc(importErrs, func(a, b ImportError) int {
+		return cmp.Compare(a.Row, b.Row)
+	})
+
+	return restrictions, importErrs
+}
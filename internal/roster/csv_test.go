@@ -0,0 +1,121 @@
+package roster_test
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cdriehuys/secret-santa/internal/roster"
+)
+
+func TestParseCSV(t *testing.T) {
+	testCases := []struct {
+		name       string
+		csv        string
+		want       []roster.Imported
+		wantErrors []string
+	}{
+		{
+			name: "all columns",
+			csv:  "Name,EMAIL,household,exclusions\nRoss,ross@example.com,Geller,\"Joey; Chandler,\"\n",
+			want: []roster.Imported{
+				{
+					Participant: roster.Participant{Name: "Ross", Email: "ross@example.com", Household: "Geller", Exclusions: []string{"Joey", "Chandler"}},
+					Row:         2,
+				},
+			},
+		},
+		{
+			name: "byte order mark and semicolons",
+			csv:  "\ufeffname;exclusion\nRoss;Joey\n;\nJoey\n",
+			want: []roster.Imported{
+				{Participant: roster.Participant{Name: "Ross", Exclusions: []string{"Joey"}}, Row: 2},
+				{Participant: roster.Participant{Name: "Joey"}, Row: 4},
+			},
+		},
+		{
+			name: "tabs and extra columns",
+			csv:  "notes\tname\nlikes dinosaurs\tRoss\n",
+			want: []roster.Imported{
+				{Participant: roster.Participant{Name: "Ross"}, Row: 2},
+			},
+		},
+		{
+			name:       "empty",
+			csv:        "",
+			wantErrors: []string{"The file is empty."},
+		},
+		{
+			name:       "no participants",
+			csv:        "name\n\n",
+			wantErrors: []string{"The file doesn't contain any participants."},
+		},
+		{
+			name:       "missing name column",
+			csv:        "email\nross@example.com\n",
+			wantErrors: []string{`The first row must name the columns and include a "name" column.`},
+		},
+		{
+			name: "malformed row",
+			csv:  "name\nRoss\n\"Joey\n",
+			want: []roster.Imported{
+				{Participant: roster.Participant{Name: "Ross"}, Row: 2},
+			},
+			wantErrors: []string{`Row 3: The row is not valid CSV: extraneous or missing " in quoted-field`},
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, errs := roster.ParseCSV(strings.NewReader(tt.csv))
+
+			if got := importErrorStrings(errs); !slices.Equal(got, tt.wantErrors) {
+				t.Errorf("Expected errors %q, got %q", tt.wantErrors, got)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected participants %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestValidateImported(t *testing.T) {
+	imported := []roster.Imported{
+		{Participant: roster.Participant{Name: "Ross", Exclusions: []string{"Monica"}}, Row: 2},
+		{Participant: roster.Participant{Name: "Ross"}, Row: 5},
+	}
+
+	_, errs := roster.ValidateImported(imported, testLimits)
+
+	want := []string{
+		`Row 2, exclusions: "Monica" is not a participant.`,
+		`Row 5, name: "Ross" is listed more than once.`,
+	}
+
+	if got := importErrorStrings(errs); !slices.Equal(got, want) {
+		t.Fatalf("Expected errors %q, got %q", want, got)
+	}
+
+	if errs[0].Row != 2 || errs[0].Column != "exclusions" {
+		t.Errorf("Expected the first error to be in row 2, exclusions, got row %d, %s", errs[0].Row, errs[0].Column)
+	}
+
+	if got, want := errs[1].Translate("de"), `Zeile 5, name: "Ross" ist mehrfach aufgeführt.`; got != want {
+		t.Errorf("Expected the translated error %q, got %q", want, got)
+	}
+}
+
+// importErrorStrings returns the errors in English, including their locations.
+func importErrorStrings(errs []roster.ImportError) []string {
+	if errs == nil {
+		return nil
+	}
+
+	strs := make([]string, len(errs))
+	for i, err := range errs {
+		strs[i] = err.String()
+	}
+
+	return strs
+}
@@ -0,0 +1,172 @@
+// Package roster reads and validates the people taking part in a draw.
+package roster
+
+import (
+	"maps"
+	"net/mail"
+	"slices"
+	"strings"
+
+	"github.com/cdriehuys/secret-santa/internal/i18n"
+)
+
+type Participant struct {
+	Name string
+
+	// Email is optional and only checked to be a valid address.
+	Email string
+
+	// Household groups participants who live together. Members of a household never draw each
+	// other, in addition to their exclusions.
+	Household string
+
+	Exclusions []string
+}
+
+// Limits bound the size of a draw.
+type Limits struct {
+	MaxParticipants int
+	MaxExclusions   int
+}
+
+// DefaultLimits fit large families and offices. They don't make the search for pairings fast on
+// their own, since a few exclusions each can still leave a draw that is hard to solve. Instead,
+// draws where someone has no one to give to or receive from are rejected up front, and the search
+// gives up after pairings.DefaultMaxSteps steps, which takes well under a second at these sizes. A
+// draw that gives up is reported to the user rather than tying up the server.
+var DefaultLimits = Limits{
+	MaxParticipants: 100,
+	MaxExclusions:   3,
+}
+
+// Error describes a problem with a list of participants.
+type Error struct {
+	// Participant is the index of the participant with the problem, or -1 if the problem is with
+	// the list as a whole.
+	Participant int
+
+	// Field is the participant's field with the problem: "name", "email", or "exclusions".
+	Field string
+
+	// Exclusion is the index of the exclusion with the problem, or -1 if the problem isn't with a
+	// single exclusion.
+	Exclusion int
+
+	// Message is translated when it's shown, since it may be shown in any locale.
+	Message i18n.Message
+}
+
+// Validate checks that every participant has a unique name and only excludes other participants,
+// and converts them into the exclusions used to generate pairings.
+//
+// Exclusions implied by households don't count towards MaxExclusions. Households are limited
+// separately: a household may include at most half of the participants, since its members can only
+// give to people outside it. Lists where someone has no one to give to, or no one can give to them,
+// are also rejected, since no draw could include them.
+func Validate(participants []Participant, limits Limits) (map[string][]string, []Error) {
+	var errs []Error
+	addError := func(i int, field string, j int, format string, args ...any) {
+		errs = append(errs, Error{Participant: i, Field: field, Exclusion: j, Message: i18n.NewMessage(format, args...)})
+	}
+
+	if len(participants) > limits.MaxParticipants {
+		addError(-1, "", -1, "There can be at most %d participants.", limits.MaxParticipants)
+		return nil, errs
+	}
+
+	restrictions := make(map[string][]string, len(participants))
+	households := make(map[string][]string)
+	for i, participant := range participants {
+		name := strings.TrimSpace(participant.Name)
+
+		if name == "" {
+			addError(i, "name", -1, "Name is required.")
+		} else if _, exists := restrictions[name]; exists {
+			addError(i, "name", -1, "%q is listed more than once.", name)
+		} else {
+			restrictions[name] = nil
+
+			if household := strings.TrimSpace(participant.Household); household != "" {
+				households[household] = append(households[household], name)
+			}
+		}
+
+		if email := strings.TrimSpace(participant.Email); email != "" {
+			if address, err := mail.ParseAddress(email); err != nil || address.Address != email {
+				addError(i, "email", -1, "%q is not a valid email address.", email)
+			}
+		}
+	}
+
+	for i, participant := range participants {
+		name := strings.TrimSpace(participant.Name)
+
+		if len(participant.Exclusions) > limits.MaxExclusions {
+			addError(i, "exclusions", -1, "There can be at most %d exclusions.", limits.MaxExclusions)
+			continue
+		}
+
+		for j, exclusion := range participant.Exclusions {
+			exclusion = strings.TrimSpace(exclusion)
+
+			if _, exists := restrictions[exclusion]; !exists {
+				addError(i, "exclusions", j, "%q is not a participant.", exclusion)
+			} else if exclusion == name {
+				addError(i, "exclusions", j, "Participants are already excluded from drawing themselves.")
+			} else if name != "" {
+				restrictions[name] = append(restrictions[name], exclusion)
+			}
+		}
+	}
+
+	for _, household := range slices.Sorted(maps.Keys(households)) {
+		members := households[household]
+		if 2*len(members) > len(restrictions) {
+			addError(-1, "", -1, "The %q household has %d of the %d participants. A household can have at most half of the participants.", household, len(members), len(restrictions))
+			continue
+		}
+
+		for _, member := range members {
+			for _, other := range members {
+				if other != member && !slices.Contains(restrictions[member], other) {
+					restrictions[member] = append(restrictions[member], other)
+				}
+			}
+		}
+	}
+
+	// The remaining checks need the complete restrictions.
+	if len(errs) > 0 {
+		return restrictions, errs
+	}
+
+	checkDegrees(participants, restrictions, addError)
+
+	return restrictions, errs
+}
+
+// checkDegrees reports participants who exclude everyone else, or whom everyone else excludes.
+func checkDegrees(participants []Participant, restrictions map[string][]string, addError func(int, string, int, string, ...any)) {
+	if len(restrictions) < 2 {
+		return
+	}
+
+	excludedBy := make(map[string]int, len(restrictions))
+	for _, exclusions := range restrictions {
+		for _, exclusion := range exclusions {
+			excludedBy[exclusion]++
+		}
+	}
+
+	for i, participant := range participants {
+		name := strings.TrimSpace(participant.Name)
+
+		if len(restrictions[name]) == len(restrictions)-1 {
+			addError(i, "exclusions", -1, "%q excludes everyone else, so they can't give a gift.", name)
+		}
+
+		if excludedBy[name] == len(restrictions)-1 {
+			addError(i, "name", -1, "Everyone else excludes %q, so no one can give them a gift.", name)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package roster_test
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/cdriehuys/secret-santa/internal/roster"
+)
+
+var testLimits = roster.Limits{MaxParticipants: 4, MaxExclusions: 1}
+
+// englishError is a roster.Error with its message in English, so errors can be compared.
+type englishError struct {
+	Participant int
+	Field       string
+	Exclusion   int
+	Message     string
+}
+
+func inEnglish(errs []roster.Error) []englishError {
+	if errs == nil {
+		return nil
+	}
+
+	english := make([]englishError, len(errs))
+	for i, err := range errs {
+		english[i] = englishError{err.Participant, err.Field, err.Exclusion, err.Message.String()}
+	}
+
+	return english
+}
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		name             string
+		participants     []roster.Participant
+		wantRestrictions map[string][]string
+		wantErrors       []englishError
+	}{
+		{
+			name: "valid",
+			participants: []roster.Participant{
+				{Name: " Ross ", Email: "ross@example.com", Exclusions: []string{" Joey"}},
+				{Name: "Joey"},
+				{Name: "Monica"},
+			},
+			wantRestrictions: map[string][]string{"Ross": {"Joey"}, "Joey": nil, "Monica": nil},
+		},
+		{
+			name: "households",
+			participants: []roster.Participant{
+				{Name: "Ross", Household: "Geller", Exclusions: []string{"Monica"}},
+				{Name: "Monica", Household: "Geller", Exclusions: []string{"Joey"}},
+				{Name: "Joey", Household: "Tribbiani"},
+				{Name: "Chandler"},
+			},
+			wantRestrictions: map[string][]string{
+				"Ross":     {"Monica"},
+				"Monica":   {"Joey", "Ross"},
+				"Joey":     nil,
+				"Chandler": nil,
+			},
+		},
+		{
+			name: "household too large",
+			participants: []roster.Participant{
+				{Name: "Ross", Household: "Geller"},
+				{Name: "Monica", Household: "Geller"},
+				{Name: "Jack", Household: "Geller"},
+				{Name: "Joey", Household: "Tribbiani"},
+			},
+			wantErrors: []englishError{
+				{Participant: -1, Exclusion: -1, Message: `The "Geller" household has 3 of the 4 participants. A household can have at most half of the participants.`},
+			},
+		},
+		{
+			name: "no one to give to",
+			participants: []roster.Participant{
+				{Name: "Ross", Exclusions: []string{"Joey"}},
+				{Name: "Joey"},
+			},
+			wantErrors: []englishError{
+				{Participant: 0, Field: "exclusions", Exclusion: -1, Message: `"Ross" excludes everyone else, so they can't give a gift.`},
+				{Participant: 1, Field: "name", Exclusion: -1, Message: `Everyone else excludes "Joey", so no one can give them a gift.`},
+			},
+		},
+		{
+			name: "no one to receive from",
+			participants: []roster.Participant{
+				{Name: "Ross", Exclusions: []string{"Joey"}},
+				{Name: "Monica", Exclusions: []string{"Joey"}},
+				{Name: "Joey"},
+			},
+			wantErrors: []englishError{
+				{Participant: 2, Field: "name", Exclusion: -1, Message: `Everyone else excludes "Joey", so no one can give them a gift.`},
+			},
+		},
+		{
+			name: "too many participants",
+			participants: []roster.Participant{
+				{Name: "Ross"}, {Name: "Monica"}, {Name: "Joey"}, {Name: "Chandler"}, {Name: "Rachel"},
+			},
+			wantErrors: []englishError{
+				{Participant: -1, Exclusion: -1, Message: "There can be at most 4 participants."},
+			},
+		},
+		{
+			name: "invalid participants",
+			participants: []roster.Participant{
+				{Name: "Ross", Email: "Ross <ross@example.com>", Exclusions: []string{"Ross"}},
+				{Name: " "},
+				{Name: "Ross", Exclusions: []string{"Monica", "Joey"}},
+				{Name: "Joey", Exclusions: []string{"Monica"}},
+			},
+			wantErrors: []englishError{
+				{Participant: 0, Field: "email", Exclusion: -1, Message: `"Ross <ross@example.com>" is not a valid email address.`},
+				{Participant: 1, Field: "name", Exclusion: -1, Message: "Name is required."},
+				{Participant: 2, Field: "name", Exclusion: -1, Message: `"Ross" is listed more than once.`},
+				{Participant: 0, Field: "exclusions", Exclusion: 0, Message: "Participants are already excluded from drawing themselves."},
+				{Participant: 2, Field: "exclusions", Exclusion: -1, Message: "There can be at most 1 exclusions."},
+				{Participant: 3, Field: "exclusions", Exclusion: 0, Message: `"Monica" is not a participant.`},
+			},
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			restrictions, errs := roster.Validate(tt.participants, testLimits)
+
+			if got := inEnglish(errs); !slices.Equal(got, tt.wantErrors) {
+				t.Fatalf("Expected errors %v, got %v", tt.wantErrors, got)
+			}
+
+			if tt.wantErrors == nil && !maps.EqualFunc(restrictions, tt.wantRestrictions, slices.Equal) {
+				t.Errorf("Expected restrictions %v, got %v", tt.wantRestrictions, restrictions)
+			}
+		})
+	}
+}
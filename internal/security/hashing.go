@@ -1,13 +1,52 @@
 package security
 
-import "github.com/alexedwards/argon2id"
+import (
+	"fmt"
 
-type Argon2IDHasher struct{}
+	"github.com/alexedwards/argon2id"
+)
+
+// Argon2IDHasher hashes passwords using Argon2id. The zero value uses argon2id.DefaultParams.
+type Argon2IDHasher struct {
+	Params *argon2id.Params
+}
+
+// NewArgon2IDHasher creates a hasher that produces hashes using the given parameters.
+func NewArgon2IDHasher(params argon2id.Params) Argon2IDHasher {
+	return Argon2IDHasher{Params: &params}
+}
 
 func (h Argon2IDHasher) Hash(password string) (string, error) {
-	return argon2id.CreateHash(password, argon2id.DefaultParams)
+	return argon2id.CreateHash(password, h.params())
 }
 
 func (h Argon2IDHasher) ComparePasswordAndHash(password string, hash string) (bool, error) {
 	return argon2id.ComparePasswordAndHash(password, hash)
 }
+
+// NeedsRehash reports whether the hash was created with parameters weaker than the hasher's current
+// parameters, meaning the password should be hashed again the next time it is available.
+func (h Argon2IDHasher) NeedsRehash(hash string) (bool, error) {
+	hashParams, _, _, err := argon2id.DecodeHash(hash)
+	if err != nil {
+		return false, fmt.Errorf("decoding hash: %v", err)
+	}
+
+	current := h.params()
+
+	weaker := hashParams.Memory < current.Memory ||
+		hashParams.Iterations < current.Iterations ||
+		hashParams.Parallelism < current.Parallelism ||
+		hashParams.SaltLength < current.SaltLength ||
+		hashParams.KeyLength < current.KeyLength
+
+	return weaker, nil
+}
+
+func (h Argon2IDHasher) params() *argon2id.Params {
+	if h.Params == nil {
+		return argon2id.DefaultParams
+	}
+
+	return h.Params
+}
@@ -0,0 +1,101 @@
+package security_test
+
+import (
+	"testing"
+
+	"github.com/alexedwards/argon2id"
+	"github.com/cdriehuys/secret-santa/internal/security"
+)
+
+// cheapParams keeps hashing fast in tests.
+var cheapParams = argon2id.Params{
+	Memory:      1024,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func TestArgon2IDHasher_NeedsRehash(t *testing.T) {
+	stronger := cheapParams
+	stronger.Iterations = 2
+
+	moreMemory := cheapParams
+	moreMemory.Memory = 2048
+
+	weaker := cheapParams
+	weaker.KeyLength = 16
+
+	testCases := []struct {
+		name          string
+		hashParams    argon2id.Params
+		currentParams argon2id.Params
+		want          bool
+	}{
+		{
+			name:          "same parameters",
+			hashParams:    cheapParams,
+			currentParams: cheapParams,
+			want:          false,
+		},
+		{
+			name:          "more iterations required",
+			hashParams:    cheapParams,
+			currentParams: stronger,
+			want:          true,
+		},
+		{
+			name:          "more memory required",
+			hashParams:    cheapParams,
+			currentParams: moreMemory,
+			want:          true,
+		},
+		{
+			name:          "hash stronger than current",
+			hashParams:    cheapParams,
+			currentParams: weaker,
+			want:          false,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, err := security.NewArgon2IDHasher(tt.hashParams).Hash("tops3cret")
+			if err != nil {
+				t.Fatalf("Failed to hash password: %v", err)
+			}
+
+			got, err := security.NewArgon2IDHasher(tt.currentParams).NeedsRehash(hash)
+			if err != nil {
+				t.Fatalf("NeedsRehash returned an error: %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Expected NeedsRehash=%v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestArgon2IDHasher_NeedsRehash_invalidHash(t *testing.T) {
+	if _, err := security.NewArgon2IDHasher(cheapParams).NeedsRehash("not-a-hash"); err == nil {
+		t.Error("Expected an error for an invalid hash.")
+	}
+}
+
+func TestArgon2IDHasher_ComparePasswordAndHash(t *testing.T) {
+	hasher := security.NewArgon2IDHasher(cheapParams)
+
+	hash, err := hasher.Hash("tops3cret")
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+
+	if match, err := hasher.ComparePasswordAndHash("tops3cret", hash); err != nil || !match {
+		t.Errorf("Expected password to match hash, got match=%v err=%v", match, err)
+	}
+
+	if match, err := hasher.ComparePasswordAndHash("wrong", hash); err != nil || match {
+		t.Errorf("Expected password not to match hash, got match=%v err=%v", match, err)
+	}
+}
@@ -0,0 +1,73 @@
+package security
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SecretBoxKeySize is the length in bytes of the key for a SecretBox.
+const SecretBoxKeySize = 32
+
+// sealedPrefix marks values encrypted by a SecretBox and names the format, so values stored before
+// encryption was added can be told apart.
+const sealedPrefix = "v1:"
+
+// SecretBox encrypts secrets that the server has to read back, like TOTP secrets, so that a copy of
+// the database isn't enough to recover them. Values are encrypted with AES-256-GCM under a key that
+// is kept out of the database.
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+// NewSecretBox creates a box that encrypts with the key, which must be SecretBoxKeySize bytes.
+func NewSecretBox(key []byte) (*SecretBox, error) {
+	if len(key) != SecretBoxKeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", SecretBoxKeySize, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("creating cipher: %v", err)
+	}
+
+	aead, err := cipher.NewGCMWithRandomNonce(block)
+	if err != nil {
+		return nil, fmt.Errorf("creating AEAD: %v", err)
+	}
+
+	return &SecretBox{aead}, nil
+}
+
+// Seal encrypts the plaintext. The associated data, such as the ID of the row the value is stored
+// in, isn't encrypted but must be given again to open the value, so a sealed value can't be moved
+// to another row.
+func (b *SecretBox) Seal(plaintext string, associated []byte) string {
+	sealed := b.aead.Seal(nil, nil, []byte(plaintext), associated)
+
+	return sealedPrefix + base64.RawStdEncoding.EncodeToString(sealed)
+}
+
+// Open decrypts a value from Seal. Values that were stored before they were encrypted are returned
+// unchanged.
+func (b *SecretBox) Open(value string, associated []byte) (string, error) {
+	encoded, ok := strings.CutPrefix(value, sealedPrefix)
+	if !ok {
+		return value, nil
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("decoding sealed value: %v", err)
+	}
+
+	plaintext, err := b.aead.Open(nil, nil, sealed, associated)
+	if err != nil {
+		return "", errors.New("sealed value could not be decrypted")
+	}
+
+	return string(plaintext), nil
+}
@@ -0,0 +1,68 @@
+package security_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/cdriehuys/secret-santa/internal/security"
+)
+
+func newSecretBox(t *testing.T, key byte) *security.SecretBox {
+	t.Helper()
+
+	box, err := security.NewSecretBox(bytes.Repeat([]byte{key}, security.SecretBoxKeySize))
+	if err != nil {
+		t.Fatalf("failed to create secret box: %v", err)
+	}
+
+	return box
+}
+
+func TestNewSecretBox_invalidKey(t *testing.T) {
+	if _, err := security.NewSecretBox(make([]byte, 16)); err == nil {
+		t.Error("Expected an error for a short key")
+	}
+}
+
+func TestSecretBox(t *testing.T) {
+	box := newSecretBox(t, 1)
+	associated := []byte("user-1")
+
+	sealed := box.Seal("JBSWY3DPEHPK3PXP", associated)
+	if strings.Contains(sealed, "JBSWY3DPEHPK3PXP") {
+		t.Fatalf("Expected the secret to be encrypted, got %q", sealed)
+	}
+
+	if again := box.Seal("JBSWY3DPEHPK3PXP", associated); again == sealed {
+		t.Error("Expected sealing the same secret twice to give different values")
+	}
+
+	got, err := box.Open(sealed, associated)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if got != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("Expected %q, got %q", "JBSWY3DPEHPK3PXP", got)
+	}
+
+	if _, err := box.Open(sealed, []byte("user-2")); err == nil {
+		t.Error("Expected an error opening the value for another row")
+	}
+
+	if _, err := newSecretBox(t, 2).Open(sealed, associated); err == nil {
+		t.Error("Expected an error opening the value with another key")
+	}
+}
+
+func TestSecretBox_Open_unsealed(t *testing.T) {
+	got, err := newSecretBox(t, 1).Open("JBSWY3DPEHPK3PXP", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if got != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("Expected a value stored before encryption to be returned unchanged, got %q", got)
+	}
+}
@@ -1,9 +1,22 @@
 package security
 
-import "crypto/rand"
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
 
 type TokenGenerator struct{}
 
 func (g TokenGenerator) Generate() string {
 	return rand.Text()
 }
+
+// HashToken returns the digest under which a token is stored. Tokens are generated with enough
+// entropy that a fast, unsalted hash is sufficient, and it allows tokens to be looked up directly
+// by their digest. Every table that stores tokens should store this digest rather than the token.
+func HashToken(token string) string {
+	digest := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(digest[:])
+}
@@ -0,0 +1,20 @@
+package security_test
+
+import (
+	"testing"
+
+	"github.com/cdriehuys/secret-santa/internal/security"
+)
+
+func TestHashToken(t *testing.T) {
+	// Known SHA-256 digest so the stored format can't silently change and orphan existing tokens.
+	want := "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"
+
+	if got := security.HashToken("secret"); got != want {
+		t.Errorf("Expected digest %q, got %q", want, got)
+	}
+
+	if security.HashToken("secret") == security.HashToken("Secret") {
+		t.Error("Expected different tokens to have different digests.")
+	}
+}
@@ -0,0 +1,180 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	totpSecretBytes = 20
+	totpDigits      = 6
+	totpPeriod      = 30 * time.Second
+
+	// recoveryCodeBytes gives each recovery code 80 random bits. Codes are stored under a fast
+	// digest so they can be looked up directly, which is only safe if guessing them is infeasible.
+	recoveryCodeBytes = 10
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTP generates and validates time-based one time passwords as described by RFC 6238, using the
+// HMAC-SHA1, 6 digit, 30 second parameters that authenticator apps support universally.
+type TOTP struct {
+	// Issuer is shown alongside the account name in authenticator apps.
+	Issuer string
+
+	// Skew is the number of time steps before or after the current one that are also accepted to
+	// allow for clock drift and slow typing.
+	Skew int
+
+	// Now returns the current time. If nil, time.Now is used.
+	Now func() time.Time
+}
+
+// GenerateSecret returns a new random base32 encoded secret.
+func (t TOTP) GenerateSecret() string {
+	secret := make([]byte, totpSecretBytes)
+	rand.Read(secret)
+
+	return totpEncoding.EncodeToString(secret)
+}
+
+// Code returns the one time password for the secret at the given time.
+func (t TOTP) Code(secret string, at time.Time) (string, error) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return hotp(key, timeStep(at)), nil
+}
+
+// Validate checks the code against the secret at the current time. If the code is valid, the time
+// step it belongs to is returned so callers can reject a code that has already been used.
+func (t TOTP) Validate(secret string, code string) (int64, bool) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return 0, false
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits {
+		return 0, false
+	}
+
+	current := timeStep(t.now())
+	for offset := -t.Skew; offset <= t.Skew; offset++ {
+		step := current + int64(offset)
+		if subtle.ConstantTimeCompare([]byte(hotp(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// ProvisioningURI returns an otpauth URI that authenticator apps can import, usually by scanning it
+// as a QR code.
+func (t TOTP) ProvisioningURI(secret string, account string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", t.Issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(int(totpPeriod.Seconds())))
+
+	uri := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + t.Issuer + ":" + account,
+		RawQuery: query.Encode(),
+	}
+
+	return uri.String()
+}
+
+// GenerateRecoveryCodes returns n random single use codes that can be used in place of a one time
+// password. Each code is 16 base32 characters in groups of four, like "ABCD-EFGH-IJKL-MNOP".
+// Normalize codes with NormalizeRecoveryCode before comparing or storing them.
+func (t TOTP) GenerateRecoveryCodes(n int) []string {
+	codes := make([]string, n)
+	for i := range codes {
+		raw := make([]byte, recoveryCodeBytes)
+		rand.Read(raw)
+
+		encoded := totpEncoding.EncodeToString(raw)
+
+		groups := make([]string, 0, len(encoded)/4)
+		for chunk := range slices.Chunk([]byte(encoded), 4) {
+			groups = append(groups, string(chunk))
+		}
+
+		codes[i] = strings.Join(groups, "-")
+	}
+
+	return codes
+}
+
+// NormalizeRecoveryCode converts a user-entered recovery code to its canonical form so formatting
+// differences like case and separators don't matter.
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToUpper(code)
+
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+
+		return r
+	}, code)
+}
+
+func (t TOTP) now() time.Time {
+	if t.Now == nil {
+		return time.Now()
+	}
+
+	return t.Now()
+}
+
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return nil, fmt.Errorf("decoding TOTP secret: %v", err)
+	}
+
+	return key, nil
+}
+
+func timeStep(at time.Time) int64 {
+	return at.Unix() / int64(totpPeriod.Seconds())
+}
+
+// hotp computes an HMAC-based one time password as described by RFC 4226.
+func hotp(key []byte, counter int64) string {
+	var message [8]byte
+	binary.BigEndian.PutUint64(message[:], uint64(counter))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(message[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	truncated := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulus := uint32(1)
+	for range totpDigits {
+		modulus *= 10
+	}
+
+	return fmt.Sprintf("%0*d", totpDigits, truncated%modulus)
+}
@@ -0,0 +1,172 @@
+package security_test
+
+import (
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/cdriehuys/secret-santa/internal/security"
+)
+
+// rfcSecret is the SHA1 seed from the RFC 6238 test vectors, "12345678901234567890", in base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTP_Code(t *testing.T) {
+	// Expected values are the last six digits of the RFC 6238 SHA1 test vectors.
+	testCases := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.want, func(t *testing.T) {
+			got, err := security.TOTP{}.Code(rfcSecret, time.Unix(tt.unix, 0))
+			if err != nil {
+				t.Fatalf("Code returned an error: %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Expected code %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTOTP_Validate(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	totp := security.TOTP{Skew: 1, Now: func() time.Time { return now }}
+
+	code := func(at time.Time) string {
+		c, err := totp.Code(rfcSecret, at)
+		if err != nil {
+			t.Fatalf("Code returned an error: %v", err)
+		}
+
+		return c
+	}
+
+	testCases := []struct {
+		name     string
+		secret   string
+		code     string
+		wantOK   bool
+		wantStep int64
+	}{
+		{
+			name:     "current code",
+			secret:   rfcSecret,
+			code:     code(now),
+			wantOK:   true,
+			wantStep: 1234567890 / 30,
+		},
+		{
+			name:     "previous step within skew",
+			secret:   rfcSecret,
+			code:     code(now.Add(-30 * time.Second)),
+			wantOK:   true,
+			wantStep: 1234567890/30 - 1,
+		},
+		{
+			name:   "outside skew",
+			secret: rfcSecret,
+			code:   code(now.Add(-90 * time.Second)),
+		},
+		{
+			name:   "wrong length",
+			secret: rfcSecret,
+			code:   "12345",
+		},
+		{
+			name:   "invalid secret",
+			secret: "not base32!",
+			code:   "123456",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := totp.Validate(tt.secret, tt.code)
+
+			if ok != tt.wantOK {
+				t.Errorf("Expected ok=%v, got %v", tt.wantOK, ok)
+			}
+
+			if step != tt.wantStep {
+				t.Errorf("Expected step %d, got %d", tt.wantStep, step)
+			}
+		})
+	}
+}
+
+func TestTOTP_GenerateSecret(t *testing.T) {
+	totp := security.TOTP{}
+	secret := totp.GenerateSecret()
+
+	if _, err := totp.Code(secret, time.Now()); err != nil {
+		t.Errorf("Generated secret %q is not usable: %v", secret, err)
+	}
+
+	if secret == totp.GenerateSecret() {
+		t.Error("Expected generated secrets to differ.")
+	}
+}
+
+func TestTOTP_ProvisioningURI(t *testing.T) {
+	totp := security.TOTP{Issuer: "Secret Santa"}
+
+	uri, err := url.Parse(totp.ProvisioningURI(rfcSecret, "test@example.com"))
+	if err != nil {
+		t.Fatalf("Provisioning URI is invalid: %v", err)
+	}
+
+	if uri.Scheme != "otpauth" || uri.Host != "totp" {
+		t.Errorf("Expected otpauth://totp URI, got %q", uri)
+	}
+
+	if want := "/Secret Santa:test@example.com"; uri.Path != want {
+		t.Errorf("Expected label %q, got %q", want, uri.Path)
+	}
+
+	query := uri.Query()
+	if got := query.Get("secret"); got != rfcSecret {
+		t.Errorf("Expected secret %q, got %q", rfcSecret, got)
+	}
+
+	if got := query.Get("issuer"); got != "Secret Santa" {
+		t.Errorf("Expected issuer %q, got %q", "Secret Santa", got)
+	}
+}
+
+func TestTOTP_GenerateRecoveryCodes(t *testing.T) {
+	codes := security.TOTP{}.GenerateRecoveryCodes(10)
+
+	if len(codes) != 10 {
+		t.Fatalf("Expected 10 codes, got %d", len(codes))
+	}
+
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		if !regexp.MustCompile(`^[A-Z2-7]{4}(-[A-Z2-7]{4}){3}$`).MatchString(code) {
+			t.Errorf("Expected a code of 16 base32 characters in groups of 4, got %q", code)
+		}
+
+		if seen[code] {
+			t.Errorf("Duplicate recovery code %q", code)
+		}
+
+		seen[code] = true
+	}
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	if got := security.NormalizeRecoveryCode(" abcd-efgh "); got != "ABCDEFGH" {
+		t.Errorf("Expected %q, got %q", "ABCDEFGH", got)
+	}
+}
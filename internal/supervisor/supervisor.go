@@ -0,0 +1,102 @@
+// Package supervisor runs the server's background goroutines and stops them in order when the
+// server shuts down.
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// Supervisor tracks background workers and the resources they depend on. Everything is stopped in
+// the reverse of the order it was added, like deferred calls, so a worker started after opening a
+// database pool stops before the pool is closed.
+type Supervisor struct {
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	tasks   []task
+	stopped bool
+}
+
+type task struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+func New(logger *slog.Logger) *Supervisor {
+	return &Supervisor{logger: logger}
+}
+
+// Go runs fn in a goroutine until Stop cancels its context. An error returned before then is
+// logged, since the worker has stopped early.
+func (s *Supervisor) Go(name string, fn func(ctx context.Context) error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		err := fn(ctx)
+		if ctx.Err() == nil {
+			s.logger.Error("Background worker stopped unexpectedly.", "worker", name, "error", err)
+		} else if err != nil && !errors.Is(err, context.Canceled) {
+			s.logger.Error("Background worker failed while stopping.", "worker", name, "error", err)
+		}
+	}()
+
+	s.add(name, func(stopCtx context.Context) error {
+		cancel()
+
+		select {
+		case <-done:
+			return nil
+		case <-stopCtx.Done():
+			return fmt.Errorf("%s did not stop in time: %v", name, stopCtx.Err())
+		}
+	})
+}
+
+// OnStop registers fn to be called by Stop, such as to close a connection pool once the workers
+// using it have stopped.
+func (s *Supervisor) OnStop(name string, fn func()) {
+	s.add(name, func(context.Context) error {
+		fn()
+		return nil
+	})
+}
+
+func (s *Supervisor) add(name string, stop func(ctx context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tasks = append(s.tasks, task{name: name, stop: stop})
+}
+
+// Stop stops every worker and calls every OnStop function in the reverse of the order they were
+// added. It waits for each worker to return until the context is done, and then carries on
+// without it. Stopping more than once has no effect.
+func (s *Supervisor) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return nil
+	}
+
+	s.stopped = true
+	tasks := s.tasks
+	s.mu.Unlock()
+
+	var errs []error
+	for i := len(tasks) - 1; i >= 0; i-- {
+		s.logger.Debug("Stopping.", "task", tasks[i].name)
+
+		if err := tasks[i].stop(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,83 @@
+package supervisor_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cdriehuys/secret-santa/internal/supervisor"
+)
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func TestSupervisor_Stop(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		events = append(events, event)
+	}
+
+	worker := func(name string) func(context.Context) error {
+		return func(ctx context.Context) error {
+			<-ctx.Done()
+			record("stopped " + name)
+			return ctx.Err()
+		}
+	}
+
+	s := supervisor.New(discardLogger)
+	s.OnStop("pool", func() { record("closed pool") })
+	s.Go("outbox", worker("outbox"))
+	s.Go("watcher", worker("watcher"))
+
+	if err := s.Stop(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := []string{"stopped watcher", "stopped outbox", "closed pool"}
+	if !slices.Equal(events, want) {
+		t.Errorf("Expected %v, got %v", want, events)
+	}
+
+	if err := s.Stop(context.Background()); err != nil {
+		t.Errorf("Expected stopping again to do nothing, got %v", err)
+	}
+
+	if len(events) != len(want) {
+		t.Errorf("Expected stopping again to do nothing, got %v", events)
+	}
+}
+
+func TestSupervisor_Stop_timeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	closed := false
+
+	s := supervisor.New(discardLogger)
+	s.OnStop("pool", func() { closed = true })
+	s.Go("stuck", func(context.Context) error {
+		<-release
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := s.Stop(ctx)
+	if err == nil || !strings.Contains(err.Error(), "stuck did not stop in time") {
+		t.Errorf("Expected an error naming the stuck worker, got %v", err)
+	}
+
+	if !closed {
+		t.Error("Expected the pool to be closed after the timeout")
+	}
+}
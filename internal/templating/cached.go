@@ -6,59 +6,50 @@ import (
 	"io"
 	"io/fs"
 	"log/slog"
-	"path/filepath"
+	"maps"
+	"path"
+	"slices"
 	texttemplate "text/template"
+	"text/template/parse"
 )
 
 type TemplateCache struct {
 	logger *slog.Logger
 
-	cache map[string]*template.Template
+	cache    map[string]*template.Template
+	messages []string
 }
 
-func NewTemplateCache(logger *slog.Logger, files fs.FS) (*TemplateCache, error) {
-	basePath := "base.html"
-	pagesPath := "pages"
-
-	var pages []string
-	visit := func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-
-		if d.IsDir() {
-			return nil
-		}
-
-		if filepath.Ext(path) == ".html" {
-			pages = append(pages, path)
-		}
-
-		return nil
-	}
-
-	if err := fs.WalkDir(files, pagesPath, visit); err != nil {
+// NewTemplateCache parses every page in the "pages" directory so that pages can be rendered
+// without touching the file system. Each page is parsed with the base layout and every partial in
+// the "partials" directory, and may use the functions in funcs. Pages are shared by every locale
+// and translate their text with T.
+func NewTemplateCache(logger *slog.Logger, files fs.FS, funcs template.FuncMap) (*TemplateCache, error) {
+	pages, err := fs.Glob(files, "pages/*.html")
+	if err != nil {
 		return nil, fmt.Errorf("failed to collect pages: %v", err)
 	}
 
 	cache := make(map[string]*template.Template, len(pages))
+	var trees []*parse.Tree
 	for _, page := range pages {
-		name, err := filepath.Rel(pagesPath, page)
-		if err != nil {
-			return nil, fmt.Errorf("failed to determine relative path for page %q: %v", page, err)
-		}
+		name := path.Base(page)
 
-		t, err := template.ParseFS(files, basePath, page)
+		t, err := parsePageFS(files, funcs, name)
 		if err != nil {
-			return nil, fmt.Errorf("failed to construct template for page %q: %v", page, err)
+			return nil, fmt.Errorf("failed to construct template for page %q: %v", name, err)
 		}
 
 		cache[name] = t
+		for _, tmpl := range t.Templates() {
+			trees = append(trees, tmpl.Tree)
+		}
 	}
 
-	return &TemplateCache{logger, cache}, nil
+	return &TemplateCache{logger, cache, templateMessages(trees)}, nil
 }
 
+// Render executes the named page.
 func (c *TemplateCache) Render(w io.Writer, page string, data any) error {
 	t, exists := c.cache[page]
 	if !exists {
@@ -68,60 +59,135 @@ func (c *TemplateCache) Render(w io.Writer, page string, data any) error {
 	return t.ExecuteTemplate(w, "main", data)
 }
 
-type EmailTemplateCache struct {
-	logger *slog.Logger
+// Names returns the names of every page in the cache.
+func (c *TemplateCache) Names() []string {
+	return slices.Sorted(maps.Keys(c.cache))
+}
 
-	cache map[string]*texttemplate.Template
+// Messages returns the text the pages translate, which every locale should have a translation for.
+func (c *TemplateCache) Messages() []string {
+	return slices.Clone(c.messages)
 }
 
-func NewEmailTemplateCache(logger *slog.Logger, files fs.FS) (*EmailTemplateCache, error) {
-	basePath := "base.txt"
-	subjectsPath := "subjects"
+// parsePageFS parses a page along with the base layout and partials.
+func parsePageFS(files fs.FS, funcs template.FuncMap, page string) (*template.Template, error) {
+	partials, err := fs.Glob(files, "partials/*.html")
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect partials: %v", err)
+	}
 
-	var subjects []string
-	visit := func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
+	patterns := append([]string{"base.html"}, partials...)
+	patterns = append(patterns, path.Join("pages", page))
 
-		if d.IsDir() {
-			return nil
-		}
+	return template.New("base.html").Funcs(funcs).ParseFS(files, patterns...)
+}
+
+// executor is the common interface of text and HTML templates.
+type executor interface {
+	ExecuteTemplate(io.Writer, string, any) error
+}
 
-		if filepath.Ext(path) == ".txt" {
-			subjects = append(subjects, path)
+// parseEmailFS parses an email template along with the base layout for its format. Plain text
+// templates use base.txt and text/template, while HTML templates use base.html and html/template so
+// that their content is escaped. Subjects may be prefixed with a locale, and emails may use the
+// same functions as pages, such as T to translate the text of the base layouts.
+func parseEmailFS(files fs.FS, subject string) (executor, error) {
+	locale, name := splitLocale(subject)
+	subjectPath := localizedPath(files, "subjects", locale, name)
+
+	switch path.Ext(name) {
+	case ".txt":
+		return texttemplate.New("base.txt").Funcs(texttemplate.FuncMap(DefaultFuncs())).ParseFS(files, "base.txt", subjectPath)
+	case ".html":
+		return template.New("base.html").Funcs(DefaultFuncs()).ParseFS(files, "base.html", subjectPath)
+	default:
+		return nil, fmt.Errorf("unsupported email template type: %v", subject)
+	}
+}
+
+// executorTrees returns the parse trees of an email template and the templates associated with it.
+func executorTrees(e executor) []*parse.Tree {
+	var trees []*parse.Tree
+	switch e := e.(type) {
+	case *texttemplate.Template:
+		for _, t := range e.Templates() {
+			trees = append(trees, t.Tree)
+		}
+	case *template.Template:
+		for _, t := range e.Templates() {
+			trees = append(trees, t.Tree)
 		}
+	}
 
-		return nil
+	return trees
+}
+
+type EmailTemplateCache struct {
+	logger *slog.Logger
+
+	cache        map[string]executor
+	untranslated []string
+	messages     []string
+}
+
+func NewEmailTemplateCache(logger *slog.Logger, files fs.FS) (*EmailTemplateCache, error) {
+	locales, err := localeDirs(files, "subjects")
+	if err != nil {
+		return nil, fmt.Errorf("collecting locales: %v", err)
 	}
 
-	if err := fs.WalkDir(files, subjectsPath, visit); err != nil {
+	subjects, err := templateNames(files, "subjects", locales, ".txt", ".html")
+	if err != nil {
 		return nil, fmt.Errorf("collecting subjects: %v", err)
 	}
 
-	cache := make(map[string]*texttemplate.Template, len(subjects))
+	cache := make(map[string]executor, len(subjects))
+	var trees []*parse.Tree
 	for _, subject := range subjects {
-		name, err := filepath.Rel(subjectsPath, subject)
-		if err != nil {
-			return nil, fmt.Errorf("determining relative path for subject %q: %v", subject, err)
-		}
-
-		t, err := texttemplate.ParseFS(files, basePath, subject)
+		t, err := parseEmailFS(files, subject)
 		if err != nil {
 			return nil, fmt.Errorf("constructing template for subject %q: %v", subject, err)
 		}
 
-		cache[name] = t
+		cache[subject] = t
+		trees = append(trees, executorTrees(t)...)
 	}
 
-	return &EmailTemplateCache{logger, cache}, nil
+	return &EmailTemplateCache{logger, cache, untranslatedNames(files, "subjects", subjects), templateMessages(trees)}, nil
 }
 
+// Render executes the named email template. Names ending in .html produce HTML, and names ending
+// in .txt produce plain text.
 func (c *EmailTemplateCache) Render(w io.Writer, subject string, data any) error {
+	return c.RenderBlock(w, subject, "main", data)
+}
+
+// Names returns the names of every email template in the cache, including translated variants.
+func (c *EmailTemplateCache) Names() []string {
+	return slices.Sorted(maps.Keys(c.cache))
+}
+
+// Untranslated returns the localized names of email templates that fall back to the default
+// template because the locale doesn't translate them.
+func (c *EmailTemplateCache) Untranslated() []string {
+	return slices.Clone(c.untranslated)
+}
+
+// Messages returns the text the emails translate with T, which every locale should have a
+// translation for.
+func (c *EmailTemplateCache) Messages() []string {
+	return slices.Clone(c.messages)
+}
+
+// RenderBlock executes a single named block of an email template, such as its subject.
+func (c *EmailTemplateCache) RenderBlock(w io.Writer, subject string, block string, data any) error {
 	t, exists := c.cache[subject]
 	if !exists {
-		return fmt.Errorf("template not found: %v", subject)
+		_, name := splitLocale(subject)
+		if t, exists = c.cache[name]; !exists {
+			return fmt.Errorf("template not found: %v", subject)
+		}
 	}
 
-	return t.ExecuteTemplate(w, "main", data)
+	return t.ExecuteTemplate(w, block, data)
 }
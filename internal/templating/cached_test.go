@@ -3,9 +3,11 @@ package templating_test
 import (
 	"bytes"
 	"log/slog"
+	"slices"
 	"testing"
 	"testing/fstest"
 
+	"github.com/cdriehuys/secret-santa/internal/i18n"
 	"github.com/cdriehuys/secret-santa/internal/templating"
 )
 
@@ -19,6 +21,9 @@ var testFS = fstest.MapFS{
 	"pages/hello.html": &fstest.MapFile{
 		Data: []byte(`{{ define "content" }}Hello{{ end }}`),
 	},
+	"pages/login.html": &fstest.MapFile{
+		Data: []byte(`{{ define "content" }}{{ T .Locale "Log In" }}{{ if .Error }}: {{ T .Locale .Error }}{{ end }}{{ end }}`),
+	},
 }
 
 func TestTemplateCache_Render(t *testing.T) {
@@ -42,15 +47,32 @@ func TestTemplateCache_Render(t *testing.T) {
 			data: map[string]string{"Content": "custom content"},
 			want: "custom content",
 		},
+		{
+			name: "translated text",
+			page: "login.html",
+			data: map[string]any{"Locale": "de", "Error": i18n.NewMessage("Password must be at least %d characters.", 8)},
+			want: "Anmelden: Das Passwort muss mindestens 8 Zeichen lang sein.",
+		},
+		{
+			name: "unknown locale",
+			page: "login.html",
+			data: map[string]any{"Locale": "fr"},
+			want: "Log In",
+		},
 		{
 			name:    "missing page",
 			page:    "missing.html",
 			wantErr: true,
 		},
+		{
+			name:    "locale prefix",
+			page:    "de/hello.html",
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			c, err := templating.NewTemplateCache(slog.New(slog.DiscardHandler), testFS)
+			c, err := templating.NewTemplateCache(slog.New(slog.DiscardHandler), testFS, templating.DefaultFuncs())
 			if err != nil {
 				t.Fatalf("could not construct template cache: %v", err)
 			}
@@ -86,6 +108,15 @@ var testEmailFS = fstest.MapFS{
 	"subjects/hello.txt": &fstest.MapFile{
 		Data: []byte(`{{ define "content" }}Hello{{ end }}`),
 	},
+	"base.html": &fstest.MapFile{
+		Data: []byte(`{{ define "main" }}<body>{{ block "content" . }}{{ end }}</body>{{ end }}`),
+	},
+	"subjects/content.html": &fstest.MapFile{
+		Data: []byte(`{{ define "content" }}<p>{{ .Content }}</p>{{ end }}`),
+	},
+	"subjects/es/hello.txt": &fstest.MapFile{
+		Data: []byte(`{{ define "content" }}Hola{{ end }}`),
+	},
 }
 
 func TestEmailTemplateCache_Render(t *testing.T) {
@@ -109,6 +140,29 @@ func TestEmailTemplateCache_Render(t *testing.T) {
 			data:    map[string]string{"Content": "custom content"},
 			want:    "custom content",
 		},
+		{
+			name:    "escaped HTML",
+			subject: "content.html",
+			data:    map[string]string{"Content": "<script>"},
+			want:    "<body><p>&lt;script&gt;</p></body>",
+		},
+		{
+			name:    "text is not escaped",
+			subject: "content.txt",
+			data:    map[string]string{"Content": "<script>"},
+			want:    "<script>",
+		},
+		{
+			name:    "translated subject",
+			subject: "es/hello.txt",
+			want:    "Hola",
+		},
+		{
+			name:    "untranslated subject",
+			subject: "es/content.html",
+			data:    map[string]string{"Content": "<script>"},
+			want:    "<body><p>&lt;script&gt;</p></body>",
+		},
 		{
 			name:    "missing subject",
 			subject: "missing.txt",
@@ -142,3 +196,182 @@ func TestEmailTemplateCache_Render(t *testing.T) {
 		})
 	}
 }
+
+func TestEmailTemplateCache_RenderBlock(t *testing.T) {
+	files := fstest.MapFS{
+		"base.txt": &fstest.MapFile{
+			Data: []byte(`{{ define "main" }}{{ block "content" . }}{{ end }}{{ end }}`),
+		},
+		"subjects/hello.txt": &fstest.MapFile{
+			Data: []byte(`{{ define "subject" }}Hello, {{ .Name }}{{ end }}{{ define "content" }}Body{{ end }}`),
+		},
+	}
+
+	c, err := templating.NewEmailTemplateCache(slog.New(slog.DiscardHandler), files)
+	if err != nil {
+		t.Fatalf("could not construct template cache: %v", err)
+	}
+
+	var buffer bytes.Buffer
+	if err := c.RenderBlock(&buffer, "hello.txt", "subject", map[string]string{"Name": "World"}); err != nil {
+		t.Fatalf("RenderBlock() failed: %v", err)
+	}
+
+	if got, want := buffer.String(), "Hello, World"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	if err := c.RenderBlock(&buffer, "hello.txt", "missing", nil); err == nil {
+		t.Error("Expected an error for an undefined block")
+	}
+
+	if err := c.RenderBlock(&buffer, "missing.txt", "subject", nil); err == nil {
+		t.Error("Expected an error for a missing template")
+	}
+}
+
+func TestEmailTemplateCache_Render_translated(t *testing.T) {
+	files := fstest.MapFS{
+		"base.txt": &fstest.MapFile{
+			Data: []byte(`{{ define "main" }}{{ block "content" . }}{{ end }} {{ T .Locale "The Elves" }}{{ end }}`),
+		},
+		"base.html": &fstest.MapFile{
+			Data: []byte(`{{ define "main" }}<p>{{ block "content" . }}{{ end }}</p><p>{{ T .Locale "Thanks," }}</p>{{ end }}`),
+		},
+		"subjects/hello.txt": &fstest.MapFile{
+			Data: []byte(`{{ define "content" }}Hello{{ end }}`),
+		},
+		"subjects/hello.html": &fstest.MapFile{
+			Data: []byte(`{{ define "content" }}Hello{{ end }}`),
+		},
+		"subjects/es/hello.txt": &fstest.MapFile{
+			Data: []byte(`{{ define "content" }}Hola{{ end }}`),
+		},
+	}
+
+	c, err := templating.NewEmailTemplateCache(slog.New(slog.DiscardHandler), files)
+	if err != nil {
+		t.Fatalf("could not construct template cache: %v", err)
+	}
+
+	tests := []struct {
+		subject string
+		want    string
+	}{
+		{subject: "es/hello.txt", want: "Hola Los Elfos"},
+		{subject: "es/hello.html", want: "<p>Hello</p><p>Gracias,</p>"},
+	}
+	for _, tt := range tests {
+		var buffer bytes.Buffer
+		if err := c.Render(&buffer, tt.subject, map[string]string{"Locale": "es"}); err != nil {
+			t.Fatalf("Render(%q) failed: %v", tt.subject, err)
+		}
+
+		if got := buffer.String(); got != tt.want {
+			t.Errorf("Render(%q): expected %q, got %q", tt.subject, tt.want, got)
+		}
+	}
+
+	want := []string{"Thanks,", "The Elves"}
+	if got := c.Messages(); !slices.Equal(got, want) {
+		t.Errorf("Expected messages %q, got %q", want, got)
+	}
+}
+
+func TestTemplateCache_Render_partials(t *testing.T) {
+	files := fstest.MapFS{
+		"base.html": &fstest.MapFile{
+			Data: []byte(`{{ define "main" }}{{ template "greeting" . }} {{ block "content" . }}{{ end }}{{ end }}`),
+		},
+		"partials/greeting.html": &fstest.MapFile{
+			Data: []byte(`{{ define "greeting" }}Hello{{ end }}`),
+		},
+		"pages/gifts.html": &fstest.MapFile{
+			Data: []byte(`{{ define "content" }}{{ .Count }} {{ pluralize .Count "gift" "gifts" }}{{ end }}`),
+		},
+	}
+
+	tests := []struct {
+		name  string
+		page  string
+		count int
+		want  string
+	}{
+		{
+			name:  "default partial",
+			page:  "gifts.html",
+			count: 1,
+			want:  "Hello 1 gift",
+		},
+		{
+			name:  "plural",
+			page:  "gifts.html",
+			count: 2,
+			want:  "Hello 2 gifts",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := templating.NewTemplateCache(slog.New(slog.DiscardHandler), files, templating.DefaultFuncs())
+			if err != nil {
+				t.Fatalf("could not construct template cache: %v", err)
+			}
+
+			var buffer bytes.Buffer
+			if err := c.Render(&buffer, tt.page, map[string]int{"Count": tt.count}); err != nil {
+				t.Fatalf("Render() failed: %v", err)
+			}
+
+			if got := buffer.String(); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNewTemplateCache_undefinedFunc(t *testing.T) {
+	plain := fstest.MapFS{
+		"base.html":        testFS["base.html"],
+		"pages/hello.html": testFS["pages/hello.html"],
+	}
+
+	_, err := templating.NewTemplateCache(slog.New(slog.DiscardHandler), plain, nil)
+	if err != nil {
+		t.Fatalf("Expected templates without functions to parse, got %v", err)
+	}
+
+	files := fstest.MapFS{
+		"base.html": testFS["base.html"],
+		"pages/date.html": &fstest.MapFile{
+			Data: []byte(`{{ define "content" }}{{ date .Date }}{{ end }}`),
+		},
+	}
+
+	if _, err := templating.NewTemplateCache(slog.New(slog.DiscardHandler), files, nil); err == nil {
+		t.Error("Expected an error for a template using an undefined function")
+	}
+}
+
+func TestTemplateCache_Messages(t *testing.T) {
+	files := fstest.MapFS{
+		"base.html": &fstest.MapFile{
+			Data: []byte(`{{ define "main" }}{{ template "nav" . }}{{ block "content" . }}{{ end }}{{ end }}`),
+		},
+		"partials/nav.html": &fstest.MapFile{
+			Data: []byte(`{{ define "nav" }}{{ if .User }}{{ T .Locale "Log Out" }}{{ else }}{{ T .Locale "Log In" }}{{ end }}{{ end }}`),
+		},
+		"pages/gifts.html": &fstest.MapFile{
+			Data: []byte(`{{ define "content" }}{{ range .Gifts }}{{ template "field-error" (T $.Locale .Error) }}{{ end }}{{ T .Locale (pluralize (len .Gifts) "%d gift" "%d gifts") (len .Gifts) }}{{ T .Locale "Log In" }}{{ end }}`),
+		},
+	}
+
+	cache, err := templating.NewTemplateCache(slog.New(slog.DiscardHandler), files, templating.DefaultFuncs())
+	if err != nil {
+		t.Fatalf("failed to construct template cache: %v", err)
+	}
+
+	want := []string{"%d gift", "%d gifts", "Log In", "Log Out"}
+	if got := cache.Messages(); !slices.Equal(got, want) {
+		t.Errorf("Expected messages %q, got %q", want, got)
+	}
+}
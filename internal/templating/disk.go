@@ -4,20 +4,22 @@ import (
 	"html/template"
 	"io"
 	"log/slog"
-	"path/filepath"
-	texttemplate "text/template"
+	"os"
 )
 
+// LiveLoader parses templates from disk each time they are rendered so that changes show up
+// without restarting the server.
 type LiveLoader struct {
 	Logger  *slog.Logger
 	BaseDir string
+
+	// Funcs are the functions available to templates. They should match the ones given to
+	// NewTemplateCache so templates behave the same regardless of how they are loaded.
+	Funcs template.FuncMap
 }
 
 func (l *LiveLoader) Render(w io.Writer, page string, data any) error {
-	basePath := filepath.Join(l.BaseDir, "base.html")
-	pagePath := filepath.Join(l.BaseDir, "pages", page)
-
-	t, err := template.ParseFiles(basePath, pagePath)
+	t, err := parsePageFS(os.DirFS(l.BaseDir), l.Funcs, page)
 	if err != nil {
 		return err
 	}
@@ -25,19 +27,22 @@ func (l *LiveLoader) Render(w io.Writer, page string, data any) error {
 	return t.ExecuteTemplate(w, "main", data)
 }
 
+// LiveEmailLoader is the email equivalent of LiveLoader.
 type LiveEmailLoader struct {
 	Logger  *slog.Logger
 	BaseDir string
 }
 
 func (l *LiveEmailLoader) Render(w io.Writer, subject string, data any) error {
-	basePath := filepath.Join(l.BaseDir, "base.txt")
-	pagePath := filepath.Join(l.BaseDir, "subjects", subject)
+	return l.RenderBlock(w, subject, "main", data)
+}
 
-	t, err := texttemplate.ParseFiles(basePath, pagePath)
+// RenderBlock executes a single named block of an email template, such as its subject.
+func (l *LiveEmailLoader) RenderBlock(w io.Writer, subject string, block string, data any) error {
+	t, err := parseEmailFS(os.DirFS(l.BaseDir), subject)
 	if err != nil {
 		return err
 	}
 
-	return t.ExecuteTemplate(w, "main", data)
+	return t.ExecuteTemplate(w, block, data)
 }
@@ -62,6 +62,16 @@ func TestLiveLoader_Render(t *testing.T) {
 			data: map[string]string{"Content": "Refrigerator"},
 			want: "Refrigerator",
 		},
+		{
+			name:         "translated text",
+			baseTemplate: standardBaseTemplate,
+			pageTemplates: map[string]string{
+				"hello.html": `{{ define "content" }}{{ T .Locale "Hello, World!" }}{{ end }}`,
+			},
+			page: "hello.html",
+			data: map[string]string{"Locale": "de"},
+			want: "Hallo, Welt!",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -74,18 +84,20 @@ func TestLiveLoader_Render(t *testing.T) {
 			}
 
 			if len(tt.pageTemplates) > 0 {
-				if err := os.Mkdir(filepath.Join(dir, "pages"), 0o755); err != nil {
-					t.Fatalf("failed to create pages dir: %v", err)
-				}
 
 				for page, template := range tt.pageTemplates {
-					if err := os.WriteFile(filepath.Join(dir, "pages", page), []byte(template), 0o644); err != nil {
+					path := filepath.Join(dir, "pages", page)
+					if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+						t.Fatalf("failed to create directory for page %q: %v", page, err)
+					}
+
+					if err := os.WriteFile(path, []byte(template), 0o644); err != nil {
 						t.Fatalf("failed to write page %q: %v", page, err)
 					}
 				}
 			}
 
-			l := templating.LiveLoader{Logger: slog.New(slog.DiscardHandler), BaseDir: dir}
+			l := templating.LiveLoader{Logger: slog.New(slog.DiscardHandler), BaseDir: dir, Funcs: templating.DefaultFuncs()}
 
 			var buffer bytes.Buffer
 
@@ -110,12 +122,32 @@ func TestLiveLoader_Render(t *testing.T) {
 	}
 }
 
+func TestLiveLoader_Render_partials(t *testing.T) {
+	dir := t.TempDir()
+
+	writeTemplate(t, dir, "base.html", `{{ define "main" }}{{ template "greeting" }}, {{ block "content" . }}{{ end }}{{ end }}`)
+	writeTemplate(t, dir, "partials/greeting.html", `{{ define "greeting" }}Hello{{ end }}`)
+	writeTemplate(t, dir, "pages/world.html", `{{ define "content" }}World{{ if gt .Count 1 }}s{{ end }} ({{ pluralize .Count "one" "many" }}){{ end }}`)
+
+	l := templating.LiveLoader{Logger: slog.New(slog.DiscardHandler), BaseDir: dir, Funcs: templating.DefaultFuncs()}
+
+	var buffer bytes.Buffer
+	if err := l.Render(&buffer, "world.html", map[string]int{"Count": 2}); err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+
+	if got, want := buffer.String(), "Hello, Worlds (many)"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
 func TestLiveEmailLoader_Render(t *testing.T) {
 	tests := []struct {
 		name string
 
 		// setup
 		baseTemplate     string
+		htmlBaseTemplate string
 		subjectTemplates map[string]string
 
 		// parameters
@@ -157,6 +189,36 @@ func TestLiveEmailLoader_Render(t *testing.T) {
 			data:    map[string]string{"Content": "Refrigerator"},
 			want:    "Refrigerator",
 		},
+		{
+			name:             "HTML uses HTML base",
+			baseTemplate:     standardBaseTemplate,
+			htmlBaseTemplate: `{{ define "main" }}<body>{{ block "content" . }}{{ end }}</body>{{ end }}`,
+			subjectTemplates: map[string]string{
+				"data.html": `{{ define "content" }}{{ .Content }}{{ end}}`,
+			},
+			subject: "data.html",
+			data:    map[string]string{"Content": "<Fridge>"},
+			want:    "<body>&lt;Fridge&gt;</body>",
+		},
+		{
+			name:         "translated subject",
+			baseTemplate: standardBaseTemplate,
+			subjectTemplates: map[string]string{
+				"hello.txt":    helloTemplate,
+				"es/hello.txt": `{{ define "content" }}¡Hola, Mundo!{{ end }}`,
+			},
+			subject: "es/hello.txt",
+			want:    "¡Hola, Mundo!",
+		},
+		{
+			name:         "unsupported extension",
+			baseTemplate: standardBaseTemplate,
+			subjectTemplates: map[string]string{
+				"data.md": helloTemplate,
+			},
+			subject: "data.md",
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -168,13 +230,21 @@ func TestLiveEmailLoader_Render(t *testing.T) {
 				}
 			}
 
-			if len(tt.subjectTemplates) > 0 {
-				if err := os.Mkdir(filepath.Join(dir, "subjects"), 0o755); err != nil {
-					t.Fatalf("failed to create subjects dir: %v", err)
+			if tt.htmlBaseTemplate != "" {
+				if err := os.WriteFile(filepath.Join(dir, "base.html"), []byte(tt.htmlBaseTemplate), 0o644); err != nil {
+					t.Fatalf("failed to create base.html: %v", err)
 				}
+			}
+
+			if len(tt.subjectTemplates) > 0 {
 
 				for page, template := range tt.subjectTemplates {
-					if err := os.WriteFile(filepath.Join(dir, "subjects", page), []byte(template), 0o644); err != nil {
+					path := filepath.Join(dir, "subjects", page)
+					if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+						t.Fatalf("failed to create directory for subject %q: %v", page, err)
+					}
+
+					if err := os.WriteFile(path, []byte(template), 0o644); err != nil {
 						t.Fatalf("failed to write subject %q: %v", page, err)
 					}
 				}
@@ -0,0 +1,158 @@
+package templating
+
+import (
+	"fmt"
+	"html/template"
+	"time"
+
+	"github.com/cdriehuys/secret-santa/internal/i18n"
+	"golang.org/x/text/currency"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+// DefaultFuncs returns the helper functions available to UI templates. Callers may add their own
+// functions to the returned map before passing it to NewTemplateCache or LiveLoader.
+//
+// Helpers that depend on the reader's language take the locale as their first argument, such as
+// {{ date .Locale .CreatedAt }}. Text is translated with T, as in {{ T .Locale "Log In" }}.
+func DefaultFuncs() template.FuncMap {
+	return template.FuncMap{
+		"T":         translate,
+		"currency":  formatCurrency,
+		"date":      formatDate,
+		"datetime":  formatDateTime,
+		"number":    formatNumber,
+		"pluralize": pluralize,
+	}
+}
+
+// localeFormat describes how a locale writes dates and amounts of money.
+type localeFormat struct {
+	months [12]string
+
+	// date is a format string that receives the day, month name, and year, in that order.
+	date string
+
+	// symbolFirst is true if currency symbols are written before the amount, as in "$5.00", rather
+	// than after it, as in "5,00 €".
+	symbolFirst bool
+}
+
+var defaultLocaleFormat = localeFormat{
+	months: [12]string{
+		"January", "February", "March", "April", "May", "June",
+		"July", "August", "September", "October", "November", "December",
+	},
+	date:        "%[2]s %[1]d, %[3]d",
+	symbolFirst: true,
+}
+
+var localeFormats = map[language.Base]localeFormat{
+	language.MustParseBase("en"): defaultLocaleFormat,
+	language.MustParseBase("de"): {
+		months: [12]string{
+			"Januar", "Februar", "März", "April", "Mai", "Juni",
+			"Juli", "August", "September", "Oktober", "November", "Dezember",
+		},
+		date: "%[1]d. %[2]s %[3]d",
+	},
+	language.MustParseBase("es"): {
+		months: [12]string{
+			"enero", "febrero", "marzo", "abril", "mayo", "junio",
+			"julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre",
+		},
+		date: "%[1]d de %[2]s de %[3]d",
+	},
+}
+
+// formatFor returns the format of the locale's language, or the English format for languages
+// without one.
+func formatFor(tag language.Tag) localeFormat {
+	base, _ := tag.Base()
+	if format, ok := localeFormats[base]; ok {
+		return format
+	}
+
+	return defaultLocaleFormat
+}
+
+// formatDate formats a time as a date in the given locale, like "December 25, 2025" in English or
+// "25. Dezember 2025" in German. The zero time formats as an empty string so templates don't need to
+// check for missing dates.
+func formatDate(locale string, t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	format := formatFor(language.Make(locale))
+
+	return fmt.Sprintf(format.date, t.Day(), format.months[t.Month()-1], t.Year())
+}
+
+// formatDateTime formats a time with second precision in a format that is unambiguous regardless of
+// the reader's locale.
+func formatDateTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Format("2006-01-02 15:04:05")
+}
+
+// formatNumber formats a number with the locale's digit grouping and decimal separator, like
+// "1,234.5" in English or "1.234,5" in German.
+func formatNumber(locale string, n any) string {
+	return message.NewPrinter(language.Make(locale)).Sprint(number.Decimal(n))
+}
+
+// formatCurrency formats an amount in the currency with the given ISO 4217 code, like "$1,234.50"
+// in English or "1.234,50 €" in German. Amounts are rounded to the currency's usual number of
+// decimal places.
+func formatCurrency(locale string, code string, amount any) (string, error) {
+	unit, err := currency.ParseISO(code)
+	if err != nil {
+		return "", fmt.Errorf("unknown currency %q: %v", code, err)
+	}
+
+	tag := language.Make(locale)
+	printer := message.NewPrinter(tag)
+
+	scale, _ := currency.Standard.Rounding(unit)
+	value := printer.Sprint(number.Decimal(amount, number.Scale(scale)))
+	symbol := printer.Sprint(currency.Symbol(unit))
+
+	if formatFor(tag).symbolFirst {
+		return symbol + value, nil
+	}
+
+	// A non-breaking space keeps the symbol on the same line as the amount.
+	return value + "\u00a0" + symbol, nil
+}
+
+// pluralize returns the singular form of a word when count is one, and the plural form otherwise.
+func pluralize(count int, singular string, plural string) string {
+	if count == 1 {
+		return singular
+	}
+
+	return plural
+}
+
+// translate returns the locale's translation of text. Text is either an English format, which is
+// filled in from the arguments, or a message from the application, such as an error, which carries
+// its own arguments. A nil message translates to an empty string so missing errors can be passed
+// straight to a partial.
+func translate(locale string, text any, args ...any) (string, error) {
+	switch text := text.(type) {
+	case string:
+		return i18n.Sprintf(locale, text, args...), nil
+	case i18n.Translatable:
+		return text.Translate(locale), nil
+	case nil:
+		return "", nil
+	default:
+		return "", fmt.Errorf("cannot translate %T", text)
+	}
+}
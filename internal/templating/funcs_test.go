@@ -0,0 +1,180 @@
+package templating_test
+
+import (
+	"bytes"
+	"html/template"
+	"testing"
+	"time"
+
+	"github.com/cdriehuys/secret-santa/internal/i18n"
+	"github.com/cdriehuys/secret-santa/internal/templating"
+)
+
+func TestDefaultFuncs(t *testing.T) {
+	christmas := time.Date(2025, time.December, 25, 8, 30, 15, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		template string
+		data     any
+		want     string
+	}{
+		{
+			name:     "date",
+			template: `{{ date "en" . }}`,
+			data:     christmas,
+			want:     "December 25, 2025",
+		},
+		{
+			name:     "date german",
+			template: `{{ date "de" . }}`,
+			data:     christmas,
+			want:     "25. Dezember 2025",
+		},
+		{
+			name:     "date spanish",
+			template: `{{ date "es" . }}`,
+			data:     christmas,
+			want:     "25 de diciembre de 2025",
+		},
+		{
+			name:     "date regional variant",
+			template: `{{ date "de-AT" . }}`,
+			data:     christmas,
+			want:     "25. Dezember 2025",
+		},
+		{
+			name:     "date unknown locale",
+			template: `{{ date "fr" . }}`,
+			data:     christmas,
+			want:     "December 25, 2025",
+		},
+		{
+			name:     "zero date",
+			template: `{{ date "en" . }}`,
+			data:     time.Time{},
+			want:     "",
+		},
+		{
+			name:     "datetime",
+			template: `{{ datetime . }}`,
+			data:     christmas,
+			want:     "2025-12-25 08:30:15",
+		},
+		{
+			name:     "zero datetime",
+			template: `{{ datetime . }}`,
+			data:     time.Time{},
+			want:     "",
+		},
+		{
+			name:     "number",
+			template: `{{ number "en" . }}`,
+			data:     1234567.5,
+			want:     "1,234,567.5",
+		},
+		{
+			name:     "number german",
+			template: `{{ number "de" . }}`,
+			data:     1234567.5,
+			want:     "1.234.567,5",
+		},
+		{
+			name:     "currency",
+			template: `{{ currency "en" "USD" . }}`,
+			data:     1234.5,
+			want:     "$1,234.50",
+		},
+		{
+			name:     "currency german",
+			template: `{{ currency "de" "EUR" . }}`,
+			data:     1234.5,
+			want:     "1.234,50\u00a0€",
+		},
+		{
+			name:     "currency without decimals",
+			template: `{{ currency "en" "JPY" . }}`,
+			data:     1500,
+			want:     "¥1,500",
+		},
+		{
+			name:     "translate",
+			template: `{{ T "es" "Log In" }}`,
+			want:     "Iniciar sesión",
+		},
+		{
+			name:     "translate with arguments",
+			template: `{{ T "de" "You are logged in as %s." . }}`,
+			data:     "santa@example.com",
+			want:     "Du bist als santa@example.com angemeldet.",
+		},
+		{
+			name:     "translate untranslated text",
+			template: `{{ T "de" "Not translated" }}`,
+			want:     "Not translated",
+		},
+		{
+			name:     "translate message",
+			template: `{{ T "de" . }}`,
+			data:     i18n.NewMessage("There can be at most %d exclusions.", 3),
+			want:     "Es sind höchstens 3 Ausschlüsse möglich.",
+		},
+		{
+			name:     "translate nil message",
+			template: `{{ T "de" . }}`,
+			want:     "",
+		},
+		{
+			name:     "translate plural",
+			template: `{{ T "de" (pluralize . "%d participant found." "%d participants found.") . }}`,
+			data:     2,
+			want:     "2 Personen gefunden.",
+		},
+		{
+			name:     "pluralize none",
+			template: `{{ pluralize . "gift" "gifts" }}`,
+			data:     0,
+			want:     "gifts",
+		},
+		{
+			name:     "pluralize one",
+			template: `{{ pluralize . "gift" "gifts" }}`,
+			data:     1,
+			want:     "gift",
+		},
+		{
+			name:     "pluralize many",
+			template: `{{ pluralize . "gift" "gifts" }}`,
+			data:     3,
+			want:     "gifts",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := template.New("test").Funcs(templating.DefaultFuncs()).Parse(tt.template)
+			if err != nil {
+				t.Fatalf("failed to parse template: %v", err)
+			}
+
+			var buffer bytes.Buffer
+			if err := tmpl.Execute(&buffer, tt.data); err != nil {
+				t.Fatalf("failed to execute template: %v", err)
+			}
+
+			if got := buffer.String(); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDefaultFuncsUnknownCurrency(t *testing.T) {
+	tmpl, err := template.New("test").Funcs(templating.DefaultFuncs()).Parse(`{{ currency "en" "XYZ1" 5 }}`)
+	if err != nil {
+		t.Fatalf("failed to parse template: %v", err)
+	}
+
+	if err := tmpl.Execute(&bytes.Buffer{}, nil); err == nil {
+		t.Error("Expected an error for an unknown currency, got nil")
+	}
+}
@@ -0,0 +1,134 @@
+// Package templating loads and renders the page and email templates. Pages are shared by every
+// locale and translate their text with the T function. Emails, whose text is mostly prose, may be
+// translated by adding variants of their subjects to a subdirectory named after the locale. For
+// example, "subjects/de/new-registration.txt" is the German version of
+// "subjects/new-registration.txt", and rendering "de/new-registration.txt" falls back to the
+// default (English) file if there is no German one. Email base layouts are shared like pages.
+package templating
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+)
+
+// splitLocale splits a template name like "de/home.html" into its locale and the name of the
+// template within the locale. Names without a locale return an empty locale.
+func splitLocale(name string) (string, string) {
+	if locale, rest, ok := strings.Cut(name, "/"); ok {
+		return locale, rest
+	}
+
+	return "", name
+}
+
+// localizedPath returns the path of the locale's variant of the named file in dir if it exists, or
+// the path of the default file otherwise.
+func localizedPath(files fs.FS, dir string, locale string, name string) string {
+	if locale != "" {
+		variant := path.Join(dir, locale, name)
+		if _, err := fs.Stat(files, variant); err == nil {
+			return variant
+		}
+	}
+
+	return path.Join(dir, name)
+}
+
+// untranslatedNames returns the localized names in dir that don't have their own file.
+func untranslatedNames(files fs.FS, dir string, names []string) []string {
+	var untranslated []string
+	for _, name := range names {
+		locale, base := splitLocale(name)
+		if locale != "" && localizedPath(files, dir, locale, base) == path.Join(dir, base) {
+			untranslated = append(untranslated, name)
+		}
+	}
+
+	return untranslated
+}
+
+// localeDirs returns the names of the locale subdirectories in any of the given directories.
+// Directories that don't exist are skipped.
+func localeDirs(files fs.FS, dirs ...string) ([]string, error) {
+	var locales []string
+	for _, dir := range dirs {
+		entries, err := fs.ReadDir(files, dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() && !slices.Contains(locales, entry.Name()) {
+				locales = append(locales, entry.Name())
+			}
+		}
+	}
+
+	slices.Sort(locales)
+
+	return locales, nil
+}
+
+// templateNames lists the templates in dir with one of the given extensions. Each locale
+// contributes a localized name for every default template, even those it doesn't translate, so
+// every template can be rendered in every locale.
+func templateNames(files fs.FS, dir string, locales []string, exts ...string) ([]string, error) {
+	var defaults []string
+	names := make(map[string]struct{})
+
+	visit := func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() || !slices.Contains(exts, path.Ext(p)) {
+			return nil
+		}
+
+		name, err := relativePath(dir, p)
+		if err != nil {
+			return err
+		}
+
+		names[name] = struct{}{}
+		if !strings.Contains(name, "/") {
+			defaults = append(defaults, name)
+		}
+
+		return nil
+	}
+
+	if err := fs.WalkDir(files, dir, visit); err != nil {
+		return nil, err
+	}
+
+	for _, locale := range locales {
+		for _, name := range defaults {
+			names[path.Join(locale, name)] = struct{}{}
+		}
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+
+	slices.Sort(sorted)
+
+	return sorted, nil
+}
+
+func relativePath(dir string, p string) (string, error) {
+	name, ok := strings.CutPrefix(p, dir+"/")
+	if !ok {
+		return "", fmt.Errorf("path %q is not inside %q", p, dir)
+	}
+
+	return name, nil
+}
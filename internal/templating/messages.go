@@ -0,0 +1,98 @@
+package templating
+
+import (
+	"maps"
+	"slices"
+	"text/template/parse"
+)
+
+// translateFunc is the name templates use to translate text.
+const translateFunc = "T"
+
+// pluralizeFunc is the name of the function that chooses between the forms of translated text.
+const pluralizeFunc = "pluralize"
+
+// templateMessages returns the text that the templates translate with T, sorted and without
+// duplicates, so it can be checked against the translations. Text chosen with pluralize, as in
+// {{ T .Locale (pluralize .Count "%d gift" "%d gifts") .Count }}, includes both forms. Other
+// text, such as messages from the application, can't be known from the templates.
+func templateMessages(trees []*parse.Tree) []string {
+	messages := make(map[string]struct{})
+	for _, tree := range trees {
+		if tree != nil && tree.Root != nil {
+			collectMessages(tree.Root, messages)
+		}
+	}
+
+	return slices.Sorted(maps.Keys(messages))
+}
+
+func collectMessages(node parse.Node, messages map[string]struct{}) {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return
+		}
+
+		for _, child := range node.Nodes {
+			collectMessages(child, messages)
+		}
+	case *parse.ActionNode:
+		collectPipeMessages(node.Pipe, messages)
+	case *parse.TemplateNode:
+		collectPipeMessages(node.Pipe, messages)
+	case *parse.IfNode:
+		collectBranchMessages(&node.BranchNode, messages)
+	case *parse.RangeNode:
+		collectBranchMessages(&node.BranchNode, messages)
+	case *parse.WithNode:
+		collectBranchMessages(&node.BranchNode, messages)
+	}
+}
+
+func collectBranchMessages(branch *parse.BranchNode, messages map[string]struct{}) {
+	collectPipeMessages(branch.Pipe, messages)
+	collectMessages(branch.List, messages)
+	collectMessages(branch.ElseList, messages)
+}
+
+func collectPipeMessages(pipe *parse.PipeNode, messages map[string]struct{}) {
+	if pipe == nil {
+		return
+	}
+
+	for _, cmd := range pipe.Cmds {
+		if len(cmd.Args) >= 3 {
+			if ident, ok := cmd.Args[0].(*parse.IdentifierNode); ok && ident.Ident == translateFunc {
+				collectLiterals(cmd.Args[2], messages)
+			}
+		}
+
+		for _, arg := range cmd.Args {
+			if arg, ok := arg.(*parse.PipeNode); ok {
+				collectPipeMessages(arg, messages)
+			}
+		}
+	}
+}
+
+// collectLiterals adds the text given to T if it's a string literal, or the forms given to
+// pluralize.
+func collectLiterals(node parse.Node, messages map[string]struct{}) {
+	switch node := node.(type) {
+	case *parse.StringNode:
+		messages[node.Text] = struct{}{}
+	case *parse.PipeNode:
+		for _, cmd := range node.Cmds {
+			if ident, ok := cmd.Args[0].(*parse.IdentifierNode); !ok || ident.Ident != pluralizeFunc {
+				continue
+			}
+
+			for _, arg := range cmd.Args[1:] {
+				if arg, ok := arg.(*parse.StringNode); ok {
+					messages[arg.Text] = struct{}{}
+				}
+			}
+		}
+	}
+}
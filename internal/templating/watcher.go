@@ -0,0 +1,204 @@
+package templating
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultPollInterval is how often a Watcher checks for changed templates if no interval is given.
+const DefaultPollInterval = 500 * time.Millisecond
+
+// fileState is what a Watcher compares to decide whether a file changed.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// Watcher renders pages from a template cache that is rebuilt whenever the files under its
+// directory change. It polls the file system rather than relying on OS notifications so it works
+// anywhere, which makes it suitable for development but not for production.
+type Watcher struct {
+	logger *slog.Logger
+	files  fs.FS
+	funcs  template.FuncMap
+
+	// PollInterval is how often Run checks for changes.
+	PollInterval time.Duration
+
+	mu       sync.RWMutex
+	cache    *TemplateCache
+	buildErr error
+	snapshot map[string]fileState
+
+	subscribersMu sync.Mutex
+	subscribers   map[chan struct{}]struct{}
+}
+
+// NewWatcher builds the initial template cache from the files in dir. It returns an error if the
+// templates can't be parsed, since there would be nothing to render.
+func NewWatcher(logger *slog.Logger, dir string, funcs template.FuncMap) (*Watcher, error) {
+	w := &Watcher{
+		logger:       logger,
+		files:        os.DirFS(dir),
+		funcs:        funcs,
+		PollInterval: DefaultPollInterval,
+		subscribers:  make(map[chan struct{}]struct{}),
+	}
+
+	snapshot, err := w.scan()
+	if err != nil {
+		return nil, fmt.Errorf("scanning templates: %v", err)
+	}
+
+	cache, err := NewTemplateCache(logger, w.files, funcs)
+	if err != nil {
+		return nil, err
+	}
+
+	w.cache = cache
+	w.snapshot = snapshot
+
+	return w, nil
+}
+
+// Render executes the named page from the most recent successful build. If the latest change
+// broke the templates, the build error is returned instead so it is obvious what went wrong.
+func (w *Watcher) Render(wr io.Writer, page string, data any) error {
+	w.mu.RLock()
+	cache, buildErr := w.cache, w.buildErr
+	w.mu.RUnlock()
+
+	if buildErr != nil {
+		return fmt.Errorf("rebuilding templates: %v", buildErr)
+	}
+
+	return cache.Render(wr, page, data)
+}
+
+// Err returns the error from the latest rebuild, or nil if the templates built successfully.
+func (w *Watcher) Err() error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.buildErr
+}
+
+// Run checks for changes every poll interval until the context is canceled.
+func (w *Watcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if _, err := w.Check(); err != nil {
+				w.logger.Error("Failed to reload templates.", "error", err)
+			}
+		}
+	}
+}
+
+// Check rebuilds the templates if any file was added, removed, or modified since the last check,
+// and notifies subscribers. It reports whether anything changed. Build errors are returned and
+// also kept so that Render reports them until the templates are fixed.
+func (w *Watcher) Check() (bool, error) {
+	snapshot, err := w.scan()
+	if err != nil {
+		return false, fmt.Errorf("scanning templates: %v", err)
+	}
+
+	w.mu.Lock()
+	changed := !maps.Equal(snapshot, w.snapshot)
+	if changed {
+		w.snapshot = snapshot
+
+		var cache *TemplateCache
+		if cache, err = NewTemplateCache(w.logger, w.files, w.funcs); err == nil {
+			w.cache = cache
+		}
+
+		w.buildErr = err
+	}
+	w.mu.Unlock()
+
+	if !changed {
+		return false, nil
+	}
+
+	w.logger.Info("Reloaded templates.")
+	w.notify()
+
+	return true, err
+}
+
+// Subscribe returns a channel that receives a value whenever the templates are reloaded, and a
+// function that must be called to stop receiving them.
+func (w *Watcher) Subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	w.subscribersMu.Lock()
+	w.subscribers[ch] = struct{}{}
+	w.subscribersMu.Unlock()
+
+	unsubscribe := func() {
+		w.subscribersMu.Lock()
+		delete(w.subscribers, ch)
+		w.subscribersMu.Unlock()
+	}
+
+	return ch, unsubscribe
+}
+
+func (w *Watcher) notify() {
+	w.subscribersMu.Lock()
+	defer w.subscribersMu.Unlock()
+
+	for ch := range w.subscribers {
+		// Subscribers only need to know that something changed, so a pending notification is as
+		// good as a new one.
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// scan records the state of every file under the watched directory.
+func (w *Watcher) scan() (map[string]fileState, error) {
+	snapshot := make(map[string]fileState)
+
+	visit := func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		snapshot[path] = fileState{modTime: info.ModTime(), size: info.Size()}
+
+		return nil
+	}
+
+	if err := fs.WalkDir(w.files, ".", visit); err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
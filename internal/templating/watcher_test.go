@@ -0,0 +1,119 @@
+package templating_test
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cdriehuys/secret-santa/internal/templating"
+)
+
+func writeTemplate(t *testing.T, dir string, name string, content string) {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("failed to create directory for %q: %v", name, err)
+	}
+
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write %q: %v", name, err)
+	}
+}
+
+func assertRenders(t *testing.T, w *templating.Watcher, page string, want string) {
+	t.Helper()
+
+	var buffer bytes.Buffer
+	if err := w.Render(&buffer, page, nil); err != nil {
+		t.Fatalf("Render(%q) failed: %v", page, err)
+	}
+
+	if got := buffer.String(); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestWatcher_Check(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "base.html", standardBaseTemplate)
+	writeTemplate(t, dir, "pages/hello.html", helloTemplate)
+
+	w, err := templating.NewWatcher(slog.New(slog.DiscardHandler), dir, templating.DefaultFuncs())
+	if err != nil {
+		t.Fatalf("NewWatcher() failed: %v", err)
+	}
+
+	reloads, unsubscribe := w.Subscribe()
+	defer unsubscribe()
+
+	assertRenders(t, w, "hello.html", helloOutput)
+
+	if changed, err := w.Check(); changed || err != nil {
+		t.Fatalf("Expected no changes, got changed=%v, err=%v", changed, err)
+	}
+
+	// Modified page
+	writeTemplate(t, dir, "pages/hello.html", `{{ define "content" }}Hello again, World!{{ end }}`)
+	if changed, err := w.Check(); !changed || err != nil {
+		t.Fatalf("Expected a successful reload, got changed=%v, err=%v", changed, err)
+	}
+
+	assertRenders(t, w, "hello.html", "Hello again, World!")
+
+	select {
+	case <-reloads:
+	default:
+		t.Error("Expected subscriber to be notified of the reload")
+	}
+
+	// New page
+	writeTemplate(t, dir, "pages/goodbye.html", `{{ define "content" }}Goodbye{{ end }}`)
+	if changed, err := w.Check(); !changed || err != nil {
+		t.Fatalf("Expected a successful reload, got changed=%v, err=%v", changed, err)
+	}
+
+	assertRenders(t, w, "goodbye.html", "Goodbye")
+
+	// Broken page
+	writeTemplate(t, dir, "pages/goodbye.html", `{{ define "content" }}Goodbye{{ end`)
+	if changed, err := w.Check(); !changed || err == nil {
+		t.Fatalf("Expected a failed reload, got changed=%v, err=%v", changed, err)
+	}
+
+	var buffer bytes.Buffer
+	if err := w.Render(&buffer, "hello.html", nil); err == nil {
+		t.Error("Expected Render() to report the build error")
+	}
+
+	if w.Err() == nil {
+		t.Error("Expected Err() to report the build error")
+	}
+
+	// Fixed page
+	if err := os.Remove(filepath.Join(dir, "pages", "goodbye.html")); err != nil {
+		t.Fatalf("failed to remove page: %v", err)
+	}
+
+	if changed, err := w.Check(); !changed || err != nil {
+		t.Fatalf("Expected a successful reload, got changed=%v, err=%v", changed, err)
+	}
+
+	assertRenders(t, w, "hello.html", "Hello again, World!")
+
+	if err := w.Err(); err != nil {
+		t.Errorf("Expected Err() to be cleared after a successful reload, got %v", err)
+	}
+}
+
+func TestNewWatcher_invalidTemplates(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "base.html", standardBaseTemplate)
+	writeTemplate(t, dir, "pages/broken.html", `{{ define "content" }}`)
+
+	if _, err := templating.NewWatcher(slog.New(slog.DiscardHandler), dir, nil); err == nil {
+		t.Error("Expected an error for invalid templates")
+	}
+}
@@ -4,115 +4,155 @@ import (
 	"context"
 	"errors"
 	"flag"
-	"io/fs"
+	"fmt"
+	"io"
 	"log/slog"
 	"math/rand"
-	"net/http"
-	"net/url"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/cdriehuys/secret-santa/internal/application"
-	"github.com/cdriehuys/secret-santa/internal/email"
-	"github.com/cdriehuys/secret-santa/internal/models"
-	"github.com/cdriehuys/secret-santa/internal/models/queries"
+	"github.com/cdriehuys/secret-santa/internal/config"
 	"github.com/cdriehuys/secret-santa/internal/pairings"
-	"github.com/cdriehuys/secret-santa/internal/security"
-	"github.com/cdriehuys/secret-santa/internal/templating"
-	"github.com/cdriehuys/secret-santa/ui"
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-var (
-	liveEmailTemplatePath string
-	liveTemplatePath      string
-)
+// command is a subcommand of the secret-santa binary. Commands parse their own flags from args.
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, logger *slog.Logger, args []string) error
+}
 
-func main() {
-	flag.StringVar(&liveEmailTemplatePath, "live-email-templates", "", "load email templates from this path for each request instead of using the embedded templates")
-	flag.StringVar(&liveTemplatePath, "live-templates", "", "load UI templates from this path for each request instead of using the embedded templates")
-	flag.Parse()
+var commands = []command{
+	{name: "serve", summary: "run the web server (the default)", run: runServe},
+	{name: "draw", summary: "draw pairings for the participants in a CSV, JSON, or YAML file", run: runDraw},
+	{name: "migrate", summary: "migrate the database schema", run: runMigrate},
+	{name: "check-templates", summary: "render every template to check for errors", run: runCheckTemplates},
+}
 
-	logger := slog.New(
-		slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
-			Level: slog.LevelInfo,
-		}),
-	)
+// usageError is returned when a command's arguments are invalid. The flag package has already
+// printed the problem and the command's usage by the time it's returned.
+type usageError struct {
+	err error
+}
 
-	pairingGenerator := func(restrictions application.GiftRestrictions) ([]pairings.Pairing, error) {
-		graph := pairings.NewGraphFromExclusions(restrictions)
-		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+func (e usageError) Error() string {
+	return e.err.Error()
+}
 
-		return graph.Pairings(r)
+func main() {
+	// Commands that read the log settings replace this logger with their own.
+	logger := config.Default().Log.NewLogger(os.Stderr)
+
+	// Flags without a command run the server so existing invocations keep working.
+	name, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
 	}
 
-	var emailTemplates application.TemplateEngine
-	if liveEmailTemplatePath != "" {
-		emailTemplates = &templating.LiveEmailLoader{Logger: logger, BaseDir: liveEmailTemplatePath}
-	} else {
-		templateFS, err := fs.Sub(ui.EmailFS, "emails")
-		if err != nil {
-			panic(err)
-		}
+	if name == "help" {
+		usage(os.Stdout)
+		return
+	}
 
-		emailTemplates, err = templating.NewEmailTemplateCache(logger, templateFS)
-		if err != nil {
-			panic(err)
-		}
+	cmd, ok := findCommand(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q.\n\n", name)
+		usage(os.Stderr)
+		os.Exit(2)
 	}
 
-	var uiTemplates application.TemplateEngine
-	if liveTemplatePath != "" {
-		uiTemplates = &templating.LiveLoader{Logger: logger, BaseDir: liveTemplatePath}
-	} else {
-		templateFS, err := fs.Sub(ui.FS, "templates")
-		if err != nil {
-			panic(err)
-		}
+	// Commands stop what they're doing when interrupted. A second signal kills the process.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	context.AfterFunc(ctx, stop)
+
+	err := cmd.run(ctx, logger, args)
+	stop()
+
+	var usageErr usageError
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+	case errors.As(err, &usageErr):
+		os.Exit(2)
+	default:
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
 
-		uiTemplates, err = templating.NewTemplateCache(logger, templateFS)
-		if err != nil {
-			panic(err)
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
 		}
 	}
 
-	emailer := email.NewConsoleMailer(os.Stdout)
-	sender := "no-reply@localhost"
-	baseDomain, err := url.Parse("http://localhost:8080")
-	if err != nil {
-		panic(err)
+	return command{}, false
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: secret-santa [command] [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-16s %s\n", cmd.name, cmd.summary)
 	}
 
-	emailVerifier := application.NewEmailVerifier(logger, emailer, emailTemplates, baseDomain, sender)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'secret-santa <command> -h' for the flags each command accepts.")
+}
 
-	connString := os.Getenv("DB_CONN")
-	dbPool, err := pgxpool.New(context.Background(), connString)
-	if err != nil {
-		panic(err)
+// newFlagSet creates the flag set for a command. Its usage message shows the command's arguments
+// and description before the flags.
+func newFlagSet(name string, arguments string, description string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: secret-santa %s %s\n\n%s\n\nFlags:\n", name, arguments, description)
+		flags.PrintDefaults()
 	}
 
-	defer dbPool.Close()
+	return flags
+}
 
-	queries := queries.New(dbPool)
+// parseFlags parses a command's flags, marking errors as usage errors.
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
 
-	users := models.NewUserModel(logger, emailVerifier, security.Argon2IDHasher{}, security.TokenGenerator{}, models.PoolWrapper{Pool: dbPool}, models.UserQueriesWrapper{Queries: queries})
+		return usageError{err}
+	}
 
-	app := application.Application{
-		Logger:           logger,
-		PairingGenerator: pairingGenerator,
-		Templates:        uiTemplates,
+	return nil
+}
 
-		Users: users,
-	}
+// isFlagSet reports whether a flag was given on the command line, as opposed to left at its
+// default.
+func isFlagSet(flags *flag.FlagSet, name string) bool {
+	set := false
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
 
-	s := http.Server{
-		Addr:    ":8080",
-		Handler: app.Routes(),
+	return set
+}
+
+// generatePairings draws pairings that respect the restrictions. The draw is repeatable if a seed
+// is given. The search is bounded by pairings.DefaultMaxSteps and the context.
+func generatePairings(ctx context.Context, restrictions application.GiftRestrictions, options application.PairingOptions) ([]pairings.Pairing, error) {
+	seed := time.Now().UnixNano()
+	if options.Seed != nil {
+		seed = *options.Seed
 	}
 
-	logger.Info("Starting web server.", "addr", s.Addr)
+	graph := pairings.NewGraphFromExclusions(restrictions)
+	r := rand.New(rand.NewSource(seed))
 
-	if err := s.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-		logger.Error("Server stopped.", "error", err)
-	}
+	return graph.Pairings(ctx, r, pairings.DefaultMaxSteps)
 }
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cdriehuys/secret-santa/internal/config"
+	"github.com/cdriehuys/secret-santa/migrations"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/tern/v2/migrate"
+)
+
+// versionTable is where the schema version is recorded. It matches tern's default so databases
+// migrated with tern directly keep working.
+const versionTable = "public.schema_version"
+
+// runMigrate migrates the database with the embedded migrations. The database connection string is
+// read from DB_CONN, the same as the server.
+func runMigrate(ctx context.Context, logger *slog.Logger, args []string) error {
+	flags := newFlagSet("migrate", "[flags]", "Migrates the database in DB_CONN to the latest version, or to the given destination.")
+
+	var destination string
+	flags.StringVar(&destination, "destination", "last", "version to migrate to: a migration number, a change relative to the current version such as +1 or -1, 0 to revert all migrations, or last")
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	conn, err := pgx.Connect(ctx, os.Getenv(config.DatabaseURLEnv))
+	if err != nil {
+		return fmt.Errorf("connecting to database: %v", err)
+	}
+
+	defer conn.Close(ctx)
+
+	migrator, err := migrate.NewMigrator(ctx, conn, versionTable)
+	if err != nil {
+		return fmt.Errorf("creating migrator: %v", err)
+	}
+
+	if err := migrator.LoadMigrations(migrations.FS); err != nil {
+		return fmt.Errorf("loading migrations: %v", err)
+	}
+
+	current, err := migrator.GetCurrentVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("getting current version: %v", err)
+	}
+
+	target, err := migrationTarget(destination, current, int32(len(migrator.Migrations)))
+	if err != nil {
+		return err
+	}
+
+	migrator.OnStart = func(sequence int32, name string, direction string, _ string) {
+		logger.Info("Running migration.", "sequence", sequence, "name", name, "direction", direction)
+	}
+
+	if err := migrator.MigrateTo(ctx, target); err != nil {
+		return fmt.Errorf("migrating from version %d to %d: %v", current, target, err)
+	}
+
+	logger.Info("Database is migrated.", "version", target)
+
+	return nil
+}
+
+// migrationTarget converts a destination given on the command line into the version to migrate
+// to.
+func migrationTarget(destination string, current int32, last int32) (int32, error) {
+	if destination == "last" {
+		return last, nil
+	}
+
+	n, err := strconv.ParseInt(destination, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid destination %q: expected a migration number, +N, -N, or last", destination)
+	}
+
+	target := int32(n)
+	if strings.HasPrefix(destination, "+") || strings.HasPrefix(destination, "-") {
+		target = current + int32(n)
+	}
+
+	if target < 0 || target > last {
+		return 0, fmt.Errorf("destination %q is outside the available migrations, 0 to %d", destination, last)
+	}
+
+	return target, nil
+}
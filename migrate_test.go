@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestMigrationTarget(t *testing.T) {
+	testCases := []struct {
+		destination string
+		current     int32
+		want        int32
+		wantErr     bool
+	}{
+		{destination: "last", current: 2, want: 5},
+		{destination: "3", current: 5, want: 3},
+		{destination: "0", current: 5, want: 0},
+		{destination: "+1", current: 2, want: 3},
+		{destination: "-2", current: 2, want: 0},
+		{destination: "-3", current: 2, wantErr: true},
+		{destination: "6", current: 2, wantErr: true},
+		{destination: "first", current: 2, wantErr: true},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.destination, func(t *testing.T) {
+			got, err := migrationTarget(tt.destination, tt.current, 5)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error, got target %d", got)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Expected target %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+CREATE TABLE sessions(
+    token TEXT PRIMARY KEY,
+    user_id uuid NOT NULL REFERENCES users(id)
+        ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions(user_id);
+
+---- create above / drop below ----
+
+DROP TABLE sessions;
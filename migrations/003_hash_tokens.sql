@@ -0,0 +1,18 @@
+-- Tokens are only stored as a SHA-256 digest so that read access to the database is not enough to
+-- use them. Existing tokens are hashed in place so outstanding links and sessions keep working.
+ALTER TABLE email_verification_keys RENAME COLUMN token TO token_hash;
+UPDATE email_verification_keys
+SET token_hash = encode(sha256(convert_to(token_hash, 'UTF8')), 'hex');
+
+ALTER TABLE sessions RENAME COLUMN token TO token_hash;
+UPDATE sessions
+SET token_hash = encode(sha256(convert_to(token_hash, 'UTF8')), 'hex');
+
+---- create above / drop below ----
+
+-- Digests can't be turned back into tokens, so any outstanding tokens are discarded.
+DELETE FROM sessions;
+ALTER TABLE sessions RENAME COLUMN token_hash TO token;
+
+DELETE FROM email_verification_keys;
+ALTER TABLE email_verification_keys RENAME COLUMN token_hash TO token;
@@ -0,0 +1,30 @@
+CREATE TABLE user_totp(
+    user_id uuid PRIMARY KEY REFERENCES users(id)
+        ON DELETE CASCADE,
+    secret TEXT NOT NULL,
+    -- NULL while the user is still enrolling.
+    confirmed_at TIMESTAMPTZ,
+    -- The most recent time step a code was accepted for, used to prevent replaying codes.
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE totp_recovery_codes(
+    id INT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    user_id uuid NOT NULL REFERENCES users(id)
+        ON DELETE CASCADE,
+    code_hash TEXT NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (user_id, code_hash)
+);
+
+-- Sessions for users who have entered their password but not yet their second factor.
+ALTER TABLE sessions ADD COLUMN two_factor_pending BOOLEAN NOT NULL DEFAULT FALSE;
+
+---- create above / drop below ----
+
+ALTER TABLE sessions DROP COLUMN two_factor_pending;
+
+DROP TABLE totp_recovery_codes;
+DROP TABLE user_totp;
@@ -0,0 +1,26 @@
+-- Emails waiting to be delivered. Rows are written in the same transaction as the change that
+-- triggers the email so a message is only sent if that change is committed.
+CREATE TABLE email_outbox(
+    -- Also used as the Message-ID so a message that is delivered twice can be deduplicated.
+    id uuid PRIMARY KEY,
+    recipient TEXT NOT NULL,
+    sender TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    text_body TEXT NOT NULL,
+    html_body TEXT NOT NULL DEFAULT '',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_error TEXT NOT NULL DEFAULT '',
+    sent_at TIMESTAMPTZ,
+    -- Set once delivery has failed too many times to keep retrying.
+    dead_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX email_outbox_pending_idx ON email_outbox(next_attempt_at)
+    WHERE sent_at IS NULL AND dead_at IS NULL;
+
+---- create above / drop below ----
+
+DROP INDEX email_outbox_pending_idx;
+DROP TABLE email_outbox;
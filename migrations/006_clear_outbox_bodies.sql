@@ -0,0 +1,10 @@
+-- Delivered and dead emails no longer keep their bodies, which can contain verification links.
+-- Clear the bodies of the emails finished before that change.
+UPDATE email_outbox
+SET text_body = '',
+    html_body = ''
+WHERE sent_at IS NOT NULL OR dead_at IS NOT NULL;
+
+---- create above / drop below ----
+
+-- The cleared bodies can't be restored.
@@ -0,0 +1,37 @@
+-- Emails are tied to the account they were sent for so they can be deleted along with it. Deleting
+-- a user doesn't cascade to the outbox; UserModel.Delete removes the emails first in the same
+-- transaction, and the foreign key stops a user from being deleted while any are left.
+ALTER TABLE email_outbox ADD COLUMN user_id uuid REFERENCES users(id);
+
+-- Existing emails belong to the user with the address, preferring the verified owner of an address
+-- that was also used to register again, or else the user who asked to change to it.
+UPDATE email_outbox
+SET user_id = (
+    SELECT users.id FROM users
+    WHERE users.email = email_outbox.recipient
+    ORDER BY users.email_verified DESC, users.created_at
+    LIMIT 1
+)
+WHERE user_id IS NULL;
+
+UPDATE email_outbox
+SET user_id = (
+    SELECT email_verification_keys.user_id FROM email_verification_keys
+    WHERE email_verification_keys.email = email_outbox.recipient
+    ORDER BY email_verification_keys.created_at DESC
+    LIMIT 1
+)
+WHERE user_id IS NULL;
+
+-- The rest were sent for accounts that have already been deleted.
+DELETE FROM email_outbox
+WHERE user_id IS NULL;
+
+ALTER TABLE email_outbox ALTER COLUMN user_id SET NOT NULL;
+
+CREATE INDEX email_outbox_user_id_idx ON email_outbox(user_id);
+
+---- create above / drop below ----
+
+DROP INDEX email_outbox_user_id_idx;
+ALTER TABLE email_outbox DROP COLUMN user_id;
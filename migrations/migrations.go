@@ -0,0 +1,10 @@
+// Package migrations embeds the SQL migrations so the binary can migrate the database without the
+// source tree.
+package migrations
+
+import "embed"
+
+// FS contains the migrations in the layout expected by tern.
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/alexedwards/argon2id"
+	"github.com/cdriehuys/secret-santa/internal/application"
+	"github.com/cdriehuys/secret-santa/internal/config"
+	"github.com/cdriehuys/secret-santa/internal/models"
+	"github.com/cdriehuys/secret-santa/internal/models/queries"
+	"github.com/cdriehuys/secret-santa/internal/ratelimit"
+	"github.com/cdriehuys/secret-santa/internal/roster"
+	"github.com/cdriehuys/secret-santa/internal/security"
+	"github.com/cdriehuys/secret-santa/internal/supervisor"
+	"github.com/cdriehuys/secret-santa/internal/templating"
+	"github.com/cdriehuys/secret-santa/ui"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// runServe runs the web server.
+func runServe(ctx context.Context, logger *slog.Logger, args []string) error {
+	flags := newFlagSet("serve", "[flags]", "Runs the web server. Every flag can also be set by an environment variable named after it, such as\nSECRET_SANTA_ADDR for -addr, or in the YAML file given to -config. The database connection string is\nread from DB_CONN and the SMTP password from SMTP_PASSWORD.")
+
+	var cfg config.Config
+	loader := config.NewLoader(&cfg, flags)
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if err := loader.Load(os.LookupEnv); err != nil {
+		return fmt.Errorf("invalid configuration:\n%v", err)
+	}
+
+	logger = cfg.Log.NewLogger(os.Stderr)
+
+	emails, err := emailTemplates(logger, cfg.Templates.LiveEmail)
+	if err != nil {
+		return err
+	}
+
+	var uiTemplates application.TemplateEngine
+	var templateReloads application.TemplateReloads
+	var watcher *templating.Watcher
+	if cfg.Templates.Live != "" {
+		watcher, err = templating.NewWatcher(logger, cfg.Templates.Live, templating.DefaultFuncs())
+		if err != nil {
+			return fmt.Errorf("watching templates: %v", err)
+		}
+
+		uiTemplates = watcher
+		if cfg.Templates.LiveReload {
+			templateReloads = watcher
+		}
+	} else {
+		if cfg.Templates.LiveReload {
+			logger.Warn("Live reload requires -live-templates and is disabled.")
+		}
+
+		templateFS, err := fs.Sub(ui.FS, "templates")
+		if err != nil {
+			return err
+		}
+
+		uiTemplates, err = templating.NewTemplateCache(logger, templateFS, templating.DefaultFuncs())
+		if err != nil {
+			return fmt.Errorf("loading templates: %v", err)
+		}
+	}
+
+	emailer, maildir, err := newMailer(logger, cfg.Email)
+	if err != nil {
+		return err
+	}
+
+	// Captured emails include verification links, so they can only be browsed in dev mode, which
+	// the configuration only allows on localhost.
+	var mailbox application.Mailbox
+	if maildir != nil && cfg.Dev {
+		logger.Warn("Development mode is on. Emails saved to the Maildir can be viewed at /dev/mail from this machine.", "path", cfg.Email.Maildir)
+		mailbox = maildir
+	} else if maildir != nil {
+		logger.Info("Saving emails to a Maildir. Run with -dev to view them at /dev/mail.", "path", cfg.Email.Maildir)
+	}
+
+	baseURL, err := url.Parse(cfg.BaseURL)
+	if err != nil {
+		return err
+	}
+
+	if baseURL.Scheme == "https" && !cfg.Cookies.Secure {
+		logger.Warn("The site is served over HTTPS but cookies aren't limited to HTTPS. Set -secure-cookies to fix this.")
+	}
+
+	emailVerifier := application.NewEmailVerifier(logger, emails, baseURL, cfg.Email.Sender)
+
+	totpKey, err := cfg.TwoFactor.DecodeKey()
+	if err != nil {
+		return err
+	}
+
+	totpSecrets, err := security.NewSecretBox(totpKey)
+	if err != nil {
+		return fmt.Errorf("creating TOTP secret box: %v", err)
+	}
+
+	dbPool, err := pgxpool.New(ctx, cfg.Database.URL)
+	if err != nil {
+		return fmt.Errorf("connecting to database: %v", err)
+	}
+
+	// Nothing below can fail before the server starts, so everything the supervisor starts is
+	// stopped by the ordered shutdown at the end.
+	workers := supervisor.New(logger)
+	workers.OnStop("database pool", dbPool.Close)
+
+	if watcher != nil {
+		workers.Go("template watcher", watcher.Run)
+	}
+
+	queries := queries.New(dbPool)
+
+	hasher := security.NewArgon2IDHasher(argon2id.Params{
+		Memory:      uint32(cfg.Argon2.Memory),
+		Iterations:  uint32(cfg.Argon2.Iterations),
+		Parallelism: uint8(cfg.Argon2.Parallelism),
+		SaltLength:  argon2id.DefaultParams.SaltLength,
+		KeyLength:   argon2id.DefaultParams.KeyLength,
+	})
+
+	users := models.NewUserModel(logger, emailVerifier, hasher, security.TokenGenerator{}, models.PoolWrapper{Pool: dbPool}, models.UserQueriesWrapper{Queries: queries})
+	sessions := models.NewSessionModel(logger, security.TokenGenerator{}, queries)
+	twoFactor := models.NewTwoFactorModel(logger, security.TOTP{Issuer: "Secret Santa", Skew: 1}, totpSecrets, models.PoolWrapper{Pool: dbPool}, models.TwoFactorQueriesWrapper{Queries: queries})
+
+	outbox := models.NewOutboxWorker(logger, emailer, queries)
+	workers.Go("email outbox", outbox.Run)
+
+	readinessChecks := []application.ReadinessCheck{
+		{Name: "database", Check: dbPool.Ping},
+	}
+
+	// Emails wait in the outbox while the mailer is unavailable, so it's only checked if asked.
+	if mailer, ok := emailer.(interface{ Ping(context.Context) error }); ok && cfg.Email.ReadinessCheck {
+		readinessChecks = append(readinessChecks, application.ReadinessCheck{Name: "mailer", Check: mailer.Ping})
+	}
+
+	shuttingDown := make(chan struct{})
+
+	app := application.Application{
+		Logger:           logger,
+		PairingGenerator: generatePairings,
+		PairingLimits: roster.Limits{
+			MaxParticipants: cfg.Limits.MaxParticipants,
+			MaxExclusions:   cfg.Limits.MaxExclusions,
+		},
+		Templates: uiTemplates,
+
+		Sessions:  sessions,
+		TwoFactor: twoFactor,
+		Users:     users,
+
+		RateLimiter: ratelimit.NewMemoryStore(),
+		RateLimits:  application.DefaultRateLimits,
+
+		Mailbox:         mailbox,
+		TemplateReloads: templateReloads,
+		TrustedProxies:  cfg.TrustedProxies,
+		SecureCookies:   cfg.Cookies.Secure,
+		ReadinessChecks: readinessChecks,
+		ShuttingDown:    shuttingDown,
+	}
+
+	s := http.Server{
+		Addr:         cfg.Addr,
+		Handler:      app.Routes(),
+		ReadTimeout:  cfg.Timeouts.Read,
+		WriteTimeout: cfg.Timeouts.Write,
+		IdleTimeout:  cfg.Timeouts.Idle,
+	}
+
+	logger.Info("Starting web server.", "addr", s.Addr)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- s.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		// The server never started, such as when the address is in use, so only the workers need
+		// to be stopped.
+		stopCtx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Shutdown)
+		defer cancel()
+
+		return errors.Join(fmt.Errorf("running server: %v", err), workers.Stop(stopCtx))
+	case <-ctx.Done():
+	}
+
+	logger.Info("Shutting down.", "drain", cfg.Timeouts.Drain, "timeout", cfg.Timeouts.Shutdown)
+
+	// The shutdown timeout starts once the drain period is over.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Drain+cfg.Timeouts.Shutdown)
+	defer cancel()
+
+	var errs []error
+	if err := stopServer(shutdownCtx, &s, shuttingDown, cfg.Timeouts.Drain); err != nil {
+		errs = append(errs, err)
+	}
+
+	// Background workers are stopped after the server has finished its in-flight requests, which
+	// may still need them.
+	if err := workers.Stop(shutdownCtx); err != nil {
+		errs = append(errs, fmt.Errorf("stopping background workers: %v", err))
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	logger.Info("Server stopped.")
+
+	return nil
+}
+
+// stopServer stops the server gracefully. It first closes shuttingDown, which fails the readiness
+// probe, and keeps serving for the drain period so load balancers can stop sending requests before
+// the listeners close. Then it waits for in-flight requests to finish, closing their connections if
+// they don't finish before the context ends.
+func stopServer(ctx context.Context, s *http.Server, shuttingDown chan<- struct{}, drain time.Duration) error {
+	close(shuttingDown)
+
+	timer := time.NewTimer(drain)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	if err := s.Shutdown(ctx); err != nil {
+		s.Close()
+		return fmt.Errorf("waiting for requests to finish: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cdriehuys/secret-santa/internal/application/testutils"
+)
+
+func TestStopServer(t *testing.T) {
+	shuttingDown := make(chan struct{})
+
+	app := testutils.NewTestApplication(t)
+	app.ShuttingDown = shuttingDown
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	s := http.Server{Handler: app.Routes()}
+	go s.Serve(listener)
+
+	client := http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	readyz := func() (int, error) {
+		res, err := client.Get("http://" + listener.Addr().String() + "/readyz")
+		if err != nil {
+			return 0, err
+		}
+
+		res.Body.Close()
+
+		return res.StatusCode, nil
+	}
+
+	if status, err := readyz(); err != nil || status != http.StatusOK {
+		t.Fatalf("Expected the server to be ready, got status %d and error %v", status, err)
+	}
+
+	const drain = 500 * time.Millisecond
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- stopServer(context.Background(), &s, shuttingDown, drain)
+	}()
+
+	// The server keeps answering while it drains, but reports that it isn't ready.
+	<-shuttingDown
+
+	if status, err := readyz(); err != nil || status != http.StatusServiceUnavailable {
+		t.Errorf("Expected the server to fail readiness while draining, got status %d and error %v", status, err)
+	}
+
+	select {
+	case <-stopped:
+		t.Fatal("Expected the server to keep serving for the drain period")
+	default:
+	}
+
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the server to stop after the drain period")
+	}
+
+	if _, err := readyz(); err == nil {
+		t.Error("Expected the server to stop accepting connections")
+	}
+}
@@ -7,3 +7,8 @@ var FS embed.FS
 
 //go:embed emails
 var EmailFS embed.FS
+
+// OpenAPI describes the JSON API.
+//
+//go:embed api/openapi.yaml
+var OpenAPI []byte